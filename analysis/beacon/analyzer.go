@@ -5,6 +5,7 @@ import (
 	"sort"
 	"sync"
 
+	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/datatypes/beacon"
 
 	"github.com/activecm/rita/util"
@@ -17,6 +18,7 @@ type (
 		connectionThreshold int                          // the minimum number of connections to be considered a beacon
 		minTime             int64                        // beginning of the observation period
 		maxTime             int64                        // ending of the observation period
+		params              beacon.ScoringParameters     // cutoffs and weights used in scoring
 		analyzedCallback    func(*beacon.AnalysisOutput) // called on each analyzed result
 		closedCallback      func()                       // called when .close() is called and no more calls to analyzedCallback will be made
 		analysisChannel     chan *beacon.AnalysisInput   // holds unanalyzed data
@@ -25,11 +27,12 @@ type (
 )
 
 // newAnalyzer creates a new analyzer for computing beaconing scores.
-func newAnalyzer(minTime, maxTime int64,
+func newAnalyzer(minTime, maxTime int64, params beacon.ScoringParameters,
 	analyzedCallback func(*beacon.AnalysisOutput), closedCallback func()) *analyzer {
 	return &analyzer{
 		minTime:          minTime,
 		maxTime:          maxTime,
+		params:           params,
 		analyzedCallback: analyzedCallback,
 		closedCallback:   closedCallback,
 		analysisChannel:  make(chan *beacon.AnalysisInput),
@@ -62,12 +65,12 @@ func (a *analyzer) start() {
 			//sort the size and timestamps since they may have arrived out of order
 			sort.Sort(util.SortableInt64(data.TsList))
 			sort.Sort(util.SortableInt64(data.OrigIPBytes))
+			sort.Sort(util.SortableInt64(data.RespIPBytes))
 
 			//store the diff slice length since we use it a lot
 			//for timestamps this is one less then the data slice length
 			//since we are calculating the times in between readings
 			tsLength := len(data.TsList) - 1
-			dsLength := len(data.OrigIPBytes)
 
 			//find the duration of this connection
 			//perfect beacons should fill the observation period
@@ -84,7 +87,6 @@ func (a *analyzer) start() {
 			//Bowley's measure of skew is used to check symmetry
 			sort.Sort(util.SortableInt64(diff))
 			tsSkew := float64(0)
-			dsSkew := float64(0)
 
 			//tsLength -1 is used since diff is a zero based slice
 			tsLow := diff[util.Round(.25*float64(tsLength-1))]
@@ -93,23 +95,12 @@ func (a *analyzer) start() {
 			tsBowleyNum := tsLow + tsHigh - 2*tsMid
 			tsBowleyDen := tsHigh - tsLow
 
-			//we do the same for datasizes
-			dsLow := data.OrigIPBytes[util.Round(.25*float64(dsLength-1))]
-			dsMid := data.OrigIPBytes[util.Round(.5*float64(dsLength-1))]
-			dsHigh := data.OrigIPBytes[util.Round(.75*float64(dsLength-1))]
-			dsBowleyNum := dsLow + dsHigh - 2*dsMid
-			dsBowleyDen := dsHigh - dsLow
-
 			//tsSkew should equal zero if the denominator equals zero
 			//bowley skew is unreliable if Q2 = Q1 or Q2 = Q3
 			if tsBowleyDen != 0 && tsMid != tsLow && tsMid != tsHigh {
 				tsSkew = float64(tsBowleyNum) / float64(tsBowleyDen)
 			}
 
-			if dsBowleyDen != 0 && dsMid != dsLow && dsMid != dsHigh {
-				dsSkew = float64(dsBowleyNum) / float64(dsBowleyDen)
-			}
-
 			//perfect beacons should have very low dispersion around the
 			//median of their delta times
 			//Median Absolute Deviation About the Median
@@ -119,20 +110,16 @@ func (a *analyzer) start() {
 				devs[i] = util.Abs(diff[i] - tsMid)
 			}
 
-			dsDevs := make([]int64, dsLength)
-			for i := 0; i < dsLength; i++ {
-				dsDevs[i] = util.Abs(data.OrigIPBytes[i] - dsMid)
-			}
-
 			sort.Sort(util.SortableInt64(devs))
-			sort.Sort(util.SortableInt64(dsDevs))
 
 			tsMadm := devs[util.Round(.5*float64(tsLength-1))]
-			dsMadm := dsDevs[util.Round(.5*float64(dsLength-1))]
+
+			//we do the same for datasizes
+			dsSkew, dsMadm := sizeSkewAndDispersion(data.OrigIPBytes)
 
 			//Store the range for human analysis
 			tsIntervalRange := diff[tsLength-1] - diff[0]
-			dsRange := data.OrigIPBytes[dsLength-1] - data.OrigIPBytes[0]
+			dsRange := data.OrigIPBytes[len(data.OrigIPBytes)-1] - data.OrigIPBytes[0]
 
 			//get a list of the intervals found in the data,
 			//the number of times the interval was found,
@@ -145,63 +132,161 @@ func (a *analyzer) start() {
 			tsSkewScore := 1.0 - math.Abs(tsSkew) //smush tsSkew
 			dsSkewScore := 1.0 - math.Abs(dsSkew) //smush dsSkew
 
-			//lower dispersion is better, cutoff dispersion scores at 30 seconds
-			tsMadmScore := 1.0 - float64(tsMadm)/30.0
+			//lower dispersion is better, cutoff dispersion scores at
+			//IntervalDispersionCutoff seconds
+			tsMadmScore := 1.0 - float64(tsMadm)/a.params.IntervalDispersionCutoff
 			if tsMadmScore < 0 {
 				tsMadmScore = 0
 			}
 
-			//lower dispersion is better, cutoff dispersion scores at 32 bytes
-			dsMadmScore := 1.0 - float64(dsMadm)/32.0
-			if dsMadmScore < 0 {
-				dsMadmScore = 0
-			}
+			//lower dispersion is better, cutoff dispersion scores at
+			//SizeDispersionCutoff bytes
+			dsMadmScore := a.sizeDispersionScore(dsMadm)
 
 			tsDurationScore := duration
 
+			//perfect beacons should connect in every bucket of the
+			//observation period, the same number of times in each
+			tsCoverage, tsConsistency := bucketCoverage(data.TsList, a.minTime,
+				a.maxTime, a.params.CoverageBucket)
+
+			//jittered beacons still connect at about the same interval
+			tsPeriod, tsPeriodScore := findPeriod(diff, tsMid)
+
+			//beacons which check in with bursts of requests form a few
+			//tight clusters of intervals
+			tsClusters := findIntervalClusters(diff)
+			tsClusterScore := scoreIntervalClusters(tsClusters, a.params.IntervalDispersionCutoff)
+
 			//smaller data sizes receive a higher score
-			dsSmallnessScore := 1.0 - float64(dsMode)/65535.0
-			if dsSmallnessScore < 0 {
-				dsSmallnessScore = 0
-			}
+			dsSmallnessScore := a.smallnessScore(dsMode)
 
+			weights := a.params.Weights
 			output := &beacon.AnalysisOutput{
-				UconnID:          data.ID,
-				Src:              data.Src,
-				Dst:              data.Dst,
-				ConnectionCount:  data.ConnectionCount,
-				AverageBytes:     data.AverageBytes,
-				TSISkew:          tsSkew,
-				TSIDispersion:    tsMadm,
-				TSDuration:       duration,
-				TSIRange:         tsIntervalRange,
-				TSIMode:          tsMode,
-				TSIModeCount:     tsModeCount,
-				TSIntervals:      intervals,
-				TSIntervalCounts: intervalCounts,
-				DSSkew:           dsSkew,
-				DSDispersion:     dsMadm,
-				DSRange:          dsRange,
-				DSSizes:          dsSizes,
-				DSSizeCounts:     dsCounts,
-				DSMode:           dsMode,
-				DSModeCount:      dsModeCount,
+				UconnID:           data.ID,
+				Src:               data.Src,
+				Dst:               data.Dst,
+				DstPort:           data.DstPort,
+				Proto:             data.Proto,
+				ConnectionCount:   data.ConnectionCount,
+				AverageBytes:      data.AverageBytes,
+				TSISkew:           tsSkew,
+				TSIDispersion:     tsMadm,
+				TSDuration:        duration,
+				TSIRange:          tsIntervalRange,
+				TSIMode:           tsMode,
+				TSIModeCount:      tsModeCount,
+				TSIntervals:       intervals,
+				TSIntervalCounts:  intervalCounts,
+				TSPeriod:          tsPeriod,
+				TSPeriodStrength:  tsPeriodScore,
+				TSClusters:        tsClusters,
+				TSClusterScore:    tsClusterScore,
+				TSCoverage:        tsCoverage,
+				TSConsistency:     tsConsistency,
+				TSSkewScore:       tsSkewScore,
+				TSDispersionScore: tsMadmScore,
+				TSDurationScore:   tsDurationScore,
+				DSSkewScore:       dsSkewScore,
+				DSDispersionScore: dsMadmScore,
+				DSSmallnessScore:  dsSmallnessScore,
+				DSSkew:            dsSkew,
+				DSDispersion:      dsMadm,
+				DSRange:           dsRange,
+				DSSizes:           dsSizes,
+				DSSizeCounts:      dsCounts,
+				DSMode:            dsMode,
+				DSModeCount:       dsModeCount,
+				Sensors:           data.Sensors,
+				SrcDevices:        data.SrcDevices,
+				DstDevices:        data.DstDevices,
+				DstIPs:            data.DstIPs,
+				Parameters:        a.params,
 			}
 
-			//score numerators
-			tsSum := tsSkewScore + tsMadmScore + tsDurationScore
-			dsSum := dsSkewScore + dsMadmScore + dsSmallnessScore
+			//constant size replies from the server are scored the same
+			//way as the originator's sizes, tasking shows up as spikes.
+			//Connections summarized before response sizes were recorded
+			//have no response sizes to score.
+			var rsSum, rsWeight float64
+			if len(data.RespIPBytes) > 0 {
+				rsSkew, rsMadm := sizeSkewAndDispersion(data.RespIPBytes)
+				rsSizes, rsCounts, rsMode, rsModeCount := createCountMap(data.RespIPBytes)
+				output.RSSkew = rsSkew
+				output.RSDispersion = rsMadm
+				output.RSRange = data.RespIPBytes[len(data.RespIPBytes)-1] - data.RespIPBytes[0]
+				output.RSSizes = rsSizes
+				output.RSSizeCounts = rsCounts
+				output.RSMode = rsMode
+				output.RSModeCount = rsModeCount
+				output.RSSkewScore = 1.0 - math.Abs(rsSkew)
+				output.RSDispersionScore = a.sizeDispersionScore(rsMadm)
+				output.RSSmallnessScore = a.smallnessScore(rsMode)
+
+				rsSum = weights.RSSkew*output.RSSkewScore +
+					weights.RSDispersion*output.RSDispersionScore +
+					weights.RSSmallness*output.RSSmallnessScore
+				rsWeight = weights.RSSkew + weights.RSDispersion + weights.RSSmallness
+				if rsWeight > 0 {
+					output.RSScore = rsSum / rsWeight
+				}
+			}
 
-			//score averages
-			output.TSScore = tsSum / 3.0
-			output.DSScore = dsSum / 3.0
-			output.Score = (tsSum + dsSum) / 6.0
+			//score numerators
+			tsSum := weights.TSSkew*tsSkewScore + weights.TSDispersion*tsMadmScore +
+				weights.TSDuration*tsDurationScore + weights.TSPeriod*tsPeriodScore +
+				weights.TSClusters*tsClusterScore + weights.TSCoverage*tsCoverage +
+				weights.TSConsistency*tsConsistency
+			dsSum := weights.DSSkew*dsSkewScore + weights.DSDispersion*dsMadmScore +
+				weights.DSSmallness*dsSmallnessScore + rsSum
+			tsWeight := weights.TSSkew + weights.TSDispersion + weights.TSDuration +
+				weights.TSPeriod + weights.TSClusters + weights.TSCoverage +
+				weights.TSConsistency
+			dsWeight := weights.DSSkew + weights.DSDispersion + weights.DSSmallness +
+				rsWeight
+
+			//score weighted averages
+			if tsWeight > 0 {
+				output.TSScore = tsSum / tsWeight
+			}
+			if dsWeight > 0 {
+				output.DSScore = dsSum / dsWeight
+			}
+			if tsWeight+dsWeight > 0 {
+				output.Score = (tsSum + dsSum) / (tsWeight + dsWeight)
+			}
 			a.analyzedCallback(output)
 		}
 		a.analysisWg.Done()
 	}()
 }
 
+//newScoringParameters collects the beacon scoring settings from the config
+func newScoringParameters(conf *config.BeaconStaticCfg) beacon.ScoringParameters {
+	return beacon.ScoringParameters{
+		MaxConnections:           conf.MaxConnections,
+		IntervalDispersionCutoff: conf.IntervalDispersionCutoff,
+		SizeDispersionCutoff:     conf.SizeDispersionCutoff,
+		SmallnessCutoff:          conf.SmallnessCutoff,
+		CoverageBucket:           conf.CoverageBucket,
+		Weights: beacon.ScoringWeights{
+			TSSkew:        conf.Weights.IntervalSkew,
+			TSDispersion:  conf.Weights.IntervalDispersion,
+			TSDuration:    conf.Weights.Duration,
+			TSPeriod:      conf.Weights.Period,
+			TSClusters:    conf.Weights.Clusters,
+			TSCoverage:    conf.Weights.Coverage,
+			TSConsistency: conf.Weights.Consistency,
+			DSSkew:        conf.Weights.SizeSkew,
+			DSDispersion:  conf.Weights.SizeDispersion,
+			DSSmallness:   conf.Weights.Smallness,
+			RSSkew:        conf.Weights.ResponseSkew,
+			RSDispersion:  conf.Weights.ResponseDispersion,
+			RSSmallness:   conf.Weights.ResponseSmallness,
+		},
+	}
+}
+
 // createCountMap returns a distinct data array, data count array, the mode,
 // and the number of times the mode occured
 func createCountMap(sortedIn []int64) ([]int64, []int64, int64, int64) {
@@ -244,3 +329,49 @@ func countAndRemoveConsecutiveDuplicates(numberList []int64) ([]int64, map[int64
 	}
 	return result, counts
 }
+
+//sizeSkewAndDispersion returns Bowley's measure of skew and the median
+//absolute deviation about the median of a sorted list of connection sizes
+func sizeSkewAndDispersion(sortedSizes []int64) (float64, int64) {
+	length := len(sortedSizes)
+	low := sortedSizes[util.Round(.25*float64(length-1))]
+	mid := sortedSizes[util.Round(.5*float64(length-1))]
+	high := sortedSizes[util.Round(.75*float64(length-1))]
+	bowleyNum := low + high - 2*mid
+	bowleyDen := high - low
+
+	//skew should equal zero if the denominator equals zero
+	//bowley skew is unreliable if Q2 = Q1 or Q2 = Q3
+	skew := float64(0)
+	if bowleyDen != 0 && mid != low && mid != high {
+		skew = float64(bowleyNum) / float64(bowleyDen)
+	}
+
+	devs := make([]int64, length)
+	for i := 0; i < length; i++ {
+		devs[i] = util.Abs(sortedSizes[i] - mid)
+	}
+	sort.Sort(util.SortableInt64(devs))
+
+	return skew, devs[util.Round(.5*float64(length-1))]
+}
+
+//sizeDispersionScore scores the dispersion of connection sizes, lower
+//dispersion is better, cutoff dispersion scores at SizeDispersionCutoff bytes
+func (a *analyzer) sizeDispersionScore(madm int64) float64 {
+	score := 1.0 - float64(madm)/a.params.SizeDispersionCutoff
+	if score < 0 {
+		score = 0
+	}
+	return score
+}
+
+//smallnessScore scores the most common connection size, smaller data
+//sizes receive a higher score
+func (a *analyzer) smallnessScore(mode int64) float64 {
+	score := 1.0 - float64(mode)/a.params.SmallnessCutoff
+	if score < 0 {
+		score = 0
+	}
+	return score
+}
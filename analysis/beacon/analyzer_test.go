@@ -4,8 +4,10 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/datatypes/beacon"
 	"github.com/activecm/rita/util"
+	"github.com/creasty/defaults"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,6 +19,7 @@ func TestAnalyzer(t *testing.T) {
 
 		analyzer := newAnalyzer(
 			val.ts[0], val.ts[len(val.ts)-1], //min max times,
+			defaultScoringParameters(t),
 			func(output *beacon.AnalysisOutput) {
 				analyzedChan <- output
 			}, func() {
@@ -41,6 +44,94 @@ func TestAnalyzer(t *testing.T) {
 	}
 }
 
+//defaultScoringParameters returns the scoring parameters used when the
+//config file does not override them
+func defaultScoringParameters(t *testing.T) beacon.ScoringParameters {
+	var conf config.BeaconStaticCfg
+	require.Nil(t, defaults.Set(&conf))
+	return newScoringParameters(&conf)
+}
+
+func TestAnalyzerScoringParameters(t *testing.T) {
+	params := defaultScoringParameters(t)
+	assert.Equal(t, 150000, params.MaxConnections)
+	assert.Equal(t, float64(65535), params.SmallnessCutoff)
+
+	//only the smallness of the connections counts towards the score
+	params.SmallnessCutoff = 1000
+	params.Weights = beacon.ScoringWeights{DSSmallness: 2}
+
+	var output *beacon.AnalysisOutput
+	analyzer := newAnalyzer(0, 400, params,
+		func(result *beacon.AnalysisOutput) { output = result },
+		func() {},
+	)
+	analyzer.start()
+	analyzer.analyze(&beacon.AnalysisInput{
+		Src:         "0.0.0.0",
+		Dst:         "0.0.0.0",
+		TsList:      []int64{0, 100, 200, 300, 400},
+		OrigIPBytes: []int64{250, 250, 250, 250, 250},
+	})
+	analyzer.close()
+
+	require.NotNil(t, output)
+	assert.InDelta(t, 0.75, output.Score, 0.0001)
+	assert.InDelta(t, 0.75, output.DSScore, 0.0001)
+	assert.Equal(t, float64(0), output.TSScore)
+	assert.Equal(t, params, output.Parameters)
+
+	//sub-scores are stored even when they are not weighted
+	assert.InDelta(t, 0.75, output.DSSmallnessScore, 0.0001)
+	assert.Equal(t, float64(1), output.TSSkewScore)
+	assert.Equal(t, float64(1), output.TSDispersionScore)
+	assert.Equal(t, float64(1), output.TSDurationScore)
+	assert.Equal(t, float64(1), output.DSSkewScore)
+	assert.Equal(t, float64(1), output.DSDispersionScore)
+}
+
+func TestAnalyzerResponseSizes(t *testing.T) {
+	params := defaultScoringParameters(t)
+	analyze := func(respBytes []int64) *beacon.AnalysisOutput {
+		var output *beacon.AnalysisOutput
+		analyzer := newAnalyzer(0, 400, params,
+			func(result *beacon.AnalysisOutput) { output = result },
+			func() {},
+		)
+		analyzer.start()
+		analyzer.analyze(&beacon.AnalysisInput{
+			Src:         "0.0.0.0",
+			Dst:         "0.0.0.0",
+			TsList:      []int64{0, 100, 200, 300, 400},
+			OrigIPBytes: []int64{250, 250, 250, 250, 250},
+			RespIPBytes: respBytes,
+		})
+		analyzer.close()
+		require.NotNil(t, output)
+		return output
+	}
+
+	//without response sizes the score is left as it was
+	withoutResp := analyze(nil)
+	assert.Equal(t, float64(0), withoutResp.RSScore)
+
+	//constant size replies keep the score of a perfect beacon high
+	constant := analyze([]int64{500, 500, 500, 500, 500})
+	assert.Equal(t, int64(500), constant.RSMode)
+	assert.Equal(t, int64(5), constant.RSModeCount)
+	assert.Equal(t, int64(0), constant.RSDispersion)
+	assert.Equal(t, float64(1), constant.RSSkewScore)
+	assert.Equal(t, float64(1), constant.RSDispersionScore)
+	assert.True(t, constant.RSScore > 0.99)
+	assert.InDelta(t, withoutResp.Score, constant.Score, 0.01)
+
+	//tasking shows up as spikes in the size of the replies
+	tasked := analyze([]int64{500, 80000, 520, 30000, 490})
+	assert.Equal(t, int64(79510), tasked.RSRange)
+	assert.True(t, tasked.RSDispersionScore < 0.5)
+	assert.True(t, tasked.Score < constant.Score)
+}
+
 func TestCreateCountMap(t *testing.T) {
 	testData := []int64{3, 4, -1, -4, -3, -1, 0, 0, 0, 0, 0, 1, 2, 3, 4, 2, 3, 4, 4}
 	testDataCounts := map[int64]int64{
@@ -7,6 +7,7 @@ import (
 	mgo "github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 
+	"github.com/activecm/rita/analysis/dhcp"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/datatypes/beacon"
 	"github.com/activecm/rita/resources"
@@ -26,6 +27,7 @@ func BuildBeaconCollection(res *resources.Resources) {
 		{Key: []string{"$hashed:src"}},
 		{Key: []string{"$hashed:dst"}},
 		{Key: []string{"-connection_count"}},
+		{Key: []string{"sensors"}},
 	}
 	err := res.DB.CreateCollection(collectionName, collectionKeys)
 	if err != nil {
@@ -41,9 +43,10 @@ func BuildBeaconCollection(res *resources.Resources) {
 	)
 
 	//Create the workers
-	writerWorker := newWriter(res.DB, res.Config)
+	writerWorker := newWriter(collectionName, res.DB, res.Config)
 	analyzerWorker := newAnalyzer(
 		minTime, maxTime,
+		newScoringParameters(&res.Config.S.Beacon),
 		writerWorker.write, writerWorker.close,
 	)
 
@@ -53,18 +56,22 @@ func BuildBeaconCollection(res *resources.Resources) {
 		writerWorker.start()
 	}
 
+	// label the hosts with the devices which held their addresses
+	timeline := dhcp.LoadTimeline(res)
+
 	// copy session
 	session := res.DB.Session.Copy()
 
 	// create find query
-	// first two lines: limit results to connection counts of min 20 and max 150k
+	// first two lines: limit results to connection counts between
+	// DefaultConnectionThresh and MaxConnections
 	// third line: analysis needs at least four delta times to analyze
 	// (Q1, Q2, Q3, Q4). This verifies at least 5 unique timestamps (connections may
 	// result in duplicates, ts_list is a unique set)
 	uconnsFindQuery := bson.M{
 		"$and": []bson.M{
 			bson.M{"connection_count": bson.M{"$gt": res.Config.S.Beacon.DefaultConnectionThresh}},
-			bson.M{"connection_count": bson.M{"$lt": 150000}},
+			bson.M{"connection_count": bson.M{"$lt": res.Config.S.Beacon.MaxConnections}},
 			bson.M{"ts_list.4": bson.M{"$exists": true}},
 		}}
 
@@ -73,10 +80,14 @@ func BuildBeaconCollection(res *resources.Resources) {
 		ID              bson.ObjectId `bson:"_id,omitempty"`
 		Src             string        `bson:"src"`
 		Dst             string        `bson:"dst"`
+		DstPort         int           `bson:"dst_port"`
+		Proto           string        `bson:"proto"`
 		TsList          []int64       `bson:"ts_list"`
 		OrigIPBytes     []int64       `bson:"orig_bytes_list"`
+		RespIPBytes     []int64       `bson:"resp_bytes_list"`
 		ConnectionCount int           `bson:"connection_count"`
 		AverageBytes    float32       `bson:"avg_bytes"`
+		Sensors         []string      `bson:"sensors"`
 	}
 
 	// execute query
@@ -94,17 +105,37 @@ func BuildBeaconCollection(res *resources.Resources) {
 			ID:              uconnRes.ID,
 			Src:             uconnRes.Src,
 			Dst:             uconnRes.Dst,
+			DstPort:         uconnRes.DstPort,
+			Proto:           uconnRes.Proto,
 			TsList:          uconnRes.TsList,
 			OrigIPBytes:     uconnRes.OrigIPBytes,
+			RespIPBytes:     uconnRes.RespIPBytes,
 			ConnectionCount: uconnRes.ConnectionCount,
 			AverageBytes:    uconnRes.AverageBytes,
+			Sensors:         uconnRes.Sensors,
 		}
+		first, last := timeRange(uconnRes.TsList)
+		newInput.SrcDevices = timeline.Devices(uconnRes.Src, first, last)
+		newInput.DstDevices = timeline.Devices(uconnRes.Dst, first, last)
 		analyzerWorker.analyze(newInput)
 	}
 	session.Close()
 
 }
 
+//timeRange returns the earliest and latest timestamps in the list
+func timeRange(tsList []int64) (first int64, last int64) {
+	for i, ts := range tsList {
+		if i == 0 || ts < first {
+			first = ts
+		}
+		if i == 0 || ts > last {
+			last = ts
+		}
+	}
+	return
+}
+
 // findAnalysisPeriod returns the lowest and highest timestamps in the
 // uconnCollection. These values are only used in calculating the
 // duration metric. This implementation uses the uconn collection rather
@@ -211,9 +242,10 @@ func findAnalysisPeriod(db *database.DB, uconnCollection string,
 }
 
 //GetBeaconResultsView finds beacons greater than a given cutoffScore
-//and links the data from the unique connections table back in to the results
-func GetBeaconResultsView(res *resources.Resources, ssn *mgo.Session, cutoffScore float64) *mgo.Iter {
-	pipeline := getViewPipeline(res, cutoffScore)
+//and links the data from the unique connections table back in to the results.
+//If sensor is not empty, only beacons recorded by that sensor are returned.
+func GetBeaconResultsView(res *resources.Resources, ssn *mgo.Session, cutoffScore float64, sensor string) *mgo.Iter {
+	pipeline := getViewPipeline(res, cutoffScore, sensor)
 	return res.DB.AggregateCollection(res.Config.T.Beacon.BeaconTable, ssn, pipeline)
 }
 
@@ -221,15 +253,21 @@ func GetBeaconResultsView(res *resources.Resources, ssn *mgo.Session, cutoffScor
 // stores uconn uid's rather than src, dest pairs. cuttoff is the lowest overall
 // score to report on. Setting cuttoff to 0 retrieves all the records from the
 // beaconing collection. Setting cuttoff to 1 will prevent the aggregation from
-// returning any records.
-func getViewPipeline(res *resources.Resources, cuttoff float64) []bson.D {
+// returning any records. Setting sensor limits the results to beacons
+// recorded by the given sensor.
+func getViewPipeline(res *resources.Resources, cuttoff float64, sensor string) []bson.D {
+	match := bson.D{
+		{"score", bson.D{
+			{"$gt", cuttoff},
+		}},
+	}
+	if sensor != "" {
+		match = append(match, bson.DocElem{"sensors", sensor})
+	}
+
 	return []bson.D{
 		{
-			{"$match", bson.D{
-				{"score", bson.D{
-					{"$gt", cuttoff},
-				}},
-			}},
+			{"$match", match},
 		},
 		{
 			{"$lookup", bson.D{
@@ -252,6 +290,8 @@ func getViewPipeline(res *resources.Resources, cuttoff float64) []bson.D {
 				{"score", 1},
 				{"src", "$uconn.src"},
 				{"dst", "$uconn.dst"},
+				{"dst_port", "$uconn.dst_port"},
+				{"proto", "$uconn.proto"},
 				{"local_src", "$uconn.local_src"},
 				{"local_dst", "$uconn.local_dst"},
 				{"connection_count", "$uconn.connection_count"},
@@ -261,12 +301,37 @@ func getViewPipeline(res *resources.Resources, cuttoff float64) []bson.D {
 				{"ts_iMode_count", 1},
 				{"ts_iSkew", 1},
 				{"ts_duration", 1},
+				{"ts_period", 1},
+				{"ts_period_strength", 1},
+				{"ts_clusters", 1},
+				{"ts_cluster_score", 1},
+				{"ts_coverage", 1},
+				{"ts_consistency", 1},
 				{"ts_iDispersion", 1},
 				{"ds_dispersion", 1},
 				{"ds_range", 1},
 				{"ds_mode", 1},
 				{"ds_mode_count", 1},
 				{"ds_skew", 1},
+				{"rs_skew", 1},
+				{"rs_dispersion", 1},
+				{"rs_range", 1},
+				{"rs_mode", 1},
+				{"rs_mode_count", 1},
+				{"sensors", 1},
+				{"src_devices", 1},
+				{"dst_devices", 1},
+				{"ts_skew_score", 1},
+				{"ts_dispersion_score", 1},
+				{"ts_duration_score", 1},
+				{"ds_skew_score", 1},
+				{"ds_dispersion_score", 1},
+				{"ds_smallness_score", 1},
+				{"rs_skew_score", 1},
+				{"rs_dispersion_score", 1},
+				{"rs_smallness_score", 1},
+				{"parameters", 1},
+				{"suppression", 1},
 			}},
 		},
 	}
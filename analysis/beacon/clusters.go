@@ -0,0 +1,190 @@
+package beacon
+
+import (
+	"math"
+	"sort"
+
+	"github.com/activecm/rita/datatypes/beacon"
+	"github.com/activecm/rita/util"
+)
+
+//intervalBinGrowth is the factor by which the width of each interval
+//histogram bin grows over the last. Log scaled bins let bursts of
+//requests seconds apart and check ins hours apart be told apart with the
+//same histogram.
+const intervalBinGrowth = 1.1
+
+//intervalBinOffset is added to intervals before they are binned so
+//intervals of a few seconds share bins rather than each getting their own
+const intervalBinOffset = 10
+
+//minClusterWeight is the smallest fraction of the intervals a cluster
+//must hold to be reported
+const minClusterWeight = 0.05
+
+//maxScoredClusters is the number of clusters which count towards the
+//cluster score. Beacons which sleep and check in with bursts of requests
+//form a few clusters. Intervals spread across more clusters than this
+//are treated as noise.
+const maxScoredClusters = 3
+
+//clusterRelativeDispersion is the fraction of its center a cluster's
+//intervals may deviate by before it is no longer tight, when that is more
+//than the dispersion cutoff. Beacons sleeping for hours vary by more
+//seconds than beacons sleeping for minutes.
+const clusterRelativeDispersion = 0.2
+
+//maxBurstInterval is the longest interval between the connections of a
+//burst. Beacons which check in with bursts of connections also sleep
+//between the bursts, so clusters of intervals this short only count fully
+//alongside a cluster of longer intervals.
+const maxBurstInterval = 10
+
+//burstOnlyWeight scales the score of connections which only ever come in
+//bursts, such as a client making many requests in quick succession
+const burstOnlyWeight = 1.0 / 3
+
+//findIntervalClusters groups the intervals between connections into
+//clusters around the peaks of their histogram. Clusters are separated at
+//the valleys between peaks which fall below half the height of the
+//smaller peak. The clusters holding at least minClusterWeight of the
+//intervals are returned ordered by weight. sortedDiffs must be sorted.
+func findIntervalClusters(sortedDiffs []int64) []beacon.IntervalCluster {
+	if len(sortedDiffs) == 0 {
+		return nil
+	}
+
+	//build a histogram with log scaled bins
+	bins := make([]int, len(sortedDiffs))
+	for index, diff := range sortedDiffs {
+		bins[index] = intervalBin(diff)
+	}
+	first := bins[0]
+	counts := make([]float64, bins[len(bins)-1]-first+1)
+	for _, bin := range bins {
+		counts[bin-first]++
+	}
+
+	//smooth the histogram so intervals which straddle the edge of
+	//a bin do not form two peaks
+	smoothed := make([]float64, len(counts))
+	for index := range counts {
+		smoothed[index] = 2 * counts[index]
+		if index > 0 {
+			smoothed[index] += counts[index-1]
+		}
+		if index < len(counts)-1 {
+			smoothed[index] += counts[index+1]
+		}
+		smoothed[index] /= 4
+	}
+
+	//find the peaks of the histogram
+	var peaks []int
+	for index := range smoothed {
+		rising := index == 0 || smoothed[index] > smoothed[index-1]
+		falling := index == len(smoothed)-1 || smoothed[index] >= smoothed[index+1]
+		if rising && falling && smoothed[index] > 0 {
+			peaks = append(peaks, index)
+		}
+	}
+
+	//split the histogram at the significant valleys between peaks
+	var bounds []int
+	tallest := 0
+	if len(peaks) > 0 {
+		tallest = peaks[0]
+	}
+	for index := 1; index < len(peaks); index++ {
+		valley := peaks[index-1]
+		for bin := peaks[index-1]; bin < peaks[index]; bin++ {
+			if smoothed[bin] < smoothed[valley] {
+				valley = bin
+			}
+		}
+		if smoothed[valley] < 0.5*math.Min(smoothed[tallest], smoothed[peaks[index]]) {
+			bounds = append(bounds, valley)
+			tallest = peaks[index]
+		} else if smoothed[peaks[index]] > smoothed[tallest] {
+			tallest = peaks[index]
+		}
+	}
+	bounds = append(bounds, len(smoothed))
+
+	//collect the intervals which fall within each cluster
+	var clusters []beacon.IntervalCluster
+	start := 0
+	for _, bound := range bounds {
+		end := start
+		for end < len(sortedDiffs) && bins[end]-first < bound {
+			end++
+		}
+		members := sortedDiffs[start:end]
+		start = end
+
+		weight := float64(len(members)) / float64(len(sortedDiffs))
+		if weight < minClusterWeight {
+			continue
+		}
+		center := members[len(members)/2]
+		devs := make([]int64, len(members))
+		for index, member := range members {
+			devs[index] = util.Abs(member - center)
+		}
+		sort.Sort(util.SortableInt64(devs))
+
+		clusters = append(clusters, beacon.IntervalCluster{
+			Center:     center,
+			Count:      int64(len(members)),
+			Weight:     weight,
+			Dispersion: devs[len(devs)/2],
+		})
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Weight > clusters[j].Weight
+	})
+	return clusters
+}
+
+//intervalBin returns the index of the log scaled histogram bin an
+//interval falls in
+func intervalBin(interval int64) int {
+	if interval < 0 {
+		interval = 0
+	}
+	return int(math.Log(float64(interval)+intervalBinOffset) / math.Log(intervalBinGrowth))
+}
+
+//scoreIntervalClusters rewards intervals which fall into a small number of
+//tight clusters. Each of the largest clusters contributes its weight
+//scaled by how tight it is, and the sum is divided by the square root of
+//the number of clusters counted. Connections which only ever come in
+//bursts are scaled down by burstOnlyWeight. A perfect beacon scores one.
+func scoreIntervalClusters(clusters []beacon.IntervalCluster, dispersionCutoff float64) float64 {
+	scored := clusters
+	if len(scored) > maxScoredClusters {
+		scored = scored[:maxScoredClusters]
+	}
+	if len(scored) == 0 {
+		return 0
+	}
+
+	var score float64
+	sleeps := false
+	for _, cluster := range scored {
+		allowed := math.Max(dispersionCutoff, clusterRelativeDispersion*float64(cluster.Center))
+		tightness := 1.0 - float64(cluster.Dispersion)/allowed
+		if tightness > 0 {
+			score += cluster.Weight * tightness
+		}
+		if cluster.Center >= maxBurstInterval {
+			sleeps = true
+		}
+	}
+	score /= math.Sqrt(float64(len(scored)))
+	if !sleeps {
+		score *= burstOnlyWeight
+	}
+	return score
+}
@@ -0,0 +1,64 @@
+package beacon
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/activecm/rita/datatypes/beacon"
+	"github.com/activecm/rita/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//clusterTestData repeats the pattern of intervals and returns them sorted
+func clusterTestData(repeat int, pattern ...int64) []int64 {
+	var diffs []int64
+	for i := 0; i < repeat; i++ {
+		diffs = append(diffs, pattern...)
+	}
+	sort.Sort(util.SortableInt64(diffs))
+	return diffs
+}
+
+func TestFindIntervalClusters(t *testing.T) {
+	// a perfect beacon forms a single cluster
+	clusters := findIntervalClusters(clusterTestData(100, 60))
+	require.Len(t, clusters, 1)
+	assert.Equal(t, int64(60), clusters[0].Center)
+	assert.Equal(t, int64(100), clusters[0].Count)
+	assert.Equal(t, 1.0, clusters[0].Weight)
+	assert.Equal(t, int64(0), clusters[0].Dispersion)
+	assert.InDelta(t, 1.0, scoreIntervalClusters(clusters, 30), 0.0001)
+
+	// three quick requests every ten minutes form two clusters
+	clusters = findIntervalClusters(clusterTestData(100, 1, 2, 597))
+	require.Len(t, clusters, 2)
+	assert.InDelta(t, 2.0/3, clusters[0].Weight, 0.0001)
+	assert.Equal(t, int64(200), clusters[0].Count)
+	assert.Equal(t, int64(597), clusters[1].Center)
+	assert.InDelta(t, 1.0/3, clusters[1].Weight, 0.0001)
+	burstScore := scoreIntervalClusters(clusters, 30)
+	assert.True(t, burstScore > 0.65, "burst score %f", burstScore)
+
+	// connections which only come in bursts never sleep like a beacon
+	burstOnlyScore := scoreIntervalClusters(findIntervalClusters(clusterTestData(100, 1, 2)), 30)
+	assert.True(t, burstOnlyScore < burstScore/2, "burst only score %f", burstOnlyScore)
+
+	// long sleeps may vary by more seconds than the dispersion cutoff
+	hourly := []beacon.IntervalCluster{{Center: 3600, Count: 24, Weight: 1, Dispersion: 100}}
+	assert.InDelta(t, 1-100.0/720, scoreIntervalClusters(hourly, 30), 0.0001)
+
+	// random connections do not form tight clusters
+	rng := rand.New(rand.NewSource(1))
+	var diffs []int64
+	for i := 0; i < 300; i++ {
+		diffs = append(diffs, int64(rng.ExpFloat64()*600))
+	}
+	sort.Sort(util.SortableInt64(diffs))
+	randomScore := scoreIntervalClusters(findIntervalClusters(diffs), 30)
+	assert.True(t, randomScore < burstScore/2, "random score %f", randomScore)
+
+	assert.Nil(t, findIntervalClusters(nil))
+	assert.Equal(t, 0.0, scoreIntervalClusters(nil, 30))
+}
@@ -0,0 +1,57 @@
+package beacon
+
+import (
+	"math"
+)
+
+//bucketCoverage splits the observation period into buckets of bucketSize
+//seconds and measures how steadily the connections fill them. A pair
+//which connects once at the start and once at the end of the period spans
+//the whole period but only covers two buckets.
+//
+//coverage is the fraction of the buckets in which the pair connected.
+//consistency is one minus the coefficient of variation of the number of
+//connections in each bucket the pair connected in, so beacons which
+//connect the same number of times every bucket score one. It falls to
+//zero when the counts vary as much as their mean.
+func bucketCoverage(tsList []int64, minTime, maxTime, bucketSize int64) (coverage float64, consistency float64) {
+	if len(tsList) == 0 || bucketSize <= 0 || maxTime < minTime {
+		return 0, 0
+	}
+
+	//the last timestamp falls in the last bucket rather than a bucket of
+	//its own
+	buckets := (maxTime - minTime + bucketSize - 1) / bucketSize
+	if buckets < 1 {
+		buckets = 1
+	}
+	counts := make(map[int64]float64)
+	for _, ts := range tsList {
+		bucket := (ts - minTime) / bucketSize
+		if bucket < 0 {
+			bucket = 0
+		} else if bucket >= buckets {
+			bucket = buckets - 1
+		}
+		counts[bucket]++
+	}
+	coverage = float64(len(counts)) / float64(buckets)
+
+	var mean float64
+	for _, count := range counts {
+		mean += count
+	}
+	mean /= float64(len(counts))
+
+	var variance float64
+	for _, count := range counts {
+		variance += (count - mean) * (count - mean)
+	}
+	variance /= float64(len(counts))
+
+	consistency = 1.0 - math.Sqrt(variance)/mean
+	if consistency < 0 {
+		consistency = 0
+	}
+	return coverage, consistency
+}
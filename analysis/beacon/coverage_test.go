@@ -0,0 +1,35 @@
+package beacon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketCoverage(t *testing.T) {
+	// a beacon every ten minutes for a day connects in every hour
+	var tsList []int64
+	for ts := int64(0); ts < 86400; ts += 600 {
+		tsList = append(tsList, ts)
+	}
+	coverage, consistency := bucketCoverage(tsList, 0, 86400, 3600)
+	assert.Equal(t, 1.0, coverage)
+	assert.InDelta(t, 1.0, consistency, 0.0001)
+
+	// connecting at the start and end of the day spans the whole day
+	// but covers two hours
+	coverage, consistency = bucketCoverage([]int64{60, 86340}, 0, 86400, 3600)
+	assert.InDelta(t, 2.0/24, coverage, 0.0001)
+	assert.Equal(t, 1.0, consistency)
+
+	// a burst of connections in one hour and a trickle in the others is
+	// not consistent
+	tsList = []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 3600, 7200}
+	coverage, consistency = bucketCoverage(tsList, 0, 10800, 3600)
+	assert.Equal(t, 1.0, coverage)
+	assert.True(t, consistency < 0.3, "consistency %f", consistency)
+
+	coverage, consistency = bucketCoverage(nil, 0, 86400, 3600)
+	assert.Equal(t, 0.0, coverage)
+	assert.Equal(t, 0.0, consistency)
+}
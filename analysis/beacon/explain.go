@@ -0,0 +1,140 @@
+package beacon
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/activecm/rita/datatypes/beacon"
+)
+
+//ScoreComponent describes how one sub-score contributed to a beacon's score
+type ScoreComponent struct {
+	Name         string  // Name of the sub-score
+	Score        float64 // Sub-score between zero and one
+	Weight       float64 // Weight the sub-score was given
+	Contribution float64 // Amount the sub-score added to the total score
+	Reason       string  // Human readable description of the measurement
+}
+
+//Explain breaks a beacon's score down into the sub-scores it was averaged
+//from. Sub-scores which were given no weight are left out. The components
+//are ordered by how much they added to the score. Beacons analyzed before
+//the scoring weights were stored are explained using equal weights.
+//Response sizes only count towards the score of beacons which recorded
+//them.
+func Explain(view *beacon.AnalysisView) []ScoreComponent {
+	weights := view.Parameters.Weights
+	if weights == (beacon.ScoringWeights{}) {
+		weights = beacon.ScoringWeights{
+			TSSkew: 1, TSDispersion: 1, TSDuration: 1,
+			DSSkew: 1, DSDispersion: 1, DSSmallness: 1,
+		}
+	}
+
+	components := []ScoreComponent{
+		{
+			Name:   "Interval Skew",
+			Score:  view.TSSkewScore,
+			Weight: weights.TSSkew,
+			Reason: fmt.Sprintf("intervals have a skew of %.3f", view.TSISkew),
+		},
+		{
+			Name:   "Interval Dispersion",
+			Score:  view.TSDispersionScore,
+			Weight: weights.TSDispersion,
+			Reason: fmt.Sprintf("intervals deviate from the median by %ds", view.TSIDispersion),
+		},
+		{
+			Name:   "Duration",
+			Score:  view.TSDurationScore,
+			Weight: weights.TSDuration,
+			Reason: fmt.Sprintf("connections span %.0f%% of the dataset", view.TSDuration*100),
+		},
+		{
+			Name:   "Coverage",
+			Score:  view.TSCoverage,
+			Weight: weights.TSCoverage,
+			Reason: fmt.Sprintf("connections were made in %.0f%% of the buckets", view.TSCoverage*100),
+		},
+		{
+			Name:   "Consistency",
+			Score:  view.TSConsistency,
+			Weight: weights.TSConsistency,
+			Reason: "the number of connections in each bucket varies by " +
+				fmt.Sprintf("%.0f%% of the average", (1-view.TSConsistency)*100),
+		},
+		{
+			Name:   "Period",
+			Score:  view.TSPeriodStr,
+			Weight: weights.TSPeriod,
+			Reason: fmt.Sprintf("connections repeat every %ds", view.TSPeriod),
+		},
+		{
+			Name:   "Interval Clusters",
+			Score:  view.TSClusterScore,
+			Weight: weights.TSClusters,
+			Reason: fmt.Sprintf("intervals form %d clusters", len(view.TSClusters)),
+		},
+		{
+			Name:   "Size Skew",
+			Score:  view.DSSkewScore,
+			Weight: weights.DSSkew,
+			Reason: fmt.Sprintf("sizes have a skew of %.3f", view.DSSkew),
+		},
+		{
+			Name:   "Size Dispersion",
+			Score:  view.DSDispersionScore,
+			Weight: weights.DSDispersion,
+			Reason: fmt.Sprintf("sizes deviate from the median by %d bytes", view.DSDispersion),
+		},
+		{
+			Name:   "Smallness",
+			Score:  view.DSSmallnessScore,
+			Weight: weights.DSSmallness,
+			Reason: fmt.Sprintf("the most common size is %d bytes", view.DSMode),
+		},
+		{
+			Name:   "Response Skew",
+			Score:  view.RSSkewScore,
+			Weight: weights.RSSkew,
+			Reason: fmt.Sprintf("response sizes have a skew of %.3f", view.RSSkew),
+		},
+		{
+			Name:   "Response Dispersion",
+			Score:  view.RSDispersionScore,
+			Weight: weights.RSDispersion,
+			Reason: fmt.Sprintf("response sizes deviate from the median by %d bytes", view.RSDispersion),
+		},
+		{
+			Name:   "Response Smallness",
+			Score:  view.RSSmallnessScore,
+			Weight: weights.RSSmallness,
+			Reason: fmt.Sprintf("the most common response size is %d bytes", view.RSMode),
+		},
+	}
+
+	//response sizes which were scored always leave a non zero skew score
+	//when the mode and range are zero
+	if view.RSMode == 0 && view.RSRange == 0 && view.RSSkewScore == 0 {
+		components = components[:len(components)-3]
+	}
+
+	var totalWeight float64
+	for _, component := range components {
+		totalWeight += component.Weight
+	}
+
+	explained := make([]ScoreComponent, 0, len(components))
+	for _, component := range components {
+		if component.Weight <= 0 {
+			continue
+		}
+		component.Contribution = component.Score * component.Weight / totalWeight
+		explained = append(explained, component)
+	}
+
+	sort.SliceStable(explained, func(i, j int) bool {
+		return explained[i].Contribution > explained[j].Contribution
+	})
+	return explained
+}
@@ -0,0 +1,41 @@
+package beacon
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/datatypes/beacon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	view := &beacon.AnalysisView{
+		Score:             0.5,
+		TSDispersionScore: 1,
+		TSIDispersion:     0,
+		DSSmallnessScore:  0.5,
+		DSMode:            32768,
+		Parameters: beacon.ScoringParameters{
+			Weights: beacon.ScoringWeights{TSDispersion: 1, DSSmallness: 2},
+		},
+	}
+
+	components := Explain(view)
+	require.Len(t, components, 2)
+	assert.Equal(t, "Interval Dispersion", components[0].Name)
+	assert.InDelta(t, 1.0/3, components[0].Contribution, 0.0001)
+	assert.Equal(t, "intervals deviate from the median by 0s", components[0].Reason)
+	assert.Equal(t, "Smallness", components[1].Name)
+	assert.InDelta(t, 1.0/3, components[1].Contribution, 0.0001)
+	assert.Equal(t, "the most common size is 32768 bytes", components[1].Reason)
+
+	// beacons scored before the weights were stored use equal weights
+	view.Parameters = beacon.ScoringParameters{}
+	components = Explain(view)
+	require.Len(t, components, 6)
+	var total float64
+	for _, component := range components {
+		total += component.Contribution
+	}
+	assert.InDelta(t, 1.5/6, total, 0.0001)
+}
@@ -0,0 +1,347 @@
+package beacon
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/activecm/rita/analysis/dhcp"
+	"github.com/activecm/rita/datatypes/beacon"
+	dnsTypes "github.com/activecm/rita/datatypes/dns"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+//maxHostnamesPerIP is the largest number of hostnames an address may
+//resolve to in DNS before it is considered shared hosting. Connections to
+//shared addresses are only attributed to hostnames seen in the connections
+//themselves.
+const maxHostnamesPerIP = 10
+
+type (
+	//fqdnBeaconKey identifies the connections from an internal host to a
+	//hostname. The destination port and protocol are set when unique
+	//connections are grouped by service.
+	fqdnBeaconKey struct {
+		src     string
+		fqdn    string
+		dstPort int
+		proto   string
+	}
+
+	//fqdnBeacon merges the unique connections from a host to each of the
+	//addresses a hostname resolved to
+	fqdnBeacon struct {
+		tsSet           map[int64]bool
+		bytes           []int64
+		respBytes       []int64
+		connectionCount int
+		totalBytes      float64
+		sensors         []string
+		dstIPs          []string
+	}
+
+	//fqdnUconn holds the fields of a unique connection merged into FQDN
+	//beacons
+	fqdnUconn struct {
+		Src             string   `bson:"src"`
+		Dst             string   `bson:"dst"`
+		DstPort         int      `bson:"dst_port"`
+		Proto           string   `bson:"proto"`
+		TsList          []int64  `bson:"ts_list"`
+		OrigIPBytes     []int64  `bson:"orig_bytes_list"`
+		RespIPBytes     []int64  `bson:"resp_bytes_list"`
+		ConnectionCount int      `bson:"connection_count"`
+		AverageBytes    float32  `bson:"avg_bytes"`
+		Sensors         []string `bson:"sensors"`
+	}
+
+	//hostnameResolver finds the hostnames a host meant to reach when it
+	//connected to an address
+	hostnameResolver struct {
+		dns   map[string][]string // address -> hostnames seen in DNS answers
+		pairs map[string][]string // src and dst address -> hostnames named in the connection
+	}
+)
+
+// BuildFQDNBeaconCollection searches for hosts which beacon to a hostname.
+// Command and control servers behind content delivery networks and round
+// robin DNS are spread across many addresses, so the unique connections
+// from a host to every address a hostname resolved to are merged before
+// being scored. Hostnames are taken from the HTTP Host header and the TLS
+// server name where they were logged and from DNS answers otherwise.
+// The unique connections are read in order of their source so only the
+// connections of one host are held in memory at a time.
+func BuildFQDNBeaconCollection(res *resources.Resources) {
+	collectionName := res.Config.T.Beacon.FQDNBeaconTable
+	collectionKeys := []mgo.Index{
+		{Key: []string{"-score"}},
+		{Key: []string{"$hashed:src"}},
+		{Key: []string{"$hashed:dst"}},
+		{Key: []string{"sensors"}},
+	}
+	err := res.DB.CreateCollection(collectionName, collectionKeys)
+	if err != nil {
+		res.Log.Error("Failed: ", collectionName, err.Error())
+		return
+	}
+
+	resolver := loadHostnameResolver(res)
+	if resolver.empty() {
+		return
+	}
+
+	ssn := res.DB.Session.Copy()
+	defer ssn.Close()
+
+	minTime, maxTime := findAnalysisPeriod(
+		res.DB,
+		res.Config.T.Structure.UniqueConnTable,
+		res.Log,
+	)
+	writerWorker := newWriter(collectionName, res.DB, res.Config)
+	analyzerWorker := newAnalyzer(
+		minTime, maxTime,
+		newScoringParameters(&res.Config.S.Beacon),
+		writerWorker.write, writerWorker.close,
+	)
+	for i := 0; i < util.Max(1, runtime.NumCPU()/2); i++ {
+		analyzerWorker.start()
+		writerWorker.start()
+	}
+
+	timeline := dhcp.LoadTimeline(res)
+	//the src and dst index lets MongoDB return the connections by source
+	uconnIter := ssn.DB(res.DB.GetSelectedDB()).
+		C(res.Config.T.Structure.UniqueConnTable).
+		Find(nil).Sort("src").Iter()
+	next := func(uconn *fqdnUconn) bool {
+		return uconnIter.Next(uconn)
+	}
+	mergeFQDNBeacons(next, resolver, func(key fqdnBeaconKey, merged *fqdnBeacon) {
+		input := merged.analysisInput(key, res.Config.S.Beacon.DefaultConnectionThresh,
+			res.Config.S.Beacon.MaxConnections)
+		if input == nil {
+			return
+		}
+		first, last := timeRange(input.TsList)
+		input.SrcDevices = timeline.Devices(input.Src, first, last)
+		analyzerWorker.analyze(input)
+	})
+	if err := uconnIter.Close(); err != nil {
+		res.Log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to read unique connections for FQDN beaconing")
+	}
+	analyzerWorker.close()
+}
+
+//mergeFQDNBeacons merges the unique connections returned by next into the
+//connections from each host to each hostname. next must return the unique
+//connections in order of their source. The merged connections of a source
+//are passed to found once all of its unique connections have been read.
+func mergeFQDNBeacons(next func(*fqdnUconn) bool, resolver *hostnameResolver,
+	found func(fqdnBeaconKey, *fqdnBeacon)) {
+	beacons := make(map[fqdnBeaconKey]*fqdnBeacon)
+	flush := func() {
+		for key, merged := range beacons {
+			found(key, merged)
+		}
+		beacons = make(map[fqdnBeaconKey]*fqdnBeacon)
+	}
+
+	var uconn fqdnUconn
+	src := ""
+	for next(&uconn) {
+		if uconn.Src != src {
+			flush()
+			src = uconn.Src
+		}
+		for _, fqdn := range resolver.hostnames(uconn.Src, uconn.Dst) {
+			key := fqdnBeaconKey{src: uconn.Src, fqdn: fqdn, dstPort: uconn.DstPort, proto: uconn.Proto}
+			merged, ok := beacons[key]
+			if !ok {
+				merged = &fqdnBeacon{tsSet: make(map[int64]bool)}
+				beacons[key] = merged
+			}
+			for _, ts := range uconn.TsList {
+				merged.tsSet[ts] = true
+			}
+			merged.bytes = append(merged.bytes, uconn.OrigIPBytes...)
+			merged.respBytes = append(merged.respBytes, uconn.RespIPBytes...)
+			merged.connectionCount += uconn.ConnectionCount
+			merged.totalBytes += float64(uconn.AverageBytes) * float64(uconn.ConnectionCount)
+			merged.sensors = addStrings(merged.sensors, uconn.Sensors...)
+			merged.dstIPs = addStrings(merged.dstIPs, uconn.Dst)
+		}
+		uconn = fqdnUconn{}
+	}
+	flush()
+}
+
+//analysisInput converts the merged connections into the input of the
+//beacon analyzer. nil is returned if the connections do not meet the
+//limits applied to unique connections.
+func (b *fqdnBeacon) analysisInput(key fqdnBeaconKey, connectionThresh int,
+	maxConnections int) *beacon.AnalysisInput {
+	if b.connectionCount <= connectionThresh || b.connectionCount >= maxConnections ||
+		len(b.tsSet) < 5 {
+		return nil
+	}
+
+	tsList := make([]int64, 0, len(b.tsSet))
+	for ts := range b.tsSet {
+		tsList = append(tsList, ts)
+	}
+	sort.Sort(util.SortableInt64(tsList))
+	sort.Strings(b.dstIPs)
+
+	return &beacon.AnalysisInput{
+		Src:             key.src,
+		Dst:             key.fqdn,
+		DstPort:         key.dstPort,
+		Proto:           key.proto,
+		TsList:          tsList,
+		OrigIPBytes:     b.bytes,
+		RespIPBytes:     b.respBytes,
+		ConnectionCount: b.connectionCount,
+		AverageBytes:    float32(b.totalBytes / float64(b.connectionCount)),
+		Sensors:         b.sensors,
+		DstIPs:          b.dstIPs,
+	}
+}
+
+//loadHostnameResolver reads the hostnames named in HTTP requests and TLS
+//handshakes and the hostnames collection built from DNS answers
+func loadHostnameResolver(res *resources.Resources) *hostnameResolver {
+	resolver := &hostnameResolver{
+		dns:   make(map[string][]string),
+		pairs: make(map[string][]string),
+	}
+
+	ssn := res.DB.Session.Copy()
+	defer ssn.Close()
+
+	hostnamesCollection := res.Config.T.DNS.HostnamesTable
+	if res.DB.CollectionExists(hostnamesCollection) {
+		var hostname dnsTypes.Hostname
+		iter := ssn.DB(res.DB.GetSelectedDB()).C(hostnamesCollection).Find(nil).Iter()
+		for iter.Next(&hostname) {
+			fqdn := strings.ToLower(hostname.Host)
+			for _, ip := range hostname.IPs {
+				resolver.dns[ip] = addStrings(resolver.dns[ip], fqdn)
+			}
+			hostname.IPs = nil
+		}
+		if err := iter.Close(); err != nil {
+			res.Log.Error("Failed reading ", hostnamesCollection, err.Error())
+		}
+	}
+
+	resolver.addPairs(res, ssn, res.Config.T.Structure.HTTPTable, proxyRequestMatch(), "$host")
+	resolver.addPairs(res, ssn, res.Config.T.Structure.SSLTable,
+		bson.M{"server_name": bson.M{"$nin": []interface{}{"", "-", nil}}}, "$server_name")
+
+	return resolver
+}
+
+//addPairs reads the hostnames named in the connections stored in a
+//collection. match selects the entries which name a host and hostField
+//is the expression holding the hostname.
+func (r *hostnameResolver) addPairs(res *resources.Resources, ssn *mgo.Session,
+	collection string, match bson.M, hostField string) {
+	if !res.DB.CollectionExists(collection) {
+		return
+	}
+
+	var pair struct {
+		Src   string   `bson:"src"`
+		Dst   string   `bson:"dst"`
+		Hosts []string `bson:"hosts"`
+	}
+	iter := res.DB.AggregateCollection(collection, ssn, []bson.D{
+		{{"$match", match}},
+		{{"$group", bson.M{
+			"_id": bson.M{
+				"src": "$id_orig_h",
+				"dst": "$id_resp_h",
+			},
+			"hosts": bson.M{"$addToSet": bson.M{"$toLower": hostField}},
+		}}},
+		{{"$project", bson.M{
+			"_id":   0,
+			"src":   "$_id.src",
+			"dst":   "$_id.dst",
+			"hosts": 1,
+		}}},
+	})
+	for iter != nil && iter.Next(&pair) {
+		for _, host := range pair.Hosts {
+			//requests made directly to an address do not name a host
+			if !util.IsIP(host) {
+				key := pair.Src + " " + pair.Dst
+				r.pairs[key] = addStrings(r.pairs[key], host)
+			}
+		}
+		pair.Hosts = nil
+	}
+}
+
+//empty returns true if no hostnames were found
+func (r *hostnameResolver) empty() bool {
+	return len(r.dns) == 0 && len(r.pairs) == 0
+}
+
+//hostnames returns the hostnames the source meant to reach when it
+//connected to the destination address. Hostnames named in the connections
+//take precedence over those found in DNS answers.
+func (r *hostnameResolver) hostnames(src string, dst string) []string {
+	if names, ok := r.pairs[src+" "+dst]; ok {
+		return names
+	}
+	names := r.dns[dst]
+	if len(names) > maxHostnamesPerIP {
+		return nil
+	}
+	return names
+}
+
+//addStrings adds the values missing from the list to the list
+func addStrings(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, item := range list {
+			if item == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+//GetFQDNBeaconResultsView finds FQDN beacons greater than a given
+//cutoffScore. If sensor is not empty, only beacons recorded by that
+//sensor are returned.
+func GetFQDNBeaconResultsView(res *resources.Resources, ssn *mgo.Session, cutoffScore float64, sensor string) *mgo.Iter {
+	match := bson.D{
+		{"score", bson.D{
+			{"$gt", cutoffScore},
+		}},
+	}
+	if sensor != "" {
+		match = append(match, bson.DocElem{"sensors", sensor})
+	}
+
+	pipeline := []bson.D{
+		{{"$match", match}},
+		{{"$sort", bson.D{{"score", -1}}}},
+	}
+	return res.DB.AggregateCollection(res.Config.T.Beacon.FQDNBeaconTable, ssn, pipeline)
+}
@@ -0,0 +1,104 @@
+package beacon
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostnameResolver(t *testing.T) {
+	resolver := &hostnameResolver{
+		dns: map[string][]string{
+			"203.0.113.1": {"c2.example.com"},
+			"203.0.113.2": {"c2.example.com", "cdn.example.net"},
+		},
+		pairs: map[string][]string{
+			"10.0.0.5 203.0.113.2": {"api.example.org"},
+		},
+	}
+	for i := 0; i <= maxHostnamesPerIP; i++ {
+		resolver.dns["198.51.100.1"] = append(resolver.dns["198.51.100.1"], fmt.Sprintf("site%d.example.com", i))
+	}
+
+	assert.Equal(t, []string{"c2.example.com"}, resolver.hostnames("10.0.0.5", "203.0.113.1"))
+	// hostnames named in the connections win over DNS answers
+	assert.Equal(t, []string{"api.example.org"}, resolver.hostnames("10.0.0.5", "203.0.113.2"))
+	assert.Equal(t, []string{"c2.example.com", "cdn.example.net"}, resolver.hostnames("10.0.0.6", "203.0.113.2"))
+	// shared hosting addresses are not attributed to any hostname
+	assert.Nil(t, resolver.hostnames("10.0.0.5", "198.51.100.1"))
+	assert.Nil(t, resolver.hostnames("10.0.0.5", "192.0.2.1"))
+}
+
+func TestFQDNBeaconAnalysisInput(t *testing.T) {
+	merged := &fqdnBeacon{tsSet: make(map[int64]bool)}
+	key := fqdnBeaconKey{src: "10.0.0.5", fqdn: "c2.example.com"}
+
+	// the connections to each address alone are too few to analyze
+	for index, ip := range []string{"203.0.113.2", "203.0.113.1"} {
+		for ts := int64(index); ts < 8; ts += 2 {
+			merged.tsSet[ts*60] = true
+			merged.bytes = append(merged.bytes, 100)
+		}
+		merged.connectionCount += 4
+		merged.totalBytes += 4 * 150
+		merged.sensors = addStrings(merged.sensors, "tap1")
+		merged.dstIPs = addStrings(merged.dstIPs, ip)
+	}
+	assert.Nil(t, merged.analysisInput(key, 8, 150000))
+
+	input := merged.analysisInput(key, 4, 150000)
+	require.NotNil(t, input)
+	assert.Equal(t, "c2.example.com", input.Dst)
+	assert.Equal(t, []int64{0, 60, 120, 180, 240, 300, 360, 420}, input.TsList)
+	assert.Len(t, input.OrigIPBytes, 8)
+	assert.Equal(t, 8, input.ConnectionCount)
+	assert.Equal(t, float32(150), input.AverageBytes)
+	assert.Equal(t, []string{"tap1"}, input.Sensors)
+	assert.Equal(t, []string{"203.0.113.1", "203.0.113.2"}, input.DstIPs)
+
+	assert.Nil(t, merged.analysisInput(key, 4, 8))
+}
+
+func TestMergeFQDNBeaconsBySource(t *testing.T) {
+	resolver := &hostnameResolver{
+		dns: map[string][]string{
+			"203.0.113.1": {"c2.example.com"},
+			"203.0.113.2": {"c2.example.com"},
+		},
+	}
+	uconns := []fqdnUconn{
+		{Src: "10.0.0.5", Dst: "203.0.113.1", TsList: []int64{0, 120}, ConnectionCount: 2},
+		{Src: "10.0.0.5", Dst: "203.0.113.2", TsList: []int64{60, 120}, ConnectionCount: 2},
+		{Src: "10.0.0.5", Dst: "192.0.2.1", TsList: []int64{30}, ConnectionCount: 1},
+		{Src: "10.0.0.6", Dst: "203.0.113.1", TsList: []int64{0}, ConnectionCount: 1},
+	}
+
+	read := 0
+	next := func(uconn *fqdnUconn) bool {
+		if read == len(uconns) {
+			return false
+		}
+		*uconn = uconns[read]
+		read++
+		return true
+	}
+
+	found := make(map[string]*fqdnBeacon)
+	mergeFQDNBeacons(next, resolver, func(key fqdnBeaconKey, merged *fqdnBeacon) {
+		assert.Equal(t, "c2.example.com", key.fqdn)
+		found[key.src] = merged
+		// a source's beacons are found as soon as the next source is read
+		if key.src == "10.0.0.5" {
+			assert.Equal(t, 4, read)
+		}
+	})
+
+	require.Len(t, found, 2)
+	first := found["10.0.0.5"]
+	assert.Len(t, first.tsSet, 3)
+	assert.Equal(t, 4, first.connectionCount)
+	assert.Equal(t, []string{"203.0.113.1", "203.0.113.2"}, first.dstIPs)
+	assert.Equal(t, 1, found["10.0.0.6"].connectionCount)
+}
@@ -0,0 +1,104 @@
+package beacon
+
+import (
+	"math"
+)
+
+//periodCandidates is the number of periods the intervals are compared
+//against
+const periodCandidates = 129
+
+//periodRefinements is the number of times the period is moved to the
+//mean of the intervals which match it
+const periodRefinements = 3
+
+//periodNullMatch is the highest average match random connections are
+//expected to reach. The intervals between random connections are
+//exponentially distributed, and at best this fraction of them match a
+//period.
+const periodNullMatch = 0.39
+
+//findPeriod searches the intervals between connections for a dominant
+//period. Each interval is compared against the period on its own, so the
+//jitter of one interval does not carry over into the next. An interval
+//within a quarter of the period matches it fully, and the match falls off
+//until the interval is three quarters of the period away. Jitter of up to
+//half the period therefore keeps most intervals matching, while the
+//intervals between random connections spread out well past the period.
+//Periods between two thirds and twice the median interval are searched so
+//harmonics are not mistaken for the period.
+//
+//The strength of the period is the fraction of the best average match
+//which rises above the match random connections are likely to reach. It
+//is zero when no period stands out and one when every interval matches.
+func findPeriod(intervals []int64, medianInterval int64) (period int64, strength float64) {
+	if len(intervals) < 4 || medianInterval <= 0 {
+		return 0, 0
+	}
+
+	low := 2 * float64(medianInterval) / 3
+	step := (2*float64(medianInterval) - low) / float64(periodCandidates-1)
+
+	var best, bestMatch float64
+	for index := 0; index < periodCandidates; index++ {
+		candidate := low + float64(index)*step
+		match := averagePeriodMatch(intervals, candidate)
+		if match > bestMatch {
+			best, bestMatch = candidate, match
+		}
+	}
+	if bestMatch == 0 {
+		return 0, 0
+	}
+
+	//many candidates may match every interval, so the period is moved to
+	//the mean of the intervals which match it at all
+	for refinement := 0; refinement < periodRefinements; refinement++ {
+		var total, count float64
+		for _, interval := range intervals {
+			if periodMatch(float64(interval)/best) > 0 {
+				total += float64(interval)
+				count++
+			}
+		}
+		if count == 0 {
+			break
+		}
+		best = total / count
+	}
+
+	//three standard deviations above the expected match bounds the best
+	//match random connections are likely to reach across the candidates
+	noise := periodNullMatch + 3*math.Sqrt(periodNullMatch*(1-periodNullMatch)/float64(len(intervals)))
+
+	period = int64(math.Floor(best + 0.5))
+	strength = (bestMatch - noise) / (1 - noise)
+	if strength < 0 {
+		strength = 0
+	}
+	return period, strength
+}
+
+//averagePeriodMatch returns the average match of the intervals against
+//the period
+func averagePeriodMatch(intervals []int64, period float64) float64 {
+	var total float64
+	for _, interval := range intervals {
+		total += periodMatch(float64(interval) / period)
+	}
+	return total / float64(len(intervals))
+}
+
+//periodMatch scores an interval given as a multiple of the period. The
+//score is one within a quarter of the period and falls to zero at three
+//quarters of the period away.
+func periodMatch(ratio float64) float64 {
+	distance := math.Abs(ratio - 1)
+	if distance <= 0.25 {
+		return 1
+	}
+	if distance >= 0.75 {
+		return 0
+	}
+	return 1 - 2*(distance-0.25)
+}
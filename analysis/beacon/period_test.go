@@ -0,0 +1,84 @@
+package beacon
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/activecm/rita/util"
+	"github.com/stretchr/testify/assert"
+)
+
+//periodTestData generates the intervals between connections drawn by the
+//interval function and their median
+func periodTestData(count int, interval func() float64) ([]int64, int64) {
+	tsList := make([]int64, count)
+	ts := 0.0
+	for index := range tsList {
+		tsList[index] = int64(ts)
+		ts += interval()
+	}
+
+	diffs := make([]int64, count-1)
+	for index := range diffs {
+		diffs[index] = tsList[index+1] - tsList[index]
+	}
+	sort.Sort(util.SortableInt64(diffs))
+	return diffs, diffs[len(diffs)/2]
+}
+
+//jitter returns an interval function which adds up to the fraction of the
+//period at random
+func jitter(random *rand.Rand, period float64, fraction float64) func() float64 {
+	return func() float64 {
+		return period * (1 - fraction + 2*fraction*random.Float64())
+	}
+}
+
+func TestFindPeriod(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	diffs, median := periodTestData(200, func() float64 { return 300 })
+	period, strength := findPeriod(diffs, median)
+	assert.Equal(t, int64(300), period)
+	assert.InDelta(t, 1, strength, 0.001, "perfect beacon strength %f", strength)
+
+	// short perfect beacons are as strong as long ones
+	diffs, median = periodTestData(24, func() float64 { return 3600 })
+	period, strength = findPeriod(diffs, median)
+	assert.Equal(t, int64(3600), period)
+	assert.InDelta(t, 1, strength, 0.001, "short beacon strength %f", strength)
+
+	// jitter inflates the dispersion well past its cutoff, but the
+	// intervals still match the period
+	for _, test := range []struct {
+		fraction float64
+		count    int
+		minimum  float64
+	}{
+		{0.2, 500, 0.9},
+		{0.3, 150, 0.9},
+		{0.3, 500, 0.9},
+		{0.5, 150, 0.5},
+		{0.5, 500, 0.6},
+	} {
+		diffs, median = periodTestData(test.count, jitter(random, 300, test.fraction))
+		period, strength = findPeriod(diffs, median)
+		assert.InDelta(t, 300, period, 15, "%.0f%% jitter period", test.fraction*100)
+		assert.True(t, strength > test.minimum, "%.0f%% jitter strength %f with %d connections",
+			test.fraction*100, strength, test.count)
+	}
+
+	// connections made at random have no period
+	for _, count := range []int{24, 150, 500} {
+		diffs, median = periodTestData(count, func() float64 {
+			return 300 * random.ExpFloat64()
+		})
+		_, strength = findPeriod(diffs, median)
+		assert.True(t, strength < 0.1, "random connection strength %f with %d connections", strength, count)
+	}
+
+	period, strength = findPeriod([]int64{60, 60}, 60)
+	assert.Equal(t, int64(0), period)
+	assert.Equal(t, float64(0), strength)
+}
@@ -0,0 +1,175 @@
+package beacon
+
+import (
+	"net"
+	"runtime"
+
+	"github.com/activecm/rita/analysis/dhcp"
+	"github.com/activecm/rita/datatypes/beacon"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// BuildProxyBeaconCollection searches the http collection for clients
+// which beacon to a hostname. Requests made through a web proxy only reach
+// the unique connections collection as connections to the proxy, so the
+// requests are grouped by the client and the requested host instead.
+func BuildProxyBeaconCollection(res *resources.Resources) {
+	httpCollection := res.Config.T.Structure.HTTPTable
+	if !res.DB.CollectionExists(httpCollection) {
+		return
+	}
+
+	collectionName := res.Config.T.Beacon.ProxyBeaconTable
+	collectionKeys := []mgo.Index{
+		{Key: []string{"-score"}},
+		{Key: []string{"$hashed:src"}},
+		{Key: []string{"$hashed:dst"}},
+		{Key: []string{"sensors"}},
+	}
+	err := res.DB.CreateCollection(collectionName, collectionKeys)
+	if err != nil {
+		res.Log.Error("Failed: ", collectionName, err.Error())
+		return
+	}
+
+	ssn := res.DB.Session.Copy()
+	defer ssn.Close()
+
+	//find the observation period
+	var period struct {
+		Min int64 `bson:"min"`
+		Max int64 `bson:"max"`
+	}
+	periodIter := res.DB.AggregateCollection(httpCollection, ssn, []bson.D{
+		{{"$match", proxyRequestMatch()}},
+		{{"$group", bson.M{
+			"_id": nil,
+			"min": bson.M{"$min": "$ts"},
+			"max": bson.M{"$max": "$ts"},
+		}}},
+	})
+	if periodIter == nil || !periodIter.Next(&period) {
+		return
+	}
+
+	writerWorker := newWriter(collectionName, res.DB, res.Config)
+	analyzerWorker := newAnalyzer(
+		period.Min, period.Max,
+		newScoringParameters(&res.Config.S.Beacon),
+		writerWorker.write, writerWorker.close,
+	)
+	for i := 0; i < util.Max(1, runtime.NumCPU()/2); i++ {
+		analyzerWorker.start()
+		writerWorker.start()
+	}
+
+	pairIter := res.DB.AggregateCollection(httpCollection, ssn,
+		getProxyPairsPipeline(res.Config.S.Beacon.DefaultConnectionThresh,
+			res.Config.S.Beacon.MaxConnections))
+	if pairIter == nil {
+		analyzerWorker.close()
+		return
+	}
+
+	timeline := dhcp.LoadTimeline(res)
+	//invalid subnets are rejected when the logs are imported
+	internal, _ := util.ParseSubnets(res.Config.S.Filtering.InternalSubnets)
+	var pair struct {
+		Src             string   `bson:"src"`
+		Host            string   `bson:"host"`
+		TsList          []int64  `bson:"ts_list"`
+		Bytes           []int64  `bson:"bytes_list"`
+		ConnectionCount int      `bson:"connection_count"`
+		AverageBytes    float32  `bson:"avg_bytes"`
+		Sensors         []string `bson:"sensors"`
+	}
+	for pairIter.Next(&pair) {
+		//only requests from internal clients to external hosts are analyzed
+		if len(internal) > 0 && (!util.ContainsIP(internal, net.ParseIP(pair.Src)) ||
+			util.ContainsIP(internal, net.ParseIP(pair.Host))) {
+			continue
+		}
+		first, last := timeRange(pair.TsList)
+		analyzerWorker.analyze(&beacon.AnalysisInput{
+			Src:             pair.Src,
+			Dst:             pair.Host,
+			TsList:          pair.TsList,
+			OrigIPBytes:     pair.Bytes,
+			ConnectionCount: pair.ConnectionCount,
+			AverageBytes:    pair.AverageBytes,
+			Sensors:         pair.Sensors,
+			SrcDevices:      timeline.Devices(pair.Src, first, last),
+		})
+	}
+	analyzerWorker.close()
+}
+
+//proxyRequestMatch matches the http requests which name a host
+func proxyRequestMatch() bson.M {
+	return bson.M{"host": bson.M{"$nin": []interface{}{"", nil}}}
+}
+
+//getProxyPairsPipeline groups the http requests by client and requested
+//host. The size of each request is the sum of its request and response
+//lengths.
+func getProxyPairsPipeline(connectionThresh int, maxConnections int) []bson.D {
+	size := bson.M{"$add": []interface{}{
+		bson.M{"$ifNull": []interface{}{"$request_body_len", 0}},
+		bson.M{"$ifNull": []interface{}{"$response_body_len", 0}},
+	}}
+	return []bson.D{
+		{{"$match", proxyRequestMatch()}},
+		{{"$group", bson.M{
+			"_id": bson.M{
+				"src":  "$id_orig_h",
+				"host": bson.M{"$toLower": "$host"},
+			},
+			"conns":   bson.M{"$sum": 1},
+			"ts":      bson.M{"$addToSet": "$ts"},
+			"bytes":   bson.M{"$push": size},
+			"abytes":  bson.M{"$avg": size},
+			"sensors": bson.M{"$addToSet": "$sensor"},
+		}}},
+		// the same limits used for unique connections apply
+		{{"$match", bson.M{
+			"$and": []bson.M{
+				bson.M{"conns": bson.M{"$gt": connectionThresh}},
+				bson.M{"conns": bson.M{"$lt": maxConnections}},
+				bson.M{"ts.4": bson.M{"$exists": true}},
+			}},
+		}},
+		{{"$project", bson.M{
+			"_id":              0,
+			"src":              "$_id.src",
+			"host":             "$_id.host",
+			"connection_count": "$conns",
+			"avg_bytes":        "$abytes",
+			"ts_list":          "$ts",
+			"bytes_list":       "$bytes",
+			"sensors":          "$sensors",
+		}}},
+	}
+}
+
+//GetProxyBeaconResultsView finds proxy beacons greater than a given
+//cutoffScore. If sensor is not empty, only beacons recorded by that
+//sensor are returned.
+func GetProxyBeaconResultsView(res *resources.Resources, ssn *mgo.Session, cutoffScore float64, sensor string) *mgo.Iter {
+	match := bson.D{
+		{"score", bson.D{
+			{"$gt", cutoffScore},
+		}},
+	}
+	if sensor != "" {
+		match = append(match, bson.DocElem{"sensors", sensor})
+	}
+
+	pipeline := []bson.D{
+		{{"$match", match}},
+		{{"$sort", bson.D{{"score", -1}}}},
+	}
+	return res.DB.AggregateCollection(res.Config.T.Beacon.ProxyBeaconTable, ssn, pipeline)
+}
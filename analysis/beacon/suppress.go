@@ -0,0 +1,254 @@
+package beacon
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/datatypes/beacon"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//suppressionDateFormat is the layout of the expiry dates in a suppression
+//file
+const suppressionDateFormat = "2006-01-02"
+
+type (
+	//SuppressionList describes known good periodic traffic, such as NTP,
+	//antivirus updates, and monitoring probes, whose beacons are hidden
+	//from results
+	SuppressionList struct {
+		Suppressions []*SuppressionRule `yaml:"Suppressions"`
+	}
+
+	//SuppressionRule matches the beacons to or from known good hosts.
+	//Hosts are given as addresses, subnets, or hostnames. Hostnames
+	//starting with "*." match every subdomain.
+	SuppressionRule struct {
+		//Host matches beacons with the host at either end
+		Host string `yaml:"Host"`
+		//Src and Dst match beacons from the source to the destination.
+		//Either may be left out to match any host.
+		Src string `yaml:"Src"`
+		Dst string `yaml:"Dst"`
+		//Expires is the last day the rule applies, as YYYY-MM-DD. Rules
+		//without an expiry date never expire.
+		Expires string `yaml:"Expires"`
+		//Reason describes why the traffic is known to be good
+		Reason string `yaml:"Reason"`
+
+		expires int64
+		host    *hostPattern
+		src     *hostPattern
+		dst     *hostPattern
+	}
+
+	//hostPattern matches an address against a subnet or hostname
+	hostPattern struct {
+		subnet   *net.IPNet
+		hostname string
+	}
+)
+
+//LoadSuppressionList reads and validates a suppression file
+func LoadSuppressionList(path string) (*SuppressionList, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseSuppressionList(contents)
+}
+
+//parseSuppressionList validates the rules of a suppression file
+func parseSuppressionList(contents []byte) (*SuppressionList, error) {
+	list := &SuppressionList{}
+	if err := yaml.UnmarshalStrict(contents, list); err != nil {
+		return nil, err
+	}
+
+	for index, rule := range list.Suppressions {
+		if err := rule.parse(); err != nil {
+			return nil, fmt.Errorf("Suppression %d: %s", index+1, err.Error())
+		}
+	}
+	return list, nil
+}
+
+//parse checks the rule and prepares its patterns for matching
+func (r *SuppressionRule) parse() error {
+	if r.Host == "" && r.Src == "" && r.Dst == "" {
+		return errors.New("Host, Src, or Dst must be given")
+	}
+	if r.Host != "" && (r.Src != "" || r.Dst != "") {
+		return errors.New("Host cannot be used with Src or Dst")
+	}
+	if r.Expires != "" {
+		day, err := time.Parse(suppressionDateFormat, r.Expires)
+		if err != nil {
+			return fmt.Errorf("Expires must be a date such as 2006-01-02, not %q", r.Expires)
+		}
+		//the rule applies until the end of the day it expires
+		r.expires = day.AddDate(0, 0, 1).Unix()
+	}
+	r.host = newHostPattern(r.Host)
+	r.src = newHostPattern(r.Src)
+	r.dst = newHostPattern(r.Dst)
+	return nil
+}
+
+//newHostPattern parses an address, subnet, or hostname. nil is returned
+//for an empty pattern.
+func newHostPattern(pattern string) *hostPattern {
+	if pattern == "" {
+		return nil
+	}
+	if subnets, err := util.ParseSubnets([]string{pattern}); err == nil {
+		return &hostPattern{subnet: subnets[0]}
+	}
+	return &hostPattern{hostname: strings.ToLower(pattern)}
+}
+
+//matches returns true if the host, or one of the hostnames it is known
+//by, matches the pattern. A nil pattern matches every host.
+func (p *hostPattern) matches(host string, hostnames []string) bool {
+	if p == nil {
+		return true
+	}
+	if p.subnet != nil {
+		return p.subnet.Contains(net.ParseIP(host))
+	}
+	if p.matchesHostname(host) {
+		return true
+	}
+	for _, hostname := range hostnames {
+		if p.matchesHostname(hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+//matchesHostname compares a hostname against the pattern
+func (p *hostPattern) matchesHostname(hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	if strings.HasPrefix(p.hostname, "*.") {
+		return strings.HasSuffix(hostname, p.hostname[1:])
+	}
+	return hostname == p.hostname
+}
+
+//Match returns the first rule which matches the beacon from src to dst
+//and has not expired. dstNames lists the hostnames the destination is
+//known by. nil is returned if no rule matches.
+func (l *SuppressionList) Match(src string, dst string, dstNames []string, now time.Time) *SuppressionRule {
+	for _, rule := range l.Suppressions {
+		if rule.expires != 0 && now.Unix() >= rule.expires {
+			continue
+		}
+		if rule.host != nil {
+			if rule.host.matches(src, nil) || rule.host.matches(dst, dstNames) {
+				return rule
+			}
+			continue
+		}
+		if rule.src.matches(src, nil) && rule.dst.matches(dst, dstNames) {
+			return rule
+		}
+	}
+	return nil
+}
+
+//SuppressBeacons flags the beacons matched by the suppression list in
+//each of the beacon collections. The beacons are kept so their scores can
+//still be reviewed. Flags left by earlier runs are cleared first so rules
+//removed from the list no longer apply. Must run after the hostnames
+//collection is built.
+func SuppressBeacons(res *resources.Resources, list *SuppressionList) {
+	resolver := loadHostnameResolver(res)
+	now := time.Now()
+
+	ssn := res.DB.Session.Copy()
+	defer ssn.Close()
+
+	collections := []string{
+		res.Config.T.Beacon.BeaconTable,
+		res.Config.T.Beacon.ProxyBeaconTable,
+		res.Config.T.Beacon.FQDNBeaconTable,
+	}
+	for _, collectionName := range collections {
+		if !res.DB.CollectionExists(collectionName) {
+			continue
+		}
+		collection := ssn.DB(res.DB.GetSelectedDB()).C(collectionName)
+
+		_, err := collection.UpdateAll(
+			bson.M{"suppression": bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{"suppression": ""}},
+		)
+		if err != nil {
+			res.Log.WithFields(log.Fields{
+				"collection": collectionName,
+				"error":      err.Error(),
+			}).Error("Failed to clear beacon suppressions")
+			continue
+		}
+
+		var found struct {
+			ID  bson.ObjectId `bson:"_id"`
+			Src string        `bson:"src"`
+			Dst string        `bson:"dst"`
+		}
+		suppressed := 0
+		iter := collection.Find(nil).Select(bson.M{"src": 1, "dst": 1}).Iter()
+		for iter.Next(&found) {
+			rule := list.Match(found.Src, found.Dst, resolver.hostnames(found.Src, found.Dst), now)
+			if rule == nil {
+				continue
+			}
+			err = collection.UpdateId(found.ID, bson.M{"$set": bson.M{
+				"suppression": beacon.Suppression{
+					Reason:  rule.Reason,
+					Expires: rule.expires,
+				},
+			}})
+			if err != nil {
+				res.Log.WithFields(log.Fields{
+					"collection": collectionName,
+					"error":      err.Error(),
+				}).Error("Failed to suppress beacon")
+				continue
+			}
+			suppressed++
+		}
+		if err := iter.Close(); err != nil {
+			res.Log.WithFields(log.Fields{
+				"collection": collectionName,
+				"error":      err.Error(),
+			}).Error("Failed to read beacons for suppression")
+		}
+
+		res.Log.WithFields(log.Fields{
+			"collection": collectionName,
+			"suppressed": suppressed,
+		}).Info("Suppressed beacons to known good hosts")
+	}
+}
+
+//HideSuppressed removes the beacons with an active suppression from the
+//results
+func HideSuppressed(data []beacon.AnalysisView, now time.Time) []beacon.AnalysisView {
+	shown := data[:0]
+	for _, d := range data {
+		if d.Suppression == nil || !d.Suppression.Active(now) {
+			shown = append(shown, d)
+		}
+	}
+	return shown
+}
@@ -0,0 +1,74 @@
+package beacon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/datatypes/beacon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const suppressionTestFile = `
+Suppressions:
+  - Host: 10.0.0.123
+    Reason: NTP server
+  - Dst: "*.windowsupdate.com"
+    Reason: Windows updates
+    Expires: 2030-06-30
+  - Src: 192.168.1.0/24
+    Dst: mdm.example.com
+    Reason: MDM check ins
+  - Dst: monitor.example.com
+    Expires: 2020-01-01
+`
+
+func TestSuppressionList(t *testing.T) {
+	list, err := parseSuppressionList([]byte(suppressionTestFile))
+	require.Nil(t, err)
+	require.Len(t, list.Suppressions, 4)
+	now := time.Date(2030, 6, 30, 12, 0, 0, 0, time.UTC)
+
+	// hosts match either end of the beacon
+	assert.Equal(t, "NTP server", list.Match("10.0.0.123", "8.8.8.8", nil, now).Reason)
+	assert.Equal(t, "NTP server", list.Match("192.168.1.5", "10.0.0.123", nil, now).Reason)
+
+	// hostnames match the destination or the names it is known by
+	assert.Equal(t, "Windows updates", list.Match("192.168.1.5", "download.WindowsUpdate.com", nil, now).Reason)
+	assert.Equal(t, "Windows updates", list.Match("192.168.1.5", "13.107.4.50", []string{"au.windowsupdate.com"}, now).Reason)
+	assert.Nil(t, list.Match("192.168.1.5", "windowsupdate.com.evil.net", nil, now))
+
+	// rules apply until the end of the day they expire
+	assert.Nil(t, list.Match("192.168.1.5", "au.windowsupdate.com", nil, now.Add(12*time.Hour)))
+	assert.Nil(t, list.Match("192.168.1.5", "monitor.example.com", nil, now))
+
+	// pairs only match beacons from the source to the destination
+	assert.Equal(t, "MDM check ins", list.Match("192.168.1.5", "mdm.example.com", nil, now).Reason)
+	assert.Nil(t, list.Match("192.168.2.5", "mdm.example.com", nil, now))
+	assert.Nil(t, list.Match("mdm.example.com", "192.168.1.5", nil, now))
+}
+
+func TestSuppressionListErrors(t *testing.T) {
+	_, err := parseSuppressionList([]byte("Suppressions:\n  - Reason: nothing\n"))
+	assert.NotNil(t, err)
+	_, err = parseSuppressionList([]byte("Suppressions:\n  - Host: 10.0.0.1\n    Dst: 10.0.0.2\n"))
+	assert.NotNil(t, err)
+	_, err = parseSuppressionList([]byte("Suppressions:\n  - Host: 10.0.0.1\n    Expires: tomorrow\n"))
+	assert.NotNil(t, err)
+	_, err = parseSuppressionList([]byte("Suppressions:\n  - Hots: 10.0.0.1\n"))
+	assert.NotNil(t, err)
+}
+
+func TestHideSuppressed(t *testing.T) {
+	now := time.Unix(1000, 0)
+	data := []beacon.AnalysisView{
+		{Src: "shown"},
+		{Src: "hidden", Suppression: &beacon.Suppression{Reason: "NTP"}},
+		{Src: "expired", Suppression: &beacon.Suppression{Expires: 1000}},
+		{Src: "expiring", Suppression: &beacon.Suppression{Expires: 1001}},
+	}
+	shown := HideSuppressed(data, now)
+	require.Len(t, shown, 2)
+	assert.Equal(t, "shown", shown[0].Src)
+	assert.Equal(t, "expired", shown[1].Src)
+}
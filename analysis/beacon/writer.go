@@ -9,22 +9,24 @@ import (
 )
 
 type (
-	//writer simply writes AnalysisOutput objects to the beacons collection
+	//writer simply writes AnalysisOutput objects to a beacons collection
 	writer struct {
-		db           *database.DB                // provides access to MongoDB
-		conf         *config.Config              // contains details needed to access MongoDB
-		writeChannel chan *beacon.AnalysisOutput // holds analyzed data
-		writeWg      sync.WaitGroup              // wait for writing to finish
+		targetCollection string
+		db               *database.DB                // provides access to MongoDB
+		conf             *config.Config              // contains details needed to access MongoDB
+		writeChannel     chan *beacon.AnalysisOutput // holds analyzed data
+		writeWg          sync.WaitGroup              // wait for writing to finish
 	}
 )
 
 //newWriter creates a writer object to write AnalysisOutput data to
-//the beacons collection
-func newWriter(db *database.DB, conf *config.Config) *writer {
+//the target beacons collection
+func newWriter(targetCollection string, db *database.DB, conf *config.Config) *writer {
 	return &writer{
-		db:           db,
-		conf:         conf,
-		writeChannel: make(chan *beacon.AnalysisOutput),
+		targetCollection: targetCollection,
+		db:               db,
+		conf:             conf,
+		writeChannel:     make(chan *beacon.AnalysisOutput),
 	}
 }
 
@@ -44,14 +46,11 @@ func (w *writer) close() {
 func (w *writer) start() {
 	w.writeWg.Add(1)
 	go func() {
-		ssn := w.db.Session.Copy()
-		defer ssn.Close()
-
-		//TODO: Implement bulk writes
+		bulk := w.db.NewBulkWriter(w.targetCollection, w.conf.S.Bro.ImportBuffer)
 		for data := range w.writeChannel {
-			ssn.DB(w.db.GetSelectedDB()).C(w.conf.T.Beacon.BeaconTable).Insert(data)
-
+			bulk.Insert(data)
 		}
+		bulk.Close()
 		w.writeWg.Done()
 	}()
 }
@@ -13,7 +13,7 @@ import (
 
 func TestWriter(t *testing.T) {
 	res := resources.InitIntegrationTestingResources(t)
-	writer := newWriter(res.DB, res.Config)
+	writer := newWriter(res.Config.T.Beacon.BeaconTable, res.DB, res.Config)
 	writer.start()
 	for i := range writerTestDataList {
 		writer.write(&writerTestDataList[i])
@@ -3,9 +3,11 @@ package blacklist
 import (
 	"sync"
 
+	"github.com/activecm/rita/analysis/dhcp"
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/datatypes/blacklist"
+	dhcpData "github.com/activecm/rita/datatypes/dhcp"
 	"github.com/globalsign/mgo/bson"
 )
 
@@ -14,6 +16,7 @@ type (
 		source           bool
 		db               *database.DB                    // provides access to MongoDB
 		conf             *config.Config                  // contains details needed to access MongoDB
+		timeline         *dhcp.Timeline                  // finds the devices which held the target addresses
 		analyzedCallback func(interface{})               // called on each analyzed result
 		closedCallback   func()                          // called when .close() is called and no more calls to analyzedCallback will be made
 		analysisChannel  chan *blacklist.IPAnalysisInput // holds unanalyzed data
@@ -24,6 +27,7 @@ type (
 		source           bool
 		db               *database.DB                          // provides access to MongoDB
 		conf             *config.Config                        // contains details needed to access MongoDB
+		timeline         *dhcp.Timeline                        // finds the devices which held the target addresses
 		analyzedCallback func(interface{})                     // called on each analyzed result
 		closedCallback   func()                                // called when .close() is called and no more calls to analyzedCallback will be made
 		analysisChannel  chan *blacklist.HostnameAnalysisInput // holds unanalyzed data
@@ -32,12 +36,13 @@ type (
 )
 
 //newIPAnalyzer creates a new analyzer for comparing IPs against blacklist
-func newIPAnalyzer(source bool, db *database.DB, conf *config.Config, analyzedCallback func(interface{}), closedCallback func()) *analyzerIP {
+func newIPAnalyzer(source bool, db *database.DB, conf *config.Config, timeline *dhcp.Timeline, analyzedCallback func(interface{}), closedCallback func()) *analyzerIP {
 
 	return &analyzerIP{
 		source:           source,
 		db:               db,
 		conf:             conf,
+		timeline:         timeline,
 		analyzedCallback: analyzedCallback,
 		closedCallback:   closedCallback,
 		analysisChannel:  make(chan *blacklist.IPAnalysisInput),
@@ -45,11 +50,12 @@ func newIPAnalyzer(source bool, db *database.DB, conf *config.Config, analyzedCa
 }
 
 //newHostnameAnalyzer creates a new analyzer for comparing hostnames against blacklist
-func newHostnameAnalyzer(db *database.DB, conf *config.Config, analyzedCallback func(interface{}), closedCallback func()) *analyzerHostname {
+func newHostnameAnalyzer(db *database.DB, conf *config.Config, timeline *dhcp.Timeline, analyzedCallback func(interface{}), closedCallback func()) *analyzerHostname {
 
 	return &analyzerHostname{
 		db:               db,
 		conf:             conf,
+		timeline:         timeline,
 		analyzedCallback: analyzedCallback,
 		closedCallback:   closedCallback,
 		analysisChannel:  make(chan *blacklist.HostnameAnalysisInput),
@@ -103,6 +109,8 @@ func (a *analyzerIP) start() {
 					TotalBytes:        data.TotalBytes,
 					AverageBytes:      data.AverageBytes,
 					Targets:           data.Targets,
+					TargetDevices:     targetDevices(a.timeline, data.TargetTimes),
+					Sensors:           data.Sensors,
 				}
 
 				// Get all blacklists result was found on
@@ -182,14 +190,16 @@ func (a *analyzerHostname) start() {
 					output.Lists = append(output.Lists, entry.List)
 				}
 
-				uconnsQuery := getUniqueHostnameFromUconnPipeline(data.IPs)
+				uconnsQuery := getUniqueHostnameFromUconnPipeline(a.conf, data.IPs)
 
 				var uconnRes struct {
-					Connections       int      `bson:"conn_count"`
-					UniqueConnections int      `bson:"uconn_count"`
-					TotalBytes        int      `bson:"total_bytes"`
-					AverageBytes      int      `bson:"avg_bytes"`
-					Targets           []string `bson:"targets"`
+					Connections       int                    `bson:"conn_count"`
+					UniqueConnections int                    `bson:"uconn_count"`
+					TotalBytes        int                    `bson:"total_bytes"`
+					AverageBytes      int                    `bson:"avg_bytes"`
+					Targets           []string               `bson:"targets"`
+					TargetTimes       []blacklist.TargetTime `bson:"target_times"`
+					Sensors           []string               `bson:"sensors"`
 				}
 
 				_ = ssn.DB(a.db.GetSelectedDB()).C(a.conf.T.Structure.UniqueConnTable).Pipe(uconnsQuery).One(&uconnRes)
@@ -199,6 +209,8 @@ func (a *analyzerHostname) start() {
 				output.TotalBytes = uconnRes.TotalBytes
 				output.AverageBytes = uconnRes.AverageBytes
 				output.Targets = uconnRes.Targets
+				output.TargetDevices = targetDevices(a.timeline, uconnRes.TargetTimes)
+				output.Sensors = uconnRes.Sensors
 
 				a.analyzedCallback(output)
 			} else {
@@ -209,3 +221,19 @@ func (a *analyzerHostname) start() {
 		a.analysisWg.Done()
 	}()
 }
+
+//targetDevices finds the devices which held the target addresses while
+//they contacted a blacklisted host
+func targetDevices(timeline *dhcp.Timeline, targets []blacklist.TargetTime) []dhcpData.Lease {
+	var devices []dhcpData.Lease
+	found := make(map[dhcpData.Lease]bool)
+	for _, target := range targets {
+		for _, lease := range timeline.Devices(target.IP, target.First, target.Last) {
+			if !found[lease] {
+				found[lease] = true
+				devices = append(devices, lease)
+			}
+		}
+	}
+	return devices
+}
@@ -3,6 +3,9 @@ package blacklist
 import (
 	"runtime"
 
+	"github.com/activecm/rita/analysis/dhcp"
+	"github.com/activecm/rita/analysis/structure"
+	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/datatypes/blacklist"
 	"github.com/activecm/rita/resources"
 	"github.com/activecm/rita/util"
@@ -10,13 +13,23 @@ import (
 	"github.com/globalsign/mgo/bson"
 )
 
-func getUniqueIPFromUconnPipeline(field string) []bson.D {
+//flattenSensors merges the sensor lists gathered from each unique connection
+var flattenSensors = bson.M{"$reduce": bson.M{
+	"input":        "$sensors",
+	"initialValue": []interface{}{},
+	"in":           bson.M{"$setUnion": []interface{}{"$$value", "$$this"}},
+}}
+
+//getUniqueIPFromUconnPipeline summarizes the unique connections of each
+//address in the given field. The connections are first merged into host
+//pairs so each peer is counted once.
+func getUniqueIPFromUconnPipeline(conf *config.Config, field string) []bson.D {
 	//nolint: vet
 	targetField := "dst"
 	if field == "dst" {
 		targetField = "src"
 	}
-	return []bson.D{
+	return append(structure.GetUniqueConnectionPairStages(conf), []bson.D{
 		{
 			{"$group", bson.M{
 				"_id":         "$" + field,
@@ -25,30 +38,44 @@ func getUniqueIPFromUconnPipeline(field string) []bson.D {
 				"conn_count":  bson.M{"$sum": "$connection_count"},
 				"uconn_count": bson.M{"$sum": 1},
 				"targets":     bson.M{"$push": "$" + targetField},
+				"sensors":     bson.M{"$push": "$sensors"},
+				"target_times": bson.M{"$push": bson.M{
+					"ip":    "$" + targetField,
+					"first": "$first_ts",
+					"last":  "$last_ts",
+				}},
 			}},
 		},
 		{
 			{"$project", bson.M{
-				"_id":         0,
-				"ip":          "$_id",
-				"total_bytes": 1,
-				"avg_bytes":   1,
-				"conn_count":  1,
-				"uconn_count": 1,
-				"targets":     1,
+				"_id":          0,
+				"ip":           "$_id",
+				"total_bytes":  1,
+				"avg_bytes":    1,
+				"conn_count":   1,
+				"uconn_count":  1,
+				"targets":      1,
+				"target_times": 1,
+				"sensors":      flattenSensors,
 			}},
 		},
-	}
+	}...)
 }
 
-func getUniqueHostnameFromUconnPipeline(hosts []string) []bson.D {
+//getUniqueHostnameFromUconnPipeline summarizes the unique connections to
+//the given addresses of a hostname. The connections are first merged into
+//host pairs so each peer is counted once.
+func getUniqueHostnameFromUconnPipeline(conf *config.Config, hosts []string) []bson.D {
 	//nolint: vet
-	return []bson.D{
+	pipeline := []bson.D{
 		{
 			{"$match", bson.M{
 				"dst": bson.M{"$in": hosts},
 			}},
 		},
+	}
+	pipeline = append(pipeline, structure.GetUniqueConnectionPairStages(conf)...)
+	return append(pipeline, []bson.D{
 		{
 			{"$group", bson.M{
 				"_id":         "$dst",
@@ -57,18 +84,26 @@ func getUniqueHostnameFromUconnPipeline(hosts []string) []bson.D {
 				"conn_count":  bson.M{"$sum": "$connection_count"},
 				"uconn_count": bson.M{"$sum": 1},
 				"targets":     bson.M{"$push": "$src"},
+				"sensors":     bson.M{"$push": "$sensors"},
+				"target_times": bson.M{"$push": bson.M{
+					"ip":    "$src",
+					"first": "$first_ts",
+					"last":  "$last_ts",
+				}},
 			}},
 		},
 		{
 			{"$project", bson.M{
-				"total_bytes": 1,
-				"avg_bytes":   1,
-				"conn_count":  1,
-				"uconn_count": 1,
-				"targets":     1,
+				"total_bytes":  1,
+				"avg_bytes":    1,
+				"conn_count":   1,
+				"uconn_count":  1,
+				"targets":      1,
+				"target_times": 1,
+				"sensors":      flattenSensors,
 			}},
 		},
-	}
+	}...)
 }
 
 //buildBlacklistedIPs builds a set of blacklisted ips from the
@@ -84,7 +119,7 @@ func buildBlacklistedIPs(res *resources.Resources, source bool) {
 	var ipIter *mgo.Iter
 	if source {
 		collectionName = res.Config.T.Blacklisted.SourceIPsTable
-		uniqueSourcesAggregation := getUniqueIPFromUconnPipeline("src")
+		uniqueSourcesAggregation := getUniqueIPFromUconnPipeline(res.Config, "src")
 		ipIter = res.DB.AggregateCollection(
 			res.Config.T.Structure.UniqueConnTable,
 			ssn,
@@ -92,7 +127,7 @@ func buildBlacklistedIPs(res *resources.Resources, source bool) {
 		)
 	} else {
 		collectionName = res.Config.T.Blacklisted.DestIPsTable
-		uniqueDestAggregation := getUniqueIPFromUconnPipeline("dst")
+		uniqueDestAggregation := getUniqueIPFromUconnPipeline(res.Config, "dst")
 		ipIter = res.DB.AggregateCollection(
 			res.Config.T.Structure.UniqueConnTable,
 			ssn,
@@ -147,6 +182,7 @@ func checkRitaBlacklistIPs(ips *mgo.Iter, res *resources.Resources, source bool,
 		source,
 		res.DB,
 		res.Config,
+		dhcp.LoadTimeline(res),
 		writerWorker.write,
 		writerWorker.close,
 	)
@@ -158,12 +194,14 @@ func checkRitaBlacklistIPs(ips *mgo.Iter, res *resources.Resources, source bool,
 	}
 
 	var uconnRes struct {
-		IP                string   `bson:"ip"`
-		Connections       int      `bson:"conn_count"`
-		UniqueConnections int      `bson:"uconn_count"`
-		TotalBytes        int      `bson:"total_bytes"`
-		AverageBytes      int      `bson:"avg_bytes"`
-		Targets           []string `bson:"targets"`
+		IP                string                 `bson:"ip"`
+		Connections       int                    `bson:"conn_count"`
+		UniqueConnections int                    `bson:"uconn_count"`
+		TotalBytes        int                    `bson:"total_bytes"`
+		AverageBytes      int                    `bson:"avg_bytes"`
+		Targets           []string               `bson:"targets"`
+		TargetTimes       []blacklist.TargetTime `bson:"target_times"`
+		Sensors           []string               `bson:"sensors"`
 	}
 
 	for ips.Next(&uconnRes) {
@@ -175,6 +213,8 @@ func checkRitaBlacklistIPs(ips *mgo.Iter, res *resources.Resources, source bool,
 			TotalBytes:        uconnRes.TotalBytes,
 			AverageBytes:      uconnRes.AverageBytes,
 			Targets:           uconnRes.Targets,
+			TargetTimes:       uconnRes.TargetTimes,
+			Sensors:           uconnRes.Sensors,
 		}
 		analyzerWorker.analyzeIP(newInput)
 	}
@@ -188,6 +228,7 @@ func checkRitaBlacklistHostnames(ips *mgo.Iter, res *resources.Resources, collec
 	analyzerWorker := newHostnameAnalyzer(
 		res.DB,
 		res.Config,
+		dhcp.LoadTimeline(res),
 		writerWorker.write,
 		writerWorker.close,
 	)
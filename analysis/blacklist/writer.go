@@ -42,12 +42,11 @@ func (w *writer) close() {
 func (w *writer) start() {
 	w.writeWg.Add(1)
 	go func() {
-		ssn := w.db.Session.Copy()
-		defer ssn.Close()
-
+		bulk := w.db.NewBulkWriter(w.targetCollection, w.conf.S.Bro.ImportBuffer)
 		for data := range w.writeChannel {
-			ssn.DB(w.db.GetSelectedDB()).C(w.targetCollection).Insert(data)
+			bulk.Insert(data)
 		}
+		bulk.Close()
 		w.writeWg.Done()
 	}()
 }
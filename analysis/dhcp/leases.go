@@ -0,0 +1,197 @@
+package dhcp
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/activecm/rita/datatypes/dhcp"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	mgo "github.com/globalsign/mgo"
+)
+
+//defaultLeaseTime is the length of a lease in seconds when the DHCP log
+//does not record it
+const defaultLeaseTime = 24 * 60 * 60
+
+type (
+	//leaseBuilder turns the DHCP acknowledgements, in the order they were
+	//sent, into a timeline of leases. Renewals by the device holding an
+	//address extend its lease. A lease ends early if the address is given
+	//to another device.
+	leaseBuilder struct {
+		current  map[string]*dhcp.Lease
+		finished []dhcp.Lease
+	}
+
+	//Timeline finds the devices which held IP addresses over time
+	Timeline struct {
+		leases map[string][]dhcp.Lease
+	}
+)
+
+// BuildLeaseCollection builds the dhcp leases collection from the DHCP
+// log. Each lease records the device which held an IP address and when
+// it held the address.
+func BuildLeaseCollection(res *resources.Resources) {
+	dhcpCollection := res.Config.T.Structure.DHCPTable
+	if !res.DB.CollectionExists(dhcpCollection) {
+		return
+	}
+
+	leaseCollection := res.Config.T.Structure.LeaseTable
+	keys := []mgo.Index{
+		{Key: []string{"ip", "start"}},
+		{Key: []string{"mac"}},
+	}
+	err := res.DB.CreateCollection(leaseCollection, keys)
+	if err != nil {
+		res.Log.Error("Failed: ", leaseCollection, err.Error())
+		return
+	}
+
+	ssn := res.DB.Session.Copy()
+	defer ssn.Close()
+
+	var entry struct {
+		TimeStamp    int64    `bson:"ts"`
+		MAC          string   `bson:"mac"`
+		HostName     string   `bson:"host_name"`
+		ClientFQDN   string   `bson:"client_fqdn"`
+		AssignedAddr string   `bson:"assigned_addr"`
+		AssignedIP   string   `bson:"assigned_ip"`
+		LeaseTime    float64  `bson:"lease_time"`
+		MsgTypes     []string `bson:"msg_types"`
+	}
+
+	builder := newLeaseBuilder()
+	iter := ssn.DB(res.DB.GetSelectedDB()).C(dhcpCollection).Find(nil).Sort("ts").Iter()
+	for iter.Next(&entry) {
+		//bro 2.6 logs every exchange, but only acknowledgements grant leases
+		if len(entry.MsgTypes) > 0 && !util.StringInSlice("ACK", entry.MsgTypes) {
+			continue
+		}
+		ip := entry.AssignedAddr
+		if ip == "" {
+			ip = entry.AssignedIP
+		}
+		hostname := entry.HostName
+		if hostname == "" {
+			hostname = entry.ClientFQDN
+		}
+		builder.add(entry.TimeStamp, ip, entry.MAC, hostname, int64(entry.LeaseTime))
+
+		entry.MsgTypes = nil
+		entry.HostName, entry.ClientFQDN = "", ""
+		entry.AssignedAddr, entry.AssignedIP = "", ""
+		entry.LeaseTime = 0
+	}
+	if err := iter.Close(); err != nil {
+		res.Log.Error("Failed reading ", dhcpCollection, err.Error())
+	}
+
+	writer := res.DB.NewBulkWriter(leaseCollection, res.Config.S.Bro.ImportBuffer)
+	for _, lease := range builder.leases() {
+		writer.Insert(lease)
+	}
+	writer.Close()
+}
+
+//newLeaseBuilder creates an empty leaseBuilder
+func newLeaseBuilder() *leaseBuilder {
+	return &leaseBuilder{current: make(map[string]*dhcp.Lease)}
+}
+
+//add records an acknowledgement granting the IP address to the device
+//for leaseTime seconds. Acknowledgements must be added in the order they
+//were sent.
+func (b *leaseBuilder) add(ts int64, ip string, mac string, hostname string, leaseTime int64) {
+	if ip == "" || mac == "" {
+		return
+	}
+	mac = strings.ToLower(mac)
+	if leaseTime <= 0 {
+		leaseTime = defaultLeaseTime
+	}
+
+	lease, ok := b.current[ip]
+	if ok && lease.MAC == mac && ts <= lease.End {
+		if ts+leaseTime > lease.End {
+			lease.End = ts + leaseTime
+		}
+		if hostname != "" {
+			lease.Hostname = hostname
+		}
+		return
+	}
+
+	if ok {
+		if lease.End > ts {
+			lease.End = ts
+		}
+		b.finished = append(b.finished, *lease)
+	}
+	b.current[ip] = &dhcp.Lease{
+		IP:       ip,
+		MAC:      mac,
+		Hostname: hostname,
+		Start:    ts,
+		End:      ts + leaseTime,
+	}
+}
+
+//leases returns every lease sorted by IP address and start time
+func (b *leaseBuilder) leases() []dhcp.Lease {
+	leases := append([]dhcp.Lease(nil), b.finished...)
+	for _, lease := range b.current {
+		leases = append(leases, *lease)
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		if leases[i].IP != leases[j].IP {
+			return leases[i].IP < leases[j].IP
+		}
+		return leases[i].Start < leases[j].Start
+	})
+	return leases
+}
+
+//NewTimeline creates a Timeline from a list of leases
+func NewTimeline(leases []dhcp.Lease) *Timeline {
+	timeline := &Timeline{leases: make(map[string][]dhcp.Lease)}
+	for _, lease := range leases {
+		timeline.leases[lease.IP] = append(timeline.leases[lease.IP], lease)
+	}
+	return timeline
+}
+
+//LoadTimeline creates a Timeline from the dhcp leases collection of the
+//selected database. The Timeline is empty if no DHCP logs were imported.
+func LoadTimeline(res *resources.Resources) *Timeline {
+	ssn := res.DB.Session.Copy()
+	defer ssn.Close()
+
+	var leases []dhcp.Lease
+	ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.Structure.LeaseTable).
+		Find(nil).Sort("ip", "start").All(&leases)
+	return NewTimeline(leases)
+}
+
+//Devices returns the leases on the IP address which were held at any
+//time between start and end, inclusive
+func (t *Timeline) Devices(ip string, start int64, end int64) []dhcp.Lease {
+	if t == nil {
+		return nil
+	}
+	var held []dhcp.Lease
+	for _, lease := range t.leases[ip] {
+		if lease.Start <= end && lease.End >= start {
+			held = append(held, lease)
+		}
+	}
+	return held
+}
+
+//Empty returns true if the Timeline holds no leases
+func (t *Timeline) Empty() bool {
+	return t == nil || len(t.leases) == 0
+}
@@ -0,0 +1,48 @@
+package dhcp
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/datatypes/dhcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildLeases(t *testing.T) {
+	builder := newLeaseBuilder()
+	builder.add(100, "10.0.0.5", "AA:BB:CC:00:00:01", "laptop", 1000)
+	//renewal extends the lease
+	builder.add(600, "10.0.0.5", "aa:bb:cc:00:00:01", "", 1000)
+	//another device takes the address before the lease runs out
+	builder.add(1200, "10.0.0.5", "aa:bb:cc:00:00:02", "phone", 0)
+	builder.add(150, "10.0.0.6", "aa:bb:cc:00:00:03", "", 500)
+	//the same device returns after its lease ran out
+	builder.add(900, "10.0.0.6", "aa:bb:cc:00:00:03", "", 500)
+	builder.add(300, "", "aa:bb:cc:00:00:04", "", 500)
+
+	assert.Equal(t, []dhcp.Lease{
+		{IP: "10.0.0.5", MAC: "aa:bb:cc:00:00:01", Hostname: "laptop", Start: 100, End: 1200},
+		{IP: "10.0.0.5", MAC: "aa:bb:cc:00:00:02", Hostname: "phone", Start: 1200, End: 1200 + defaultLeaseTime},
+		{IP: "10.0.0.6", MAC: "aa:bb:cc:00:00:03", Start: 150, End: 650},
+		{IP: "10.0.0.6", MAC: "aa:bb:cc:00:00:03", Start: 900, End: 1400},
+	}, builder.leases())
+}
+
+func TestTimelineDevices(t *testing.T) {
+	laptop := dhcp.Lease{IP: "10.0.0.5", MAC: "aa:bb:cc:00:00:01", Hostname: "laptop", Start: 100, End: 1200}
+	phone := dhcp.Lease{IP: "10.0.0.5", MAC: "aa:bb:cc:00:00:02", Start: 1200, End: 2000}
+	timeline := NewTimeline([]dhcp.Lease{laptop, phone})
+
+	assert.Equal(t, []dhcp.Lease{laptop}, timeline.Devices("10.0.0.5", 200, 300))
+	assert.Equal(t, []dhcp.Lease{laptop, phone}, timeline.Devices("10.0.0.5", 1000, 1500))
+	assert.Equal(t, []dhcp.Lease{phone}, timeline.Devices("10.0.0.5", 1500, 3000))
+	assert.Empty(t, timeline.Devices("10.0.0.5", 2500, 3000))
+	assert.Empty(t, timeline.Devices("10.0.0.6", 200, 300))
+
+	var missing *Timeline
+	assert.Empty(t, missing.Devices("10.0.0.5", 200, 300))
+	assert.True(t, missing.Empty())
+	assert.False(t, timeline.Empty())
+
+	assert.Equal(t, "laptop (aa:bb:cc:00:00:01)", laptop.Device())
+	assert.Equal(t, "aa:bb:cc:00:00:02", phone.Device())
+}
@@ -27,7 +27,7 @@ func buildExplodedDNSVistedCounts(res *resources.Resources) {
 	res.DB.MapReduceCollection(
 		res.Config.T.Structure.DNSTable,
 		mgo.MapReduce{
-			Map:      getExplodedDNSMapper("query"),
+			Map:      getExplodedDNSMapper("query", "this.sensor ? [this.sensor] : []"),
 			Reduce:   getExplodedDNSReducer(),
 			Finalize: getExplodedDNSFinalizer(),
 			Out:      bson.M{"replace": tempVistedCountCollName},
@@ -41,7 +41,7 @@ func buildExplodedDNSUniqSubdomains(res *resources.Resources) {
 	res.DB.MapReduceCollection(
 		tempVistedCountCollName,
 		mgo.MapReduce{
-			Map:      getExplodedDNSMapper("_id"),
+			Map:      getExplodedDNSMapper("_id", "this.value.sensors"),
 			Reduce:   getExplodedDNSReducer(),
 			Finalize: getExplodedDNSFinalizer(),
 			Out:      bson.M{"replace": tempUniqSubdomainCollName},
@@ -55,6 +55,7 @@ func zipExplodedDNSResults(res *resources.Resources) {
 	indexes := []mgo.Index{
 		{Key: []string{"domain"}, Unique: true},
 		{Key: []string{"subdomains"}},
+		{Key: []string{"sensors"}},
 	}
 	res.DB.CreateCollection(res.Config.T.DNS.ExplodedDNSTable, indexes)
 	res.DB.AggregateCollection(tempVistedCountCollName, ssn,
@@ -77,6 +78,7 @@ func zipExplodedDNSResults(res *resources.Resources) {
 					{"domain", "$_id"},
 					{"visited", "$value.result"},
 					{"subdomains", "$subdomains.value.result"},
+					{"sensors", "$value.sensors"},
 				}},
 			},
 			{
@@ -90,11 +92,13 @@ func zipExplodedDNSResults(res *resources.Resources) {
 
 // getExplodedDNSMapper creates on O(N) map reduce job which
 // grabs all of the superdomains from a fqdn e.g. maps.google.com produces
-// maps.google.com, google.com, and com
-func getExplodedDNSMapper(nameField string) string {
+// maps.google.com, google.com, and com. Each superdomain is emitted with
+// the sensors given by the sensors expression.
+func getExplodedDNSMapper(nameField string, sensors string) string {
 	return `function() {
 		var dots = [];
 		var domain = this.` + nameField + `.toLowerCase();
+		var sensors = ` + sensors + `;
 		//find all subdomain separators
 		for (i = 0; i < domain.length; i++) {
 				if (domain[i] == '.') {
@@ -102,23 +106,34 @@ func getExplodedDNSMapper(nameField string) string {
 				}
 		}
 		//emit all of the "super domains"
-		emit(domain, 1);
+		emit(domain, {count: 1, sensors: sensors});
 		for (i = 0; i < dots.length; i++) {
-				emit(domain.substring(dots[i] + 1), 1);
+				emit(domain.substring(dots[i] + 1), {count: 1, sensors: sensors});
 		}
 	}`
 }
 
+// getExplodedDNSReducer sums the counts and merges the sensors emitted for
+// a domain
 func getExplodedDNSReducer() string {
-	return `function(subdomain, countArr) {
-						return Array.sum(countArr);
+	return `function(subdomain, values) {
+						var reduced = {count: 0, sensors: []};
+						values.forEach(function(value) {
+								reduced.count += value.count;
+								value.sensors.forEach(function(sensor) {
+										if (reduced.sensors.indexOf(sensor) == -1) {
+												reduced.sensors.push(sensor);
+										}
+								});
+						});
+						return reduced;
 					}`
 }
 
 func getExplodedDNSFinalizer() string {
-	return `function(subdomain, count) {
+	return `function(subdomain, reduced) {
 						// For some reason this works
-						return {result: new NumberLong(count)};
+						return {result: new NumberLong(reduced.count), sensors: reduced.sensors};
 						// But return new NumberLong(count) doesn't...
 					}`
 }
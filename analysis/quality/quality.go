@@ -0,0 +1,268 @@
+package quality
+
+import (
+	"sort"
+	"time"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+//hour is the length in seconds of the buckets records are counted in
+const hour = 3600
+
+// BuildQualityReport counts the records of each log type imported into the
+// target database by sensor and hour, looks for outages and clock problems,
+// and stores the results in the database's MetaDB record. Outages are only
+// looked for in the monitored log types.
+func BuildQualityReport(res *resources.Resources, targetDB string) {
+	ssn := res.DB.Session.Copy()
+	defer ssn.Close()
+
+	names, err := ssn.DB(targetDB).CollectionNames()
+	if err != nil {
+		res.Log.WithFields(log.Fields{
+			"database": targetDB,
+			"error":    err.Error(),
+		}).Error("Could not list collections for the data quality report")
+		return
+	}
+
+	report := database.QualityReport{Created: time.Now()}
+	logTypes := []string{
+		res.Config.T.Structure.ConnTable,
+		res.Config.T.Structure.DNSTable,
+		res.Config.T.Structure.HTTPTable,
+		res.Config.T.Structure.DHCPTable,
+		res.Config.T.Structure.SSLTable,
+	}
+	for _, collection := range logTypes {
+		if !util.StringInSlice(collection, names) {
+			continue
+		}
+
+		volumes, first, last := countHours(ssn.DB(targetDB).C(collection))
+		if len(volumes) == 0 {
+			continue
+		}
+		report.Logs = append(report.Logs, volumes...)
+		if report.Start == 0 || first < report.Start {
+			report.Start = first
+		}
+		if last > report.End {
+			report.End = last
+		}
+	}
+
+	for _, volume := range report.Logs {
+		if !util.StringInSlice(volume.Collection, res.Config.S.Quality.MonitoredLogs) {
+			continue
+		}
+		report.Gaps = append(report.Gaps, findGaps(volume, report.Start,
+			report.End, res.Config.S.Quality.MinimumGap)...)
+		report.Drops = append(report.Drops, findDrops(volume, report.Start,
+			report.End, res.Config.S.Quality)...)
+	}
+
+	files, err := res.MetaDB.GetFiles()
+	if err == nil {
+		report.Skews = findSkews(files, targetDB, res.Config.S.Quality.ClockSkew)
+	}
+
+	if len(report.Gaps) > 0 || len(report.Drops) > 0 || len(report.Skews) > 0 {
+		res.Log.WithFields(log.Fields{
+			"database":     targetDB,
+			"gaps":         len(report.Gaps),
+			"drops":        len(report.Drops),
+			"skewed_files": len(report.Skews),
+		}).Warning("Found data quality problems. Run show-data-quality for details")
+	}
+
+	res.MetaDB.SetQualityReport(targetDB, report)
+}
+
+//countHours counts the records in a collection by sensor and hour. The
+//volumes are ordered by sensor. The earliest and latest timestamps in the
+//collection are also returned.
+func countHours(collection *mgo.Collection) ([]database.LogVolume, int64, int64) {
+	iter := collection.Pipe([]bson.D{
+		{
+			{"$group", bson.M{
+				"_id": bson.M{
+					"sensor": "$sensor",
+					"hour": bson.M{"$subtract": []interface{}{
+						"$ts", bson.M{"$mod": []interface{}{"$ts", hour}},
+					}},
+				},
+				"records": bson.M{"$sum": 1},
+				"first":   bson.M{"$min": "$ts"},
+				"last":    bson.M{"$max": "$ts"},
+			}},
+		},
+		{
+			{"$sort", bson.D{{"_id.sensor", 1}, {"_id.hour", 1}}},
+		},
+	}).AllowDiskUse().Iter()
+
+	var bucket struct {
+		ID struct {
+			Sensor string `bson:"sensor"`
+			Hour   int64  `bson:"hour"`
+		} `bson:"_id"`
+		Records int64 `bson:"records"`
+		First   int64 `bson:"first"`
+		Last    int64 `bson:"last"`
+	}
+
+	var volumes []database.LogVolume
+	var first, last int64
+	for iter.Next(&bucket) {
+		if len(volumes) == 0 || volumes[len(volumes)-1].Sensor != bucket.ID.Sensor {
+			volumes = append(volumes, database.LogVolume{
+				Collection: collection.Name,
+				Sensor:     bucket.ID.Sensor,
+			})
+		}
+		volume := &volumes[len(volumes)-1]
+		volume.Hours = append(volume.Hours, database.HourCount{
+			Hour:    bucket.ID.Hour,
+			Records: bucket.Records,
+		})
+		volume.Records += bucket.Records
+		if first == 0 || bucket.First < first {
+			first = bucket.First
+		}
+		if bucket.Last > last {
+			last = bucket.Last
+		}
+	}
+	iter.Close()
+	return volumes, first, last
+}
+
+//hourlyCounts lists the records logged in every hour from the hour
+//holding start to the hour holding end, including hours without records
+func hourlyCounts(volume database.LogVolume, start int64, end int64) []int64 {
+	firstHour := start - start%hour
+	counts := make([]int64, (end-end%hour-firstHour)/hour+1)
+	for _, count := range volume.Hours {
+		index := (count.Hour - firstHour) / hour
+		if index >= 0 && index < int64(len(counts)) {
+			counts[index] = count.Records
+		}
+	}
+	return counts
+}
+
+//findGaps finds the runs of at least minimum hours between start and end
+//in which no records of the log type were logged by the volume's sensor
+func findGaps(volume database.LogVolume, start int64, end int64, minimum int) []database.VolumeGap {
+	if minimum < 1 {
+		minimum = 1
+	}
+	firstHour := start - start%hour
+	counts := hourlyCounts(volume, start, end)
+
+	var gaps []database.VolumeGap
+	runStart := -1
+	for i := 0; i <= len(counts); i++ {
+		if i < len(counts) && counts[i] == 0 {
+			if runStart < 0 {
+				runStart = i
+			}
+			continue
+		}
+		if runStart >= 0 && i-runStart >= minimum {
+			gaps = append(gaps, database.VolumeGap{
+				Collection: volume.Collection,
+				Sensor:     volume.Sensor,
+				Start:      firstHour + int64(runStart)*hour,
+				End:        firstHour + int64(i)*hour,
+			})
+		}
+		runStart = -1
+	}
+	return gaps
+}
+
+//findDrops finds the hours which hold records of the log type but fewer
+//than DropRatio times the median of the preceding DropWindow hours. The
+//last hour is compared against the part of the hour which was observed.
+func findDrops(volume database.LogVolume, start int64, end int64, conf config.QualityStaticCfg) []database.VolumeDrop {
+	firstHour := start - start%hour
+	counts := hourlyCounts(volume, start, end)
+
+	var drops []database.VolumeDrop
+	for i := 1; i < len(counts); i++ {
+		if counts[i] == 0 {
+			//empty hours are reported as gaps
+			continue
+		}
+
+		windowStart := i - conf.DropWindow
+		if windowStart < 0 || conf.DropWindow < 1 {
+			windowStart = 0
+		}
+		expected := median(counts[windowStart:i])
+		if expected < float64(conf.DropVolume) {
+			continue
+		}
+		if i == len(counts)-1 {
+			expected *= float64(end%hour+1) / hour
+		}
+
+		if float64(counts[i]) < conf.DropRatio*expected {
+			drops = append(drops, database.VolumeDrop{
+				Collection: volume.Collection,
+				Sensor:     volume.Sensor,
+				Hour:       firstHour + int64(i)*hour,
+				Records:    counts[i],
+				Expected:   expected,
+			})
+		}
+	}
+	return drops
+}
+
+//findSkews finds the files imported into the target database whose last
+//record was logged more than threshold seconds from the time the file was
+//last modified
+func findSkews(files []fpt.IndexedFile, targetDB string, threshold int64) []database.ClockSkew {
+	var skews []database.ClockSkew
+	for _, file := range files {
+		if file.TargetDatabase != targetDB || file.LastTs == 0 || file.ModTime.IsZero() {
+			continue
+		}
+		skew := file.ModTime.Unix() - file.LastTs
+		if skew > threshold || -skew > threshold {
+			skews = append(skews, database.ClockSkew{
+				Path:     file.Path,
+				Sensor:   file.Sensor,
+				LastTs:   file.LastTs,
+				Modified: file.ModTime,
+				Skew:     skew,
+			})
+		}
+	}
+	return skews
+}
+
+//median returns the median of the values
+func median(values []int64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return float64(sorted[middle])
+	}
+	return float64(sorted[middle-1]+sorted[middle]) / 2
+}
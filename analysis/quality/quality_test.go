@@ -0,0 +1,89 @@
+package quality
+
+import (
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	"github.com/stretchr/testify/assert"
+)
+
+//day is the start of an hour used as the beginning of the test data
+const day = 1514764800
+
+func hours(counts ...int64) database.LogVolume {
+	volume := database.LogVolume{Collection: "conn"}
+	for i, count := range counts {
+		if count > 0 {
+			volume.Hours = append(volume.Hours, database.HourCount{
+				Hour:    day + int64(i)*hour,
+				Records: count,
+			})
+			volume.Records += count
+		}
+	}
+	return volume
+}
+
+func TestFindGaps(t *testing.T) {
+	volume := hours(10, 0, 0, 12, 0, 9)
+	end := int64(day + 6*hour - 1)
+
+	assert.Equal(t, []database.VolumeGap{
+		{Collection: "conn", Start: day + hour, End: day + 3*hour},
+		{Collection: "conn", Start: day + 4*hour, End: day + 5*hour},
+	}, findGaps(volume, day+100, end, 1))
+
+	assert.Equal(t, []database.VolumeGap{
+		{Collection: "conn", Start: day + hour, End: day + 3*hour},
+	}, findGaps(volume, day+100, end, 2))
+
+	//hours after the last record of the log type are gaps as well
+	assert.Equal(t, []database.VolumeGap{
+		{Collection: "conn", Start: day + 6*hour, End: day + 8*hour},
+	}, findGaps(hours(10, 10, 10, 10, 10, 10), day, end+2*hour, 2))
+
+	//gaps name the sensor which stopped sending records
+	volume.Sensor = "sensor1"
+	assert.Equal(t, []database.VolumeGap{
+		{Collection: "conn", Sensor: "sensor1", Start: day + hour, End: day + 3*hour},
+	}, findGaps(volume, day+100, end, 2))
+}
+
+func TestFindDrops(t *testing.T) {
+	conf := config.QualityStaticCfg{DropRatio: 0.25, DropWindow: 3, DropVolume: 100}
+
+	volume := hours(1000, 1100, 900, 100, 1000, 50)
+	drops := findDrops(volume, day, day+6*hour-1, conf)
+	assert.Equal(t, []database.VolumeDrop{
+		{Collection: "conn", Hour: day + 3*hour, Records: 100, Expected: 1000},
+		{Collection: "conn", Hour: day + 5*hour, Records: 50, Expected: 900},
+	}, drops)
+
+	//the last hour is compared against the part of the hour observed
+	volume = hours(1000, 1000, 1000, 200)
+	assert.Empty(t, findDrops(volume, day, day+3*hour+hour/2-1, conf))
+
+	//quiet logs are not reported
+	volume = hours(40, 50, 2)
+	assert.Empty(t, findDrops(volume, day, day+3*hour-1, conf))
+}
+
+func TestFindSkews(t *testing.T) {
+	files := []fpt.IndexedFile{
+		{Path: "ok.log", TargetDatabase: "db", LastTs: day, ModTime: time.Unix(day+60, 0)},
+		{Path: "behind.log", TargetDatabase: "db", LastTs: day, ModTime: time.Unix(day+3*hour, 0)},
+		{Path: "ahead.log", TargetDatabase: "db", LastTs: day + 3*hour, ModTime: time.Unix(day, 0)},
+		{Path: "other.log", TargetDatabase: "other", LastTs: day, ModTime: time.Unix(day+3*hour, 0)},
+		{Path: "unknown.log", TargetDatabase: "db", ModTime: time.Unix(day+3*hour, 0)},
+	}
+
+	skews := findSkews(files, "db", 2*hour)
+	assert.Len(t, skews, 2)
+	assert.Equal(t, "behind.log", skews[0].Path)
+	assert.Equal(t, int64(3*hour), skews[0].Skew)
+	assert.Equal(t, "ahead.log", skews[1].Path)
+	assert.Equal(t, int64(-3*hour), skews[1].Skew)
+}
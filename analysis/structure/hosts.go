@@ -1,9 +1,9 @@
 package structure
 
 import (
-	"fmt"
 	"net"
 
+	"github.com/activecm/rita/analysis/dhcp"
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/datatypes/structure"
@@ -47,9 +47,10 @@ func getHosts(res *resources.Resources, conf *config.Config, sourceCollection st
 	session := res.DB.Session.Copy()
 	defer session.Close()
 
-	// Aggregation to populate the hosts collection
+	// Aggregation to populate the hosts collection. The unique connections
+	// are first merged into host pairs so each peer is counted once.
 	// nolint: vet
-	uconnsFindQuery := []bson.D{
+	uconnsFindQuery := append(GetUniqueConnectionPairStages(conf), []bson.D{
 		{
 			{"$project", bson.D{
 				{"hosts", []interface{}{
@@ -58,12 +59,18 @@ func getHosts(res *resources.Resources, conf *config.Config, sourceCollection st
 						{"local", "$local_src"},
 						{"src", true},
 						{"max_duration", "$max_duration"},
+						{"sensors", "$sensors"},
+						{"first_ts", "$first_ts"},
+						{"last_ts", "$last_ts"},
 					},
 					bson.D{
 						{"ip", "$dst"},
 						{"local", "$local_dst"},
 						{"dst", true},
 						{"max_duration", "$max_duration"},
+						{"sensors", "$sensors"},
+						{"first_ts", "$first_ts"},
+						{"last_ts", "$last_ts"},
 					},
 				}},
 			}},
@@ -86,6 +93,15 @@ func getHosts(res *resources.Resources, conf *config.Config, sourceCollection st
 				{"max_duration", bson.D{
 					{"$max", "$hosts.max_duration"},
 				}},
+				{"sensors", bson.D{
+					{"$push", "$hosts.sensors"},
+				}},
+				{"first_ts", bson.D{
+					{"$min", "$hosts.first_ts"},
+				}},
+				{"last_ts", bson.D{
+					{"$max", "$hosts.last_ts"},
+				}},
 			}},
 		},
 		{
@@ -126,11 +142,23 @@ func getHosts(res *resources.Resources, conf *config.Config, sourceCollection st
 					{"$size", "$dst"},
 				}},
 				{"max_duration", 1},
+				{"first_ts", 1},
+				{"last_ts", 1},
+				// Flatten the sensor lists gathered from each unique connection
+				{"sensors", bson.D{
+					{"$reduce", bson.D{
+						{"input", "$sensors"},
+						{"initialValue", []interface{}{}},
+						{"in", bson.D{
+							{"$setUnion", []interface{}{"$$value", "$$this"}},
+						}},
+					}},
+				}},
 			}},
 		},
 		// Instead of sending this output directly to a new collection,
 		// we need to iterate in order to convert IPv4 strings to binary
-	}
+	}...)
 
 	var queryRes struct {
 		ID          bson.ObjectId `bson:"_id,omitempty"`
@@ -140,8 +168,14 @@ func getHosts(res *resources.Resources, conf *config.Config, sourceCollection st
 		CountSrc    int32         `bson:"count_src"`
 		CountDst    int32         `bson:"count_dst"`
 		MaxDuration float32       `bson:"max_duration"`
+		Sensors     []string      `bson:"sensors"`
+		FirstTs     int64         `bson:"first_ts"`
+		LastTs      int64         `bson:"last_ts"`
 	}
 
+	// label the hosts with the devices which held their addresses
+	timeline := dhcp.LoadTimeline(res)
+
 	// execute query
 	uconnIter := res.DB.AggregateCollection(sourceCollection, session, uconnsFindQuery)
 
@@ -156,6 +190,8 @@ func getHosts(res *resources.Resources, conf *config.Config, sourceCollection st
 			CountSrc:    queryRes.CountSrc,
 			CountDst:    queryRes.CountDst,
 			MaxDuration: queryRes.MaxDuration,
+			Sensors:     queryRes.Sensors,
+			Devices:     timeline.Devices(queryRes.IP, queryRes.FirstTs, queryRes.LastTs),
 		}
 
 		ip := net.ParseIP(queryRes.IP)
@@ -201,58 +237,10 @@ func getHosts(res *resources.Resources, conf *config.Config, sourceCollection st
 
 // hostWriter inserts host entries into the database in bulk using buffer
 func hostWriter(output []*structure.Host, resDB *database.DB, resConf *config.Config, targetCollection string) {
-
 	// buffer length controls amount of ram used while exporting
-	bufferLen := resConf.S.Bro.ImportBuffer
-
-	// Create a buffer to hold a portion of the results
-	buffer := make([]interface{}, 0, bufferLen)
-	// while we can still iterate through the data add to the buffer
+	writer := resDB.NewBulkWriter(targetCollection, resConf.S.Bro.ImportBuffer)
 	for _, data := range output {
-
-		// if the buffer is full, send to the remote database and clear buffer
-		if len(buffer) == bufferLen {
-
-			err := bulkWriteHosts(buffer, resDB, resConf, targetCollection)
-
-			if err != nil && err.Error() != "invalid BulkError instance: no errors" {
-				fmt.Println(buffer)
-				fmt.Println("write error 2", err)
-			}
-
-			buffer = buffer[:0]
-
-		}
-
-		buffer = append(buffer, data)
-	}
-
-	//send any data left in the buffer to the remote database
-	err := bulkWriteHosts(buffer, resDB, resConf, targetCollection)
-	if err != nil && err.Error() != "invalid BulkError instance: no errors" {
-		fmt.Println(buffer)
-		fmt.Println("write error 2", err)
+		writer.Insert(data)
 	}
-
-}
-
-// bulkWriteHosts uses MongoDB's Bulk API to insert entries into a collection.
-// It also allows out of order writes to speed things up. This is the fastest
-// way we know of to get data into the database.
-func bulkWriteHosts(buffer []interface{}, resDB *database.DB, resConf *config.Config, targetCollection string) error {
-	ssn := resDB.Session.Copy()
-	defer ssn.Close()
-
-	// set up for bulk write to database
-	bulk := ssn.DB(resDB.GetSelectedDB()).C(targetCollection).Bulk()
-	// writes can be sent out of order
-	bulk.Unordered()
-	// inserts everything in the buffer into the bulk write object as a list
-	// of single interfaces
-	bulk.Insert(buffer...)
-
-	// runs all queued operations
-	_, err := bulk.Run()
-
-	return err
+	writer.Close()
 }
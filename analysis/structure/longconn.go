@@ -0,0 +1,303 @@
+package structure
+
+import (
+	"math"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/datatypes/structure"
+	"github.com/activecm/rita/resources"
+
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	//connSegment holds the conn records which share a bro uid. Records
+	//which split a session at log rotations or intervals hold partial
+	//counters, which are summed. Records which re-log a session that is
+	//still open share its start time and hold cumulative counters, so only
+	//the largest is kept. Copies of the session recorded by other sensors
+	//are not summed either.
+	connSegment struct {
+		UID         string   `bson:"_id"`
+		Src         string   `bson:"src"`
+		SrcPort     int      `bson:"src_port"`
+		Dst         string   `bson:"dst"`
+		DstPort     int      `bson:"dst_port"`
+		Proto       string   `bson:"proto"`
+		TsStart     int64    `bson:"ts_start"`
+		TsEnd       float64  `bson:"ts_end"`
+		OrigBytes   int64    `bson:"orig_bytes"`
+		RespBytes   int64    `bson:"resp_bytes"`
+		OrigPackets int64    `bson:"orig_pkts"`
+		RespPackets int64    `bson:"resp_pkts"`
+		RecordCount int      `bson:"record_count"`
+		Sensors     []string `bson:"sensors"`
+	}
+
+	//connStitcher merges consecutive segments with the same 5-tuple
+	//into long connections. Segments must be supplied sorted by
+	//5-tuple and start time.
+	connStitcher struct {
+		mergeGap    int64
+		minDuration float64
+		current     *structure.LongConnection
+		currentEnd  float64
+		emit        func(*structure.LongConnection)
+	}
+)
+
+//BuildLongConnCollection merges conn records which were split across
+//log rotations into single sessions and stores the sessions which
+//lasted at least the configured minimum duration
+func BuildLongConnCollection(res *resources.Resources) {
+	sourceCollectionName,
+		newCollectionName,
+		newCollectionKeys,
+		pipeline := getLongConnSegmentsScript(res.Config)
+
+	err := res.DB.CreateCollection(newCollectionName, newCollectionKeys)
+	if err != nil {
+		res.Log.Error("Failed: ", newCollectionName, err.Error())
+		return
+	}
+
+	ssn := res.DB.Session.Copy()
+	defer ssn.Close()
+
+	iter := res.DB.AggregateCollection(sourceCollectionName, ssn, pipeline)
+	if iter == nil {
+		return
+	}
+
+	var output []*structure.LongConnection
+	stitcher := newConnStitcher(
+		res.Config.S.LongConn.MergeGap,
+		res.Config.S.LongConn.MinimumDuration,
+		func(longConn *structure.LongConnection) {
+			output = append(output, longConn)
+		},
+	)
+
+	var segment connSegment
+	for iter.Next(&segment) {
+		stitcher.add(segment)
+		segment = connSegment{}
+	}
+	stitcher.flush()
+
+	if err := iter.Close(); err != nil {
+		res.Log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to read conn segments for long connection analysis")
+	}
+
+	longConnWriter(output, res, newCollectionName)
+}
+
+func getLongConnSegmentsScript(conf *config.Config) (string, string, []mgo.Index, []bson.D) {
+	// Name of source collection which will be aggregated into the new collection
+	sourceCollectionName := conf.T.Structure.ConnTable
+
+	// Name of the new collection
+	newCollectionName := conf.T.Structure.LongConnTable
+
+	// Desired Indexes
+	keys := []mgo.Index{
+		{Key: []string{"-duration"}},
+		{Key: []string{"src", "dst"}},
+		{Key: []string{"sensors"}},
+	}
+
+	// Records without a uid are treated as their own segment
+	// nolint: vet
+	pipeline := []bson.D{
+		// Re-logs of a session which is still open share its start time
+		// and hold cumulative counters, so the largest are kept
+		{
+			{"$group", bson.D{
+				{"_id", bson.D{
+					{"uid", bson.D{
+						{"$cond", bson.D{
+							{"if", bson.D{{"$gt", []interface{}{"$uid", ""}}}},
+							{"then", "$uid"},
+							{"else", "$_id"},
+						}},
+					}},
+					{"sensor", "$sensor"},
+					{"ts", "$ts"},
+				}},
+				{"src", bson.D{{"$first", "$id_orig_h"}}},
+				{"src_port", bson.D{{"$first", "$id_orig_p"}}},
+				{"dst", bson.D{{"$first", "$id_resp_h"}}},
+				{"dst_port", bson.D{{"$first", "$id_resp_p"}}},
+				{"proto", bson.D{{"$first", "$proto"}}},
+				{"ts_end", bson.D{
+					{"$max", bson.D{{"$add", []interface{}{"$ts", "$duration"}}}},
+				}},
+				{"orig_bytes", bson.D{{"$max", "$orig_bytes"}}},
+				{"resp_bytes", bson.D{{"$max", "$resp_bytes"}}},
+				{"orig_pkts", bson.D{{"$max", "$orig_pkts"}}},
+				{"resp_pkts", bson.D{{"$max", "$resp_pkts"}}},
+				{"record_count", bson.D{{"$sum", 1}}},
+			}},
+		},
+		// Records which split the session at log rotations or intervals
+		// hold partial counters, so each sensor's records are summed
+		{
+			{"$group", bson.D{
+				{"_id", bson.D{
+					{"uid", "$_id.uid"},
+					{"sensor", "$_id.sensor"},
+				}},
+				{"src", bson.D{{"$first", "$src"}}},
+				{"src_port", bson.D{{"$first", "$src_port"}}},
+				{"dst", bson.D{{"$first", "$dst"}}},
+				{"dst_port", bson.D{{"$first", "$dst_port"}}},
+				{"proto", bson.D{{"$first", "$proto"}}},
+				{"ts_start", bson.D{{"$min", "$_id.ts"}}},
+				{"ts_end", bson.D{{"$max", "$ts_end"}}},
+				{"orig_bytes", bson.D{{"$sum", "$orig_bytes"}}},
+				{"resp_bytes", bson.D{{"$sum", "$resp_bytes"}}},
+				{"orig_pkts", bson.D{{"$sum", "$orig_pkts"}}},
+				{"resp_pkts", bson.D{{"$sum", "$resp_pkts"}}},
+				{"record_count", bson.D{{"$sum", "$record_count"}}},
+			}},
+		},
+		// Sensors which recorded the same session hold copies of it, so
+		// the largest totals are kept
+		{
+			{"$group", bson.D{
+				{"_id", "$_id.uid"},
+				{"src", bson.D{{"$first", "$src"}}},
+				{"src_port", bson.D{{"$first", "$src_port"}}},
+				{"dst", bson.D{{"$first", "$dst"}}},
+				{"dst_port", bson.D{{"$first", "$dst_port"}}},
+				{"proto", bson.D{{"$first", "$proto"}}},
+				{"ts_start", bson.D{{"$min", "$ts_start"}}},
+				{"ts_end", bson.D{{"$max", "$ts_end"}}},
+				{"orig_bytes", bson.D{{"$max", "$orig_bytes"}}},
+				{"resp_bytes", bson.D{{"$max", "$resp_bytes"}}},
+				{"orig_pkts", bson.D{{"$max", "$orig_pkts"}}},
+				{"resp_pkts", bson.D{{"$max", "$resp_pkts"}}},
+				{"record_count", bson.D{{"$max", "$record_count"}}},
+				{"sensors", bson.D{{"$addToSet", "$_id.sensor"}}},
+			}},
+		},
+		{
+			{"$sort", bson.D{
+				{"src", 1},
+				{"src_port", 1},
+				{"dst", 1},
+				{"dst_port", 1},
+				{"proto", 1},
+				{"ts_start", 1},
+			}},
+		},
+	}
+
+	return sourceCollectionName, newCollectionName, keys, pipeline
+}
+
+//newConnStitcher creates a connStitcher which joins segments starting
+//within mergeGap seconds of the previous segment's end. Sessions
+//shorter than minDuration seconds are discarded.
+func newConnStitcher(mergeGap int64, minDuration float64,
+	emit func(*structure.LongConnection)) *connStitcher {
+	return &connStitcher{
+		mergeGap:    mergeGap,
+		minDuration: minDuration,
+		emit:        emit,
+	}
+}
+
+//add merges the segment into the current session or, if the segment
+//belongs to a different session, emits the current session and
+//starts a new one
+func (c *connStitcher) add(segment connSegment) {
+	cur := c.current
+	if cur != nil &&
+		cur.Src == segment.Src && cur.SrcPort == segment.SrcPort &&
+		cur.Dst == segment.Dst && cur.DstPort == segment.DstPort &&
+		cur.Proto == segment.Proto &&
+		float64(segment.TsStart) <= c.currentEnd+float64(c.mergeGap) {
+
+		c.currentEnd = math.Max(c.currentEnd, segment.TsEnd)
+		cur.OrigBytes += segment.OrigBytes
+		cur.RespBytes += segment.RespBytes
+		cur.OrigPackets += segment.OrigPackets
+		cur.RespPackets += segment.RespPackets
+		cur.RecordCount += segment.RecordCount
+		cur.UIDs = append(cur.UIDs, segment.UID)
+		cur.Sensors = mergeSensors(cur.Sensors, segment.Sensors)
+		return
+	}
+
+	c.flush()
+	c.currentEnd = segment.TsEnd
+	c.current = &structure.LongConnection{
+		Src:         segment.Src,
+		SrcPort:     segment.SrcPort,
+		Dst:         segment.Dst,
+		DstPort:     segment.DstPort,
+		Proto:       segment.Proto,
+		TsStart:     segment.TsStart,
+		OrigBytes:   segment.OrigBytes,
+		RespBytes:   segment.RespBytes,
+		OrigPackets: segment.OrigPackets,
+		RespPackets: segment.RespPackets,
+		RecordCount: segment.RecordCount,
+		UIDs:        []string{segment.UID},
+		Sensors:     mergeSensors(nil, segment.Sensors),
+	}
+}
+
+//flush emits the current session if it is long enough
+func (c *connStitcher) flush() {
+	cur := c.current
+	c.current = nil
+	if cur == nil {
+		return
+	}
+
+	cur.TsEnd = int64(math.Ceil(c.currentEnd))
+	cur.Duration = c.currentEnd - float64(cur.TsStart)
+	if cur.Duration < c.minDuration {
+		return
+	}
+	cur.TotalBytes = cur.OrigBytes + cur.RespBytes
+	cur.TotalPackets = cur.OrigPackets + cur.RespPackets
+	c.emit(cur)
+}
+
+//mergeSensors adds the non-empty sensor names in additions to sensors
+//if they are not already present
+func mergeSensors(sensors []string, additions []string) []string {
+	for _, addition := range additions {
+		if addition == "" {
+			continue
+		}
+		found := false
+		for _, sensor := range sensors {
+			if sensor == addition {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sensors = append(sensors, addition)
+		}
+	}
+	return sensors
+}
+
+// longConnWriter inserts long connections into the database in bulk using buffer
+func longConnWriter(output []*structure.LongConnection, res *resources.Resources, targetCollection string) {
+	// buffer length controls amount of ram used while exporting
+	writer := res.DB.NewBulkWriter(targetCollection, res.Config.S.Bro.ImportBuffer)
+	for _, data := range output {
+		writer.Insert(data)
+	}
+	writer.Close()
+}
@@ -0,0 +1,105 @@
+package structure
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/datatypes/structure"
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSegment(uid string, dstPort int, start int64, end float64, bytes int64) connSegment {
+	return connSegment{
+		UID:         uid,
+		Src:         "10.0.0.1",
+		SrcPort:     50000,
+		Dst:         "192.0.2.1",
+		DstPort:     dstPort,
+		Proto:       "tcp",
+		TsStart:     start,
+		TsEnd:       end,
+		OrigBytes:   bytes,
+		RespBytes:   bytes,
+		OrigPackets: 1,
+		RespPackets: 1,
+		RecordCount: 1,
+		Sensors:     []string{"sensor-a"},
+	}
+}
+
+func TestConnStitcherMergesRotatedSegments(t *testing.T) {
+	var output []*structure.LongConnection
+	stitcher := newConnStitcher(60, 0, func(longConn *structure.LongConnection) {
+		output = append(output, longConn)
+	})
+
+	// three hourly segments of the same session, the last seen by a second sensor
+	stitcher.add(newTestSegment("C1", 443, 0, 3600, 100))
+	stitcher.add(newTestSegment("C2", 443, 3601, 7200, 100))
+	third := newTestSegment("C3", 443, 7230, 9000.5, 50)
+	third.Sensors = []string{"sensor-b", ""}
+	stitcher.add(third)
+	stitcher.flush()
+
+	require.Len(t, output, 1)
+	longConn := output[0]
+	assert.Equal(t, int64(0), longConn.TsStart)
+	assert.Equal(t, int64(9001), longConn.TsEnd)
+	assert.Equal(t, 9000.5, longConn.Duration)
+	assert.Equal(t, int64(500), longConn.TotalBytes)
+	assert.Equal(t, int64(6), longConn.TotalPackets)
+	assert.Equal(t, 3, longConn.RecordCount)
+	assert.Equal(t, []string{"C1", "C2", "C3"}, longConn.UIDs)
+	assert.Equal(t, []string{"sensor-a", "sensor-b"}, longConn.Sensors)
+}
+
+func TestConnStitcherSplitsSessions(t *testing.T) {
+	var output []*structure.LongConnection
+	stitcher := newConnStitcher(60, 100, func(longConn *structure.LongConnection) {
+		output = append(output, longConn)
+	})
+
+	// a gap larger than the merge gap starts a new session
+	stitcher.add(newTestSegment("C1", 443, 0, 3600, 100))
+	stitcher.add(newTestSegment("C2", 443, 3700, 7200, 100))
+	// a different 5-tuple starts a new session
+	stitcher.add(newTestSegment("C3", 8443, 7200, 9000, 100))
+	// sessions shorter than the minimum duration are dropped
+	stitcher.add(newTestSegment("C4", 9443, 0, 10, 100))
+	stitcher.flush()
+
+	require.Len(t, output, 3)
+	assert.Equal(t, []string{"C1"}, output[0].UIDs)
+	assert.Equal(t, []string{"C2"}, output[1].UIDs)
+	assert.Equal(t, []string{"C3"}, output[2].UIDs)
+}
+
+func TestLongConnSegmentsTotals(t *testing.T) {
+	_, _, _, pipeline := getLongConnSegmentsScript(&config.Config{})
+
+	var groups []bson.D
+	for _, stage := range pipeline {
+		if stage[0].Name == "$group" {
+			groups = append(groups, stage[0].Value.(bson.D))
+		}
+	}
+	require.Len(t, groups, 3)
+	accumulator := func(group bson.D, field string) string {
+		for _, elem := range group {
+			if elem.Name == field {
+				return elem.Value.(bson.D)[0].Name
+			}
+		}
+		return ""
+	}
+
+	// re-logs of an open session keep the largest cumulative counters,
+	// split records are summed, and copies from other sensors are not
+	for index, expected := range []string{"$max", "$sum", "$max"} {
+		for _, field := range []string{"orig_bytes", "resp_bytes", "orig_pkts", "resp_pkts"} {
+			assert.Equal(t, expected, accumulator(groups[index], field), "stage %d %s", index, field)
+		}
+	}
+}
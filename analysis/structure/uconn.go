@@ -64,6 +64,40 @@ func getUniqueConnectionsScript(conf *config.Config) (string, string, []mgo.Inde
 		{Key: []string{"connection_count"}},
 	}
 
+	// The connections between two hosts are grouped by src and dst. When
+	// grouping by service, each destination port and protocol is
+	// summarized separately so unrelated services on the same host are
+	// not blended together.
+	groupID := bson.M{
+		"src": "$id_orig_h",
+		"dst": "$id_resp_h",
+	}
+	project := bson.M{
+		"_id":              0,
+		"connection_count": "$conns",
+		"src":              "$_id.src",
+		"dst":              "$_id.dst",
+		"local_src":        "$ls",
+		"local_dst":        "$ld",
+		"total_bytes":      "$tbytes",
+		"avg_bytes":        "$abytes",
+		"ts_list":          "$ts",
+		"first_ts":         "$first_ts",
+		"last_ts":          "$last_ts",
+		"orig_bytes_list":  "$orig_bytes",
+		"resp_bytes_list":  "$resp_bytes",
+		"max_duration":     "$max_duration",
+		"total_duration":   "$total_duration",
+		"sensors":          "$sensors",
+	}
+	if conf.S.Beacon.GroupByService {
+		keys[0].Key = []string{"src", "dst", "dst_port", "proto"}
+		groupID["dst_port"] = "$id_resp_p"
+		groupID["proto"] = "$proto"
+		project["dst_port"] = "$_id.dst_port"
+		project["proto"] = "$_id.proto"
+	}
+
 	// Aggregation to calculate various metrics (shown in the $project stage) that
 	// occur between a unique IP pair. That is, all individual connections between two
 	// given IPs will be summarized into a single entry in the resulting uconn collection.
@@ -94,13 +128,10 @@ func getUniqueConnectionsScript(conf *config.Config) (string, string, []mgo.Inde
 		},
 		{
 			{"$group", bson.M{
-				"_id": bson.M{
-					// In addition to defining the entry's key,
-					// putting these here makes them available
-					// for storing in the $project stage through $_id.*
-					"src": "$id_orig_h",
-					"dst": "$id_resp_h",
-				},
+				// In addition to defining the entry's key,
+				// putting these here makes them available
+				// for storing in the $project stage through $_id.*
+				"_id": groupID,
 				// local_* is set per IP so we just need to know
 				// any one of the connections' values
 				"ls": bson.M{"$first": "$local_orig"},
@@ -131,29 +162,23 @@ func getUniqueConnectionsScript(conf *config.Config) (string, string, []mgo.Inde
 				// consecutive values being 0 in the beacon analysis and
 				// would throw off the algorithm.
 				"ts": bson.M{"$addToSet": "$ts"},
+				// Time of the first and last connections
+				"first_ts": bson.M{"$min": "$ts"},
+				"last_ts":  bson.M{"$max": "$ts"},
 				// Array of bytes sent from origin in each connection
 				// Here we want $push because every size is used as-is
 				// instead of the difference of consecutive timestamps.
-				"orig_bytes":     bson.M{"$push": "$orig_ip_bytes"},
+				"orig_bytes": bson.M{"$push": "$orig_ip_bytes"},
+				// Array of bytes sent back by the responder
+				"resp_bytes":     bson.M{"$push": "$resp_ip_bytes"},
 				"max_duration":   bson.M{"$max": "$duration"},
 				"total_duration": bson.M{"$sum": "$duration"},
+				// Array of the sensors which recorded the connections
+				"sensors": bson.M{"$addToSet": "$sensor"},
 			}},
 		},
 		{
-			{"$project", bson.M{
-				"_id":              0,
-				"connection_count": "$conns",
-				"src":              "$_id.src",
-				"dst":              "$_id.dst",
-				"local_src":        "$ls",
-				"local_dst":        "$ld",
-				"total_bytes":      "$tbytes",
-				"avg_bytes":        "$abytes",
-				"ts_list":          "$ts",
-				"orig_bytes_list":  "$orig_bytes",
-				"max_duration":     "$max_duration",
-				"total_duration":   "$total_duration",
-			}},
+			{"$project", project},
 		},
 		{
 			{"$out", newCollectionName},
@@ -162,3 +187,59 @@ func getUniqueConnectionsScript(conf *config.Config) (string, string, []mgo.Inde
 
 	return sourceCollectionName, newCollectionName, keys, pipeline
 }
+
+//GetUniqueConnectionPairStages returns the aggregation stages which merge
+//the unique connections between each pair of hosts. When connections are
+//grouped by service, a pair has an entry for each service it used, and these
+//stages sum them back into a single entry so every pair is counted once.
+//Otherwise, the entries are already pairs and no stages are returned.
+func GetUniqueConnectionPairStages(conf *config.Config) []bson.D {
+	if !conf.S.Beacon.GroupByService {
+		return nil
+	}
+
+	//nolint: vet
+	return []bson.D{
+		{
+			{"$group", bson.M{
+				"_id": bson.M{
+					"src": "$src",
+					"dst": "$dst",
+				},
+				"local_src":        bson.M{"$first": "$local_src"},
+				"local_dst":        bson.M{"$first": "$local_dst"},
+				"connection_count": bson.M{"$sum": "$connection_count"},
+				"total_bytes":      bson.M{"$sum": "$total_bytes"},
+				"first_ts":         bson.M{"$min": "$first_ts"},
+				"last_ts":          bson.M{"$max": "$last_ts"},
+				"max_duration":     bson.M{"$max": "$max_duration"},
+				"total_duration":   bson.M{"$sum": "$total_duration"},
+				"sensors":          bson.M{"$push": "$sensors"},
+			}},
+		},
+		{
+			{"$project", bson.M{
+				"_id":              0,
+				"src":              "$_id.src",
+				"dst":              "$_id.dst",
+				"local_src":        1,
+				"local_dst":        1,
+				"connection_count": 1,
+				"total_bytes":      1,
+				"avg_bytes": bson.M{"$divide": []interface{}{
+					"$total_bytes", "$connection_count",
+				}},
+				"first_ts":       1,
+				"last_ts":        1,
+				"max_duration":   1,
+				"total_duration": 1,
+				// Merge the sensor lists gathered from each service
+				"sensors": bson.M{"$reduce": bson.M{
+					"input":        "$sensors",
+					"initialValue": []interface{}{},
+					"in":           bson.M{"$setUnion": []interface{}{"$$value", "$$this"}},
+				}},
+			}},
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package structure
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/config"
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//uconnGroupStage returns the $group and $project stages of the unique
+//connections pipeline
+func uconnGroupStage(t *testing.T, conf *config.Config) (bson.M, bson.M) {
+	_, _, _, pipeline := getUniqueConnectionsScript(conf)
+	var group, project bson.M
+	for _, stage := range pipeline {
+		switch stage[0].Name {
+		case "$group":
+			group = stage[0].Value.(bson.M)
+		case "$project":
+			project = stage[0].Value.(bson.M)
+		}
+	}
+	require.NotNil(t, group)
+	require.NotNil(t, project)
+	return group, project
+}
+
+func TestUniqueConnectionsGroupByService(t *testing.T) {
+	conf := &config.Config{}
+
+	_, _, keys, _ := getUniqueConnectionsScript(conf)
+	assert.Equal(t, []string{"src", "dst"}, keys[0].Key)
+	group, project := uconnGroupStage(t, conf)
+	assert.Equal(t, bson.M{"src": "$id_orig_h", "dst": "$id_resp_h"}, group["_id"])
+	assert.NotContains(t, project, "dst_port")
+
+	conf.S.Beacon.GroupByService = true
+	_, _, keys, _ = getUniqueConnectionsScript(conf)
+	assert.Equal(t, []string{"src", "dst", "dst_port", "proto"}, keys[0].Key)
+	assert.True(t, keys[0].Unique)
+	group, project = uconnGroupStage(t, conf)
+	assert.Equal(t, "$id_resp_p", group["_id"].(bson.M)["dst_port"])
+	assert.Equal(t, "$proto", group["_id"].(bson.M)["proto"])
+	assert.Equal(t, "$_id.dst_port", project["dst_port"])
+	assert.Equal(t, "$_id.proto", project["proto"])
+}
+
+func TestUniqueConnectionPairStages(t *testing.T) {
+	conf := &config.Config{}
+	assert.Empty(t, GetUniqueConnectionPairStages(conf))
+
+	conf.S.Beacon.GroupByService = true
+	stages := GetUniqueConnectionPairStages(conf)
+	require.Len(t, stages, 2)
+	require.Equal(t, "$group", stages[0][0].Name)
+	group := stages[0][0].Value.(bson.M)
+	assert.Equal(t, bson.M{"src": "$src", "dst": "$dst"}, group["_id"])
+	assert.Equal(t, bson.M{"$sum": "$connection_count"}, group["connection_count"])
+	require.Equal(t, "$project", stages[1][0].Name)
+	project := stages[1][0].Value.(bson.M)
+	assert.Equal(t, "$_id.src", project["src"])
+	assert.Equal(t, "$_id.dst", project["dst"])
+}
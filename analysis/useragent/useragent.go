@@ -41,6 +41,7 @@ func getUserAgentCollectionScript(conf *config.Config) (string, string, []mgo.In
 	keys := []mgo.Index{
 		{Key: []string{"user_agent"}, Unique: true},
 		{Key: []string{"times_used"}},
+		{Key: []string{"sensors"}},
 	}
 
 	//[]string{"-times_used"}
@@ -54,6 +55,9 @@ func getUserAgentCollectionScript(conf *config.Config) (string, string, []mgo.In
 				{"times_used", bson.D{
 					{"$sum", 1},
 				}},
+				{"sensors", bson.D{
+					{"$addToSet", "$sensor"},
+				}},
 			}},
 		},
 		{
@@ -61,6 +65,7 @@ func getUserAgentCollectionScript(conf *config.Config) (string, string, []mgo.In
 				{"_id", 0},
 				{"user_agent", "$_id"},
 				{"times_used", 1},
+				{"sensors", 1},
 			}},
 		},
 		{
@@ -6,6 +6,7 @@ import (
 
 	"github.com/activecm/rita/analysis/beacon"
 	"github.com/activecm/rita/analysis/blacklist"
+	"github.com/activecm/rita/analysis/dhcp"
 	"github.com/activecm/rita/analysis/dns"
 	"github.com/activecm/rita/analysis/structure"
 	"github.com/activecm/rita/analysis/useragent"
@@ -69,6 +70,22 @@ func analyze(inDb string, res *resources.Resources, resetFlag bool) error {
 
 	var toRunDirty []string
 	var toRun []string
+	failedBefore := res.DB.FailedWrites()
+
+	var suppressions *beacon.SuppressionList
+	if res.Config.S.Beacon.Enabled {
+		if errs := res.Config.S.Beacon.Validate(); len(errs) > 0 {
+			return cli.NewExitError("Invalid beacon configuration: "+errs[0].Error()+
+				". Run rita test-config for details.", -1)
+		}
+		if path := res.Config.S.Beacon.SuppressionFile; path != "" {
+			var err error
+			suppressions, err = beacon.LoadSuppressionList(path)
+			if err != nil {
+				return cli.NewExitError("Invalid beacon suppression file "+path+": "+err.Error(), -1)
+			}
+		}
+	}
 
 	// Check to see if we want to run a full database or just one off the command line
 	if inDb == "" {
@@ -142,11 +159,26 @@ func analyze(inDb string, res *resources.Resources, resetFlag bool) error {
 			structure.BuildUniqueConnectionsCollection,
 		)
 
+		// must go before the analyses which label hosts with devices
+		logAnalysisFunc("DHCP Leases", td, res,
+			dhcp.BuildLeaseCollection,
+		)
+
+		if res.Config.S.LongConn.Enabled {
+			logAnalysisFunc("Long Connections", td, res,
+				structure.BuildLongConnCollection,
+			)
+		}
+
 		if res.Config.S.Beacon.Enabled {
 			// must go after uconns
 			logAnalysisFunc("Beaconing", td, res,
 				beacon.BuildBeaconCollection,
 			)
+
+			logAnalysisFunc("Proxy Beaconing", td, res,
+				beacon.BuildProxyBeaconCollection,
+			)
 		}
 
 		// must go after beaconing
@@ -166,6 +198,21 @@ func analyze(inDb string, res *resources.Resources, resetFlag bool) error {
 			)
 		}
 
+		if res.Config.S.Beacon.Enabled {
+			// must go after the hostnames are mapped to addresses
+			logAnalysisFunc("FQDN Beaconing", td, res,
+				beacon.BuildFQDNBeaconCollection,
+			)
+
+			if suppressions != nil {
+				logAnalysisFunc("Beacon Suppression", td, res,
+					func(innerRes *resources.Resources) {
+						beacon.SuppressBeacons(innerRes, suppressions)
+					},
+				)
+			}
+		}
+
 		if res.Config.S.UserAgent.Enabled {
 			logAnalysisFunc("User Agent", td, res,
 				useragent.BuildUserAgentCollection,
@@ -191,7 +238,7 @@ func analyze(inDb string, res *resources.Resources, resetFlag bool) error {
 		"end_time": endAll.Format(util.TimeFormat),
 		"duration": endAll.Sub(startAll),
 	}).Info("Analysis complete")
-	return nil
+	return writeFailureError(res, failedBefore)
 }
 
 func logAnalysisFunc(analysisName string, databaseName string,
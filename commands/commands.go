@@ -1,9 +1,12 @@
 package commands
 
 import (
+	"fmt"
 	"runtime"
 	"strconv"
+	"strings"
 
+	"github.com/activecm/rita/datatypes/dhcp"
 	"github.com/activecm/rita/resources"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -47,6 +50,13 @@ var (
 		Usage: "Print a report instead of csv",
 	}
 
+	// sensorFlag allows users to limit output to data recorded by one sensor
+	sensorFlag = cli.StringFlag{
+		Name:  "sensor",
+		Usage: "Only show results recorded by sensor `NAME`",
+		Value: "",
+	}
+
 	blSortFlag = cli.StringFlag{
 		Name:  "sort, s",
 		Usage: "Sort by conn (# of connections), uconn (# of unique connections), total_bytes (# of bytes)",
@@ -100,3 +110,26 @@ func f(f float64) string {
 func i(i int64) string {
 	return strconv.FormatInt(i, 10)
 }
+
+//devices names the devices which held the given leases
+func devices(leases []dhcp.Lease) string {
+	names := make([]string, 0, len(leases))
+	for _, lease := range leases {
+		names = append(names, lease.Device())
+	}
+	return strings.Join(names, " ")
+}
+
+//writeFailureError returns an error to exit with if records could not be
+//written to MongoDB since the number of failed writes was failedBefore
+func writeFailureError(res *resources.Resources, failedBefore int64) error {
+	failed := res.DB.FailedWrites() - failedBefore
+	if failed <= 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("%d records could not be written to MongoDB", failed)
+	if res.Config.S.MongoDB.DeadLetterPath != "" {
+		msg += ". They were saved in " + res.Config.S.MongoDB.DeadLetterPath
+	}
+	return cli.NewExitError(msg, -1)
+}
@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/activecm/rita/parser"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	importDelimitedCommand := cli.Command{
+		Name:  "import-delimited",
+		Usage: "Import CSV, TSV, or other delimited logs described by a column mapping",
+		UsageText: "rita import-delimited [command options] <database> <log file>...\n\n" +
+			"The mapping file declares the delimiter, the timestamp format, and which" +
+			" column holds each conn, dns, or http field by its bro log name." +
+			" Files may be gzip compressed.",
+		Flags: []cli.Flag{
+			threadFlag,
+			configFlag,
+			cli.StringFlag{
+				Name:  "mapping, m",
+				Usage: "Read the column mapping from `FILE`",
+				Value: "",
+			},
+			cli.StringFlag{
+				Name:  "sensor",
+				Usage: "Tag the imported records as recorded by sensor `NAME`",
+				Value: "",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			r := importDelimited(c)
+			fmt.Printf(updateCheck(c.String("config")))
+			return r
+		},
+	}
+
+	bootstrapCommands(importDelimitedCommand)
+}
+
+// importDelimited imports delimited text logs
+func importDelimited(c *cli.Context) error {
+	res := resources.InitResources(c.String("config"))
+	targetDatabase := c.Args().Get(0)
+	files := c.Args().Tail()
+	threads := util.Max(c.Int("threads")/2, 1)
+
+	if c.String("mapping") == "" {
+		return cli.NewExitError("Specify a column mapping file with --mapping", -1)
+	}
+	if targetDatabase == "" {
+		return cli.NewExitError("Specify a database", -1)
+	}
+	if len(files) == 0 {
+		return cli.NewExitError("Specify log files to import", -1)
+	}
+
+	mapping, err := parser.LoadColumnMapping(c.String("mapping"))
+	if err != nil {
+		return cli.NewExitError("Invalid column mapping: "+err.Error(), -1)
+	}
+
+	importer := parser.NewFSImporter(res, threads, threads)
+	if len(importer.GetInternalSubnets()) == 0 {
+		return cli.NewExitError("Internal subnets are not defined. Please set the InternalSubnets section of the config file.", -1)
+	}
+
+	res.Log.Infof("Importing delimited logs into %s\n", targetDatabase)
+	fmt.Println("[+] Importing delimited logs into " + targetDatabase)
+	datastore, _ := newImportDatastore(res)
+	parser.NewDelimitedImporter(importer, targetDatabase, c.String("sensor"), mapping).
+		ImportFiles(files, datastore)
+	res.Log.Infof("Finished importing delimited logs into %s\n", targetDatabase)
+	return writeFailureError(res, 0)
+}
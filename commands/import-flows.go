@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/activecm/rita/parser"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	importFlowsCommand := cli.Command{
+		Name:  "import-flows",
+		Usage: "Import NetFlow v5/v9 and IPFIX flows into a target database",
+		UsageText: "rita import-flows [command options] <database> [<flow file>...]\n\n" +
+			"Flow files may be pcap or pcapng captures of flow export traffic or IPFIX files," +
+			" optionally gzip compressed. With --listen, RITA receives flows" +
+			" directly from exporters until it is interrupted. Flows are stored" +
+			" as conn records so they may be analyzed like bro logs.",
+		Flags: []cli.Flag{
+			threadFlag,
+			configFlag,
+			cli.StringFlag{
+				Name:  "listen",
+				Usage: "Receive flows from exporters on the UDP address `ADDRESS`, e.g. :2055",
+				Value: "",
+			},
+			cli.StringFlag{
+				Name:  "sensor",
+				Usage: "Tag the imported flows as recorded by sensor `NAME` instead of by their exporter",
+				Value: "",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			r := importFlows(c)
+			fmt.Printf(updateCheck(c.String("config")))
+			return r
+		},
+	}
+
+	bootstrapCommands(importFlowsCommand)
+}
+
+// importFlows imports flow files or listens for flows from exporters
+func importFlows(c *cli.Context) error {
+	res := resources.InitResources(c.String("config"))
+	targetDatabase := c.Args().Get(0)
+	files := c.Args().Tail()
+	listenAddress := c.String("listen")
+	threads := util.Max(c.Int("threads")/2, 1)
+
+	if targetDatabase == "" {
+		return cli.NewExitError("Specify a database", -1)
+	}
+	if len(files) == 0 && listenAddress == "" {
+		return cli.NewExitError("Specify flow files to import or an address to --listen on", -1)
+	}
+
+	importer := parser.NewFSImporter(res, threads, threads)
+	if len(importer.GetInternalSubnets()) == 0 {
+		return cli.NewExitError("Internal subnets are not defined. Please set the InternalSubnets section of the config file.", -1)
+	}
+	flowImporter := parser.NewFlowImporter(importer, targetDatabase, c.String("sensor"))
+
+	if len(files) > 0 {
+		res.Log.Infof("Importing flows into %s\n", targetDatabase)
+		fmt.Println("[+] Importing flows into " + targetDatabase)
+		datastore, _ := newImportDatastore(res)
+		flowImporter.ImportFiles(files, datastore)
+		res.Log.Infof("Finished importing flows into %s\n", targetDatabase)
+	}
+
+	if listenAddress == "" {
+		return writeFailureError(res, 0)
+	}
+
+	conn, err := net.ListenPacket("udp", listenAddress)
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
+	}
+	defer conn.Close()
+
+	// finish writing the received flows before exiting on an interrupt
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println("[+] Stopping after the received flows are written")
+		close(stop)
+	}()
+
+	res.Log.Infof("Receiving flows on %s\n", conn.LocalAddr().String())
+	fmt.Println("[+] Receiving flows on " + conn.LocalAddr().String())
+	datastore, _ := newImportDatastore(res)
+	flowImporter.Listen(conn, datastore, stop)
+	res.Log.Infof("Finished receiving flows into %s\n", targetDatabase)
+	return writeFailureError(res, 0)
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/activecm/rita/parser"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	importPcapCommand := cli.Command{
+		Name:  "import-pcap",
+		Usage: "Build conn and dns records from packet captures without bro",
+		UsageText: "rita import-pcap [command options] <pcap file>... <database>\n\n" +
+			"TCP, UDP, and ICMP sessions in pcap or pcapng captures are stored as conn" +
+			" records and DNS queries and answers are stored as dns records. Connection" +
+			" states and histories approximate what bro would log. Captures may be" +
+			" gzip compressed. Use bro for large captures or when HTTP records are needed.",
+		Flags: []cli.Flag{
+			threadFlag,
+			configFlag,
+			cli.StringFlag{
+				Name:  "sensor",
+				Usage: "Tag the imported records as recorded by sensor `NAME`",
+				Value: "",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			r := importPcap(c)
+			fmt.Printf(updateCheck(c.String("config")))
+			return r
+		},
+	}
+
+	bootstrapCommands(importPcapCommand)
+}
+
+// importPcap imports packet captures
+func importPcap(c *cli.Context) error {
+	res := resources.InitResources(c.String("config"))
+	threads := util.Max(c.Int("threads")/2, 1)
+
+	if c.NArg() < 2 {
+		return cli.NewExitError("Both <pcap file> and <database> are required", -1)
+	}
+	files := c.Args()[:c.NArg()-1]
+	targetDatabase := c.Args()[c.NArg()-1]
+
+	importer := parser.NewFSImporter(res, threads, threads)
+	if len(importer.GetInternalSubnets()) == 0 {
+		return cli.NewExitError("Internal subnets are not defined. Please set the InternalSubnets section of the config file.", -1)
+	}
+
+	res.Log.Infof("Importing packet captures into %s\n", targetDatabase)
+	fmt.Println("[+] Importing packet captures into " + targetDatabase)
+	datastore, _ := newImportDatastore(res)
+	parser.NewPcapImporter(importer, targetDatabase, c.String("sensor")).
+		ImportFiles(files, datastore)
+	res.Log.Infof("Finished importing packet captures into %s\n", targetDatabase)
+	return writeFailureError(res, 0)
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/activecm/rita/parser"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	importProxyCommand := cli.Command{
+		Name:  "import-proxy",
+		Usage: "Import web proxy access logs into a target database",
+		UsageText: "rita import-proxy [command options] <database> <access log>...\n\n" +
+			"Each request is stored as an http record holding the client which made" +
+			" the request and the host it requested, so beacons sent through the" +
+			" proxy may be found with show-beacons --proxy. Files may be gzip compressed.",
+		Flags: []cli.Flag{
+			threadFlag,
+			configFlag,
+			cli.StringFlag{
+				Name: "format, f",
+				Usage: "Read logs written in `FORMAT`: squid, common, combined, " +
+					"or a squid logformat definition such as '%ts.%03tu %>a %rm %ru'",
+				Value: "squid",
+			},
+			cli.StringFlag{
+				Name:  "sensor",
+				Usage: "Tag the imported records as recorded by sensor `NAME`",
+				Value: "",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			r := importProxy(c)
+			fmt.Printf(updateCheck(c.String("config")))
+			return r
+		},
+	}
+
+	bootstrapCommands(importProxyCommand)
+}
+
+// importProxy imports proxy access logs
+func importProxy(c *cli.Context) error {
+	res := resources.InitResources(c.String("config"))
+	targetDatabase := c.Args().Get(0)
+	files := c.Args().Tail()
+	threads := util.Max(c.Int("threads")/2, 1)
+
+	if targetDatabase == "" {
+		return cli.NewExitError("Specify a database", -1)
+	}
+	if len(files) == 0 {
+		return cli.NewExitError("Specify access logs to import", -1)
+	}
+
+	format, err := parser.NewProxyLogFormat(c.String("format"))
+	if err != nil {
+		return cli.NewExitError("Invalid log format: "+err.Error(), -1)
+	}
+
+	importer := parser.NewFSImporter(res, threads, threads)
+	if len(importer.GetInternalSubnets()) == 0 {
+		return cli.NewExitError("Internal subnets are not defined. Please set the InternalSubnets section of the config file.", -1)
+	}
+
+	res.Log.Infof("Importing proxy logs into %s\n", targetDatabase)
+	fmt.Println("[+] Importing proxy logs into " + targetDatabase)
+	datastore, _ := newImportDatastore(res)
+	parser.NewProxyImporter(importer, targetDatabase, c.String("sensor"), format).
+		ImportFiles(files, datastore)
+	res.Log.Infof("Finished importing proxy logs into %s\n", targetDatabase)
+	return writeFailureError(res, 0)
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/activecm/rita/parser"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	importServerCommand := cli.Command{
+		Name:  "import-server",
+		Usage: "Accept bro logs pushed by sensors over HTTP and import them",
+		UsageText: "rita import-server [command options]\n\n" +
+			"Sensors POST bro logs to /api/v1/logs?sensor=<sensor>&dataset=<dataset>&filename=<file>" +
+			" using the token configured for the sensor in the Ingest section of the" +
+			" configuration file. Logs are imported into a database named <database root>-<dataset>" +
+			" in the background, and each accepted log is answered with 202 Accepted.",
+		Flags: []cli.Flag{
+			threadFlag,
+			configFlag,
+		},
+		Action: func(c *cli.Context) error {
+			fmt.Printf(updateCheck(c.String("config")))
+			return runImportServer(c)
+		},
+	}
+
+	bootstrapCommands(importServerCommand)
+}
+
+// runImportServer serves the HTTP ingestion endpoint until an error occurs
+// or RITA is interrupted
+func runImportServer(c *cli.Context) error {
+	res := resources.InitResources(c.String("config"))
+	threads := util.Max(c.Int("threads")/2, 1)
+	ingestConfig := res.Config.S.Ingest
+
+	if len(ingestConfig.SensorTokens) == 0 {
+		return cli.NewExitError("No sensors are allowed to push logs. Please set the SensorTokens in the Ingest section of the config file.", -1)
+	}
+
+	err := os.MkdirAll(ingestConfig.SpoolDirectory, 0755)
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
+	}
+
+	// received files are spooled into the import directory so they are
+	// assigned to databases in the same way as files on disk
+	res.Config.S.Bro.ImportDirectory = filepath.Clean(ingestConfig.SpoolDirectory)
+
+	importer := parser.NewFSImporter(res, threads, threads)
+	if len(importer.GetInternalSubnets()) == 0 {
+		return cli.NewExitError("Internal subnets are not defined. Please set the InternalSubnets section of the config file.", -1)
+	}
+
+	if ingestConfig.CloseTime <= 0 {
+		return cli.NewExitError("Ingest CloseTime must be greater than zero.", -1)
+	}
+
+	httpImporter := parser.NewHTTPImporter(importer,
+		func() parser.Datastore {
+			datastore, _ := newImportDatastore(res)
+			return datastore
+		},
+		ingestConfig.SensorTokens,
+		ingestConfig.MaxUploadSize*1024*1024,
+		ingestConfig.QueueSize,
+		time.Duration(ingestConfig.CloseTime)*time.Second,
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/logs", httpImporter)
+	server := &http.Server{
+		Addr:              ingestConfig.ListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Duration(ingestConfig.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(ingestConfig.WriteTimeout) * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	// files left in the spool directory by an earlier run are imported
+	// before newly received files
+	httpImporter.Resume()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		httpImporter.Run(stop)
+		close(stopped)
+	}()
+
+	// stop accepting files and finish the open datasets on an interrupt
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println("[+] Stopping after the current import completes")
+		server.Shutdown(context.Background())
+	}()
+
+	res.Log.Infof("Accepting logs on %s\n", ingestConfig.ListenAddress)
+	fmt.Println("[+] Accepting logs on " + ingestConfig.ListenAddress)
+	if ingestConfig.TLSCertFile != "" && ingestConfig.TLSKeyFile != "" {
+		err = server.ListenAndServeTLS(ingestConfig.TLSCertFile, ingestConfig.TLSKeyFile)
+	} else {
+		fmt.Println("[!] TLS is not configured. Sensor tokens will be sent in the clear.")
+		err = server.ListenAndServe()
+	}
+
+	close(stop)
+	<-stopped
+	if err != http.ErrServerClosed {
+		return cli.NewExitError(err.Error(), -1)
+	}
+	return writeFailureError(res, 0)
+}
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/activecm/rita/parser"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	importSuricataCommand := cli.Command{
+		Name:  "import-suricata",
+		Usage: "Import Suricata eve.json logs into a target database",
+		UsageText: "rita import-suricata [command options] <database> <eve.json>...\n\n" +
+			"Flow, dns, http, and tls events are stored as conn, dns, http, and ssl records" +
+			" so they may be analyzed like bro logs. Files may be gzip compressed.",
+		Flags: []cli.Flag{
+			threadFlag,
+			configFlag,
+			cli.StringFlag{
+				Name:  "sensor",
+				Usage: "Tag the imported events as recorded by sensor `NAME` instead of by the host named in each event",
+				Value: "",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			r := importSuricata(c)
+			fmt.Printf(updateCheck(c.String("config")))
+			return r
+		},
+	}
+
+	bootstrapCommands(importSuricataCommand)
+}
+
+// importSuricata imports eve.json files
+func importSuricata(c *cli.Context) error {
+	res := resources.InitResources(c.String("config"))
+	targetDatabase := c.Args().Get(0)
+	files := c.Args().Tail()
+	threads := util.Max(c.Int("threads")/2, 1)
+
+	if targetDatabase == "" {
+		return cli.NewExitError("Specify a database", -1)
+	}
+	if len(files) == 0 {
+		return cli.NewExitError("Specify eve.json files to import", -1)
+	}
+
+	importer := parser.NewFSImporter(res, threads, threads)
+	if len(importer.GetInternalSubnets()) == 0 {
+		return cli.NewExitError("Internal subnets are not defined. Please set the InternalSubnets section of the config file.", -1)
+	}
+
+	res.Log.Infof("Importing Suricata events into %s\n", targetDatabase)
+	fmt.Println("[+] Importing Suricata events into " + targetDatabase)
+	datastore, _ := newImportDatastore(res)
+	parser.NewSuricataImporter(importer, targetDatabase, c.String("sensor")).
+		ImportFiles(files, datastore)
+	res.Log.Infof("Finished importing Suricata events into %s\n", targetDatabase)
+	return writeFailureError(res, 0)
+}
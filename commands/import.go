@@ -2,11 +2,17 @@ package commands
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/activecm/rita/parser"
 	"github.com/activecm/rita/resources"
 	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
@@ -19,10 +25,39 @@ func init() {
 			" named <database root>. Files in a subfolder of <import directory> will be imported" +
 			" into <database root>-$SUBFOLDER_NAME. <import directory>" +
 			" and <database root> will be loaded from the configuration file unless" +
-			" BOTH arguments are supplied.",
+			" BOTH arguments are supplied.\n\n" +
+			"With --watch, RITA keeps running and imports new logs once they have" +
+			" finished being written.",
 		Flags: []cli.Flag{
 			threadFlag,
 			configFlag,
+			cli.StringFlag{
+				Name:  "sensor",
+				Usage: "Tag the imported logs as recorded by sensor `NAME`",
+				Value: "",
+			},
+			cli.BoolFlag{
+				Name:  "dedupe",
+				Usage: "Drop records which were already imported from an overlapping sensor",
+			},
+			cli.StringFlag{
+				Name:  "since",
+				Usage: "Only import records logged at or after `TIME`",
+				Value: "",
+			},
+			cli.StringFlag{
+				Name:  "until",
+				Usage: "Only import records logged at or before `TIME`",
+				Value: "",
+			},
+			cli.BoolFlag{
+				Name:  "watch",
+				Usage: "Keep running and import new logs as they appear in the import directory",
+			},
+			cli.BoolFlag{
+				Name:  "analyze",
+				Usage: "When watching, analyze each dataset once it stops receiving new logs",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			r := doImport(c)
@@ -60,16 +95,163 @@ func doImport(c *cli.Context) error {
 		res.Config.S.Bro.DBRoot = targetDatabase
 	}
 
+	//check if the user overrode the sensor name
+	if c.String("sensor") != "" {
+		res.Config.S.Bro.SensorID = c.String("sensor")
+	}
+
+	if c.Bool("dedupe") {
+		res.Config.S.Bro.Deduplicate = true
+	}
+
+	var window parser.TimeWindow
+	var err error
+	if c.String("since") != "" {
+		window.Since, err = parseImportTime(c.String("since"))
+		if err != nil {
+			return cli.NewExitError("Could not parse --since: "+err.Error(), -1)
+		}
+	}
+	if c.String("until") != "" {
+		window.Until, err = parseImportTime(c.String("until"))
+		if err != nil {
+			return cli.NewExitError("Could not parse --until: "+err.Error(), -1)
+		}
+	}
+	if !window.Since.IsZero() && !window.Until.IsZero() && window.Until.Before(window.Since) {
+		return cli.NewExitError("--until must not be before --since", -1)
+	}
+
 	importer := parser.NewFSImporter(res, threads, threads)
 	if len(importer.GetInternalSubnets()) == 0 {
 		return cli.NewExitError("Internal subnets are not defined. Please set the InternalSubnets section of the config file.", -1)
 	}
+	importer.SetTimeWindow(window)
+
+	if c.Bool("analyze") {
+		res.Config.S.Watch.Analyze = true
+	}
+
+	if c.Bool("watch") {
+		return watchImport(res, importer)
+	}
 
 	res.Log.Infof("Importing %s\n", res.Config.S.Bro.ImportDirectory)
 	fmt.Println("[+] Importing " + res.Config.S.Bro.ImportDirectory)
-	datastore := parser.NewMongoDatastore(res.DB.Session, res.MetaDB,
-		res.Config.S.Bro.ImportBuffer, res.Log)
+	datastore, deduper := newImportDatastore(res)
+
 	importer.Run(datastore)
+
+	if deduper != nil {
+		fmt.Printf("\t[-] Dropped %d duplicate records\n", deduper.DuplicateCount())
+	}
 	res.Log.Infof("Finished importing %s\n", res.Config.S.Bro.ImportDirectory)
-	return nil
+	return writeFailureError(res, 0)
+}
+
+//newImportDatastore creates the datastore imported records are written to.
+//The deduplicating wrapper is returned as well if deduplication is enabled.
+func newImportDatastore(res *resources.Resources) (parser.Datastore, *parser.DedupingDatastore) {
+	mongoDatastore := parser.NewMongoDatastore(res.DB,
+		res.MetaDB, res.Config.S.Bro.ImportBuffer, res.Log)
+	mongoDatastore.SetMemoryBudget(res.Config.S.Bro.ImportMemory)
+	mongoDatastore.SetStatsInterval(time.Duration(res.Config.S.Bro.ImportStats) * time.Second)
+	var datastore parser.Datastore = mongoDatastore
+
+	var deduper *parser.DedupingDatastore
+	if res.Config.S.Bro.Deduplicate {
+		deduper = parser.NewDedupingDatastore(datastore,
+			res.Config.S.Bro.DedupeTolerance, res.Log)
+		datastore = deduper
+	}
+	return datastore, deduper
+}
+
+//watchImport imports new logs from the import directory until RITA is
+//interrupted, optionally analyzing datasets once they stop receiving logs
+func watchImport(res *resources.Resources, importer *parser.FSImporter) error {
+	watchConfig := res.Config.S.Watch
+	if watchConfig.PollInterval <= 0 {
+		return cli.NewExitError("Watch PollInterval must be greater than zero.", -1)
+	}
+
+	watcher := parser.NewFSWatcher(importer,
+		func() parser.Datastore {
+			datastore, _ := newImportDatastore(res)
+			return datastore
+		},
+		time.Duration(watchConfig.PollInterval)*time.Second,
+		time.Duration(watchConfig.SettleTime)*time.Second,
+		time.Duration(watchConfig.CloseTime)*time.Second,
+	)
+
+	if watchConfig.Analyze {
+		watcher.SetDatasetClosedHandler(
+			func(database string) {
+				info, err := res.MetaDB.GetDBMetaInfo(database)
+				if err != nil {
+					return
+				}
+				if info.Analyzed {
+					//the dataset was reopened by new files, or was
+					//analyzed before watching began, so the results
+					//are replaced to cover all of its files
+					err = resetAnalysis(database, res, true)
+					if err != nil {
+						res.Log.WithFields(log.Fields{
+							"database": database,
+							"error":    err.Error(),
+						}).Error("Could not reset analysis of reopened dataset")
+						return
+					}
+				}
+				err = analyze(database, res, false)
+				if err != nil {
+					res.Log.WithFields(log.Fields{
+						"database": database,
+						"error":    err.Error(),
+					}).Error("Could not analyze closed dataset")
+				}
+			},
+		)
+	}
+
+	// finish the current batch of files before exiting on an interrupt
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println("[+] Stopping after the current import completes")
+		close(stop)
+	}()
+
+	res.Log.Infof("Watching %s\n", res.Config.S.Bro.ImportDirectory)
+	watcher.Run(stop)
+	res.Log.Infof("Finished watching %s\n", res.Config.S.Bro.ImportDirectory)
+	return writeFailureError(res, 0)
+}
+
+//importTimeLayouts lists the accepted formats for --since and --until.
+//Times without a zone are interpreted in the local time zone.
+var importTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+//parseImportTime parses a time given on the command line as either a
+//unix timestamp or one of the importTimeLayouts
+func parseImportTime(value string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q, expected a unix timestamp or YYYY-MM-DD[ HH:MM[:SS]]", value)
 }
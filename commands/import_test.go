@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportTime(t *testing.T) {
+	parsed, err := parseImportTime("1514764800")
+	require.Nil(t, err)
+	assert.Equal(t, int64(1514764800), parsed.Unix())
+
+	parsed, err = parseImportTime("2018-01-01T00:00:00Z")
+	require.Nil(t, err)
+	assert.Equal(t, int64(1514764800), parsed.Unix())
+
+	parsed, err = parseImportTime("2018-01-02 03:04")
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2018, 1, 2, 3, 4, 0, 0, time.Local), parsed)
+
+	parsed, err = parseImportTime("2018-01-02")
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2018, 1, 2, 0, 0, 0, 0, time.Local), parsed)
+
+	_, err = parseImportTime("yesterday")
+	assert.NotNil(t, err)
+}
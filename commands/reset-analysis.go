@@ -44,6 +44,8 @@ func resetAnalysis(database string, res *resources.Resources, forceFlag bool) er
 	http := res.Config.T.Structure.HTTPTable
 	dns := res.Config.T.Structure.DNSTable
 	strobe := res.Config.T.Structure.FrequentConnTable
+	dhcp := res.Config.T.Structure.DHCPTable
+	ssl := res.Config.T.Structure.SSLTable
 
 	names, err := res.DB.Session.DB(database).CollectionNames()
 	if err != nil || len(names) == 0 {
@@ -69,7 +71,7 @@ func resetAnalysis(database string, res *resources.Resources, forceFlag bool) er
 	var err2Flag error
 	for _, name := range names {
 		switch name {
-		case conn, http, dns, strobe:
+		case conn, http, dns, strobe, dhcp, ssl:
 			continue
 		default:
 			err2 := res.DB.Session.DB(database).C(name).DropCollection()
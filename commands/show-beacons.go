@@ -2,7 +2,11 @@ package commands
 
 import (
 	"encoding/csv"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/activecm/rita/analysis/beacon"
 	beaconData "github.com/activecm/rita/datatypes/beacon"
@@ -19,6 +23,28 @@ func init() {
 		Flags: []cli.Flag{
 			humanFlag,
 			configFlag,
+			sensorFlag,
+			cli.BoolFlag{
+				Name:  "proxy",
+				Usage: "Show clients which beacon to a hostname through a web proxy or over HTTP",
+			},
+			cli.BoolFlag{
+				Name:  "fqdn",
+				Usage: "Show hosts which beacon to a hostname across all of the addresses it resolved to",
+			},
+			cli.BoolFlag{
+				Name:  "suppressed",
+				Usage: "Show beacons to known good hosts listed in the suppression file",
+			},
+			cli.BoolFlag{
+				Name:  "explain",
+				Usage: "Explain how the scores of the top beacons were reached",
+			},
+			cli.IntFlag{
+				Name:  "top",
+				Usage: "Explain the top `N` beacons",
+				Value: 10,
+			},
 		},
 		Action: showBeacons,
 	}
@@ -31,75 +57,220 @@ func showBeacons(c *cli.Context) error {
 	if db == "" {
 		return cli.NewExitError("Specify a database", -1)
 	}
+	if c.Bool("proxy") && c.Bool("fqdn") {
+		return cli.NewExitError("--proxy and --fqdn cannot be used together", -1)
+	}
 	res := resources.InitResources(c.String("config"))
 	res.DB.SelectDB(db)
 
 	var data []beaconData.AnalysisView
 
 	ssn := res.DB.Session.Copy()
-	resultsView := beacon.GetBeaconResultsView(res, ssn, 0)
+	getResultsView := beacon.GetBeaconResultsView
+	dstHeader := "Destination IP"
+	if c.Bool("proxy") {
+		getResultsView = beacon.GetProxyBeaconResultsView
+		dstHeader = "Destination Host"
+	}
+	if c.Bool("fqdn") {
+		getResultsView = beacon.GetFQDNBeaconResultsView
+		dstHeader = "Destination FQDN"
+	}
+	resultsView := getResultsView(res, ssn, 0, c.String("sensor"))
 	if resultsView == nil {
 		return cli.NewExitError("No results were found for "+db, -1)
 	}
 	resultsView.All(&data)
 	ssn.Close()
 
+	showSuppressed := c.Bool("suppressed")
+	if !showSuppressed {
+		data = beacon.HideSuppressed(data, time.Now())
+	}
+
+	columns := newBeaconColumns(data, c.Bool("fqdn"), showSuppressed)
+	if c.Bool("explain") {
+		showBeaconExplanations(data, c.Int("top"))
+		return nil
+	}
+
 	if c.Bool("human-readable") {
-		err := showBeaconReport(data)
+		err := showBeaconReport(data, dstHeader, columns)
 		if err != nil {
 			return cli.NewExitError(err.Error(), -1)
 		}
 		return nil
 	}
 
-	err := showBeaconCsv(data)
+	err := showBeaconCsv(data, dstHeader, columns)
 	if err != nil {
 		return cli.NewExitError(err.Error(), -1)
 	}
 	return nil
 }
 
-func showBeaconReport(data []beaconData.AnalysisView) error {
+//beaconColumns selects the optional columns of the beacon output
+type beaconColumns struct {
+	dstIPs     bool // addresses FQDN beacons resolved to
+	services   bool // destination port and protocol
+	suppressed bool // reason the beacon was suppressed
+}
+
+//newBeaconColumns shows the port and protocol of beacons when the unique
+//connections were grouped by service
+func newBeaconColumns(data []beaconData.AnalysisView, dstIPs bool, suppressed bool) beaconColumns {
+	columns := beaconColumns{dstIPs: dstIPs, suppressed: suppressed}
+	for _, d := range data {
+		if d.Proto != "" {
+			columns.services = true
+			break
+		}
+	}
+	return columns
+}
+
+//destination returns the destination columns of a beacon
+func (b beaconColumns) destination(d *beaconData.AnalysisView) []string {
+	if !b.services {
+		return []string{d.Dst}
+	}
+	return []string{d.Dst, i(int64(d.DstPort)), d.Proto}
+}
+
+//optional returns the optional columns at the end of a beacon's row
+func (b beaconColumns) optional(d *beaconData.AnalysisView) []string {
+	var row []string
+	if b.dstIPs {
+		row = append(row, strings.Join(d.DstIPs, " "))
+	}
+	if b.suppressed {
+		row = append(row, suppression(d.Suppression))
+	}
+	return row
+}
+
+//headers surrounds the headers of the scores with the headers of the
+//destination and optional columns
+func (b beaconColumns) headers(src string, dst string, scores ...string) []string {
+	headers := []string{"Score", src, dst}
+	if b.services {
+		headers = append(headers, "Port", "Proto")
+	}
+	headers = append(headers, scores...)
+	if b.dstIPs {
+		headers = append(headers, "Destination IPs")
+	}
+	if b.suppressed {
+		headers = append(headers, "Suppressed")
+	}
+	return headers
+}
+
+func showBeaconReport(data []beaconData.AnalysisView, dstHeader string, columns beaconColumns) error {
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Score", "Source IP", "Destination IP",
+	table.SetHeader(columns.headers("Source IP", dstHeader,
 		"Connections", "Avg. Bytes", "Intvl Range", "Size Range", "Top Intvl",
 		"Top Size", "Top Intvl Count", "Top Size Count", "Intvl Skew",
-		"Size Skew", "Intvl Dispersion", "Size Dispersion", "Intvl Duration"})
+		"Size Skew", "Intvl Dispersion", "Size Dispersion", "Top Resp Size",
+		"Resp Size Skew", "Resp Size Dispersion", "Intvl Duration", "Coverage",
+		"Consistency", "Period", "Period Strength", "Intvl Clusters", "Cluster Score",
+		"Sensors", "Source Devices", "Destination Devices"))
 
-	for _, d := range data {
-		table.Append(
-			[]string{
-				f(d.Score), d.Src, d.Dst, i(d.Connections), f(d.AvgBytes),
-				i(d.TSIRange), i(d.DSRange), i(d.TSIMode), i(d.DSMode),
-				i(d.TSIModeCount), i(d.DSModeCount), f(d.TSISkew), f(d.DSSkew),
-				i(d.TSIDispersion), i(d.DSDispersion), f(d.TSDuration),
-			},
+	for index := range data {
+		d := &data[index]
+		row := append([]string{f(d.Score), d.Src}, columns.destination(d)...)
+		row = append(row,
+			i(d.Connections), f(d.AvgBytes),
+			i(d.TSIRange), i(d.DSRange), i(d.TSIMode), i(d.DSMode),
+			i(d.TSIModeCount), i(d.DSModeCount), f(d.TSISkew), f(d.DSSkew),
+			i(d.TSIDispersion), i(d.DSDispersion), i(d.RSMode), f(d.RSSkew),
+			i(d.RSDispersion), f(d.TSDuration), f(d.TSCoverage), f(d.TSConsistency),
+			i(d.TSPeriod), f(d.TSPeriodStr), intervalClusters(d.TSClusters), f(d.TSClusterScore),
+			strings.Join(d.Sensors, " "), devices(d.SrcDevices),
+			devices(d.DstDevices),
 		)
+		table.Append(append(row, columns.optional(d)...))
 	}
 	table.Render()
 	return nil
 }
 
-func showBeaconCsv(data []beaconData.AnalysisView) error {
+func showBeaconCsv(data []beaconData.AnalysisView, dstHeader string, columns beaconColumns) error {
 	csvWriter := csv.NewWriter(os.Stdout)
-	headers := []string{
-		"Score", "Source", "Destination", "Connections",
-		"Avg Bytes", "TS Range", "DS Range", "TS Mode", "DS Mode", "TS Mode Count",
-		"DS Mode Count", "TS Skew", "DS Skew", "TS Dispersion", "DS Dispersion",
-		"TS Duration",
-	}
-	csvWriter.Write(headers)
+	csvWriter.Write(columns.headers("Source", strings.TrimSuffix(dstHeader, " IP"),
+		"Connections", "Avg Bytes", "TS Range", "DS Range", "TS Mode", "DS Mode",
+		"TS Mode Count", "DS Mode Count", "TS Skew", "DS Skew", "TS Dispersion",
+		"DS Dispersion", "RS Range", "RS Mode", "RS Mode Count", "RS Skew",
+		"RS Dispersion", "TS Duration", "TS Coverage", "TS Consistency", "TS Period",
+		"TS Period Strength", "TS Clusters", "TS Cluster Score", "Sensors",
+		"Source Devices", "Destination Devices", "TS Skew Score",
+		"TS Dispersion Score", "TS Duration Score", "Period Score",
+		"Interval Clusters Score", "DS Skew Score",
+		"DS Dispersion Score", "DS Smallness Score", "RS Skew Score",
+		"RS Dispersion Score", "RS Smallness Score"))
 
-	for _, d := range data {
-		csvWriter.Write(
-			[]string{
-				f(d.Score), d.Src, d.Dst, i(d.Connections), f(d.AvgBytes),
-				i(d.TSIRange), i(d.DSRange), i(d.TSIMode), i(d.DSMode),
-				i(d.TSIModeCount), i(d.DSModeCount), f(d.TSISkew), f(d.DSSkew),
-				i(d.TSIDispersion), i(d.DSDispersion), f(d.TSDuration),
-			},
+	for index := range data {
+		d := &data[index]
+		row := append([]string{f(d.Score), d.Src}, columns.destination(d)...)
+		row = append(row,
+			i(d.Connections), f(d.AvgBytes),
+			i(d.TSIRange), i(d.DSRange), i(d.TSIMode), i(d.DSMode),
+			i(d.TSIModeCount), i(d.DSModeCount), f(d.TSISkew), f(d.DSSkew),
+			i(d.TSIDispersion), i(d.DSDispersion), i(d.RSRange), i(d.RSMode),
+			i(d.RSModeCount), f(d.RSSkew), i(d.RSDispersion), f(d.TSDuration),
+			f(d.TSCoverage), f(d.TSConsistency), i(d.TSPeriod), f(d.TSPeriodStr),
+			intervalClusters(d.TSClusters), f(d.TSClusterScore),
+			strings.Join(d.Sensors, " "), devices(d.SrcDevices),
+			devices(d.DstDevices), f(d.TSSkewScore), f(d.TSDispersionScore),
+			f(d.TSDurationScore), f(d.TSPeriodStr), f(d.TSClusterScore),
+			f(d.DSSkewScore), f(d.DSDispersionScore),
+			f(d.DSSmallnessScore), f(d.RSSkewScore), f(d.RSDispersionScore),
+			f(d.RSSmallnessScore),
 		)
+		csvWriter.Write(append(row, columns.optional(d)...))
 	}
 	csvWriter.Flush()
 	return nil
 }
+
+//showBeaconExplanations prints the sub-scores which make up the score of
+//each of the top beacons
+func showBeaconExplanations(data []beaconData.AnalysisView, top int) {
+	if top > 0 && len(data) > top {
+		data = data[:top]
+	}
+	for index := range data {
+		d := &data[index]
+		dst := d.Dst
+		if d.Proto != "" {
+			dst += fmt.Sprintf(" port %d/%s", d.DstPort, d.Proto)
+		}
+		fmt.Printf("%s -> %s scored %.3f\n", d.Src, dst, d.Score)
+		for _, component := range beacon.Explain(d) {
+			fmt.Printf("    %-20s %.3f x %-5s +%.3f  %s\n", component.Name, component.Score,
+				f(component.Weight), component.Contribution, component.Reason)
+		}
+		fmt.Println()
+	}
+}
+
+//suppression describes why a beacon is suppressed
+func suppression(s *beaconData.Suppression) string {
+	if s == nil || !s.Active(time.Now()) {
+		return ""
+	}
+	if s.Reason == "" {
+		return "yes"
+	}
+	return s.Reason
+}
+
+//intervalClusters lists the center of each interval cluster with the
+//fraction of intervals in the cluster
+func intervalClusters(clusters []beaconData.IntervalCluster) string {
+	parts := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		parts = append(parts, i(cluster.Center)+"s:"+strconv.FormatFloat(cluster.Weight*100, 'f', 0, 64)+"%")
+	}
+	return strings.Join(parts, " ")
+}
@@ -9,9 +9,8 @@ import (
 
 	"github.com/activecm/rita/analysis/dns"
 	"github.com/activecm/rita/datatypes/blacklist"
-	"github.com/activecm/rita/datatypes/structure"
 	"github.com/activecm/rita/resources"
-	"github.com/globalsign/mgo/bson"
+	"github.com/activecm/rita/util"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
@@ -26,6 +25,7 @@ func init() {
 			blConnFlag,
 			blSortFlag,
 			configFlag,
+			sensorFlag,
 		},
 		Usage:  "Print blacklisted hostnames which received connections",
 		Action: printBLHostnames,
@@ -45,7 +45,7 @@ func printBLHostnames(c *cli.Context) error {
 	var blHosts []blacklist.BlacklistedHostname
 	res.DB.Session.DB(db).
 		C(res.Config.T.Blacklisted.HostnamesTable).
-		Find(nil).Sort("-" + sort).All(&blHosts)
+		Find(blSensorQuery(c)).Sort("-" + sort).All(&blHosts)
 
 	if len(blHosts) == 0 {
 		return cli.NewExitError("No results were found for "+db, -1)
@@ -59,14 +59,16 @@ func printBLHostnames(c *cli.Context) error {
 			//and loop over the ips
 			for _, ip := range ips {
 				//then find all of the hosts which talked to the ip
-				var connected []structure.UniqueConnection
+				var connected []string
 				res.DB.Session.DB(db).
 					C(res.Config.T.Structure.UniqueConnTable).Find(
-					bson.M{"dst": ip},
-				).All(&connected)
+					connectedQuery(c, "dst", ip),
+				).Distinct("src", &connected)
 				//and aggregate the source ip addresses
-				for _, uconn := range connected {
-					blHosts[i].ConnectedHosts = append(blHosts[i].ConnectedHosts, uconn.Src)
+				for _, src := range connected {
+					if !util.StringInSlice(src, blHosts[i].ConnectedHosts) {
+						blHosts[i].ConnectedHosts = append(blHosts[i].ConnectedHosts, src)
+					}
 				}
 			}
 		}
@@ -89,7 +91,7 @@ func printBLHostnames(c *cli.Context) error {
 
 func showBLHostnames(hostnames []blacklist.BlacklistedHostname, connectedHosts bool) error {
 	csvWriter := csv.NewWriter(os.Stdout)
-	headers := []string{"Hostname", "Connections", "Unique Connections", "Total Bytes", "Lists"}
+	headers := []string{"Hostname", "Connections", "Unique Connections", "Total Bytes", "Lists", "Devices", "Sensors"}
 	if connectedHosts {
 		headers = append(headers, "Sources")
 	}
@@ -102,6 +104,8 @@ func showBLHostnames(hostnames []blacklist.BlacklistedHostname, connectedHosts b
 			strconv.Itoa(hostname.UniqueConnections),
 			strconv.Itoa(hostname.TotalBytes),
 			strings.Join(hostname.Lists, " "),
+			devices(hostname.TargetDevices),
+			strings.Join(hostname.Sensors, " "),
 		}
 		if connectedHosts {
 			sort.Strings(hostname.ConnectedHosts)
@@ -116,7 +120,7 @@ func showBLHostnames(hostnames []blacklist.BlacklistedHostname, connectedHosts b
 
 func showBLHostnamesHuman(hostnames []blacklist.BlacklistedHostname, connectedHosts bool) error {
 	table := tablewriter.NewWriter(os.Stdout)
-	headers := []string{"Hostname", "Connections", "Unique Connections", "Total Bytes", "Lists"}
+	headers := []string{"Hostname", "Connections", "Unique Connections", "Total Bytes", "Lists", "Devices", "Sensors"}
 	if connectedHosts {
 		headers = append(headers, "Sources")
 	}
@@ -129,6 +133,8 @@ func showBLHostnamesHuman(hostnames []blacklist.BlacklistedHostname, connectedHo
 			strconv.Itoa(hostname.UniqueConnections),
 			strconv.Itoa(hostname.TotalBytes),
 			strings.Join(hostname.Lists, " "),
+			devices(hostname.TargetDevices),
+			strings.Join(hostname.Sensors, " "),
 		}
 		if connectedHosts {
 			sort.Strings(hostname.ConnectedHosts)
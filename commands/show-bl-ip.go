@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/activecm/rita/datatypes/blacklist"
-	"github.com/activecm/rita/datatypes/structure"
 	"github.com/activecm/rita/resources"
 	"github.com/globalsign/mgo/bson"
 	"github.com/olekukonko/tablewriter"
@@ -27,6 +26,7 @@ func init() {
 			blConnFlag,
 			blSortFlag,
 			configFlag,
+			sensorFlag,
 		},
 		Usage:  "Print blacklisted IPs which initiated connections",
 		Action: printBLSourceIPs,
@@ -40,6 +40,7 @@ func init() {
 			blConnFlag,
 			blSortFlag,
 			configFlag,
+			sensorFlag,
 		},
 		Usage:  "Print blacklisted IPs which received connections",
 		Action: printBLDestIPs,
@@ -48,6 +49,25 @@ func init() {
 	bootstrapCommands(blSourceIPs, blDestIPs)
 }
 
+//blSensorQuery limits blacklist results to those recorded by the sensor
+//given on the command line
+func blSensorQuery(c *cli.Context) bson.M {
+	if c.String("sensor") == "" {
+		return nil
+	}
+	return bson.M{"sensors": c.String("sensor")}
+}
+
+//connectedQuery finds the unique connections with the blacklisted host
+//which were recorded by the sensor given on the command line
+func connectedQuery(c *cli.Context, field string, host string) bson.M {
+	query := bson.M{field: host}
+	if c.String("sensor") != "" {
+		query["sensors"] = c.String("sensor")
+	}
+	return query
+}
+
 func parseBLArgs(c *cli.Context) (string, string, bool, bool, error) {
 	db := c.Args().Get(0)
 	sort := c.String("sort")
@@ -72,7 +92,7 @@ func printBLSourceIPs(c *cli.Context) error {
 	var blIPs []blacklist.BlacklistedIP
 	res.DB.Session.DB(db).
 		C(res.Config.T.Blacklisted.SourceIPsTable).
-		Find(nil).Sort("-" + sort).All(&blIPs)
+		Find(blSensorQuery(c)).Sort("-" + sort).All(&blIPs)
 
 	if len(blIPs) == 0 {
 		return cli.NewExitError("No results were found for "+db, -1)
@@ -80,14 +100,10 @@ func printBLSourceIPs(c *cli.Context) error {
 
 	if connected {
 		for i, ip := range blIPs {
-			var connected []structure.UniqueConnection
 			res.DB.Session.DB(db).
 				C(res.Config.T.Structure.UniqueConnTable).Find(
-				bson.M{"src": ip.IP},
-			).All(&connected)
-			for _, uconn := range connected {
-				blIPs[i].ConnectedHosts = append(blIPs[i].ConnectedHosts, uconn.Dst)
-			}
+				connectedQuery(c, "src", ip.IP),
+			).Distinct("dst", &blIPs[i].ConnectedHosts)
 		}
 	}
 
@@ -115,7 +131,7 @@ func printBLDestIPs(c *cli.Context) error {
 	var blIPs []blacklist.BlacklistedIP
 	res.DB.Session.DB(db).
 		C(res.Config.T.Blacklisted.DestIPsTable).
-		Find(nil).Sort("-" + sort).All(&blIPs)
+		Find(blSensorQuery(c)).Sort("-" + sort).All(&blIPs)
 
 	if len(blIPs) == 0 {
 		return cli.NewExitError("No results were found for "+db, -1)
@@ -123,14 +139,10 @@ func printBLDestIPs(c *cli.Context) error {
 
 	if connected {
 		for i, ip := range blIPs {
-			var connected []structure.UniqueConnection
 			res.DB.Session.DB(db).
 				C(res.Config.T.Structure.UniqueConnTable).Find(
-				bson.M{"dst": ip.IP},
-			).All(&connected)
-			for _, uconn := range connected {
-				blIPs[i].ConnectedHosts = append(blIPs[i].ConnectedHosts, uconn.Src)
-			}
+				connectedQuery(c, "dst", ip.IP),
+			).Distinct("src", &blIPs[i].ConnectedHosts)
 		}
 	}
 
@@ -150,7 +162,7 @@ func printBLDestIPs(c *cli.Context) error {
 
 func showBLIPs(ips []blacklist.BlacklistedIP, connectedHosts, source bool) error {
 	csvWriter := csv.NewWriter(os.Stdout)
-	headers := []string{"IP", "Connections", "Unique Connections", "Total Bytes", "Lists"}
+	headers := []string{"IP", "Connections", "Unique Connections", "Total Bytes", "Lists", "Devices", "Sensors"}
 	if connectedHosts {
 		if source {
 			headers = append(headers, "Destinations")
@@ -167,6 +179,8 @@ func showBLIPs(ips []blacklist.BlacklistedIP, connectedHosts, source bool) error
 			strconv.Itoa(ip.UniqueConnections),
 			strconv.Itoa(ip.TotalBytes),
 			strings.Join(ip.Lists, " "),
+			devices(ip.TargetDevices),
+			strings.Join(ip.Sensors, " "),
 		}
 		if connectedHosts {
 			sort.Strings(ip.ConnectedHosts)
@@ -180,7 +194,7 @@ func showBLIPs(ips []blacklist.BlacklistedIP, connectedHosts, source bool) error
 
 func showBLIPsHuman(ips []blacklist.BlacklistedIP, connectedHosts, source bool) error {
 	table := tablewriter.NewWriter(os.Stdout)
-	headers := []string{"IP", "Connections", "Unique Connections", "Total Bytes", "Lists"}
+	headers := []string{"IP", "Connections", "Unique Connections", "Total Bytes", "Lists", "Devices", "Sensors"}
 	if connectedHosts {
 		if source {
 			headers = append(headers, "Destinations")
@@ -197,6 +211,8 @@ func showBLIPsHuman(ips []blacklist.BlacklistedIP, connectedHosts, source bool)
 			strconv.Itoa(ip.UniqueConnections),
 			strconv.Itoa(ip.TotalBytes),
 			strings.Join(ip.Lists, " "),
+			devices(ip.TargetDevices),
+			strings.Join(ip.Sensors, " "),
 		}
 		if connectedHosts {
 			sort.Strings(ip.ConnectedHosts)
@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/resources"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	command := cli.Command{
+		Name:      "show-data-quality",
+		Usage:     "Print gaps, volume drops, and clock skew found when importing a database",
+		ArgsUsage: "<database>",
+		Flags: []cli.Flag{
+			humanFlag,
+			configFlag,
+			cli.BoolFlag{
+				Name:  "hourly",
+				Usage: "Print the number of records of each log type in every hour instead",
+			},
+		},
+		Action: showDataQuality,
+	}
+
+	bootstrapCommands(command)
+}
+
+func showDataQuality(c *cli.Context) error {
+	db := c.Args().Get(0)
+	if db == "" {
+		return cli.NewExitError("Specify a database", -1)
+	}
+	res := resources.InitResources(c.String("config"))
+
+	info, err := res.MetaDB.GetDBMetaInfo(db)
+	if err != nil {
+		return cli.NewExitError("No database named "+db+" was found", -1)
+	}
+	if info.Quality == nil {
+		return cli.NewExitError("No data quality report was found for "+db+
+			". Reports are built when data is imported.", -1)
+	}
+
+	var headers []string
+	var rows [][]string
+	if c.Bool("hourly") {
+		headers, rows = hourlyVolumeRows(info.Quality)
+	} else {
+		headers, rows = dataQualityRows(info.Quality)
+	}
+
+	if c.Bool("human-readable") {
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader(headers)
+		table.AppendBulk(rows)
+		table.Render()
+		return nil
+	}
+
+	csvWriter := csv.NewWriter(os.Stdout)
+	csvWriter.Write(headers)
+	csvWriter.WriteAll(rows)
+	return nil
+}
+
+//dataQualityRows lists the observed time range followed by each problem
+//found in the report
+func dataQualityRows(report *database.QualityReport) ([]string, [][]string) {
+	headers := []string{"Finding", "Log", "Sensor", "Start", "End", "Records", "Detail"}
+
+	var total int64
+	for _, volume := range report.Logs {
+		total += volume.Records
+	}
+	rows := [][]string{
+		{"Time Range", "", "", timestamp(report.Start), timestamp(report.End), i(total), ""},
+	}
+
+	for _, gap := range report.Gaps {
+		rows = append(rows, []string{
+			"Gap", gap.Collection, gap.Sensor, timestamp(gap.Start), timestamp(gap.End), "0",
+			i((gap.End-gap.Start)/3600) + " hours without records",
+		})
+	}
+	for _, drop := range report.Drops {
+		rows = append(rows, []string{
+			"Volume Drop", drop.Collection, drop.Sensor, timestamp(drop.Hour),
+			timestamp(drop.Hour + 3600), i(drop.Records),
+			"expected about " + strconv.FormatFloat(drop.Expected, 'f', 0, 64),
+		})
+	}
+	for _, skew := range report.Skews {
+		detail := skew.Path + " was modified " + i(skew.Skew) + " seconds after its last record"
+		if skew.Skew < 0 {
+			detail = skew.Path + " was modified " + i(-skew.Skew) + " seconds before its last record"
+		}
+		rows = append(rows, []string{
+			"Clock Skew", "", skew.Sensor, timestamp(skew.LastTs),
+			timestamp(skew.Modified.Unix()), "", detail,
+		})
+	}
+	return headers, rows
+}
+
+//hourlyVolumeRows lists the records of each log type recorded by each
+//sensor in every hour of the observed time range
+func hourlyVolumeRows(report *database.QualityReport) ([]string, [][]string) {
+	headers := []string{"Hour"}
+	counts := make(map[int64][]string)
+	firstHour := report.Start - report.Start%3600
+	for hour := firstHour; hour <= report.End; hour += 3600 {
+		counts[hour] = make([]string, len(report.Logs))
+		for index := range report.Logs {
+			counts[hour][index] = "0"
+		}
+	}
+
+	for index, volume := range report.Logs {
+		header := volume.Collection
+		if volume.Sensor != "" {
+			header += " (" + volume.Sensor + ")"
+		}
+		headers = append(headers, header)
+		for _, count := range volume.Hours {
+			if _, ok := counts[count.Hour]; ok {
+				counts[count.Hour][index] = i(count.Records)
+			}
+		}
+	}
+
+	var rows [][]string
+	for hour := firstHour; hour <= report.End; hour += 3600 {
+		rows = append(rows, append([]string{timestamp(hour)}, counts[hour]...))
+	}
+	return headers, rows
+}
+
+//timestamp formats a unix timestamp in the local time zone
+func timestamp(ts int64) string {
+	return time.Unix(ts, 0).Format("2006-01-02 15:04:05 MST")
+}
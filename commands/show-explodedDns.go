@@ -3,9 +3,11 @@ package commands
 import (
 	"encoding/csv"
 	"os"
+	"strings"
 
 	"github.com/activecm/rita/datatypes/dns"
 	"github.com/activecm/rita/resources"
+	"github.com/globalsign/mgo/bson"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
@@ -19,6 +21,7 @@ func init() {
 		Flags: []cli.Flag{
 			humanFlag,
 			configFlag,
+			sensorFlag,
 		},
 		Action: func(c *cli.Context) error {
 			db := c.Args().Get(0)
@@ -28,8 +31,13 @@ func init() {
 
 			res := resources.InitResources(c.String("config"))
 
+			var query bson.M
+			if c.String("sensor") != "" {
+				query = bson.M{"sensors": c.String("sensor")}
+			}
+
 			var explodedResults []dns.ExplodedDNS
-			iter := res.DB.Session.DB(db).C(res.Config.T.DNS.ExplodedDNSTable).Find(nil)
+			iter := res.DB.Session.DB(db).C(res.Config.T.DNS.ExplodedDNSTable).Find(query)
 
 			iter.Sort("-subdomains").All(&explodedResults)
 
@@ -56,10 +64,11 @@ func init() {
 
 func showDNSResults(dnsResults []dns.ExplodedDNS) error {
 	csvWriter := csv.NewWriter(os.Stdout)
-	csvWriter.Write([]string{"Domain", "Unique Subdomains", "Times Looked Up"})
+	csvWriter.Write([]string{"Domain", "Unique Subdomains", "Times Looked Up", "Sensors"})
 	for _, result := range dnsResults {
 		csvWriter.Write([]string{
 			result.Domain, i(result.Subdomains), i(result.Visited),
+			strings.Join(result.Sensors, " "),
 		})
 	}
 	csvWriter.Flush()
@@ -68,10 +77,11 @@ func showDNSResults(dnsResults []dns.ExplodedDNS) error {
 
 func showDNSResultsHuman(dnsResults []dns.ExplodedDNS) error {
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Domain", "Unique Subdomains", "Times Looked Up"})
+	table.SetHeader([]string{"Domain", "Unique Subdomains", "Times Looked Up", "Sensors"})
 	for _, result := range dnsResults {
 		table.Append([]string{
 			result.Domain, i(result.Subdomains), i(result.Visited),
+			strings.Join(result.Sensors, " "),
 		})
 	}
 	table.Render()
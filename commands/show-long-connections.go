@@ -4,9 +4,11 @@ import (
 	"encoding/csv"
 	"os"
 	"strconv"
+	"strings"
 
-	"github.com/activecm/rita/datatypes/data"
+	"github.com/activecm/rita/datatypes/structure"
 	"github.com/activecm/rita/resources"
+	"github.com/globalsign/mgo/bson"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
@@ -20,6 +22,7 @@ func init() {
 		Flags: []cli.Flag{
 			humanFlag,
 			configFlag,
+			sensorFlag,
 		},
 		Action: func(c *cli.Context) error {
 			db := c.Args().Get(0)
@@ -29,12 +32,17 @@ func init() {
 
 			res := resources.InitResources(c.String("config"))
 
-			var longConns []data.Conn
-			coll := res.DB.Session.DB(db).C(res.Config.T.Structure.ConnTable)
+			var longConns []structure.LongConnection
+			coll := res.DB.Session.DB(db).C(res.Config.T.Structure.LongConnTable)
 
 			sortStr := "-duration"
 
-			coll.Find(nil).Sort(sortStr).All(&longConns)
+			var query bson.M
+			if c.String("sensor") != "" {
+				query = bson.M{"sensors": c.String("sensor")}
+			}
+
+			coll.Find(query).Sort(sortStr).All(&longConns)
 
 			if len(longConns) == 0 {
 				return cli.NewExitError("No results were found for "+db, -1)
@@ -57,36 +65,46 @@ func init() {
 	bootstrapCommands(command)
 }
 
-func showConns(connResults []data.Conn) error {
+func showConns(connResults []structure.LongConnection) error {
 	csvWriter := csv.NewWriter(os.Stdout)
 	csvWriter.Write([]string{"Source IP", "Source Port", "Destination IP",
-		"Destination Port", "Duration", "Protocol"})
+		"Destination Port", "Duration", "Protocol", "Total Bytes",
+		"Total Packets", "Records", "Sensors"})
 	for _, result := range connResults {
 		csvWriter.Write([]string{
 			result.Src,
-			strconv.Itoa(result.Spt),
+			strconv.Itoa(result.SrcPort),
 			result.Dst,
-			strconv.Itoa(result.Dpt),
-			f(result.Dur),
+			strconv.Itoa(result.DstPort),
+			f(result.Duration),
 			result.Proto,
+			i(result.TotalBytes),
+			i(result.TotalPackets),
+			strconv.Itoa(result.RecordCount),
+			strings.Join(result.Sensors, " "),
 		})
 	}
 	csvWriter.Flush()
 	return nil
 }
 
-func showConnsHuman(connResults []data.Conn) error {
+func showConnsHuman(connResults []structure.LongConnection) error {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"Source IP", "Source Port", "Destination IP",
-		"Destination Port", "Duration", "Protocol"})
+		"Destination Port", "Duration", "Protocol", "Total Bytes",
+		"Total Packets", "Records", "Sensors"})
 	for _, result := range connResults {
 		table.Append([]string{
 			result.Src,
-			strconv.Itoa(result.Spt),
+			strconv.Itoa(result.SrcPort),
 			result.Dst,
-			strconv.Itoa(result.Dpt),
-			f(result.Dur),
+			strconv.Itoa(result.DstPort),
+			f(result.Duration),
 			result.Proto,
+			i(result.TotalBytes),
+			i(result.TotalPackets),
+			strconv.Itoa(result.RecordCount),
+			strings.Join(result.Sensors, " "),
 		})
 	}
 	table.Render()
@@ -3,9 +3,11 @@ package commands
 import (
 	"encoding/csv"
 	"os"
+	"strings"
 
 	"github.com/activecm/rita/datatypes/strobe"
 	"github.com/activecm/rita/resources"
+	"github.com/globalsign/mgo/bson"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
@@ -23,6 +25,7 @@ func init() {
 				Usage: "Sort the strobes by largest connection count.",
 			},
 			configFlag,
+			sensorFlag,
 		},
 		Action: func(c *cli.Context) error {
 			db := c.Args().Get(0)
@@ -42,7 +45,12 @@ func init() {
 				sortStr = "-connection_count"
 			}
 
-			coll.Find(nil).Sort(sortStr).All(&strobes)
+			var query bson.M
+			if c.String("sensor") != "" {
+				query = bson.M{"sensors": c.String("sensor")}
+			}
+
+			coll.Find(query).Sort(sortStr).All(&strobes)
 
 			if len(strobes) == 0 {
 				return cli.NewExitError("No results were found for "+db, -1)
@@ -67,9 +75,10 @@ func init() {
 
 func showStrobes(strobes []strobe.Strobe) error {
 	csvWriter := csv.NewWriter(os.Stdout)
-	csvWriter.Write([]string{"Source", "Destination", "Connection Count"})
+	csvWriter.Write([]string{"Source", "Destination", "Connection Count", "Sensors"})
 	for _, strobe := range strobes {
-		csvWriter.Write([]string{strobe.Source, strobe.Destination, i(strobe.ConnectionCount)})
+		csvWriter.Write([]string{strobe.Source, strobe.Destination, i(strobe.ConnectionCount),
+			strings.Join(strobe.Sensors, " ")})
 	}
 	csvWriter.Flush()
 	return nil
@@ -78,9 +87,10 @@ func showStrobes(strobes []strobe.Strobe) error {
 func showStrobesHuman(strobes []strobe.Strobe) error {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetColWidth(100)
-	table.SetHeader([]string{"Source", "Destination", "Connection Count"})
+	table.SetHeader([]string{"Source", "Destination", "Connection Count", "Sensors"})
 	for _, strobe := range strobes {
-		table.Append([]string{strobe.Source, strobe.Destination, i(strobe.ConnectionCount)})
+		table.Append([]string{strobe.Source, strobe.Destination, i(strobe.ConnectionCount),
+			strings.Join(strobe.Sensors, " ")})
 	}
 	table.Render()
 	return nil
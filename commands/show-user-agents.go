@@ -3,9 +3,11 @@ package commands
 import (
 	"encoding/csv"
 	"os"
+	"strings"
 
 	"github.com/activecm/rita/datatypes/useragent"
 	"github.com/activecm/rita/resources"
+	"github.com/globalsign/mgo/bson"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
@@ -23,6 +25,7 @@ func init() {
 				Usage: "Sort the user agents from least used to most used.",
 			},
 			configFlag,
+			sensorFlag,
 		},
 		Action: func(c *cli.Context) error {
 			db := c.Args().Get(0)
@@ -42,7 +45,12 @@ func init() {
 				sortStr = "-times_used"
 			}
 
-			coll.Find(nil).Sort(sortStr).All(&agents)
+			var query bson.M
+			if c.String("sensor") != "" {
+				query = bson.M{"sensors": c.String("sensor")}
+			}
+
+			coll.Find(query).Sort(sortStr).All(&agents)
 
 			if len(agents) == 0 {
 				return cli.NewExitError("No results were found for "+db, -1)
@@ -67,9 +75,9 @@ func init() {
 
 func showAgents(agents []useragent.UserAgent) error {
 	csvWriter := csv.NewWriter(os.Stdout)
-	csvWriter.Write([]string{"User Agent", "Times Used"})
+	csvWriter.Write([]string{"User Agent", "Times Used", "Sensors"})
 	for _, agent := range agents {
-		csvWriter.Write([]string{agent.UserAgent, i(agent.TimesUsed)})
+		csvWriter.Write([]string{agent.UserAgent, i(agent.TimesUsed), strings.Join(agent.Sensors, " ")})
 	}
 	csvWriter.Flush()
 	return nil
@@ -78,9 +86,9 @@ func showAgents(agents []useragent.UserAgent) error {
 func showAgentsHuman(agents []useragent.UserAgent) error {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetColWidth(100)
-	table.SetHeader([]string{"User Agent", "Times Used"})
+	table.SetHeader([]string{"User Agent", "Times Used", "Sensors"})
 	for _, agent := range agents {
-		table.Append([]string{agent.UserAgent, i(agent.TimesUsed)})
+		table.Append([]string{agent.UserAgent, i(agent.TimesUsed), strings.Join(agent.Sensors, " ")})
 	}
 	table.Render()
 	return nil
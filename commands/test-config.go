@@ -50,6 +50,14 @@ func testConfiguration(c *cli.Context) error {
 	fmt.Fprintf(os.Stdout, "\n%s\n", string(staticConfig))
 	fmt.Fprintf(os.Stdout, "\n%s\n", string(tableConfig))
 
+	// Check the settings which cannot be checked while parsing
+	if errs := conf.S.Validate(); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stdout, "[!] %s\n", err.Error())
+		}
+		return cli.NewExitError("The configuration file is invalid", -1)
+	}
+
 	// Then test initializing external resources like db connection and file handles
 	resources.InitResources(c.String("config"))
 
@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"regexp"
 
 	"github.com/activecm/mgosec"
 	"github.com/blang/semver"
@@ -14,6 +15,7 @@ type (
 	//RunningCfg holds configuration options that are parsed at run time
 	RunningCfg struct {
 		MongoDB MongoDBRunningCfg
+		Bro     BroRunningCfg
 		Version semver.Version
 	}
 
@@ -24,6 +26,11 @@ type (
 			TLSConfig *tls.Config
 		}
 	}
+
+	//BroRunningCfg holds parsed information for importing bro logs
+	BroRunningCfg struct {
+		SensorPattern *regexp.Regexp
+	}
 )
 
 // initRunningConfig uses data in the static config initialize
@@ -60,6 +67,16 @@ func initRunningConfig(static *StaticCfg, running *RunningCfg) error {
 	}
 	running.MongoDB.AuthMechanismParsed = authMechanism
 
+	//compile the pattern used to pull sensor names out of file paths
+	if static.Bro.SensorPattern != "" {
+		sensorPattern, err2 := regexp.Compile(static.Bro.SensorPattern)
+		if err2 != nil {
+			fmt.Println("[!] Could not parse Bro SensorPattern")
+			return err2
+		}
+		running.Bro.SensorPattern = sensorPattern
+	}
+
 	running.Version, err = semver.ParseTolerant(static.Version)
 	if err != nil {
 		fmt.Println("[!] Version error: please ensure that you cloned the git repo and are using make to build.")
@@ -23,16 +23,23 @@ type (
 		Bro          BroStaticCfg         `yaml:"Bro"`
 		Filtering    FilteringStaticCfg   `yaml:"Filtering"`
 		Strobe       StrobeStaticCfg      `yaml:"Strobe"`
+		LongConn     LongConnStaticCfg    `yaml:"LongConnections"`
+		Watch        WatchStaticCfg       `yaml:"Watch"`
+		Ingest       IngestStaticCfg      `yaml:"Ingest"`
+		Quality      QualityStaticCfg     `yaml:"DataQuality"`
 		Version      string
 		ExactVersion string
 	}
 
 	//MongoDBStaticCfg contains the means for connecting to MongoDB
 	MongoDBStaticCfg struct {
-		ConnectionString string        `yaml:"ConnectionString" default:"mongodb://localhost:27017"`
-		AuthMechanism    string        `yaml:"AuthenticationMechanism" default:""`
-		SocketTimeout    time.Duration `yaml:"SocketTimeout" default:"2"`
-		TLS              TLSStaticCfg  `yaml:"TLS"`
+		ConnectionString  string        `yaml:"ConnectionString" default:"mongodb://localhost:27017"`
+		AuthMechanism     string        `yaml:"AuthenticationMechanism" default:""`
+		SocketTimeout     time.Duration `yaml:"SocketTimeout" default:"2"`
+		TLS               TLSStaticCfg  `yaml:"TLS"`
+		WriteRetries      int           `yaml:"WriteRetries" default:"3"`
+		WriteRetryBackoff int           `yaml:"WriteRetryBackoff" default:"1"`
+		DeadLetterPath    string        `yaml:"DeadLetterPath" default:"/var/lib/rita/deadletters"`
 	}
 
 	//TLSStaticCfg contains the means for connecting to MongoDB over TLS
@@ -52,10 +59,19 @@ type (
 
 	//BroStaticCfg controls the file parser
 	BroStaticCfg struct {
-		ImportDirectory string `yaml:"ImportDirectory" default:"/opt/bro/logs/"`
-		DBRoot          string `yaml:"DBRoot" default:"RITA"`
-		MetaDB          string `yaml:"MetaDB" default:"MetaDatabase"`
-		ImportBuffer    int    `yaml:"ImportBuffer" default:"30000"`
+		ImportDirectory string           `yaml:"ImportDirectory" default:"/opt/bro/logs/"`
+		DBRoot          string           `yaml:"DBRoot" default:"RITA"`
+		MetaDB          string           `yaml:"MetaDB" default:"MetaDatabase"`
+		ImportBuffer    int              `yaml:"ImportBuffer" default:"30000"`
+		ImportMemory    int64            `yaml:"ImportMemory" default:"0"`
+		ImportStats     int              `yaml:"ImportStatsInterval" default:"30"`
+		SensorID        string           `yaml:"SensorID" default:""`
+		SensorPattern   string           `yaml:"SensorPattern" default:""`
+		Deduplicate     bool             `yaml:"Deduplicate" default:"false"`
+		DedupeTolerance int64            `yaml:"DeduplicateTolerance" default:"1"`
+		ClockOffsets    map[string]int64 `yaml:"ClockOffsets"`
+		ClockReference  string           `yaml:"ClockReference" default:""`
+		MaxClockOffset  int64            `yaml:"MaxClockOffset" default:"3600"`
 	}
 
 	//UserCfgStaticCfg contains
@@ -76,8 +92,34 @@ type (
 
 	//BeaconStaticCfg is used to control the beaconing analysis module
 	BeaconStaticCfg struct {
-		Enabled                 bool `yaml:"Enabled" default:"true"`
-		DefaultConnectionThresh int  `yaml:"DefaultConnectionThresh" default:"20"`
+		Enabled                  bool                   `yaml:"Enabled" default:"true"`
+		DefaultConnectionThresh  int                    `yaml:"DefaultConnectionThresh" default:"20"`
+		MaxConnections           int                    `yaml:"MaxConnections" default:"150000"`
+		IntervalDispersionCutoff float64                `yaml:"IntervalDispersionCutoff" default:"30"`
+		SizeDispersionCutoff     float64                `yaml:"SizeDispersionCutoff" default:"32"`
+		SmallnessCutoff          float64                `yaml:"SmallnessCutoff" default:"65535"`
+		CoverageBucket           int64                  `yaml:"CoverageBucket" default:"3600"`
+		SuppressionFile          string                 `yaml:"SuppressionFile" default:""`
+		GroupByService           bool                   `yaml:"GroupByService" default:"false"`
+		Weights                  BeaconWeightsStaticCfg `yaml:"Weights"`
+	}
+
+	//BeaconWeightsStaticCfg controls how much each sub-score contributes
+	//to a beacon's score
+	BeaconWeightsStaticCfg struct {
+		IntervalSkew       float64 `yaml:"IntervalSkew" default:"1"`
+		IntervalDispersion float64 `yaml:"IntervalDispersion" default:"1"`
+		Duration           float64 `yaml:"Duration" default:"0"`
+		Period             float64 `yaml:"Period" default:"0"`
+		Clusters           float64 `yaml:"Clusters" default:"1"`
+		Coverage           float64 `yaml:"Coverage" default:"1"`
+		Consistency        float64 `yaml:"Consistency" default:"0"`
+		SizeSkew           float64 `yaml:"SizeSkew" default:"1"`
+		SizeDispersion     float64 `yaml:"SizeDispersion" default:"1"`
+		Smallness          float64 `yaml:"Smallness" default:"1"`
+		ResponseSkew       float64 `yaml:"ResponseSkew" default:"1"`
+		ResponseDispersion float64 `yaml:"ResponseDispersion" default:"1"`
+		ResponseSmallness  float64 `yaml:"ResponseSmallness" default:"1"`
 	}
 
 	//DNSStaticCfg is used to control the DNS analysis module
@@ -101,6 +143,45 @@ type (
 	StrobeStaticCfg struct {
 		ConnectionLimit int `yaml:"ConnectionLimit" default:"250000"`
 	}
+
+	//LongConnStaticCfg controls how connections split across log rotations are merged
+	LongConnStaticCfg struct {
+		Enabled         bool    `yaml:"Enabled" default:"true"`
+		MergeGap        int64   `yaml:"MergeGap" default:"60"`
+		MinimumDuration float64 `yaml:"MinimumDuration" default:"60"`
+	}
+
+	//WatchStaticCfg controls continuous importing with `rita import --watch`
+	WatchStaticCfg struct {
+		PollInterval int  `yaml:"PollInterval" default:"60"`
+		SettleTime   int  `yaml:"SettleTime" default:"120"`
+		Analyze      bool `yaml:"Analyze" default:"false"`
+		CloseTime    int  `yaml:"CloseTime" default:"3600"`
+	}
+
+	//IngestStaticCfg controls the HTTP endpoint sensors push logs to
+	IngestStaticCfg struct {
+		ListenAddress  string            `yaml:"ListenAddress" default:":4096"`
+		SpoolDirectory string            `yaml:"SpoolDirectory" default:"/var/lib/rita/ingest"`
+		MaxUploadSize  int64             `yaml:"MaxUploadSize" default:"1024"`
+		TLSCertFile    string            `yaml:"TLSCertFile" default:""`
+		TLSKeyFile     string            `yaml:"TLSKeyFile" default:""`
+		SensorTokens   map[string]string `yaml:"SensorTokens"`
+		QueueSize      int               `yaml:"QueueSize" default:"1000"`
+		CloseTime      int               `yaml:"CloseTime" default:"3600"`
+		ReadTimeout    int               `yaml:"ReadTimeout" default:"600"`
+		WriteTimeout   int               `yaml:"WriteTimeout" default:"60"`
+	}
+
+	//QualityStaticCfg controls the data quality report built after each import
+	QualityStaticCfg struct {
+		MinimumGap    int      `yaml:"MinimumGap" default:"1"`
+		DropRatio     float64  `yaml:"DropRatio" default:"0.25"`
+		DropWindow    int      `yaml:"DropWindow" default:"24"`
+		DropVolume    int64    `yaml:"DropVolume" default:"100"`
+		ClockSkew     int64    `yaml:"ClockSkew" default:"7200"`
+		MonitoredLogs []string `yaml:"MonitoredLogs" default:"[\"conn\",\"dns\"]"`
+	}
 )
 
 // readStaticConfigFile attempts to read the contents of the
@@ -28,6 +28,10 @@ type (
 		IPv4Table         string `default:"ipv4"`
 		IPv6Table         string `default:"ipv6"`
 		FrequentConnTable string `default:"freqConn"`
+		LongConnTable     string `default:"longConn"`
+		DHCPTable         string `default:"dhcp"`
+		SSLTable          string `default:"ssl"`
+		LeaseTable        string `default:"dhcpLeases"`
 	}
 
 	//BlacklistedTableCfg is used to control the blacklisted analysis module
@@ -45,7 +49,9 @@ type (
 
 	//BeaconTableCfg is used to control the beaconing analysis module
 	BeaconTableCfg struct {
-		BeaconTable string `default:"beacon"`
+		BeaconTable      string `default:"beacon"`
+		ProxyBeaconTable string `default:"beaconProxy"`
+		FQDNBeaconTable  string `default:"beaconFQDN"`
 	}
 
 	//StrobeTableCfg is used to control the strobe analysis module
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+//Validate checks the static config for values which RITA cannot use and
+//returns an error describing each one
+func (s *StaticCfg) Validate() []error {
+	return s.Beacon.Validate()
+}
+
+//Validate checks the beacon scoring parameters and returns an error
+//describing each invalid value
+func (b *BeaconStaticCfg) Validate() []error {
+	var errs []error
+	if b.DefaultConnectionThresh < 0 {
+		errs = append(errs, errors.New("Beacon DefaultConnectionThresh must not be negative"))
+	}
+	if b.MaxConnections <= b.DefaultConnectionThresh {
+		errs = append(errs, errors.New("Beacon MaxConnections must be greater than DefaultConnectionThresh"))
+	}
+
+	cutoffs := []struct {
+		name  string
+		value float64
+	}{
+		{"IntervalDispersionCutoff", b.IntervalDispersionCutoff},
+		{"SizeDispersionCutoff", b.SizeDispersionCutoff},
+		{"SmallnessCutoff", b.SmallnessCutoff},
+	}
+	for _, cutoff := range cutoffs {
+		if cutoff.value <= 0 {
+			errs = append(errs, fmt.Errorf("Beacon %s must be greater than zero", cutoff.name))
+		}
+	}
+	if b.CoverageBucket <= 0 {
+		errs = append(errs, errors.New("Beacon CoverageBucket must be greater than zero"))
+	}
+
+	weights := []struct {
+		name  string
+		value float64
+	}{
+		{"IntervalSkew", b.Weights.IntervalSkew},
+		{"IntervalDispersion", b.Weights.IntervalDispersion},
+		{"Duration", b.Weights.Duration},
+		{"Period", b.Weights.Period},
+		{"Clusters", b.Weights.Clusters},
+		{"Coverage", b.Weights.Coverage},
+		{"Consistency", b.Weights.Consistency},
+		{"SizeSkew", b.Weights.SizeSkew},
+		{"SizeDispersion", b.Weights.SizeDispersion},
+		{"Smallness", b.Weights.Smallness},
+		{"ResponseSkew", b.Weights.ResponseSkew},
+		{"ResponseDispersion", b.Weights.ResponseDispersion},
+		{"ResponseSmallness", b.Weights.ResponseSmallness},
+	}
+	var total float64
+	for _, weight := range weights {
+		if weight.value < 0 {
+			errs = append(errs, fmt.Errorf("Beacon Weights %s must not be negative", weight.name))
+		}
+		total += weight.value
+	}
+	if total <= 0 {
+		errs = append(errs, errors.New("At least one of the Beacon Weights must be greater than zero"))
+	}
+	return errs
+}
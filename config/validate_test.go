@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/creasty/defaults"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBeacon(t *testing.T) {
+	var beacon BeaconStaticCfg
+	require.Nil(t, defaults.Set(&beacon))
+	assert.Empty(t, beacon.Validate())
+
+	beacon.MaxConnections = beacon.DefaultConnectionThresh
+	beacon.SizeDispersionCutoff = 0
+	beacon.Weights.Duration = -1
+	beacon.CoverageBucket = 0
+	assert.Len(t, beacon.Validate(), 4)
+
+	var noWeights BeaconStaticCfg
+	require.Nil(t, defaults.Set(&noWeights))
+	noWeights.Weights = BeaconWeightsStaticCfg{}
+	assert.Len(t, noWeights.Validate(), 1)
+}
@@ -0,0 +1,428 @@
+package database
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+//transientErrorCodes are the MongoDB error codes which indicate a write
+//may succeed if it is tried again
+var transientErrorCodes = map[int]bool{
+	6:     true, // HostUnreachable
+	7:     true, // HostNotFound
+	50:    true, // ExceededTimeLimit
+	89:    true, // NetworkTimeout
+	91:    true, // ShutdownInProgress
+	189:   true, // PrimarySteppedDown
+	9001:  true, // SocketException
+	10107: true, // NotMaster
+	11600: true, // InterruptedAtShutdown
+	11602: true, // InterruptedDueToReplStateChange
+	13435: true, // NotMasterNoSlaveOk
+	13436: true, // NotMasterOrSecondary
+}
+
+type (
+	//WritePolicy controls how failed bulk writes are retried and keeps
+	//track of the documents which could not be written. Documents which
+	//fail permanently are appended to a dead letter file for the target
+	//collection in the DeadLetterPath. A WritePolicy may be shared by
+	//many BulkWriters.
+	WritePolicy struct {
+		//failed is accessed atomically and is kept first
+		//to ensure 64 bit alignment
+		failed      int64
+		retries     int
+		backoff     time.Duration
+		deadLetters string
+		log         *log.Logger
+		mutex       *sync.Mutex
+		sleep       func(time.Duration)
+	}
+
+	//BulkWriter buffers documents and inserts them into a MongoDB
+	//collection in bulk. A BulkWriter must only be used by one thread.
+	BulkWriter struct {
+		//written and failed are accessed atomically and are kept first
+		//to ensure 64 bit alignment
+		written    int64
+		failed     int64
+		session    *mgo.Session
+		database   string
+		collection string
+		bufferSize int
+		buffer     []interface{}
+		policy     *WritePolicy
+		run        func([]interface{}) error
+	}
+
+	//failedDocument is a document which could not be written along with
+	//the reason why
+	failedDocument struct {
+		doc interface{}
+		err error
+	}
+)
+
+//NewWritePolicy creates a WritePolicy which retries failed writes up to
+//retries times, doubling the wait between attempts starting at backoff.
+//Documents which cannot be written are stored in files in deadLetterPath.
+//If deadLetterPath is empty, failed documents are only logged.
+func NewWritePolicy(retries int, backoff time.Duration, deadLetterPath string,
+	logger *log.Logger) *WritePolicy {
+	return &WritePolicy{
+		retries:     retries,
+		backoff:     backoff,
+		deadLetters: deadLetterPath,
+		log:         logger,
+		mutex:       new(sync.Mutex),
+		sleep:       time.Sleep,
+	}
+}
+
+//Failed returns the number of documents which could not be written by any
+//BulkWriter using this policy
+func (p *WritePolicy) Failed() int64 {
+	return atomic.LoadInt64(&p.failed)
+}
+
+//NewBulkWriter creates a BulkWriter which writes to the given collection
+//in the selected database in batches of bufferSize documents
+func (d *DB) NewBulkWriter(collection string, bufferSize int) *BulkWriter {
+	return d.NewBulkWriterFor(d.selected, collection, bufferSize)
+}
+
+//NewBulkWriterFor creates a BulkWriter which writes to the given collection
+//in the given database in batches of bufferSize documents
+func (d *DB) NewBulkWriterFor(database string, collection string, bufferSize int) *BulkWriter {
+	writer := newBulkWriter(database, collection, bufferSize, d.writes)
+	writer.session = d.Session.Copy()
+	coll := writer.session.DB(database).C(collection)
+	writer.run = func(docs []interface{}) error {
+		bulk := coll.Bulk()
+		bulk.Unordered()
+		bulk.Insert(docs...)
+		_, err := bulk.Run()
+		return err
+	}
+	return writer
+}
+
+//FailedWrites returns the number of documents which could not be written
+//by the BulkWriters created from this DB
+func (d *DB) FailedWrites() int64 {
+	return d.writes.Failed()
+}
+
+//newBulkWriter creates a BulkWriter without a connection to MongoDB
+func newBulkWriter(database string, collection string, bufferSize int,
+	policy *WritePolicy) *BulkWriter {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &BulkWriter{
+		database:   database,
+		collection: collection,
+		bufferSize: bufferSize,
+		buffer:     make([]interface{}, 0, bufferSize),
+		policy:     policy,
+	}
+}
+
+//Insert buffers a document, writing out the buffer once it is full
+func (w *BulkWriter) Insert(doc interface{}) {
+	w.buffer = append(w.buffer, doc)
+	if len(w.buffer) >= w.bufferSize {
+		w.Flush()
+	}
+}
+
+//Buffered returns the number of documents waiting to be written
+func (w *BulkWriter) Buffered() int {
+	return len(w.buffer)
+}
+
+//Written returns the number of documents written so far
+func (w *BulkWriter) Written() int64 {
+	return atomic.LoadInt64(&w.written)
+}
+
+//Failed returns the number of documents which could not be written
+func (w *BulkWriter) Failed() int64 {
+	return atomic.LoadInt64(&w.failed)
+}
+
+//Flush writes out the buffered documents. Writes which fail due to
+//transient errors are retried with exponential backoff. Documents which
+//still cannot be written are sent to the dead letter file.
+//
+//A transient error may be returned after some of the documents were
+//written. Each document is given an _id before the first attempt so
+//retrying them cannot write them twice. Documents which fail with a
+//duplicate _id once they are retried were written by an earlier attempt.
+func (w *BulkWriter) Flush() {
+	if len(w.buffer) == 0 {
+		return
+	}
+
+	pending := w.buffer
+	if w.policy.retries > 0 {
+		var invalid []failedDocument
+		pending, invalid = withObjectIDs(w.buffer)
+		w.policy.deadLetter(w.database, w.collection, invalid)
+		atomic.AddInt64(&w.failed, int64(len(invalid)))
+	}
+
+	backoff := w.policy.backoff
+	for attempt := 0; len(pending) > 0; attempt++ {
+		retry, failed := classifyWriteErrors(pending, w.run(pending), attempt > 0)
+		if len(retry) > 0 && attempt >= w.policy.retries {
+			failed = append(failed, retry...)
+			retry = nil
+		}
+		atomic.AddInt64(&w.written, int64(len(pending)-len(retry)-len(failed)))
+		w.policy.deadLetter(w.database, w.collection, failed)
+		atomic.AddInt64(&w.failed, int64(len(failed)))
+
+		if len(retry) == 0 {
+			break
+		}
+
+		w.policy.log.WithFields(log.Fields{
+			"database":   w.database,
+			"collection": w.collection,
+			"documents":  len(retry),
+			"attempt":    attempt + 1,
+			"error":      retry[0].err.Error(),
+		}).Warning("Retrying bulk write to MongoDB")
+		w.policy.sleep(backoff)
+		backoff *= 2
+
+		pending = make([]interface{}, len(retry))
+		for i := range retry {
+			pending[i] = retry[i].doc
+		}
+	}
+
+	w.buffer = w.buffer[:0]
+}
+
+//Close writes out the buffered documents and releases the writer's
+//connection to MongoDB
+func (w *BulkWriter) Close() {
+	w.Flush()
+	if w.session != nil {
+		w.session.Close()
+	}
+}
+
+//withObjectIDs gives each document an _id, keeping the _id of documents
+//which already have one. Documents which cannot be marshalled are returned
+//as failed.
+func withObjectIDs(docs []interface{}) (withIDs []interface{}, failed []failedDocument) {
+	withIDs = make([]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		withID, err := withObjectID(doc)
+		if err != nil {
+			failed = append(failed, failedDocument{doc, err})
+			continue
+		}
+		withIDs = append(withIDs, withID)
+	}
+	return withIDs, failed
+}
+
+//withObjectID returns the document's fields with a new ObjectId as its
+//_id if the document does not have an _id
+func withObjectID(doc interface{}) (interface{}, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var fields bson.RawD
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if field.Name == "_id" {
+			return fields, nil
+		}
+	}
+	id := bson.RawDocElem{
+		Name:  "_id",
+		Value: bson.Raw{Kind: 0x07, Data: []byte(bson.NewObjectId())},
+	}
+	return append(bson.RawD{id}, fields...), nil
+}
+
+//classifyWriteErrors splits the documents of a failed bulk write into
+//those which should be retried and those which failed permanently.
+//If the documents are being retried, documents which fail because their
+//_id already exists were written by an earlier attempt and are neither.
+func classifyWriteErrors(docs []interface{}, err error, retried bool) (retry []failedDocument, failed []failedDocument) {
+	if err == nil {
+		return nil, nil
+	}
+
+	bulkErr, ok := err.(*mgo.BulkError)
+	if !ok {
+		if retried && isDuplicateIDError(err) {
+			return nil, nil
+		}
+		for _, doc := range docs {
+			if isTransientWriteError(err) {
+				retry = append(retry, failedDocument{doc, err})
+			} else {
+				failed = append(failed, failedDocument{doc, err})
+			}
+		}
+		return retry, failed
+	}
+
+	//mgo may return a BulkError with no errors when every write succeeded
+	seen := make(map[int]bool)
+	for _, ecase := range bulkErr.Cases() {
+		indexes := []int{ecase.Index}
+		//old servers do not report which document failed
+		if ecase.Index < 0 || ecase.Index >= len(docs) {
+			indexes = make([]int, len(docs))
+			for i := range docs {
+				indexes[i] = i
+			}
+		}
+		for _, index := range indexes {
+			if seen[index] {
+				continue
+			}
+			seen[index] = true
+			if retried && isDuplicateIDError(ecase.Err) {
+				continue
+			}
+			if isTransientWriteError(ecase.Err) {
+				retry = append(retry, failedDocument{docs[index], ecase.Err})
+			} else {
+				failed = append(failed, failedDocument{docs[index], ecase.Err})
+			}
+		}
+	}
+	return retry, failed
+}
+
+//isTransientWriteError returns true if a write which failed with the
+//given error may succeed if it is tried again
+func isTransientWriteError(err error) bool {
+	if err == nil || mgo.IsDup(err) {
+		return false
+	}
+	switch e := err.(type) {
+	case *mgo.LastError:
+		return transientErrorCodes[e.Code]
+	case *mgo.QueryError:
+		return transientErrorCodes[e.Code]
+	case net.Error:
+		return true
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "no reachable servers") ||
+		strings.Contains(msg, "Closed explicitly") ||
+		strings.Contains(msg, "connection reset") ||
+		strings.Contains(msg, "i/o timeout")
+}
+
+//isDuplicateIDError returns true if a write failed because a document
+//with the same _id already exists
+func isDuplicateIDError(err error) bool {
+	return mgo.IsDup(err) && strings.Contains(err.Error(), "_id_")
+}
+
+//deadLetter records documents which could not be written. The documents
+//are appended as extended JSON to the file named after the target
+//database and collection in the dead letter directory.
+func (p *WritePolicy) deadLetter(database string, collection string, failed []failedDocument) {
+	if len(failed) == 0 {
+		return
+	}
+	atomic.AddInt64(&p.failed, int64(len(failed)))
+
+	fields := log.Fields{
+		"database":   database,
+		"collection": collection,
+		"documents":  len(failed),
+		"error":      failed[0].err.Error(),
+	}
+	if p.deadLetters == "" {
+		p.log.WithFields(fields).Error("Failed to write documents to MongoDB")
+		return
+	}
+
+	path := filepath.Join(p.deadLetters, database+"."+collection+".json")
+	fields["dead_letter_file"] = path
+	if err := p.writeDeadLetters(path, database, collection, failed); err != nil {
+		fields["dead_letter_error"] = err.Error()
+	}
+	p.log.WithFields(fields).Error("Failed to write documents to MongoDB")
+}
+
+//writeDeadLetters appends failed documents to a dead letter file, one
+//JSON object per line
+func (p *WritePolicy) writeDeadLetters(path string, database string, collection string,
+	failed []failedDocument) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	out := bufio.NewWriter(file)
+	now := time.Now()
+	for _, entry := range failed {
+		line, err := deadLetterJSON(now, database, collection, entry)
+		if err != nil {
+			return err
+		}
+		out.Write(line)
+	}
+	return out.Flush()
+}
+
+//deadLetterJSON formats a failed document and the reason it was not
+//written as a line of extended JSON
+func deadLetterJSON(now time.Time, database string, collection string,
+	entry failedDocument) ([]byte, error) {
+	//round trip through bson so the document's bson field names are used
+	raw, err := bson.Marshal(entry.doc)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return bson.MarshalJSON(bson.M{
+		"time":       now,
+		"database":   database,
+		"collection": collection,
+		"error":      entry.err.Error(),
+		"document":   doc,
+	})
+}
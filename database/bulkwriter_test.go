@@ -0,0 +1,146 @@
+package database
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bulkWriterTestDoc struct {
+	Name string `bson:"name"`
+}
+
+//newTestBulkWriter creates a BulkWriter whose writes return the next error
+//in errs, recording the documents of each attempt
+func newTestBulkWriter(policy *WritePolicy, errs []error, attempts *[][]interface{}) *BulkWriter {
+	writer := newBulkWriter("db", "coll", 2, policy)
+	writer.run = func(docs []interface{}) error {
+		*attempts = append(*attempts, append([]interface{}{}, docs...))
+		if len(errs) == 0 {
+			return nil
+		}
+		err := errs[0]
+		errs = errs[1:]
+		return err
+	}
+	return writer
+}
+
+func TestBulkWriterRetriesTransientErrors(t *testing.T) {
+	policy := NewWritePolicy(3, time.Second, "", log.New())
+	var waits []time.Duration
+	policy.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	var attempts [][]interface{}
+	writer := newTestBulkWriter(policy, []error{io.EOF, errors.New("no reachable servers")}, &attempts)
+	writer.Insert(bulkWriterTestDoc{"a"})
+	assert.Equal(t, 1, writer.Buffered())
+	writer.Insert(bulkWriterTestDoc{"b"})
+	assert.Equal(t, 0, writer.Buffered())
+
+	assert.Len(t, attempts, 3)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+	assert.Equal(t, int64(2), writer.Written())
+	assert.Equal(t, int64(0), writer.Failed())
+	assert.Equal(t, int64(0), policy.Failed())
+}
+
+func TestBulkWriterDeadLetters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-deadletters")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	policy := NewWritePolicy(1, time.Second, dir, log.New())
+	policy.sleep = func(time.Duration) {}
+
+	// permanent errors are not retried
+	var attempts [][]interface{}
+	writer := newTestBulkWriter(policy, []error{&mgo.LastError{Code: 121, Err: "invalid document"}}, &attempts)
+	writer.Insert(bulkWriterTestDoc{"a"})
+	writer.Close()
+	assert.Len(t, attempts, 1)
+	assert.Equal(t, int64(1), writer.Failed())
+
+	// transient errors are given up on after the configured retries
+	attempts = nil
+	writer = newTestBulkWriter(policy, []error{io.EOF, io.EOF, io.EOF}, &attempts)
+	writer.Insert(bulkWriterTestDoc{"b"})
+	writer.Close()
+	assert.Len(t, attempts, 2)
+	assert.Equal(t, int64(0), writer.Written())
+	assert.Equal(t, int64(1), writer.Failed())
+
+	assert.Equal(t, int64(2), policy.Failed())
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "db.coll.json"))
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"name":"a"`)
+	assert.Contains(t, lines[0], `"error":"invalid document"`)
+	assert.Contains(t, lines[1], `"name":"b"`)
+	assert.Contains(t, lines[1], `"collection":"coll"`)
+}
+
+func TestBulkWriterRetriesDoNotDuplicate(t *testing.T) {
+	policy := NewWritePolicy(3, time.Second, "", log.New())
+	policy.sleep = func(time.Duration) {}
+
+	// the connection is lost after the documents were written, so the
+	// retry finds them already stored
+	dupErr := &mgo.LastError{
+		Code: 11000,
+		Err:  "E11000 duplicate key error collection: db.coll index: _id_ dup key",
+	}
+	var attempts [][]interface{}
+	writer := newTestBulkWriter(policy, []error{io.EOF, dupErr}, &attempts)
+	writer.Insert(bulkWriterTestDoc{"a"})
+	writer.Insert(bulkWriterTestDoc{"b"})
+
+	require.Len(t, attempts, 2)
+	assert.Equal(t, attempts[0], attempts[1])
+	ids := make(map[string]bool)
+	for _, doc := range attempts[0] {
+		var fields struct {
+			ID   bson.ObjectId `bson:"_id"`
+			Name string        `bson:"name"`
+		}
+		raw, err := bson.Marshal(doc)
+		require.Nil(t, err)
+		require.Nil(t, bson.Unmarshal(raw, &fields))
+		assert.True(t, fields.ID.Valid())
+		ids[fields.ID.Hex()] = true
+	}
+	assert.Len(t, ids, 2)
+	assert.Equal(t, int64(2), writer.Written())
+	assert.Equal(t, int64(0), writer.Failed())
+
+	// a duplicate _id on the first attempt is not one of the writer's own
+	attempts = nil
+	writer = newTestBulkWriter(policy, []error{dupErr}, &attempts)
+	writer.Insert(bulkWriterTestDoc{"c"})
+	writer.Close()
+	assert.Len(t, attempts, 1)
+	assert.Equal(t, int64(0), writer.Written())
+	assert.Equal(t, int64(1), writer.Failed())
+}
+
+func TestIsTransientWriteError(t *testing.T) {
+	assert.True(t, isTransientWriteError(io.EOF))
+	assert.True(t, isTransientWriteError(&mgo.LastError{Code: 10107}))
+	assert.True(t, isTransientWriteError(&mgo.QueryError{Code: 91}))
+	assert.False(t, isTransientWriteError(&mgo.LastError{Code: 11000}))
+	assert.False(t, isTransientWriteError(errors.New("document is too large")))
+	assert.False(t, isTransientWriteError(nil))
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/activecm/mgosec"
 	"github.com/activecm/rita/config"
@@ -33,6 +34,7 @@ type DB struct {
 	Session  *mgo.Session
 	log      *log.Logger
 	selected string
+	writes   *WritePolicy
 }
 
 //NewDB constructs a new DB struct
@@ -50,6 +52,12 @@ func NewDB(conf *config.Config, log *log.Logger) (*DB, error) {
 		Session:  session,
 		log:      log,
 		selected: "",
+		writes: NewWritePolicy(
+			conf.S.MongoDB.WriteRetries,
+			time.Duration(conf.S.MongoDB.WriteRetryBackoff)*time.Second,
+			conf.S.MongoDB.DeadLetterPath,
+			log,
+		),
 	}, nil
 }
 
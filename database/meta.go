@@ -32,12 +32,13 @@ type (
 
 	// DBMetaInfo defines some information about the database
 	DBMetaInfo struct {
-		ID             bson.ObjectId `bson:"_id,omitempty"`   // Ident
-		Name           string        `bson:"name"`            // Top level name of the database
-		ImportFinished bool          `bson:"import_finished"` // Has this database been entirely imported
-		Analyzed       bool          `bson:"analyzed"`        // Has this database been analyzed
-		ImportVersion  string        `bson:"import_version"`  // Rita version at import
-		AnalyzeVersion string        `bson:"analyze_version"` // Rita version at analyze
+		ID             bson.ObjectId  `bson:"_id,omitempty"`     // Ident
+		Name           string         `bson:"name"`              // Top level name of the database
+		ImportFinished bool           `bson:"import_finished"`   // Has this database been entirely imported
+		Analyzed       bool           `bson:"analyzed"`          // Has this database been analyzed
+		ImportVersion  string         `bson:"import_version"`    // Rita version at import
+		AnalyzeVersion string         `bson:"analyze_version"`   // Rita version at analyze
+		Quality        *QualityReport `bson:"quality,omitempty"` // Completeness of the imported data
 	}
 )
 
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// QualityReport describes the completeness of the data imported into a
+	// database. It is rebuilt at the end of each import.
+	QualityReport struct {
+		Created time.Time    `bson:"created"`      // Time the report was built
+		Start   int64        `bson:"start"`        // Earliest record timestamp
+		End     int64        `bson:"end"`          // Latest record timestamp
+		Logs    []LogVolume  `bson:"logs"`         // Record counts by log type and sensor
+		Gaps    []VolumeGap  `bson:"gaps"`         // Hours without records
+		Drops   []VolumeDrop `bson:"drops"`        // Hours with unusually few records
+		Skews   []ClockSkew  `bson:"skewed_files"` // Files logged with a skewed clock
+	}
+
+	// LogVolume counts the records of a log type recorded by a sensor in
+	// each hour
+	LogVolume struct {
+		Collection string      `bson:"collection"`       // Collection holding the log type
+		Sensor     string      `bson:"sensor,omitempty"` // Sensor which recorded the logs
+		Records    int64       `bson:"records"`          // Total number of records
+		Hours      []HourCount `bson:"hours"`            // Records by hour, in order
+	}
+
+	// HourCount counts the records logged in the hour starting at Hour
+	HourCount struct {
+		Hour    int64 `bson:"hour"`
+		Records int64 `bson:"records"`
+	}
+
+	// VolumeGap is a run of hours in which no records of a log type
+	// were logged, starting at Start and ending before End
+	VolumeGap struct {
+		Collection string `bson:"collection"`
+		Sensor     string `bson:"sensor,omitempty"`
+		Start      int64  `bson:"start"`
+		End        int64  `bson:"end"`
+	}
+
+	// VolumeDrop is an hour holding far fewer records of a log type than the
+	// hours before it
+	VolumeDrop struct {
+		Collection string  `bson:"collection"`
+		Sensor     string  `bson:"sensor,omitempty"`
+		Hour       int64   `bson:"hour"`
+		Records    int64   `bson:"records"`
+		Expected   float64 `bson:"expected"` // Median records of the preceding hours
+	}
+
+	// ClockSkew is a file whose last record was logged far from the time
+	// the file was last modified
+	ClockSkew struct {
+		Path     string    `bson:"filepath"`
+		Sensor   string    `bson:"sensor"`
+		LastTs   int64     `bson:"last_ts"`
+		Modified time.Time `bson:"modified"`
+		Skew     int64     `bson:"skew"` // Seconds the file was modified after its last record
+	}
+)
+
+// SetQualityReport stores the data quality report for a database
+func (m *MetaDB) SetQualityReport(name string, report QualityReport) error {
+	dbr, err := m.GetDBMetaInfo(name)
+	if err != nil {
+		m.log.WithFields(log.Fields{
+			"database_requested": name,
+			"error":              err.Error(),
+		}).Error("database not found in metadata directory")
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	ssn := m.dbHandle.Copy()
+	defer ssn.Close()
+
+	err = ssn.DB(m.config.S.Bro.MetaDB).C(m.config.T.Meta.DatabasesTable).
+		Update(bson.M{"_id": dbr.ID}, bson.M{
+			"$set": bson.D{
+				{"quality", report},
+			},
+		})
+
+	if err != nil {
+		m.log.WithFields(log.Fields{
+			"metadb_attempted":   m.config.S.Bro.MetaDB,
+			"database_requested": name,
+			"_id":                dbr.ID.Hex,
+			"error":              err.Error(),
+		}).Error("could not update database entry in meta")
+		return err
+	}
+	return nil
+}
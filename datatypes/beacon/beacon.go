@@ -1,67 +1,185 @@
 package beacon
 
 import (
+	"time"
+
+	"github.com/activecm/rita/datatypes/dhcp"
 	"github.com/globalsign/mgo/bson"
 )
 
 type (
 	//AnalysisInput contains the summary statistics of a unique connection
 	AnalysisInput struct {
-		ID              bson.ObjectId `bson:"_id,omitempty"`    // Unique Connection ID
-		Src             string        `bson:"src"`              // Source IP
-		Dst             string        `bson:"dst"`              // Destination IP
-		TsList          []int64       `bson:"ts_list"`          // Connection timestamps for this src, dst pair
-		OrigIPBytes     []int64       `bson:"orig_bytes_list"`  // Src to dst connection sizes for each connection
-		ConnectionCount int           `bson:"connection_count"` // Total connection count between pair
+		ID              bson.ObjectId `bson:"_id,omitempty"`      // Unique Connection ID
+		Src             string        `bson:"src"`                // Source IP
+		Dst             string        `bson:"dst"`                // Destination IP
+		DstPort         int           `bson:"dst_port,omitempty"` // Destination port when grouped by service
+		Proto           string        `bson:"proto,omitempty"`    // Protocol when grouped by service
+		TsList          []int64       `bson:"ts_list"`            // Connection timestamps for this src, dst pair
+		OrigIPBytes     []int64       `bson:"orig_bytes_list"`    // Src to dst connection sizes for each connection
+		RespIPBytes     []int64       `bson:"resp_bytes_list"`    // Dst to src connection sizes for each connection
+		ConnectionCount int           `bson:"connection_count"`   // Total connection count between pair
 		AverageBytes    float32       `bson:"avg_bytes"`
+		Sensors         []string      `bson:"sensors"`               // Sensors which recorded the connections
+		SrcDevices      []dhcp.Lease  `bson:"src_devices,omitempty"` // Devices which held the source IP
+		DstDevices      []dhcp.Lease  `bson:"dst_devices,omitempty"` // Devices which held the destination IP
+		DstIPs          []string      `bson:"dst_ips,omitempty"`     // Addresses a destination hostname resolved to
 	}
 
 	//AnalysisOutput contains the summary statistics of a unique beacon
 	AnalysisOutput struct {
-		UconnID          bson.ObjectId `bson:"uconn_id"`
-		Src              string        `bson:"src"`
-		Dst              string        `bson:"dst"`
-		ConnectionCount  int           `bson:"connection_count"`
-		AverageBytes     float32       `bson:"avg_bytes"`
-		TSIRange         int64         `bson:"ts_iRange"`
-		TSIMode          int64         `bson:"ts_iMode"`
-		TSIModeCount     int64         `bson:"ts_iMode_count"`
-		TSIntervals      []int64       `bson:"ts_intervals"`
-		TSIntervalCounts []int64       `bson:"ts_interval_counts"`
-		TSIDispersion    int64         `bson:"ts_iDispersion"`
-		TSISkew          float64       `bson:"ts_iSkew"`
-		TSDuration       float64       `bson:"ts_duration"`
-		TSScore          float64       `bson:"ts_score"`
-		DSRange          int64         `bson:"ds_range"`
-		DSMode           int64         `bson:"ds_mode"`
-		DSModeCount      int64         `bson:"ds_mode_count"`
-		DSSizes          []int64       `bson:"ds_sizes"`
-		DSSizeCounts     []int64       `bson:"ds_counts"`
-		DSDispersion     int64         `bson:"ds_dispersion"`
-		DSSkew           float64       `bson:"ds_skew"`
-		DSScore          float64       `bson:"ds_score"`
-		Score            float64       `bson:"score"`
+		UconnID           bson.ObjectId     `bson:"uconn_id,omitempty"`
+		Src               string            `bson:"src"`
+		Dst               string            `bson:"dst"`
+		DstPort           int               `bson:"dst_port,omitempty"`
+		Proto             string            `bson:"proto,omitempty"`
+		ConnectionCount   int               `bson:"connection_count"`
+		AverageBytes      float32           `bson:"avg_bytes"`
+		TSIRange          int64             `bson:"ts_iRange"`
+		TSIMode           int64             `bson:"ts_iMode"`
+		TSIModeCount      int64             `bson:"ts_iMode_count"`
+		TSIntervals       []int64           `bson:"ts_intervals"`
+		TSIntervalCounts  []int64           `bson:"ts_interval_counts"`
+		TSIDispersion     int64             `bson:"ts_iDispersion"`
+		TSISkew           float64           `bson:"ts_iSkew"`
+		TSDuration        float64           `bson:"ts_duration"`
+		TSPeriod          int64             `bson:"ts_period"`
+		TSPeriodStrength  float64           `bson:"ts_period_strength"`
+		TSClusters        []IntervalCluster `bson:"ts_clusters"`
+		TSClusterScore    float64           `bson:"ts_cluster_score"`
+		TSCoverage        float64           `bson:"ts_coverage"`
+		TSConsistency     float64           `bson:"ts_consistency"`
+		TSSkewScore       float64           `bson:"ts_skew_score"`
+		TSDispersionScore float64           `bson:"ts_dispersion_score"`
+		TSDurationScore   float64           `bson:"ts_duration_score"`
+		TSScore           float64           `bson:"ts_score"`
+		DSRange           int64             `bson:"ds_range"`
+		DSMode            int64             `bson:"ds_mode"`
+		DSModeCount       int64             `bson:"ds_mode_count"`
+		DSSizes           []int64           `bson:"ds_sizes"`
+		DSSizeCounts      []int64           `bson:"ds_counts"`
+		DSDispersion      int64             `bson:"ds_dispersion"`
+		DSSkew            float64           `bson:"ds_skew"`
+		DSSkewScore       float64           `bson:"ds_skew_score"`
+		DSDispersionScore float64           `bson:"ds_dispersion_score"`
+		DSSmallnessScore  float64           `bson:"ds_smallness_score"`
+		RSRange           int64             `bson:"rs_range"`
+		RSMode            int64             `bson:"rs_mode"`
+		RSModeCount       int64             `bson:"rs_mode_count"`
+		RSSizes           []int64           `bson:"rs_sizes"`
+		RSSizeCounts      []int64           `bson:"rs_counts"`
+		RSDispersion      int64             `bson:"rs_dispersion"`
+		RSSkew            float64           `bson:"rs_skew"`
+		RSSkewScore       float64           `bson:"rs_skew_score"`
+		RSDispersionScore float64           `bson:"rs_dispersion_score"`
+		RSSmallnessScore  float64           `bson:"rs_smallness_score"`
+		RSScore           float64           `bson:"rs_score"`
+		DSScore           float64           `bson:"ds_score"`
+		Score             float64           `bson:"score"`
+		Sensors           []string          `bson:"sensors"`
+		SrcDevices        []dhcp.Lease      `bson:"src_devices,omitempty"`
+		DstDevices        []dhcp.Lease      `bson:"dst_devices,omitempty"`
+		DstIPs            []string          `bson:"dst_ips,omitempty"`
+		Parameters        ScoringParameters `bson:"parameters"`
 	}
 
-	//AnalysisView used in order to join the uconn and beacon tables
-	AnalysisView struct {
-		Src           string  `bson:"src"`
-		Dst           string  `bson:"dst"`
-		LocalSrc      bool    `bson:"local_src"`
-		LocalDst      bool    `bson:"local_dst"`
-		Connections   int64   `bson:"connection_count"`
-		AvgBytes      float64 `bson:"avg_bytes"`
-		TSIRange      int64   `bson:"ts_iRange"`
-		TSIMode       int64   `bson:"ts_iMode"`
-		TSIModeCount  int64   `bson:"ts_iMode_count"`
-		TSISkew       float64 `bson:"ts_iSkew"`
-		TSIDispersion int64   `bson:"ts_iDispersion"`
+	//ScoringParameters holds the settings a beacon was scored with
+	ScoringParameters struct {
+		MaxConnections           int            `bson:"max_connections"`
+		IntervalDispersionCutoff float64        `bson:"ts_dispersion_cutoff"`
+		SizeDispersionCutoff     float64        `bson:"ds_dispersion_cutoff"`
+		SmallnessCutoff          float64        `bson:"ds_smallness_cutoff"`
+		CoverageBucket           int64          `bson:"coverage_bucket"`
+		Weights                  ScoringWeights `bson:"weights"`
+	}
+
+	//ScoringWeights holds how much each sub-score contributed to a
+	//beacon's score
+	ScoringWeights struct {
+		TSSkew        float64 `bson:"ts_skew"`
+		TSDispersion  float64 `bson:"ts_dispersion"`
 		TSDuration    float64 `bson:"ts_duration"`
-		Score         float64 `bson:"score"`
+		TSPeriod      float64 `bson:"ts_period"`
+		TSClusters    float64 `bson:"ts_clusters"`
+		TSCoverage    float64 `bson:"ts_coverage"`
+		TSConsistency float64 `bson:"ts_consistency"`
 		DSSkew        float64 `bson:"ds_skew"`
-		DSDispersion  int64   `bson:"ds_dispersion"`
-		DSRange       int64   `bson:"ds_range"`
-		DSMode        int64   `bson:"ds_mode"`
-		DSModeCount   int64   `bson:"ds_mode_count"`
+		DSDispersion  float64 `bson:"ds_dispersion"`
+		DSSmallness   float64 `bson:"ds_smallness"`
+		RSSkew        float64 `bson:"rs_skew"`
+		RSDispersion  float64 `bson:"rs_dispersion"`
+		RSSmallness   float64 `bson:"rs_smallness"`
+	}
+
+	//IntervalCluster is a group of similar intervals between connections.
+	//Beacons which sleep between bursts of requests form a cluster for the
+	//intervals within the bursts and another for the sleep.
+	IntervalCluster struct {
+		Center     int64   `bson:"center"`     // Median interval of the cluster
+		Count      int64   `bson:"count"`      // Number of intervals in the cluster
+		Weight     float64 `bson:"weight"`     // Fraction of all intervals in the cluster
+		Dispersion int64   `bson:"dispersion"` // Median absolute deviation from the center
+	}
+
+	//AnalysisView used in order to join the uconn and beacon tables
+	AnalysisView struct {
+		Src               string            `bson:"src"`
+		Dst               string            `bson:"dst"`
+		DstPort           int               `bson:"dst_port"`
+		Proto             string            `bson:"proto"`
+		LocalSrc          bool              `bson:"local_src"`
+		LocalDst          bool              `bson:"local_dst"`
+		Connections       int64             `bson:"connection_count"`
+		AvgBytes          float64           `bson:"avg_bytes"`
+		TSIRange          int64             `bson:"ts_iRange"`
+		TSIMode           int64             `bson:"ts_iMode"`
+		TSIModeCount      int64             `bson:"ts_iMode_count"`
+		TSISkew           float64           `bson:"ts_iSkew"`
+		TSIDispersion     int64             `bson:"ts_iDispersion"`
+		TSDuration        float64           `bson:"ts_duration"`
+		TSPeriod          int64             `bson:"ts_period"`
+		TSPeriodStr       float64           `bson:"ts_period_strength"`
+		TSClusters        []IntervalCluster `bson:"ts_clusters"`
+		TSClusterScore    float64           `bson:"ts_cluster_score"`
+		TSCoverage        float64           `bson:"ts_coverage"`
+		TSConsistency     float64           `bson:"ts_consistency"`
+		Score             float64           `bson:"score"`
+		DSSkew            float64           `bson:"ds_skew"`
+		DSDispersion      int64             `bson:"ds_dispersion"`
+		DSRange           int64             `bson:"ds_range"`
+		DSMode            int64             `bson:"ds_mode"`
+		DSModeCount       int64             `bson:"ds_mode_count"`
+		RSSkew            float64           `bson:"rs_skew"`
+		RSDispersion      int64             `bson:"rs_dispersion"`
+		RSRange           int64             `bson:"rs_range"`
+		RSMode            int64             `bson:"rs_mode"`
+		RSModeCount       int64             `bson:"rs_mode_count"`
+		Sensors           []string          `bson:"sensors"`
+		SrcDevices        []dhcp.Lease      `bson:"src_devices"`
+		DstDevices        []dhcp.Lease      `bson:"dst_devices"`
+		DstIPs            []string          `bson:"dst_ips"`
+		TSSkewScore       float64           `bson:"ts_skew_score"`
+		TSDispersionScore float64           `bson:"ts_dispersion_score"`
+		TSDurationScore   float64           `bson:"ts_duration_score"`
+		DSSkewScore       float64           `bson:"ds_skew_score"`
+		DSDispersionScore float64           `bson:"ds_dispersion_score"`
+		DSSmallnessScore  float64           `bson:"ds_smallness_score"`
+		RSSkewScore       float64           `bson:"rs_skew_score"`
+		RSDispersionScore float64           `bson:"rs_dispersion_score"`
+		RSSmallnessScore  float64           `bson:"rs_smallness_score"`
+		Parameters        ScoringParameters `bson:"parameters"`
+		Suppression       *Suppression      `bson:"suppression,omitempty"`
+	}
+
+	//Suppression marks a beacon to known good hosts which is hidden from
+	//results
+	Suppression struct {
+		Reason  string `bson:"reason"`
+		Expires int64  `bson:"expires"` // Time the suppression ends, zero if it never ends
 	}
 )
+
+//Active returns true if the suppression has not expired
+func (s *Suppression) Active(now time.Time) bool {
+	return s.Expires == 0 || now.Unix() < s.Expires
+}
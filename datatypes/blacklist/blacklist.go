@@ -1,28 +1,35 @@
 package blacklist
 
-import "github.com/globalsign/mgo/bson"
+import (
+	"github.com/activecm/rita/datatypes/dhcp"
+	"github.com/globalsign/mgo/bson"
+)
 
 //BlacklistedIP holds information on a blacklisted IP address and
 //the summary statistics on the host
 type (
 	BlacklistedIP struct {
-		IP                string   `bson:"ip"`
-		Connections       int      `bson:"conn"`
-		UniqueConnections int      `bson:"uconn"`
-		TotalBytes        int      `bson:"total_bytes"`
-		Lists             []string `bson:"lists"`
-		ConnectedHosts    []string `bson:",omitempty"`
+		IP                string       `bson:"ip"`
+		Connections       int          `bson:"conn"`
+		UniqueConnections int          `bson:"uconn"`
+		TotalBytes        int          `bson:"total_bytes"`
+		Lists             []string     `bson:"lists"`
+		TargetDevices     []dhcp.Lease `bson:"target_devices"`
+		Sensors           []string     `bson:"sensors"`
+		ConnectedHosts    []string     `bson:",omitempty"`
 	}
 
 	//BlacklistedHostname holds information on a blacklisted hostname and
 	//the summary statistics associated with the hosts behind the hostname
 	BlacklistedHostname struct {
-		Hostname          string   `bson:"hostname"`
-		Connections       int      `bson:"conn"`
-		UniqueConnections int      `bson:"uconn"`
-		TotalBytes        int      `bson:"total_bytes"`
-		Lists             []string `bson:"lists"`
-		ConnectedHosts    []string `bson:",omitempty"`
+		Hostname          string       `bson:"hostname"`
+		Connections       int          `bson:"conn"`
+		UniqueConnections int          `bson:"uconn"`
+		TotalBytes        int          `bson:"total_bytes"`
+		Lists             []string     `bson:"lists"`
+		TargetDevices     []dhcp.Lease `bson:"target_devices"`
+		Sensors           []string     `bson:"sensors"`
+		ConnectedHosts    []string     `bson:",omitempty"`
 	}
 
 	//IPAnalysisInput contains the summary statistics of a unique connection
@@ -33,6 +40,17 @@ type (
 		TotalBytes        int      `bson:"total_bytes"`
 		AverageBytes      int      `bson:"avg_bytes"`
 		Targets           []string `bson:"targets"`
+		// When each target contacted the IP
+		TargetTimes []TargetTime `bson:"target_times"`
+		// Sensors which recorded the connections
+		Sensors []string `bson:"sensors"`
+	}
+
+	//TargetTime records when a host contacted a blacklisted host
+	TargetTime struct {
+		IP    string `bson:"ip"`
+		First int64  `bson:"first"`
+		Last  int64  `bson:"last"`
 	}
 
 	//HostnameAnalysisInput contains the summary statistics of a unique hostname
@@ -66,6 +84,10 @@ type (
 		AverageBytes      int      `bson:"avg_bytes"`
 		Lists             []string `bson:"lists"`
 		Targets           []string `bson:"targets"`
+		// Devices which held the target addresses while they contacted the IP
+		TargetDevices []dhcp.Lease `bson:"target_devices,omitempty"`
+		// Sensors which recorded the connections
+		Sensors []string `bson:"sensors"`
 	}
 
 	//HostnameAnalysisOutput contains the summary statistics of a unique connection
@@ -78,5 +100,10 @@ type (
 		Lists             []string `bson:"lists"`
 		Targets           []string `bson:"targets"`
 		IPs               []string `bson:"ips"` // associated ips
+		// Devices which held the target addresses while they contacted the
+		// hostname
+		TargetDevices []dhcp.Lease `bson:"target_devices,omitempty"`
+		// Sensors which recorded the connections
+		Sensors []string `bson:"sensors"`
 	}
 )
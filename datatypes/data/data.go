@@ -18,6 +18,7 @@ type (
 		OriginIPBytes   int64   `bson:"orig_ip_bytes,omitempty"`
 		OriginPackets   int64   `bson:"orig_pkts,omitempty"`
 		ResponsePackets int64   `bson:"resp_pkts,omitempty"`
+		Sensor          string  `bson:"sensor,omitempty"`
 	}
 
 	// DNS provides structure for a subset of the fields in the
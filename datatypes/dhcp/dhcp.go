@@ -0,0 +1,22 @@
+package dhcp
+
+type (
+	//Lease maps to an entry in the dhcp leases collection. It records the
+	//device which held an IP address between the start and end times.
+	Lease struct {
+		IP       string `bson:"ip"`
+		MAC      string `bson:"mac"`
+		Hostname string `bson:"hostname"`
+		Start    int64  `bson:"start"`
+		End      int64  `bson:"end"`
+	}
+)
+
+//Device names the device which held the lease by its hostname and MAC
+//address
+func (l Lease) Device() string {
+	if l.Hostname == "" {
+		return l.MAC
+	}
+	return l.Hostname + " (" + l.MAC + ")"
+}
@@ -3,9 +3,10 @@ package dns
 type (
 	//ExplodedDNS maps to an entry in the exploded dns collection
 	ExplodedDNS struct {
-		Domain     string `bson:"domain"`
-		Subdomains int64  `bson:"subdomains"`
-		Visited    int64  `bson:"visited"`
+		Domain     string   `bson:"domain"`
+		Subdomains int64    `bson:"subdomains"`
+		Visited    int64    `bson:"visited"`
+		Sensors    []string `bson:"sensors"`
 	}
 
 	//Hostname maps to an entry in the hostnames collection
@@ -0,0 +1,26 @@
+package structure
+
+type (
+	//LongConnection describes a single logical session between two
+	//endpoints. Bro may log a long-lived session several times as its
+	//logs rotate; those records are merged into one LongConnection.
+	LongConnection struct {
+		Src          string   `bson:"src"`
+		SrcPort      int      `bson:"src_port"`
+		Dst          string   `bson:"dst"`
+		DstPort      int      `bson:"dst_port"`
+		Proto        string   `bson:"proto"`
+		TsStart      int64    `bson:"ts_start"`
+		TsEnd        int64    `bson:"ts_end"`
+		Duration     float64  `bson:"duration"`
+		OrigBytes    int64    `bson:"orig_bytes"`
+		RespBytes    int64    `bson:"resp_bytes"`
+		TotalBytes   int64    `bson:"total_bytes"`
+		OrigPackets  int64    `bson:"orig_pkts"`
+		RespPackets  int64    `bson:"resp_pkts"`
+		TotalPackets int64    `bson:"total_pkts"`
+		RecordCount  int      `bson:"record_count"`
+		UIDs         []string `bson:"uids"`
+		Sensors      []string `bson:"sensors"`
+	}
+)
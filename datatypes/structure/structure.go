@@ -1,6 +1,7 @@
 package structure
 
 import (
+	"github.com/activecm/rita/datatypes/dhcp"
 	"github.com/globalsign/mgo/bson"
 )
 
@@ -28,14 +29,18 @@ type (
 		CountDst   int32         `bson:"count_dst"`
 		IPv4Binary int64         `bson:"ipv4_binary"`
 		// IPv6Binary IPv6Integers  `bson:"ipv6_binary"` // for future ipv6 support
-		MaxDuration        float32 `bson:"max_duration"`
-		MaxBeaconScore     float64 `bson:"max_beacon_score"`
-		MaxBeaconConnCount int     `bson:"max_beacon_conn_count"`
-		BlOutCount         int32   `bson:"bl_out_count"`
-		BlInCount          int32   `bson:"bl_in_count"`
-		BlSumAvgBytes      int32   `bson:"bl_sum_avg_bytes"`
-		BlTotalBytes       int32   `bson:"bl_total_bytes"`
-		TxtQueryCount      int     `bson:"txt_query_count"`
+		MaxDuration        float32  `bson:"max_duration"`
+		MaxBeaconScore     float64  `bson:"max_beacon_score"`
+		MaxBeaconConnCount int      `bson:"max_beacon_conn_count"`
+		BlOutCount         int32    `bson:"bl_out_count"`
+		BlInCount          int32    `bson:"bl_in_count"`
+		BlSumAvgBytes      int32    `bson:"bl_sum_avg_bytes"`
+		BlTotalBytes       int32    `bson:"bl_total_bytes"`
+		TxtQueryCount      int      `bson:"txt_query_count"`
+		Sensors            []string `bson:"sensors"`
+		// Devices which held the IP address while it was seen, found in the
+		// DHCP logs
+		Devices []dhcp.Lease `bson:"devices,omitempty"`
 	}
 
 	//UniqueConnection describes a pair of IP addresses which contacted
@@ -45,13 +50,19 @@ type (
 		ConnectionCount int           `bson:"connection_count"`
 		Src             string        `bson:"src"`
 		Dst             string        `bson:"dst"`
+		DstPort         int           `bson:"dst_port,omitempty"` // Set when connections are grouped by service
+		Proto           string        `bson:"proto,omitempty"`
 		LocalSrc        bool          `bson:"local_src"`
 		LocalDst        bool          `bson:"local_dst"`
 		TotalBytes      int           `bson:"total_bytes"`
 		AverageBytes    float32       `bson:"avg_bytes"`
 		TsList          []int64       `bson:"ts_list"`         // Connection timestamps for this src, dst pair
+		FirstTs         int64         `bson:"first_ts"`        // Time of the first connection
+		LastTs          int64         `bson:"last_ts"`         // Time of the last connection
 		OrigIPBytes     []int64       `bson:"orig_bytes_list"` // Src to dst connection sizes for each connection
+		RespIPBytes     []int64       `bson:"resp_bytes_list"` // Dst to src connection sizes for each connection
 		MaxDuration     float32       `bson:"max_duration"`
 		TotalDuration   float32       `bson:"total_duration"`
+		Sensors         []string      `bson:"sensors"` // Sensors which recorded connections between the pair
 	}
 )
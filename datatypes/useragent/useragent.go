@@ -2,6 +2,7 @@ package useragent
 
 //UserAgent holds the results of the user agent analysis
 type UserAgent struct {
-	UserAgent string `bson:"user_agent"`
-	TimesUsed int64  `bson:"times_used"`
+	UserAgent string   `bson:"user_agent"`
+	TimesUsed int64    `bson:"times_used"`
+	Sensors   []string `bson:"sensors"`
 }
@@ -27,23 +27,12 @@ COLLECTOR=""
 KEYFILE=""
 
 ##################################################
-# We use shared locks for writing to the server, 
-# and an exclusive lock for parsing
-# Note: distributed locking is hard, 
-# so we use ssh to lock on the RITA server
-LOCK="$REMOTE_LOG_DIR/.rita.read.lock"
+# RITA imports the logs once they stop changing,
+# so no locking is needed on the RITA server
 
 # We will store the log data here
 DEST_DIR="$REMOTE_LOG_DIR/$COLLECTOR"
 
-# On the RITA server we lock, sleep, and wait
-# Note: we grab the sleep pid to kill the shell
-# SSH will exit gracefully
-SCRIPT='( flock -s 9; sleep infinity & echo $!; wait )9>'"$LOCK"
-
-# We use a named pipe to talk between threaded tasks
-LOCK_PIPE=".lock_pipe"
-
 # We want to transfer yesterday's logs
 TX_DIR=$LOCAL_LOG_DIR/$(date +%Y-%m-%d)
 
@@ -54,30 +43,5 @@ then
   exit 1
 fi
 
-# Cleanup the named pipe
-trap "rm -f $LOCK_PIPE" EXIT
-
-# Create the named pipe
-if [[ ! -p $LOCK_PIPE ]]
-then
-  mkfifo $LOCK_PIPE
-fi
-
-# Run the lock script from the server in the background
-# and read output into the channel
-# Stream redirection directly into the pipe doesn't work since
-# bash will wait until ssh to forward the output
-echo $SCRIPT | ssh -i $KEYFILE $USER@$REMOTE "/bin/bash" | {
-  read line
-  echo $line > $LOCK_PIPE
-} &
-
-# Grab the sleep pid
-echo "Synchronizing"
-lock_pid=$(<$LOCK_PIPE)
-echo $lock_pid
 echo "Writing"
 rsync -a -e "ssh -i $KEYFILE" $TX_DIR $USER@$REMOTE:$DEST_DIR
-
-# kill the sleep process and unlock
-ssh -i $KEYFILE $USER@$REMOTE "kill $lock_pid"
@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/activecm/rita/config"
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	log "github.com/sirupsen/logrus"
+)
+
+//clockOffsetSamples limits the number of entries read from a sensor's conn
+//and dns logs when estimating its clock offset
+const clockOffsetSamples = 100000
+
+//minClockOffsetMatches is the fewest entries seen by both a sensor and the
+//reference sensor which a clock offset estimate may be based on
+const minClockOffsetMatches = 10
+
+//configuredClockOffset returns the clock offset configured for the file.
+//An offset set for the file's sensor takes precedence over one set for a
+//directory holding the file. Directories may be absolute or relative to
+//the ImportDirectory. ok is false if no offset applies to the file.
+func configuredClockOffset(filePath string, sensor string,
+	broConfig *config.BroStaticCfg) (offset int64, ok bool) {
+	if sensorOffset, found := broConfig.ClockOffsets[sensor]; found && sensor != "" {
+		return sensorOffset, true
+	}
+
+	longest := -1
+	for dir, dirOffset := range broConfig.ClockOffsets {
+		prefix := dir
+		if !filepath.IsAbs(prefix) {
+			prefix = filepath.Join(broConfig.ImportDirectory, prefix)
+		}
+		prefix = strings.TrimSuffix(prefix, string(os.PathSeparator)) + string(os.PathSeparator)
+		if strings.HasPrefix(filePath, prefix) && len(prefix) > longest {
+			longest = len(prefix)
+			offset = dirOffset
+		}
+	}
+	return offset, longest >= 0
+}
+
+//assignClockOffsets sets the clock offset applied to the entries of each
+//file. If a ClockReference sensor is configured, the offsets of sensors
+//without a configured offset are estimated by matching their conn and dns
+//entries against the reference sensor's entries in the same import.
+func (fs *FSImporter) assignClockOffsets(indexedFiles []*fpt.IndexedFile) {
+	broConfig := &fs.res.Config.S.Bro
+	logger := fs.res.Log
+
+	var reference []*fpt.IndexedFile
+	unconfigured := make(map[string][]*fpt.IndexedFile)
+	for _, indexedFile := range indexedFiles {
+		offset, ok := configuredClockOffset(indexedFile.Path, indexedFile.Sensor, broConfig)
+		indexedFile.ClockOffset = offset
+		if broConfig.ClockReference == "" || indexedFile.Sensor == "" {
+			continue
+		}
+		if indexedFile.Sensor == broConfig.ClockReference {
+			reference = append(reference, indexedFile)
+		} else if !ok {
+			unconfigured[indexedFile.Sensor] = append(unconfigured[indexedFile.Sensor], indexedFile)
+		}
+	}
+
+	if len(unconfigured) == 0 {
+		return
+	}
+	if len(reference) == 0 {
+		logger.WithFields(log.Fields{
+			"reference_sensor": broConfig.ClockReference,
+		}).Warning("Cannot estimate clock offsets without logs from the reference sensor")
+		return
+	}
+
+	referenceTimes := sampleTimestamps(reference, logger)
+	for sensor, files := range unconfigured {
+		offset, matches := estimateClockOffset(referenceTimes,
+			sampleTimestamps(files, logger), broConfig.MaxClockOffset)
+		fields := log.Fields{
+			"sensor":           sensor,
+			"reference_sensor": broConfig.ClockReference,
+			"matches":          matches,
+		}
+		if matches < minClockOffsetMatches {
+			logger.WithFields(fields).Warning("Too few entries were seen by both sensors to estimate a clock offset")
+			continue
+		}
+		fields["offset"] = offset
+		logger.WithFields(fields).Info("Estimated sensor clock offset")
+		for _, indexedFile := range files {
+			indexedFile.ClockOffset = offset
+			indexedFile.ClockOffsetEstimated = true
+		}
+	}
+}
+
+//sampleTimestamps reads the timestamps of the conn and dns entries in the
+//files, with each file's clock offset applied. The entries are keyed by
+//their 5-tuple and query so the same entry logged by another sensor can be
+//found.
+func sampleTimestamps(indexedFiles []*fpt.IndexedFile, logger *log.Logger) map[dedupeKey]int64 {
+	times := make(map[dedupeKey]int64)
+	read := 0
+	for _, indexedFile := range indexedFiles {
+		objType := indexedFile.GetHeader().ObjType
+		if objType != "conn" && objType != "dns" {
+			continue
+		}
+
+		fileHandle, err := os.Open(indexedFile.Path)
+		if err != nil {
+			continue
+		}
+		fileScanner, err := getFileScanner(fileHandle)
+		if err != nil {
+			fileHandle.Close()
+			continue
+		}
+
+		for read < clockOffsetSamples && fileScanner.Scan() {
+			data := parseLine(
+				fileScanner.Text(),
+				indexedFile.GetHeader(),
+				indexedFile.GetFieldMap(),
+				indexedFile.GetBroDataFactory(),
+				indexedFile.ClockOffset,
+				logger,
+			)
+			if data == nil {
+				continue
+			}
+			_, key, ts, ok := getDedupeKeys(&ImportedData{BroData: data})
+			if !ok {
+				continue
+			}
+			key.collection = objType
+			if _, seen := times[key]; !seen {
+				times[key] = ts
+			}
+			read++
+		}
+		fileHandle.Close()
+	}
+	return times
+}
+
+//estimateClockOffset estimates the number of seconds to add to a sensor's
+//timestamps to match the reference sensor's clock. The estimate is the
+//median difference between the timestamps of the entries seen by both
+//sensors. Differences larger than maxOffset seconds are ignored. The
+//number of entries the estimate is based on is returned along with it.
+func estimateClockOffset(reference map[dedupeKey]int64, sample map[dedupeKey]int64,
+	maxOffset int64) (int64, int) {
+	var diffs []int64
+	for key, ts := range sample {
+		referenceTs, ok := reference[key]
+		if !ok {
+			continue
+		}
+		diff := referenceTs - ts
+		if maxOffset > 0 && (diff > maxOffset || -diff > maxOffset) {
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+	if len(diffs) == 0 {
+		return 0, 0
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i] < diffs[j] })
+	return diffs[len(diffs)/2], len(diffs)
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/config"
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	pt "github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfiguredClockOffset(t *testing.T) {
+	broConfig := &config.BroStaticCfg{
+		ImportDirectory: "/opt/bro/logs",
+		ClockOffsets: map[string]int64{
+			"site-a":              -95,
+			"site-b":              30,
+			"site-b/tap2":         45,
+			"/mnt/remote/sensors": 600,
+		},
+	}
+
+	offset, ok := configuredClockOffset("/opt/bro/logs/x/conn.log", "site-a", broConfig)
+	assert.True(t, ok)
+	assert.Equal(t, int64(-95), offset)
+
+	// the longest matching directory is used
+	offset, ok = configuredClockOffset("/opt/bro/logs/site-b/tap2/conn.log", "", broConfig)
+	assert.True(t, ok)
+	assert.Equal(t, int64(45), offset)
+	offset, ok = configuredClockOffset("/opt/bro/logs/site-b/tap1/conn.log", "", broConfig)
+	assert.True(t, ok)
+	assert.Equal(t, int64(30), offset)
+
+	offset, ok = configuredClockOffset("/mnt/remote/sensors/conn.log", "", broConfig)
+	assert.True(t, ok)
+	assert.Equal(t, int64(600), offset)
+
+	// directories must match whole path elements
+	_, ok = configuredClockOffset("/opt/bro/logs/site-bb/conn.log", "site-c", broConfig)
+	assert.False(t, ok)
+}
+
+func TestEstimateClockOffset(t *testing.T) {
+	key := func(id string) dedupeKey {
+		return dedupeKey{collection: "conn", id: id}
+	}
+	reference := map[dedupeKey]int64{
+		key("a"): 1000, key("b"): 1010, key("c"): 1020, key("d"): 1030,
+		key("e"): 5000, key("f"): 1050,
+	}
+	sample := map[dedupeKey]int64{
+		key("a"): 1060, key("b"): 1070, key("c"): 1081, key("d"): 1090,
+		// seen long after the reference saw it, ignored
+		key("e"): 100,
+		// not seen by the reference
+		key("z"): 1200,
+	}
+
+	offset, matches := estimateClockOffset(reference, sample, 3600)
+	assert.Equal(t, int64(-60), offset)
+	assert.Equal(t, 4, matches)
+
+	_, matches = estimateClockOffset(reference, map[dedupeKey]int64{key("z"): 1}, 3600)
+	assert.Equal(t, 0, matches)
+}
+
+func TestParseLineClockOffset(t *testing.T) {
+	header := &fpt.BroHeader{
+		Names:     []string{"ts", "uid", "id.orig_h"},
+		Types:     []string{"time", "string", "addr"},
+		Separator: "\t",
+		Empty:     "(empty)",
+		Unset:     "-",
+		ObjType:   "conn",
+	}
+	factory := pt.NewBroDataFactory("conn")
+	fieldMap, err := mapBroHeaderToParserType(header, factory, log.New())
+	require.Nil(t, err)
+
+	line := "1517336042.279652\tCabc\t10.0.0.1"
+	conn := parseLine(line, header, fieldMap, factory, 0, log.New()).(*pt.Conn)
+	assert.Equal(t, int64(1517336042), conn.TimeStamp)
+
+	conn = parseLine(line, header, fieldMap, factory, -95, log.New()).(*pt.Conn)
+	assert.Equal(t, int64(1517336042-95), conn.TimeStamp)
+	assert.Equal(t, "10.0.0.1", conn.Source)
+}
+
+func TestFileInWindowClockOffset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-clockoffset")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "conn.log")
+	require.Nil(t, ioutil.WriteFile(path, []byte("#close\t2018-01-01-01-00-00\n"), 0644))
+	closeTime, err := scanCloseTime(path)
+	require.Nil(t, err)
+
+	indexedFile := &fpt.IndexedFile{Path: path, Sensor: "site-b"}
+	indexedFile.SetHeader(&fpt.BroHeader{Open: closeTime.Add(-time.Hour)})
+	window := TimeWindow{Since: closeTime.Add(time.Minute)}
+	broConfig := &config.BroStaticCfg{ImportDirectory: dir}
+
+	assert.False(t, fileInWindow(indexedFile, window, broConfig, log.New()))
+
+	//the file's clock is two minutes behind
+	broConfig.ClockOffsets = map[string]int64{"site-b": 120}
+	assert.True(t, fileInWindow(indexedFile, window, broConfig, log.New()))
+
+	//files whose offset may be estimated are checked against a wider range
+	broConfig.ClockOffsets = nil
+	broConfig.ClockReference = "site-a"
+	broConfig.MaxClockOffset = 3600
+	assert.True(t, fileInWindow(indexedFile, window, broConfig, log.New()))
+}
@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/activecm/rita/analysis/quality"
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+)
+
+//DatasetImport imports bro files into a dataset in batches as they arrive.
+//The number of conn entries for each unique connection is kept across the
+//batches, so connections spread over several batches are still moved to
+//the strobe collection once they exceed the connection limit. Indexing and
+//the data quality report are left until the dataset is finished.
+type DatasetImport struct {
+	importer   *FSImporter
+	database   string
+	filter     *recordFilter
+	datastores []Datastore
+}
+
+//NewDatasetImport starts importing batches of files into the database.
+//Every file imported must target the database.
+func (fs *FSImporter) NewDatasetImport(database string) *DatasetImport {
+	return &DatasetImport{
+		importer: fs,
+		database: database,
+		filter:   fs.newRecordFilter(nil),
+	}
+}
+
+//ImportFiles imports a batch of bro files into the dataset. The datastore
+//is flushed before returning and may not be reused. If sensor is set, it
+//overrides the sensor assigned to each file. The files which were imported
+//are returned.
+func (d *DatasetImport) ImportFiles(files []string, sensor string,
+	datastore Datastore) []*fpt.IndexedFile {
+	fs := d.importer
+	start := time.Now()
+	fs.res.Log.WithFields(log.Fields{
+		"start_time": start.Format(util.TimeFormat),
+		"files":      len(files),
+		"database":   d.database,
+	}).Info("Starting dataset import. Collecting file details.")
+
+	indexedFiles := fs.indexNewFiles(files, sensor, start)
+	if len(indexedFiles) == 0 {
+		return nil
+	}
+
+	d.filter.datastore = datastore
+	fs.parseFiles(indexedFiles, fs.parseThreads, d.filter, fs.res.Log)
+	datastore.Flush()
+	d.datastores = append(d.datastores, datastore)
+
+	updateFilesIndex(indexedFiles, fs.res.MetaDB, fs.res.Log)
+
+	progTime := time.Now()
+	fs.res.Log.WithFields(log.Fields{
+		"current_time": progTime.Format(util.TimeFormat),
+		"total_time":   progTime.Sub(start).String(),
+		"database":     d.database,
+	}).Info("Finished importing batch of log files")
+	return indexedFiles
+}
+
+//Finish moves the unique connections which exceeded the connection limit
+//into the strobe collection, indexes the data, and builds the data quality
+//report of the dataset. Nothing is done if no files were imported.
+func (d *DatasetImport) Finish() {
+	if len(d.datastores) == 0 {
+		return
+	}
+	fs := d.importer
+
+	fs.bulkRemoveHugeUconns(d.database, d.filter)
+	fmt.Println("\t[-] Indexing log entries. This may take a while.")
+	for _, datastore := range d.datastores {
+		datastore.Index()
+	}
+	quality.BuildQualityReport(fs.res, d.database)
+
+	d.datastores = nil
+	d.filter = fs.newRecordFilter(nil)
+	fs.res.Log.WithFields(log.Fields{
+		"database": d.database,
+	}).Info("Finished importing dataset")
+}
@@ -1,6 +1,9 @@
 package parser
 
-import "github.com/activecm/rita/parser/parsetypes"
+import (
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	"github.com/activecm/rita/parser/parsetypes"
+)
 
 //Datastore allows RITA to store bro data in a database
 type Datastore interface {
@@ -9,6 +12,13 @@ type Datastore interface {
 	Index()
 }
 
+//fileTracker is implemented by datastores which need to know which files
+//are still being read
+type fileTracker interface {
+	ExpectFiles([]*fpt.IndexedFile)
+	FinishFile(*fpt.IndexedFile)
+}
+
 //ImportedData directs BroData to a specific database and collection
 type ImportedData struct {
 	BroData          parsetypes.BroData
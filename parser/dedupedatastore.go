@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"fmt"
+	"sync"
+
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	"github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+)
+
+//dedupePruneInterval is the number of entries checked between sweeps
+//for keys which are too old to match any entry still to come
+const dedupePruneInterval = 100000
+
+//dedupeOpenSlack is how long before a file was opened its entries may
+//start. Long connections are logged when they end, so their timestamps
+//may precede the file's #open time.
+const dedupeOpenSlack = 3600
+
+type (
+	//DedupingDatastore wraps a Datastore and drops bro entries which have
+	//already been stored. Entries are matched by their bro uid or by their
+	//5-tuple, and their timestamps must be within a tolerance, which
+	//catches the same traffic recorded by overlapping sensors.
+	DedupingDatastore struct {
+		datastore  Datastore
+		tolerance  int64
+		logger     *log.Logger
+		lock       *sync.Mutex
+		uids       map[dedupeKey]int64
+		tuples     map[dedupeKey][]int64
+		duplicates map[string]int64
+		// pruneInterval is the number of entries checked between sweeps
+		pruneInterval int
+		// checked counts the entries checked since the last sweep
+		checked int
+		// reading holds the watermark of each file which has not been
+		// read to the end. No entry still to come from a file is older
+		// than its watermark.
+		reading map[*fpt.IndexedFile]int64
+	}
+
+	//dedupeKey identifies a bro entry within a target collection.
+	//id holds either the bro uid or the 5-tuple, and detail distinguishes
+	//entries which legitimately share a connection, such as several DNS
+	//queries or pipelined HTTP requests.
+	dedupeKey struct {
+		database   string
+		collection string
+		id         string
+		detail     string
+	}
+)
+
+//NewDedupingDatastore returns a DedupingDatastore which forwards unique
+//entries to the given datastore. Entries with the same 5-tuple whose
+//timestamps are within tolerance seconds are considered duplicates.
+func NewDedupingDatastore(datastore Datastore, tolerance int64,
+	logger *log.Logger) *DedupingDatastore {
+	return &DedupingDatastore{
+		datastore:     datastore,
+		tolerance:     tolerance,
+		logger:        logger,
+		lock:          new(sync.Mutex),
+		uids:          make(map[dedupeKey]int64),
+		tuples:        make(map[dedupeKey][]int64),
+		duplicates:    make(map[string]int64),
+		pruneInterval: dedupePruneInterval,
+		reading:       make(map[*fpt.IndexedFile]int64),
+	}
+}
+
+//Store forwards the data to the wrapped datastore unless it has been seen
+func (d *DedupingDatastore) Store(data *ImportedData) {
+	uidKey, tupleKey, ts, ok := getDedupeKeys(data)
+	if !ok {
+		d.datastore.Store(data)
+		return
+	}
+
+	d.lock.Lock()
+	duplicate := d.checkAndRecord(uidKey, tupleKey, ts)
+	if duplicate {
+		d.duplicates[data.TargetDatabase+"."+data.TargetCollection]++
+	}
+	d.lock.Unlock()
+
+	if !duplicate {
+		d.datastore.Store(data)
+	}
+}
+
+//ExpectFiles records the files which are about to be read. Keys are kept
+//until every file which may hold a copy of their entries has been read.
+func (d *DedupingDatastore) ExpectFiles(files []*fpt.IndexedFile) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, file := range files {
+		d.reading[file] = fileWatermark(file)
+	}
+}
+
+//FinishFile records that a file has been read to the end
+func (d *DedupingDatastore) FinishFile(file *fpt.IndexedFile) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.reading, file)
+}
+
+//Flush waits for the wrapped datastore to finish writing and reports
+//the number of duplicates dropped
+func (d *DedupingDatastore) Flush() {
+	d.datastore.Flush()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for collection, count := range d.duplicates {
+		d.logger.WithFields(log.Fields{
+			"collection": collection,
+			"duplicates": count,
+		}).Info("Dropped duplicate records")
+	}
+}
+
+//Index ensures that the data in the wrapped datastore is searchable
+func (d *DedupingDatastore) Index() {
+	d.datastore.Index()
+}
+
+//DuplicateCount returns the total number of entries which were dropped
+func (d *DedupingDatastore) DuplicateCount() int64 {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var total int64
+	for _, count := range d.duplicates {
+		total += count
+	}
+	return total
+}
+
+//checkAndRecord returns true if either key has been seen before with a
+//timestamp within the tolerance. Otherwise, the keys are recorded so later
+//copies of the entry are caught. The caller must hold the lock.
+func (d *DedupingDatastore) checkAndRecord(uidKey, tupleKey dedupeKey, ts int64) bool {
+	d.trackAge()
+
+	if uidKey.id != "" {
+		if seenTS, ok := d.uids[uidKey]; ok && d.withinTolerance(seenTS, ts) {
+			return true
+		}
+	}
+
+	seen := d.tuples[tupleKey]
+	for _, seenTS := range seen {
+		if d.withinTolerance(seenTS, ts) {
+			return true
+		}
+	}
+
+	if uidKey.id != "" {
+		d.uids[uidKey] = ts
+	}
+	d.tuples[tupleKey] = append(seen, ts)
+	return false
+}
+
+//withinTolerance returns true if the timestamps are close enough for the
+//entries to be copies of each other
+func (d *DedupingDatastore) withinTolerance(a int64, b int64) bool {
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= d.tolerance
+}
+
+//trackAge periodically forgets the keys which are too old to match new
+//entries. Files are read in any order and their entries are not sorted,
+//so only keys older than the watermark of every file which has not been
+//read to the end are forgotten. Nothing is forgotten unless the files
+//being read are known. The caller must hold the lock.
+func (d *DedupingDatastore) trackAge() {
+	d.checked++
+	if d.checked < d.pruneInterval {
+		return
+	}
+	d.checked = 0
+
+	if len(d.reading) == 0 {
+		return
+	}
+	var watermark int64
+	first := true
+	for _, fileWatermark := range d.reading {
+		if fileWatermark == 0 {
+			//the file's entries may be from any time
+			return
+		}
+		if first || fileWatermark < watermark {
+			watermark = fileWatermark
+			first = false
+		}
+	}
+	d.prune(watermark - d.tolerance)
+}
+
+//fileWatermark returns the earliest timestamp expected in a file, which
+//is shortly before the file was opened. Zero is returned if the file's
+//open time is unknown.
+func fileWatermark(file *fpt.IndexedFile) int64 {
+	header := file.GetHeader()
+	if header == nil || header.Open.IsZero() {
+		return 0
+	}
+	return header.Open.Unix() + file.ClockOffset - dedupeOpenSlack
+}
+
+//prune forgets the keys recorded before the cutoff.
+//The caller must hold the lock.
+func (d *DedupingDatastore) prune(cutoff int64) {
+	for key, seenTS := range d.uids {
+		if seenTS < cutoff {
+			delete(d.uids, key)
+		}
+	}
+	for key, seen := range d.tuples {
+		kept := seen[:0]
+		for _, seenTS := range seen {
+			if seenTS >= cutoff {
+				kept = append(kept, seenTS)
+			}
+		}
+		if len(kept) == 0 {
+			delete(d.tuples, key)
+		} else {
+			d.tuples[key] = kept
+		}
+	}
+}
+
+//getDedupeKeys builds the uid and 5-tuple keys for the log types which
+//may be deduplicated. ok is false for any other type of data.
+func getDedupeKeys(data *ImportedData) (uidKey dedupeKey, tupleKey dedupeKey, ts int64, ok bool) {
+	var uid, detail string
+	switch entry := data.BroData.(type) {
+	case *parsetypes.Conn:
+		uid = entry.UID
+		ts = entry.TimeStamp
+		tupleKey.id = fiveTuple(entry.Source, entry.SourcePort,
+			entry.Destination, entry.DestinationPort, entry.Proto)
+	case *parsetypes.DNS:
+		uid = entry.UID
+		ts = entry.TimeStamp
+		detail = fmt.Sprintf("%d %s %s", entry.TransID, entry.QTypeName, entry.Query)
+		tupleKey.id = fiveTuple(entry.Source, entry.SourcePort,
+			entry.Destination, entry.DestinationPort, entry.Proto)
+	case *parsetypes.HTTP:
+		uid = entry.UID
+		ts = entry.TimeStamp
+		detail = fmt.Sprintf("%d %s %s%s", entry.TransDepth, entry.Method, entry.Host, entry.URI)
+		tupleKey.id = fiveTuple(entry.Source, entry.SourcePort,
+			entry.Destination, entry.DestinationPort, "tcp")
+	case *parsetypes.SSL:
+		uid = entry.UID
+		ts = entry.TimeStamp
+		detail = entry.ServerName
+		tupleKey.id = fiveTuple(entry.Source, entry.SourcePort,
+			entry.Destination, entry.DestinationPort, "tcp")
+	default:
+		return uidKey, tupleKey, 0, false
+	}
+
+	uidKey = dedupeKey{
+		database:   data.TargetDatabase,
+		collection: data.TargetCollection,
+		id:         uid,
+		detail:     detail,
+	}
+	tupleKey.database = data.TargetDatabase
+	tupleKey.collection = data.TargetCollection
+	tupleKey.detail = detail
+	return uidKey, tupleKey, ts, true
+}
+
+//fiveTuple formats a connection's addresses, ports, and protocol as a string
+func fiveTuple(src string, srcPort int, dst string, dstPort int, proto string) string {
+	return fmt.Sprintf("%s:%d-%s:%d/%s", src, srcPort, dst, dstPort, proto)
+}
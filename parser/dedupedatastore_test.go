@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	"github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+//memoryDatastore keeps stored data in a slice for inspection
+type memoryDatastore struct {
+	stored []*ImportedData
+}
+
+func (m *memoryDatastore) Store(data *ImportedData) { m.stored = append(m.stored, data) }
+func (m *memoryDatastore) Flush()                   {}
+func (m *memoryDatastore) Index()                   {}
+
+func newTestConn(uid string, ts int64, srcPort int) *ImportedData {
+	return &ImportedData{
+		BroData: &parsetypes.Conn{
+			UID:             uid,
+			TimeStamp:       ts,
+			Source:          "10.0.0.1",
+			SourcePort:      srcPort,
+			Destination:     "1.1.1.1",
+			DestinationPort: 443,
+			Proto:           "tcp",
+		},
+		TargetDatabase:   "test",
+		TargetCollection: "conn",
+	}
+}
+
+func TestDedupingDatastoreConn(t *testing.T) {
+	mem := &memoryDatastore{}
+	deduper := NewDedupingDatastore(mem, 1, log.New())
+
+	deduper.Store(newTestConn("CA", 100, 50000))
+	// same uid, e.g. the same file imported from two places
+	deduper.Store(newTestConn("CA", 100, 50000))
+	// different sensor uid, same 5-tuple, within tolerance
+	deduper.Store(newTestConn("CB", 101, 50000))
+	// same 5-tuple outside of the tolerance
+	deduper.Store(newTestConn("CC", 200, 50000))
+	// different 5-tuple at the same time
+	deduper.Store(newTestConn("CD", 100, 50001))
+	// a reused uid outside of the tolerance
+	deduper.Store(newTestConn("CA", 5000, 50002))
+	deduper.Flush()
+
+	assert.Len(t, mem.stored, 4)
+	assert.Equal(t, int64(2), deduper.DuplicateCount())
+}
+
+func TestDedupingDatastoreDNS(t *testing.T) {
+	mem := &memoryDatastore{}
+	deduper := NewDedupingDatastore(mem, 0, log.New())
+
+	query := func(uid string, transID int64, q string) *ImportedData {
+		return &ImportedData{
+			BroData: &parsetypes.DNS{
+				UID:             uid,
+				TimeStamp:       100,
+				Source:          "10.0.0.1",
+				SourcePort:      5353,
+				Destination:     "8.8.8.8",
+				DestinationPort: 53,
+				Proto:           "udp",
+				TransID:         transID,
+				Query:           q,
+				QTypeName:       "A",
+			},
+			TargetDatabase:   "test",
+			TargetCollection: "dns",
+		}
+	}
+
+	// several queries sharing a connection are not duplicates
+	deduper.Store(query("CA", 1, "example.com"))
+	deduper.Store(query("CA", 2, "example.org"))
+	// the same query seen by another sensor is
+	deduper.Store(query("CB", 1, "example.com"))
+
+	assert.Len(t, mem.stored, 2)
+	assert.Equal(t, int64(1), deduper.DuplicateCount())
+}
+
+func TestDedupingDatastorePrune(t *testing.T) {
+	mem := &memoryDatastore{}
+	deduper := NewDedupingDatastore(mem, 1, log.New())
+
+	deduper.Store(newTestConn("CA", 100, 50000))
+	deduper.Store(newTestConn("CB", 200, 50001))
+
+	deduper.lock.Lock()
+	deduper.prune(150)
+	assert.Len(t, deduper.uids, 1)
+	assert.Len(t, deduper.tuples, 1)
+	deduper.lock.Unlock()
+
+	// the remaining keys still catch duplicates
+	deduper.Store(newTestConn("CB", 200, 50001))
+	assert.Len(t, mem.stored, 2)
+}
+
+func TestDedupingDatastoreSensorsReadInTurn(t *testing.T) {
+	mem := &memoryDatastore{}
+	deduper := NewDedupingDatastore(mem, 1, log.New())
+	deduper.pruneInterval = 10
+
+	open := time.Unix(100000, 0)
+	newFile := func(sensor string, open time.Time) *fpt.IndexedFile {
+		file := &fpt.IndexedFile{Sensor: sensor}
+		file.SetHeader(&fpt.BroHeader{Open: open})
+		return file
+	}
+	sensor1 := newFile("sensor1", open)
+	sensor2 := newFile("sensor2", open)
+	later := newFile("sensor1", open.Add(4*time.Hour))
+	deduper.ExpectFiles([]*fpt.IndexedFile{sensor1, sensor2, later})
+
+	// sensor1's file is read to the end, sweeping several times, with
+	// its unsorted entries ending well after the file opened
+	for i := 0; i < 50; i++ {
+		deduper.Store(newTestConn("CA"+strconv.Itoa(i), open.Unix()+int64((i*7919)%3600), 50000+i))
+	}
+	deduper.FinishFile(sensor1)
+
+	// sensor2's copies of the same connections are still caught
+	for i := 0; i < 50; i++ {
+		deduper.Store(newTestConn("CB"+strconv.Itoa(i), open.Unix()+int64((i*7919)%3600), 50000+i))
+	}
+	deduper.FinishFile(sensor2)
+	assert.Len(t, mem.stored, 50)
+	assert.Equal(t, int64(50), deduper.DuplicateCount())
+
+	// once only the later file is left, the older keys are forgotten
+	for i := 0; i < 10; i++ {
+		deduper.Store(newTestConn("CC"+strconv.Itoa(i), later.GetHeader().Open.Unix(), 60000+i))
+	}
+	deduper.lock.Lock()
+	assert.Len(t, deduper.uids, 10)
+	deduper.lock.Unlock()
+}
+
+func TestDedupingDatastorePassThrough(t *testing.T) {
+	mem := &memoryDatastore{}
+	deduper := NewDedupingDatastore(mem, 1, log.New())
+
+	freq := &ImportedData{
+		BroData:          &parsetypes.Freq{Source: "10.0.0.1", Destination: "1.1.1.1"},
+		TargetDatabase:   "test",
+		TargetCollection: "freqConn",
+	}
+	deduper.Store(freq)
+	deduper.Store(freq)
+
+	assert.Len(t, mem.stored, 2)
+	assert.Equal(t, int64(0), deduper.DuplicateCount())
+}
@@ -0,0 +1,408 @@
+package parser
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	pt "github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type (
+	//ColumnMapping describes how the columns of a delimited text log, such
+	//as a CSV or TSV export from a firewall or proxy, map onto the fields
+	//of a conn, dns, or http entry. Fields are named by their bro log names.
+	ColumnMapping struct {
+		//Type is the kind of entry each line holds: conn, dns, or http
+		Type string `yaml:"Type"`
+		//Delimiter separates the columns. It defaults to a comma.
+		Delimiter string `yaml:"Delimiter"`
+		//SetSeparator separates the items of set and vector fields. It
+		//defaults to a comma.
+		SetSeparator string `yaml:"SetSeparator"`
+		//SkipLines is the number of lines to skip at the top of each file
+		SkipLines int `yaml:"SkipLines"`
+		//Header marks that the first line after the skipped lines names the
+		//columns
+		Header bool `yaml:"Header"`
+		//Comment marks lines which should be ignored
+		Comment string `yaml:"Comment"`
+		//TimestampFormat is unix, unix_ms, or a Go time layout. It defaults
+		//to unix, which accepts fractional seconds.
+		TimestampFormat string `yaml:"TimestampFormat"`
+		//TimeZone is the zone used for time layouts which do not include
+		//one. It defaults to the local time zone.
+		TimeZone string `yaml:"TimeZone"`
+		//Empty lists the values which mark an empty column. It defaults to
+		//an empty string and "-".
+		Empty []string `yaml:"Empty"`
+		//Columns maps bro field names to columns. Columns are given by
+		//their position, starting at 1, or by their name in the header.
+		Columns map[string]string `yaml:"Columns"`
+		//Constants maps bro field names to values used for every entry
+		Constants map[string]string `yaml:"Constants"`
+
+		factory  func() pt.BroData
+		fields   map[string]mappedField
+		comma    rune
+		location *time.Location
+	}
+
+	//mappedField is a field of the entry's data structure
+	mappedField struct {
+		offset  int
+		broType string
+	}
+
+	//mappedColumn links a column of a delimited log to a field
+	mappedColumn struct {
+		index int
+		name  string
+		field mappedField
+	}
+
+	//delimitedConverter converts the lines of a delimited log into entries
+	//once the columns have been resolved
+	delimitedConverter struct {
+		mapping *ColumnMapping
+		columns []mappedColumn
+		empty   map[string]bool
+		logger  *log.Logger
+	}
+
+	//DelimitedImporter imports delimited text logs described by a
+	//ColumnMapping
+	DelimitedImporter struct {
+		fs             *FSImporter
+		targetDatabase string
+		sensor         string
+		mapping        *ColumnMapping
+	}
+)
+
+//LoadColumnMapping reads and validates a column mapping file
+func LoadColumnMapping(path string) (*ColumnMapping, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseColumnMapping(contents)
+}
+
+//parseColumnMapping validates a column mapping and fills in its defaults
+func parseColumnMapping(contents []byte) (*ColumnMapping, error) {
+	mapping := &ColumnMapping{}
+	if err := yaml.UnmarshalStrict(contents, mapping); err != nil {
+		return nil, err
+	}
+
+	mapping.Type = strings.ToLower(mapping.Type)
+	switch mapping.Type {
+	case "conn", "dns", "http":
+		mapping.factory = pt.NewBroDataFactory(mapping.Type)
+	}
+	if mapping.factory == nil {
+		return nil, fmt.Errorf("Type must be conn, dns, or http, not %q", mapping.Type)
+	}
+	mapping.fields = broFields(mapping.factory())
+
+	switch mapping.Delimiter {
+	case "":
+		mapping.Delimiter = ","
+	case `\t`, "tab":
+		mapping.Delimiter = "\t"
+	}
+	delimiter := []rune(mapping.Delimiter)
+	if len(delimiter) != 1 {
+		return nil, fmt.Errorf("Delimiter must be a single character, not %q", mapping.Delimiter)
+	}
+	mapping.comma = delimiter[0]
+	if mapping.SetSeparator == "" {
+		mapping.SetSeparator = ","
+	}
+	if mapping.Comment != "" && len([]rune(mapping.Comment)) != 1 {
+		return nil, fmt.Errorf("Comment must be a single character, not %q", mapping.Comment)
+	}
+	if mapping.SkipLines < 0 {
+		return nil, errors.New("SkipLines must not be negative")
+	}
+	if mapping.Empty == nil {
+		mapping.Empty = []string{"", "-"}
+	}
+
+	if mapping.TimestampFormat == "" {
+		mapping.TimestampFormat = "unix"
+	}
+	mapping.location = time.Local
+	if mapping.TimeZone != "" {
+		location, err := time.LoadLocation(mapping.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+		mapping.location = location
+	}
+
+	if len(mapping.Columns) == 0 {
+		return nil, errors.New("No Columns are mapped")
+	}
+	for name, column := range mapping.Columns {
+		if _, ok := mapping.fields[name]; !ok {
+			return nil, fmt.Errorf("%s entries have no field named %q", mapping.Type, name)
+		}
+		index, err := strconv.Atoi(column)
+		if err == nil && index < 1 {
+			return nil, fmt.Errorf("Column positions start at 1, %q is mapped to %d", name, index)
+		}
+		if err != nil && !mapping.Header {
+			return nil, fmt.Errorf("%q is mapped to the column named %q but Header is not set", name, column)
+		}
+	}
+	for name := range mapping.Constants {
+		if _, ok := mapping.fields[name]; !ok {
+			return nil, fmt.Errorf("%s entries have no field named %q", mapping.Type, name)
+		}
+		if _, ok := mapping.Columns[name]; ok {
+			return nil, fmt.Errorf("%q is mapped to both a column and a constant", name)
+		}
+	}
+	for _, name := range []string{"ts", "id.orig_h", "id.resp_h"} {
+		_, column := mapping.Columns[name]
+		_, constant := mapping.Constants[name]
+		if !column && !constant {
+			return nil, fmt.Errorf("%q must be mapped", name)
+		}
+	}
+	return mapping, nil
+}
+
+//broFields lists the fields of a BroData struct by their bro names
+func broFields(data pt.BroData) map[string]mappedField {
+	fields := make(map[string]mappedField)
+	structType := reflect.TypeOf(data).Elem()
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		broName := structField.Tag.Get("bro")
+		broType := structField.Tag.Get("brotype")
+		if broName == "" || broType == "" {
+			continue
+		}
+		fields[broName] = mappedField{offset: i, broType: broType}
+	}
+	return fields
+}
+
+//newConverter resolves the mapped columns against the header of a log,
+//which may be nil if the mapping does not use column names
+func (m *ColumnMapping) newConverter(header []string, logger *log.Logger) (*delimitedConverter, error) {
+	converter := &delimitedConverter{
+		mapping: m,
+		empty:   make(map[string]bool),
+		logger:  logger,
+	}
+	for _, value := range m.Empty {
+		converter.empty[value] = true
+	}
+
+	for name, column := range m.Columns {
+		index, err := strconv.Atoi(column)
+		if err == nil {
+			index--
+		} else {
+			index = -1
+			for i, headerName := range header {
+				if strings.TrimSpace(headerName) == column {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				return nil, fmt.Errorf("the header has no column named %q", column)
+			}
+		}
+		converter.columns = append(converter.columns, mappedColumn{
+			index: index,
+			name:  name,
+			field: m.fields[name],
+		})
+	}
+	return converter, nil
+}
+
+//convert creates an entry from the columns of a line
+func (c *delimitedConverter) convert(record []string) (pt.BroData, error) {
+	data := c.mapping.factory()
+	structValue := reflect.ValueOf(data).Elem()
+
+	for name, value := range c.mapping.Constants {
+		if err := c.setField(structValue, c.mapping.fields[name], value); err != nil {
+			return nil, err
+		}
+	}
+	for _, column := range c.columns {
+		if column.index >= len(record) {
+			return nil, fmt.Errorf("the line has no column %d", column.index+1)
+		}
+		value := strings.TrimSpace(record[column.index])
+		if c.empty[value] {
+			continue
+		}
+		if err := c.setField(structValue, column.field, value); err != nil {
+			return nil, err
+		}
+	}
+
+	uid := structValue.FieldByName("UID")
+	if uid.IsValid() && uid.String() == "" {
+		uid.SetString(delimitedUID(record))
+	}
+	return data, nil
+}
+
+//setField converts a value into the field's bro type and stores it
+func (c *delimitedConverter) setField(structValue reflect.Value, field mappedField, value string) error {
+	target := structValue.Field(field.offset)
+	switch field.broType {
+	case pt.Time:
+		ts, err := c.mapping.parseTimestamp(value)
+		if err != nil {
+			return err
+		}
+		target.SetInt(ts.Unix())
+	case pt.Bool:
+		flag, _ := strconv.ParseBool(value)
+		target.SetBool(flag)
+	case pt.StringSet, pt.EnumSet, pt.StringVector, pt.IntervalVector:
+		//the bro log parser splits these on commas
+		value = strings.Replace(value, c.mapping.SetSeparator, ",", -1)
+		setBroField(target, field.broType, value, c.logger)
+	default:
+		setBroField(target, field.broType, value, c.logger)
+	}
+	return nil
+}
+
+//parseTimestamp parses a timestamp written in the mapping's format
+func (m *ColumnMapping) parseTimestamp(value string) (time.Time, error) {
+	switch m.TimestampFormat {
+	case "unix", "unix_ms":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if m.TimestampFormat == "unix_ms" {
+			number /= 1000
+		}
+		secs := int64(number)
+		return time.Unix(secs, int64((number-float64(secs))*1e9)), nil
+	}
+	return time.ParseInLocation(m.TimestampFormat, value, m.location)
+}
+
+//delimitedUID creates a unique id for an entry from the line it was
+//read from
+func delimitedUID(record []string) string {
+	hash := fnv.New64a()
+	for _, column := range record {
+		hash.Write([]byte(column))
+		hash.Write([]byte{0})
+	}
+	return "M" + strconv.FormatUint(hash.Sum64(), 36)
+}
+
+//NewDelimitedImporter creates a DelimitedImporter which imports logs
+//described by the mapping into the target database and tags the entries
+//with the sensor name. The importer's filters and time window are applied
+//to every entry.
+func NewDelimitedImporter(fs *FSImporter, targetDatabase string, sensor string,
+	mapping *ColumnMapping) *DelimitedImporter {
+	return &DelimitedImporter{
+		fs:             fs,
+		targetDatabase: targetDatabase,
+		sensor:         sensor,
+		mapping:        mapping,
+	}
+}
+
+//ImportFiles imports the given delimited logs, which may be gzip
+//compressed. Files which have already been imported into the target
+//database are skipped.
+func (d *DelimitedImporter) ImportFiles(files []string, datastore Datastore) {
+	collection := d.mapping.factory().TargetCollection(&d.fs.res.Config.T.Structure)
+	d.fs.importRecordFiles(files, d.targetDatabase, collection, d.sensor, datastore, d.importFile)
+}
+
+//importFile stores the entries in a delimited log. The number of entries
+//stored is returned.
+func (d *DelimitedImporter) importFile(path string, filter *recordFilter) (int, error) {
+	reader, closer, err := openRecordFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer closer.Close()
+
+	for i := 0; i < d.mapping.SkipLines; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return 0, err
+		}
+	}
+
+	lines := csv.NewReader(reader)
+	lines.Comma = d.mapping.comma
+	lines.FieldsPerRecord = -1
+	lines.LazyQuotes = true
+	if d.mapping.Comment != "" {
+		lines.Comment = []rune(d.mapping.Comment)[0]
+	}
+
+	var header []string
+	if d.mapping.Header {
+		header, err = lines.Read()
+		if err != nil {
+			return 0, err
+		}
+	}
+	converter, err := d.mapping.newConverter(header, d.fs.res.Log)
+	if err != nil {
+		return 0, err
+	}
+
+	structure := &d.fs.res.Config.T.Structure
+	count := 0
+	malformed := 0
+	for {
+		record, err := lines.Read()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(*csv.ParseError); ok {
+			malformed++
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+
+		data, err := converter.convert(record)
+		if err != nil {
+			malformed++
+			continue
+		}
+		filter.store(data, d.sensor, d.targetDatabase, data.TargetCollection(structure))
+		count++
+	}
+
+	d.fs.res.Log.WithFields(log.Fields{
+		"path":      path,
+		"malformed": malformed,
+	}).Info("Finished converting delimited log")
+	return count, nil
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"testing"
+
+	pt "github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelimitedConnMapping(t *testing.T) {
+	mapping, err := parseColumnMapping([]byte(`
+Type: conn
+TimestampFormat: "2006-01-02 15:04:05"
+TimeZone: UTC
+Columns:
+  ts: 1
+  id.orig_h: 2
+  id.orig_p: 3
+  id.resp_h: 4
+  id.resp_p: 5
+  orig_ip_bytes: 6
+  local_orig: 7
+Constants:
+  proto: tcp
+`))
+	require.Nil(t, err)
+	assert.Equal(t, ",", mapping.Delimiter)
+
+	converter, err := mapping.newConverter(nil, log.New())
+	require.Nil(t, err)
+
+	data, err := converter.convert([]string{"2019-03-01 12:00:00", "10.0.0.1", "50000", "192.0.2.1", "443", "1500", "true"})
+	require.Nil(t, err)
+	conn := data.(*pt.Conn)
+	assert.Equal(t, int64(1551441600), conn.TimeStamp)
+	assert.Equal(t, "10.0.0.1", conn.Source)
+	assert.Equal(t, 50000, conn.SourcePort)
+	assert.Equal(t, "192.0.2.1", conn.Destination)
+	assert.Equal(t, 443, conn.DestinationPort)
+	assert.Equal(t, int64(1500), conn.OrigIPBytes)
+	assert.True(t, conn.LocalOrigin)
+	assert.Equal(t, "tcp", conn.Proto)
+	assert.Equal(t, "M", conn.UID[:1])
+
+	//empty columns are left unset
+	data, err = converter.convert([]string{"2019-03-01 12:00:00", "10.0.0.1", "-", "192.0.2.1", "443", "", "F"})
+	require.Nil(t, err)
+	assert.Equal(t, 0, data.(*pt.Conn).SourcePort)
+
+	_, err = converter.convert([]string{"yesterday", "10.0.0.1", "1", "192.0.2.1", "443", "1", "F"})
+	assert.NotNil(t, err)
+	_, err = converter.convert([]string{"2019-03-01 12:00:00", "10.0.0.1"})
+	assert.NotNil(t, err)
+}
+
+func TestDelimitedHeaderMapping(t *testing.T) {
+	mapping, err := parseColumnMapping([]byte(`
+Type: dns
+Delimiter: tab
+Header: true
+TimestampFormat: unix_ms
+SetSeparator: "|"
+Columns:
+  ts: time
+  id.orig_h: client
+  id.resp_h: server
+  query: name
+  answers: answers
+`))
+	require.Nil(t, err)
+	assert.Equal(t, "\t", mapping.Delimiter)
+
+	_, err = mapping.newConverter([]string{"time", "client", "server", "name"}, log.New())
+	assert.NotNil(t, err)
+
+	converter, err := mapping.newConverter([]string{"time", "server", "client", "name", "answers"}, log.New())
+	require.Nil(t, err)
+	data, err := converter.convert([]string{"1551441600500", "10.0.0.53", "10.0.0.1", "example.com", "192.0.2.1|192.0.2.2"})
+	require.Nil(t, err)
+	dns := data.(*pt.DNS)
+	assert.Equal(t, int64(1551441600), dns.TimeStamp)
+	assert.Equal(t, "10.0.0.1", dns.Source)
+	assert.Equal(t, "10.0.0.53", dns.Destination)
+	assert.Equal(t, "example.com", dns.Query)
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2"}, dns.Answers)
+}
+
+func TestDelimitedMappingErrors(t *testing.T) {
+	invalid := []string{
+		"Type: freq\nColumns: {ts: 1, id.orig_h: 2, id.resp_h: 3}",
+		"Type: conn\nDelimiter: '::'\nColumns: {ts: 1, id.orig_h: 2, id.resp_h: 3}",
+		"Type: conn\nColumns: {ts: 1, id.orig_h: 2, id.resp_h: 3, bogus: 4}",
+		"Type: conn\nColumns: {ts: 0, id.orig_h: 2, id.resp_h: 3}",
+		"Type: conn\nColumns: {ts: time, id.orig_h: 2, id.resp_h: 3}",
+		"Type: conn\nColumns: {ts: 1, id.orig_h: 2}",
+		"Type: conn\nColumns: {ts: 1, id.orig_h: 2, id.resp_h: 3}\nConstants: {ts: 0}",
+		"Type: conn\nColums: {ts: 1, id.orig_h: 2, id.resp_h: 3}",
+	}
+	for _, contents := range invalid {
+		_, err := parseColumnMapping([]byte(contents))
+		assert.NotNil(t, err, contents)
+	}
+}
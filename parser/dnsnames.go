@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+//dnsQueryTypes maps the names of DNS query types to their values
+var dnsQueryTypes = map[string]int64{
+	"A": 1, "NS": 2, "CNAME": 5, "SOA": 6, "NULL": 10, "WKS": 11, "PTR": 12,
+	"HINFO": 13, "MX": 15, "TXT": 16, "AAAA": 28, "SRV": 33, "NAPTR": 35,
+	"DS": 43, "RRSIG": 46, "NSEC": 47, "DNSKEY": 48, "NSEC3": 50, "TLSA": 52,
+	"SVCB": 64, "HTTPS": 65, "SPF": 99, "AXFR": 252, "ANY": 255, "CAA": 257,
+}
+
+//dnsResponseCodes maps the names of DNS response codes to their values
+var dnsResponseCodes = map[string]int64{
+	"NOERROR": 0, "FORMERR": 1, "SERVFAIL": 2, "NXDOMAIN": 3, "NOTIMP": 4,
+	"REFUSED": 5, "YXDOMAIN": 6, "YXRRSET": 7, "NXRRSET": 8, "NOTAUTH": 9,
+	"NOTZONE": 10,
+}
+
+//dnsQueryType returns the value of a DNS query type name. Unnamed types
+//are written as TYPE followed by their value.
+func dnsQueryType(name string) int64 {
+	if value, ok := dnsQueryTypes[name]; ok {
+		return value
+	}
+	value, _ := strconv.ParseInt(strings.TrimPrefix(name, "TYPE"), 10, 64)
+	return value
+}
+
+//dnsQueryTypeName returns the name of a DNS query type as bro logs it
+func dnsQueryTypeName(value int64) string {
+	for name, known := range dnsQueryTypes {
+		if known == value {
+			return name
+		}
+	}
+	return "TYPE" + strconv.FormatInt(value, 10)
+}
+
+//dnsResponseCodeName returns the name of a DNS response code
+func dnsResponseCodeName(value int64) string {
+	for name, known := range dnsResponseCodes {
+		if known == value {
+			return name
+		}
+	}
+	return "unknown-" + strconv.FormatInt(value, 10)
+}
+
+//dnsClassName returns the name of a DNS query class as bro logs it
+func dnsClassName(value int64) string {
+	switch value {
+	case 1:
+		return "C_INTERNET"
+	case 3:
+		return "C_CHAOS"
+	case 4:
+		return "C_HESIOD"
+	case 255:
+		return "C_ANY"
+	}
+	return "unknown-" + strconv.FormatInt(value, 10)
+}
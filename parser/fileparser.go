@@ -69,6 +69,8 @@ func scanHeader(fileScanner *bufio.Scanner) (*fpt.BroHeader, error) {
 				toReturn.Types = line[1:]
 			} else if strings.Contains(line[0], "path") {
 				toReturn.ObjType = line[1]
+			} else if line[0] == "#open" && len(line) > 1 {
+				toReturn.Open, _ = parseBroLogTime(line[1])
 			}
 		} else {
 			//We are done parsing the comments
@@ -82,6 +84,60 @@ func scanHeader(fileScanner *bufio.Scanner) (*fpt.BroHeader, error) {
 	return toReturn, nil
 }
 
+//parseBroLogTime parses the timestamps found in the #open and #close
+//comment lines of a bro file
+func parseBroLogTime(value string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02-15-04-05", value, time.Local)
+}
+
+//scanCloseTime reads the time a bro file was closed from its #close
+//comment line. Only the tail of uncompressed files is read, but
+//compressed files must be read in their entirety.
+func scanCloseTime(filePath string) (time.Time, error) {
+	fileHandle, err := os.Open(filePath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer fileHandle.Close()
+
+	if !strings.HasSuffix(filePath, ".gz") {
+		fInfo, err := fileHandle.Stat()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fInfo.Size() > 4096 {
+			fileHandle.Seek(fInfo.Size()-4096, 0)
+		}
+	}
+
+	scanner, err := getFileScanner(fileHandle)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var closeTime time.Time
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#close") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 1 {
+			closeTime, err = parseBroLogTime(fields[1])
+			if err != nil {
+				return time.Time{}, err
+			}
+		}
+	}
+	if scanner.Err() != nil {
+		return time.Time{}, scanner.Err()
+	}
+	if closeTime.IsZero() {
+		return closeTime, errors.New("No #close line found in file")
+	}
+	return closeTime, nil
+}
+
 //mapBroHeaderToParserType checks a parsed BroHeader against
 //a BroData struct and returns a mapping from bro field names in the
 //bro header to the indexes of the respective fields in the BroData struct
@@ -147,10 +203,11 @@ func mapBroHeaderToParserType(header *fpt.BroHeader, broDataFactory func() pt.Br
 }
 
 //parseLine parses a line of a bro log with a given broHeader, fieldMap, into
-//the BroData created by the broDataFactory
+//the BroData created by the broDataFactory. clockOffset seconds are added
+//to the entry's timestamp to correct the clock of the sensor which logged it.
 func parseLine(lineString string, header *fpt.BroHeader,
 	fieldMap fpt.BroHeaderIndexMap, broDataFactory func() pt.BroData,
-	logger *log.Logger) pt.BroData {
+	clockOffset int64, logger *log.Logger) pt.BroData {
 	line := strings.Split(lineString, header.Separator)
 	if len(line) < len(header.Names) {
 		return nil
@@ -174,124 +231,165 @@ func parseLine(lineString string, header *fpt.BroHeader,
 			continue
 		}
 
-		switch header.Types[idx] {
-		case pt.Time:
-			secs := strings.Split(line[idx], ".")
-			s, err := strconv.ParseInt(secs[0], 10, 64)
-			if err != nil {
-				logger.WithFields(log.Fields{
-					"error": err.Error(),
-					"value": line[idx],
-				}).Error("Couldn't convert unix ts")
-				data.Field(fieldOffset).SetInt(-1)
-				break
-			}
+		setBroField(data.Field(fieldOffset), header.Types[idx], line[idx], logger)
+	}
 
-			n, err := strconv.ParseInt(secs[1], 10, 64)
-			if err != nil {
-				logger.WithFields(log.Fields{
-					"error": err.Error(),
-					"value": line[idx],
-				}).Error("Couldn't convert unix ts")
-				data.Field(fieldOffset).SetInt(-1)
-				break
-			}
+	shiftTimestamp(dat, clockOffset)
+	return dat
+}
+
+//setBroField converts a value written in a bro log into the given bro
+//type and stores it in the field of a BroData struct
+func setBroField(field reflect.Value, broType string, value string, logger *log.Logger) {
+	switch broType {
+	case pt.Time:
+		secs := strings.Split(value, ".")
+		s, err := strconv.ParseInt(secs[0], 10, 64)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"error": err.Error(),
+				"value": value,
+			}).Error("Couldn't convert unix ts")
+			field.SetInt(-1)
+			break
+		}
 
-			ttim := time.Unix(s, n)
-			tval := ttim.Unix()
-			data.Field(fieldOffset).SetInt(tval)
+		n, err := strconv.ParseInt(secs[1], 10, 64)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"error": err.Error(),
+				"value": value,
+			}).Error("Couldn't convert unix ts")
+			field.SetInt(-1)
 			break
-		case pt.String:
-			data.Field(fieldOffset).SetString(line[idx])
+		}
+
+		ttim := time.Unix(s, n)
+		tval := ttim.Unix()
+		field.SetInt(tval)
+		break
+	case pt.String:
+		field.SetString(value)
+		break
+	case pt.Addr:
+		field.SetString(value)
+		break
+	case pt.Port:
+		pval, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"error": err.Error(),
+				"value": value,
+			}).Error("Couldn't convert port number")
+			field.SetInt(-1)
 			break
-		case pt.Addr:
-			data.Field(fieldOffset).SetString(line[idx])
+		}
+		field.SetInt(pval)
+		break
+	case pt.Enum:
+		field.SetString(value)
+		break
+	case pt.Interval:
+		flt, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"error": err.Error(),
+				"value": value,
+			}).Error("Couldn't convert float")
+			field.SetFloat(-1.0)
 			break
-		case pt.Port:
-			pval, err := strconv.ParseInt(line[idx], 10, 32)
-			if err != nil {
-				logger.WithFields(log.Fields{
-					"error": err.Error(),
-					"value": line[idx],
-				}).Error("Couldn't convert port number")
-				data.Field(fieldOffset).SetInt(-1)
-				break
-			}
-			data.Field(fieldOffset).SetInt(pval)
+		}
+		field.SetFloat(flt)
+		break
+	case pt.Count:
+		cnt, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"error": err.Error(),
+				"value": value,
+			}).Error("Couldn't convert count")
+			field.SetInt(-1)
 			break
-		case pt.Enum:
-			data.Field(fieldOffset).SetString(line[idx])
+		}
+		field.SetInt(cnt)
+		break
+	case pt.Bool:
+		if value == "T" {
+			field.SetBool(true)
 			break
-		case pt.Interval:
-			flt, err := strconv.ParseFloat(line[idx], 64)
+		}
+		field.SetBool(false)
+		break
+	case pt.StringSet:
+		tokens := strings.Split(value, ",")
+		tVal := reflect.ValueOf(tokens)
+		field.Set(tVal)
+		break
+	case pt.EnumSet:
+		tokens := strings.Split(value, ",")
+		tVal := reflect.ValueOf(tokens)
+		field.Set(tVal)
+		break
+	case pt.StringVector:
+		tokens := strings.Split(value, ",")
+		tVal := reflect.ValueOf(tokens)
+		field.Set(tVal)
+		break
+	case pt.IntervalVector:
+		tokens := strings.Split(value, ",")
+		floats := make([]float64, len(tokens))
+		for i, val := range tokens {
+			var err error
+			floats[i], err = strconv.ParseFloat(val, 64)
 			if err != nil {
 				logger.WithFields(log.Fields{
 					"error": err.Error(),
-					"value": line[idx],
+					"value": val,
 				}).Error("Couldn't convert float")
-				data.Field(fieldOffset).SetFloat(-1.0)
 				break
 			}
-			data.Field(fieldOffset).SetFloat(flt)
-			break
-		case pt.Count:
-			cnt, err := strconv.ParseInt(line[idx], 10, 64)
-			if err != nil {
-				logger.WithFields(log.Fields{
-					"error": err.Error(),
-					"value": line[idx],
-				}).Error("Couldn't convert count")
-				data.Field(fieldOffset).SetInt(-1)
-				break
-			}
-			data.Field(fieldOffset).SetInt(cnt)
-			break
-		case pt.Bool:
-			if line[idx] == "T" {
-				data.Field(fieldOffset).SetBool(true)
-				break
-			}
-			data.Field(fieldOffset).SetBool(false)
-			break
-		case pt.StringSet:
-			tokens := strings.Split(line[idx], ",")
-			tVal := reflect.ValueOf(tokens)
-			data.Field(fieldOffset).Set(tVal)
-			break
-		case pt.EnumSet:
-			tokens := strings.Split(line[idx], ",")
-			tVal := reflect.ValueOf(tokens)
-			data.Field(fieldOffset).Set(tVal)
-			break
-		case pt.StringVector:
-			tokens := strings.Split(line[idx], ",")
-			tVal := reflect.ValueOf(tokens)
-			data.Field(fieldOffset).Set(tVal)
-			break
-		case pt.IntervalVector:
-			tokens := strings.Split(line[idx], ",")
-			floats := make([]float64, len(tokens))
-			for i, val := range tokens {
-				var err error
-				floats[i], err = strconv.ParseFloat(val, 64)
-				if err != nil {
-					logger.WithFields(log.Fields{
-						"error": err.Error(),
-						"value": val,
-					}).Error("Couldn't convert float")
-					break
-				}
-			}
-			fVal := reflect.ValueOf(floats)
-			data.Field(fieldOffset).Set(fVal)
-			break
-		default:
-			logger.WithFields(log.Fields{
-				"error": "Unhandled type",
-				"value": header.Types[idx],
-			}).Error("Encountered unhandled type in log")
 		}
+		fVal := reflect.ValueOf(floats)
+		field.Set(fVal)
+		break
+	default:
+		logger.WithFields(log.Fields{
+			"error": "Unhandled type",
+			"value": broType,
+		}).Error("Encountered unhandled type in log")
 	}
+}
 
-	return dat
+//setSensor stamps the name of the sensor which recorded a bro entry onto
+//the entry if the entry's data structure tracks it
+func setSensor(data pt.BroData, sensor string) {
+	if sensor == "" {
+		return
+	}
+	field := reflect.ValueOf(data).Elem().FieldByName("Sensor")
+	if field.IsValid() && field.Kind() == reflect.String {
+		field.SetString(sensor)
+	}
+}
+
+//shiftTimestamp adds offset seconds to the time a bro entry was logged if
+//the entry's data structure tracks it
+func shiftTimestamp(data pt.BroData, offset int64) {
+	if offset == 0 {
+		return
+	}
+	field := reflect.ValueOf(data).Elem().FieldByName("TimeStamp")
+	if field.IsValid() && field.Kind() == reflect.Int64 {
+		field.SetInt(field.Int() + offset)
+	}
+}
+
+//getTimestamp returns the time a bro entry was logged if the entry's
+//data structure tracks it
+func getTimestamp(data pt.BroData) (int64, bool) {
+	field := reflect.ValueOf(data).Elem().FieldByName("TimeStamp")
+	if field.IsValid() && field.Kind() == reflect.Int64 {
+		return field.Int(), true
+	}
+	return 0, false
 }
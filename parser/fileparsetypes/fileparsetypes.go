@@ -10,19 +10,27 @@ import (
 //BroHeader contains the parse information contained within the comment lines
 //of bro files
 type BroHeader struct {
-	Names     []string // Names of fields
-	Types     []string // Types of fields
-	Separator string   // Field separator
-	SetSep    string   // Set separator
-	Empty     string   // Empty field tag
-	Unset     string   // Unset field tag
-	ObjType   string   // Object type (comes from #path)
+	Names     []string  // Names of fields
+	Types     []string  // Types of fields
+	Separator string    // Field separator
+	SetSep    string    // Set separator
+	Empty     string    // Empty field tag
+	Unset     string    // Unset field tag
+	ObjType   string    // Object type (comes from #path)
+	Open      time.Time // Time the log was opened (comes from #open)
 }
 
 //BroHeaderIndexMap maps the names of bro fields to their indexes in a
 //BroData struct
 type BroHeaderIndexMap map[string]int
 
+//ImportedRange bounds the unix timestamps of the records imported from a
+//file by an earlier import. Zero leaves that side of the range open.
+type ImportedRange struct {
+	Since int64
+	Until int64
+}
+
 //IndexedFile ties a file to a target collection and database
 type IndexedFile struct {
 	ID               bson.ObjectId `bson:"_id,omitempty"`
@@ -32,10 +40,23 @@ type IndexedFile struct {
 	Hash             string        `bson:"hash"`
 	TargetCollection string        `bson:"collection"`
 	TargetDatabase   string        `bson:"database"`
+	Sensor           string        `bson:"sensor"`
 	ParseTime        time.Time     `bson:"time_complete"`
-	header           *BroHeader
-	broDataFactory   func() pt.BroData
-	fieldMap         BroHeaderIndexMap
+	FirstTs          int64         `bson:"first_ts"`
+	LastTs           int64         `bson:"last_ts"`
+	// ClockOffset is the number of seconds added to the file's timestamps
+	// to correct the sensor's clock
+	ClockOffset          int64 `bson:"clock_offset"`
+	ClockOffsetEstimated bool  `bson:"clock_offset_estimated"`
+	// WindowSince and WindowUntil bound the unix timestamps of the records
+	// imported from the file when the import was limited to a time window.
+	// Zero leaves that side of the window open.
+	WindowSince    int64 `bson:"window_since,omitempty"`
+	WindowUntil    int64 `bson:"window_until,omitempty"`
+	header         *BroHeader
+	broDataFactory func() pt.BroData
+	fieldMap       BroHeaderIndexMap
+	importedRanges []ImportedRange
 }
 
 //The following functions are for interacting with the private data in
@@ -75,3 +96,37 @@ func (i *IndexedFile) SetFieldMap(fieldMap BroHeaderIndexMap) {
 func (i *IndexedFile) GetFieldMap() BroHeaderIndexMap {
 	return i.fieldMap
 }
+
+//SetImportedRanges sets the time ranges of the records which earlier
+//imports of the file already stored
+func (i *IndexedFile) SetImportedRanges(importedRanges []ImportedRange) {
+	i.importedRanges = importedRanges
+}
+
+//GetImportedRanges retrieves the time ranges of the records which earlier
+//imports of the file already stored
+func (i *IndexedFile) GetImportedRanges() []ImportedRange {
+	return i.importedRanges
+}
+
+//WasImported returns true if an earlier import of the file already stored
+//the records logged at the given timestamp
+func (i *IndexedFile) WasImported(ts int64) bool {
+	for _, r := range i.importedRanges {
+		if (r.Since == 0 || ts >= r.Since) && (r.Until == 0 || ts <= r.Until) {
+			return true
+		}
+	}
+	return false
+}
+
+//ObserveTimestamp widens the time range of the entries read from the file
+//to include the given timestamp
+func (i *IndexedFile) ObserveTimestamp(ts int64) {
+	if i.FirstTs == 0 || ts < i.FirstTs {
+		i.FirstTs = ts
+	}
+	if ts > i.LastTs {
+		i.LastTs = ts
+	}
+}
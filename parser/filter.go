@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net"
 	"os"
+
+	"github.com/activecm/rita/util"
 )
 
 // filterConnPair returns true if a connection pair is filtered/excluded.
@@ -19,12 +21,12 @@ func (fs *FSImporter) filterConnPair(src string, dst string) bool {
 	dstIP := net.ParseIP(dst)
 
 	// check if on always included list
-	isSrcIncluded := containsIP(fs.alwaysIncluded, srcIP)
-	isDstIncluded := containsIP(fs.alwaysIncluded, dstIP)
+	isSrcIncluded := util.ContainsIP(fs.alwaysIncluded, srcIP)
+	isDstIncluded := util.ContainsIP(fs.alwaysIncluded, dstIP)
 
 	// check if on never included list
-	isSrcExcluded := containsIP(fs.neverIncluded, srcIP)
-	isDstExcluded := containsIP(fs.neverIncluded, dstIP)
+	isSrcExcluded := util.ContainsIP(fs.neverIncluded, srcIP)
+	isDstExcluded := util.ContainsIP(fs.neverIncluded, dstIP)
 
 	// if either IP is on the AlwaysInclude list, filter does not apply
 	if isSrcIncluded || isDstIncluded {
@@ -43,8 +45,8 @@ func (fs *FSImporter) filterConnPair(src string, dst string) bool {
 	}
 
 	// check if src and dst are internal
-	isSrcInternal := containsIP(fs.internal, srcIP)
-	isDstInternal := containsIP(fs.internal, dstIP)
+	isSrcInternal := util.ContainsIP(fs.internal, srcIP)
+	isDstInternal := util.ContainsIP(fs.internal, dstIP)
 
 	// if both addresses are internal, filter applies
 	if isSrcInternal && isDstInternal {
@@ -60,38 +62,13 @@ func (fs *FSImporter) filterConnPair(src string, dst string) bool {
 	return false
 }
 
-//parseSubnets parses the provided subnets into net.ipnet format
-func getParsedSubnets(subnets []string) (parsedSubnets []*net.IPNet) {
-
-	for _, entry := range subnets {
-		//try to parse out cidr range
-		_, block, err := net.ParseCIDR(entry)
-
-		//if there was an error, check if entry was an IP not a range
-		if err != nil {
-			// try to parse out IP as range of single host
-			_, block, err = net.ParseCIDR(entry + "/32")
-
-			// if error, report and return
-			if err != nil {
-				fmt.Fprintf(os.Stdout, "Error parsing CIDR entry: %s\n", err.Error())
-				os.Exit(-1)
-				return
-			}
-		}
-
-		// add cidr range to list
-		parsedSubnets = append(parsedSubnets, block)
+//getParsedSubnets parses the subnets given in the config file. RITA exits
+//if any of the subnets are invalid.
+func getParsedSubnets(subnets []string) []*net.IPNet {
+	parsed, err := util.ParseSubnets(subnets)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "Error parsing CIDR entry: %s\n", err.Error())
+		os.Exit(-1)
 	}
-	return
-}
-
-//containsIP checks if a specified subnet contains an ip
-func containsIP(subnets []*net.IPNet, ip net.IP) bool {
-	for _, block := range subnets {
-		if block.Contains(ip) {
-			return true
-		}
-	}
-	return false
+	return parsed
 }
@@ -0,0 +1,279 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/activecm/rita/parser/netflow"
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/parser/pcap"
+	log "github.com/sirupsen/logrus"
+)
+
+//FlowImporter imports NetFlow v5, NetFlow v9, and IPFIX flow records as
+//conn entries. Flows may be read from pcap and pcapng captures of export
+//traffic, from IPFIX files, or received directly from exporters over UDP.
+type FlowImporter struct {
+	fs             *FSImporter
+	decoder        *netflow.Decoder
+	targetDatabase string
+	sensor         string
+}
+
+//NewFlowImporter creates a FlowImporter which imports flows into the
+//target database. Flows are tagged with the sensor name, or with the
+//address of the exporter which sent them if the sensor name is empty.
+//The importer's filters and time window are applied to every flow.
+func NewFlowImporter(fs *FSImporter, targetDatabase string, sensor string) *FlowImporter {
+	return &FlowImporter{
+		fs:             fs,
+		decoder:        netflow.NewDecoder(),
+		targetDatabase: targetDatabase,
+		sensor:         sensor,
+	}
+}
+
+//ImportFiles imports the flows held in the given files. Files which have
+//already been imported into the target database are skipped.
+func (f *FlowImporter) ImportFiles(files []string, datastore Datastore) {
+	f.fs.importRecordFiles(files, f.targetDatabase,
+		f.fs.res.Config.T.Structure.ConnTable, f.sensor, datastore, f.importFile)
+}
+
+//Listen imports the flows sent to the connection by exporters until the
+//stop channel is closed
+func (f *FlowImporter) Listen(conn net.PacketConn, datastore Datastore, stop <-chan struct{}) {
+	logger := f.fs.res.Log
+	filter := f.fs.newRecordFilter(datastore)
+	buffer := make([]byte, 65535)
+	count := 0
+
+	for {
+		select {
+		case <-stop:
+			logger.WithFields(log.Fields{
+				"address": conn.LocalAddr().String(),
+				"flows":   count,
+			}).Info("Stopped listening for flows")
+			f.fs.finishRecords(filter, f.targetDatabase, datastore)
+			return
+		default:
+		}
+
+		//wake up periodically to check if we should stop
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		length, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			logger.WithFields(log.Fields{
+				"address": conn.LocalAddr().String(),
+				"error":   err.Error(),
+			}).Error("Could not receive flows")
+			f.fs.finishRecords(filter, f.targetDatabase, datastore)
+			return
+		}
+
+		exporter := addr.String()
+		if udpAddr, ok := addr.(*net.UDPAddr); ok {
+			exporter = udpAddr.IP.String()
+		}
+		count += f.storeMessage(exporter, buffer[:length], filter)
+	}
+}
+
+//importFile stores the flows in a pcap or pcapng capture or an IPFIX file,
+//which may be gzip compressed. The number of flows read is returned.
+func (f *FlowImporter) importFile(path string, filter *recordFilter) (int, error) {
+	reader, closer, err := openRecordFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer closer.Close()
+
+	magic, _ := reader.Peek(4)
+	switch {
+	case pcap.IsPcap(magic) || pcap.IsPcapng(magic):
+		return f.importCapture(reader, filter)
+	case len(magic) >= 2 && binary.BigEndian.Uint16(magic) == 10:
+		return f.importIPFIXFile(reader, filter)
+	}
+	return 0, errors.New("file is not a pcap capture or IPFIX file")
+}
+
+//importCapture stores the flows exported in the UDP datagrams of a pcap
+//or pcapng capture. Datagrams which do not hold export messages are skipped.
+func (f *FlowImporter) importCapture(reader *bufio.Reader, filter *recordFilter) (int, error) {
+	capture, err := pcap.OpenCapture(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		packet, err := capture.ReadPacket()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		decoded, err := pcap.Decode(packet.LinkType, packet.Data)
+		if err != nil || decoded.Protocol != pcap.ProtocolUDP || decoded.Fragment ||
+			len(decoded.Payload) < 2 {
+			continue
+		}
+		//skip other UDP traffic caught in the capture
+		switch binary.BigEndian.Uint16(decoded.Payload) {
+		case 5, 9, 10:
+		default:
+			continue
+		}
+		count += f.storeMessage(decoded.SrcIP.String(), decoded.Payload, filter)
+	}
+}
+
+//importIPFIXFile stores the flows in an IPFIX file, which is a series of
+//IPFIX messages
+func (f *FlowImporter) importIPFIXFile(reader *bufio.Reader, filter *recordFilter) (int, error) {
+	count := 0
+	for {
+		header, err := reader.Peek(16)
+		if err == io.EOF && len(header) == 0 {
+			return count, nil
+		}
+		length, err := netflow.MessageLength(header)
+		if err != nil {
+			return count, err
+		}
+		if length < 16 {
+			return count, fmt.Errorf("invalid IPFIX message length %d", length)
+		}
+
+		message := make([]byte, length)
+		if _, err = io.ReadFull(reader, message); err != nil {
+			return count, netflow.ErrTruncated
+		}
+		//the exporter of an IPFIX file is unknown, so templates are
+		//shared by everything in the file
+		count += f.storeMessage(f.sensor, message, filter)
+	}
+}
+
+//storeMessage decodes an export message and stores its flows. The number
+//of flows decoded is returned.
+func (f *FlowImporter) storeMessage(exporter string, message []byte, filter *recordFilter) int {
+	flows, err := f.decoder.Decode(exporter, message)
+	if err != nil {
+		f.fs.res.Log.WithFields(log.Fields{
+			"exporter": exporter,
+			"error":    err.Error(),
+		}).Warning("Could not decode flow export message")
+	}
+
+	sensor := f.sensor
+	if sensor == "" {
+		sensor = exporter
+	}
+	for _, flow := range flows {
+		conn := flowToConn(flow)
+		if conn == nil {
+			continue
+		}
+		filter.store(conn, sensor, f.targetDatabase,
+			f.fs.res.Config.T.Structure.ConnTable)
+	}
+	return len(flows)
+}
+
+//flowToConn converts a flow record into a conn entry. Flow exporters only
+//count IP bytes, so the payload byte counts are filled with the IP byte
+//counts as well. nil is returned for flows missing either address, such
+//as those from templates which do not export the IPv4 or IPv6 addresses.
+func flowToConn(flow netflow.Flow) *parsetypes.Conn {
+	if flow.SrcAddr == nil || flow.DstAddr == nil {
+		return nil
+	}
+	conn := &parsetypes.Conn{
+		TimeStamp:       flow.Start.Unix(),
+		UID:             flowUID(flow),
+		Source:          flow.SrcAddr.String(),
+		SourcePort:      int(flow.SrcPort),
+		Destination:     flow.DstAddr.String(),
+		DestinationPort: int(flow.DstPort),
+		Proto:           flowProto(flow.Protocol),
+		Duration:        flow.End.Sub(flow.Start).Seconds(),
+		OrigBytes:       int64(flow.Bytes),
+		RespBytes:       int64(flow.ReverseBytes),
+		OrigPkts:        int64(flow.Packets),
+		OrigIPBytes:     int64(flow.Bytes),
+		RespPkts:        int64(flow.ReversePackets),
+		RespIPBytes:     int64(flow.ReverseBytes),
+	}
+
+	conn.ConnState = approximateConnState(conn.Proto, flow.TCPFlags, flow.ReversePackets > 0)
+
+	//exporters encode the ICMP type and code in the destination port,
+	//while bro logs them as the source and destination ports
+	if conn.Proto == "icmp" {
+		conn.SourcePort = int(flow.DstPort >> 8)
+		conn.DestinationPort = int(flow.DstPort & 0xff)
+	}
+	return conn
+}
+
+//flowProto maps an IP protocol number to the transport names bro uses
+func flowProto(protocol uint8) string {
+	switch protocol {
+	case pcap.ProtocolTCP:
+		return "tcp"
+	case pcap.ProtocolUDP:
+		return "udp"
+	case pcap.ProtocolICMP, pcap.ProtocolICMPv6:
+		return "icmp"
+	}
+	return "unknown_transport"
+}
+
+//approximateConnState approximates bro's connection state using the TCP
+//flags seen on a connection and whether any traffic was sent in reply
+func approximateConnState(proto string, tcpFlags uint8, replied bool) string {
+	if proto != "tcp" {
+		if replied {
+			return "SF"
+		}
+		return "S0"
+	}
+	switch {
+	case tcpFlags&pcap.TCPFlagRST != 0:
+		return "RSTO"
+	case tcpFlags&pcap.TCPFlagSYN != 0 && tcpFlags&pcap.TCPFlagFIN != 0:
+		return "SF"
+	case tcpFlags&pcap.TCPFlagSYN != 0 && tcpFlags&pcap.TCPFlagACK == 0:
+		return "S0"
+	case tcpFlags&pcap.TCPFlagSYN != 0:
+		return "S1"
+	}
+	return "OTH"
+}
+
+//flowUID creates a stable unique id for a flow from its five tuple and
+//start time so re-exported flows receive the same id
+func flowUID(flow netflow.Flow) string {
+	hash := fnv.New64a()
+	hash.Write(flow.SrcAddr)
+	hash.Write(flow.DstAddr)
+	hash.Write([]byte{byte(flow.SrcPort >> 8), byte(flow.SrcPort),
+		byte(flow.DstPort >> 8), byte(flow.DstPort), flow.Protocol})
+	hash.Write([]byte(strconv.FormatInt(flow.Start.UnixNano()/int64(time.Millisecond), 10)))
+	return "F" + strconv.FormatUint(hash.Sum64(), 16)
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/parser/netflow"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlowToConn(t *testing.T) {
+	start := time.Unix(1500000000, 250*int64(time.Millisecond))
+	flow := netflow.Flow{
+		Start:          start,
+		End:            start.Add(1500 * time.Millisecond),
+		SrcAddr:        net.ParseIP("10.0.0.1"),
+		DstAddr:        net.ParseIP("192.0.2.1"),
+		SrcPort:        50000,
+		DstPort:        443,
+		Protocol:       6,
+		TCPFlags:       0x1b,
+		Bytes:          1500,
+		Packets:        10,
+		ReverseBytes:   9000,
+		ReversePackets: 8,
+	}
+
+	conn := flowToConn(flow)
+	assert.Equal(t, int64(1500000000), conn.TimeStamp)
+	assert.Equal(t, "10.0.0.1", conn.Source)
+	assert.Equal(t, "192.0.2.1", conn.Destination)
+	assert.Equal(t, 50000, conn.SourcePort)
+	assert.Equal(t, 443, conn.DestinationPort)
+	assert.Equal(t, "tcp", conn.Proto)
+	assert.Equal(t, "SF", conn.ConnState)
+	assert.Equal(t, 1.5, conn.Duration)
+	assert.Equal(t, int64(1500), conn.OrigIPBytes)
+	assert.Equal(t, int64(9000), conn.RespIPBytes)
+	assert.Equal(t, int64(10), conn.OrigPkts)
+	assert.Equal(t, int64(8), conn.RespPkts)
+
+	//the id only depends on the five tuple and start time
+	assert.Equal(t, conn.UID, flowToConn(flow).UID)
+	flow.SrcPort++
+	assert.NotEqual(t, conn.UID, flowToConn(flow).UID)
+
+	//echo requests are type 8 code 0
+	flow.Protocol = 1
+	flow.DstPort = 8 << 8
+	conn = flowToConn(flow)
+	assert.Equal(t, "icmp", conn.Proto)
+	assert.Equal(t, 8, conn.SourcePort)
+	assert.Equal(t, 0, conn.DestinationPort)
+
+	flow.Protocol = 47
+	assert.Equal(t, "unknown_transport", flowToConn(flow).Proto)
+
+	//flows without both addresses can't be stored
+	flow.DstAddr = nil
+	assert.Nil(t, flowToConn(flow))
+}
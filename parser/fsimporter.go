@@ -6,11 +6,11 @@ import (
 	"net"
 	"os"
 	"path"
-	"reflect"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/activecm/rita/analysis/quality"
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	fpt "github.com/activecm/rita/parser/fileparsetypes"
@@ -30,6 +30,7 @@ type (
 		internal        []*net.IPNet
 		alwaysIncluded  []*net.IPNet
 		neverIncluded   []*net.IPNet
+		window          TimeWindow
 	}
 
 	uconnPair struct {
@@ -56,6 +57,11 @@ func (fs *FSImporter) GetInternalSubnets() []*net.IPNet {
 	return fs.internal
 }
 
+//SetTimeWindow restricts the import to records logged within the window
+func (fs *FSImporter) SetTimeWindow(window TimeWindow) {
+	fs.window = window
+}
+
 //Run starts importing a given path into a datastore
 func (fs *FSImporter) Run(datastore Datastore) {
 	// track the time spent parsing
@@ -70,28 +76,29 @@ func (fs *FSImporter) Run(datastore Datastore) {
 	//find all of the bro log paths
 	files := readDir(fs.res.Config.S.Bro.ImportDirectory, fs.res.Log)
 
-	//hash the files and get their stats
-	indexedFiles := indexFiles(files, fs.indexingThreads, fs.res.Config, fs.res.Log)
-
-	progTime := time.Now()
-	fs.res.Log.WithFields(
-		log.Fields{
-			"current_time": progTime.Format(util.TimeFormat),
-			"total_time":   progTime.Sub(start).String(),
-		},
-	).Info("Finished collecting file details. Starting upload.")
+	fs.importFiles(files, "", datastore, start)
+}
 
-	indexedFiles = removeOldFilesFromIndex(indexedFiles, fs.res.MetaDB, fs.res.Log)
+//importFiles indexes, parses, and stores the given bro files. If sensor is
+//set, it overrides the sensor assigned to each file. The files which were
+//imported are returned.
+func (fs *FSImporter) importFiles(files []string, sensor string,
+	datastore Datastore, start time.Time) []*fpt.IndexedFile {
+	indexedFiles := fs.indexNewFiles(files, sensor, start)
+	if len(indexedFiles) == 0 {
+		return nil
+	}
 
-	filterHugeUconnsMap, connMap := fs.parseFiles(indexedFiles, fs.parseThreads, datastore, fs.res.Log)
+	filter := fs.newRecordFilter(datastore)
+	fs.parseFiles(indexedFiles, fs.parseThreads, filter, fs.res.Log)
 
 	// Must wait for all inserts to finish before attempting to delete
 	datastore.Flush()
-	fs.bulkRemoveHugeUconns(indexedFiles[0].TargetDatabase, filterHugeUconnsMap, connMap)
+	fs.bulkRemoveHugeUconns(indexedFiles[0].TargetDatabase, filter)
 
 	updateFilesIndex(indexedFiles, fs.res.MetaDB, fs.res.Log)
 
-	progTime = time.Now()
+	progTime := time.Now()
 	fs.res.Log.WithFields(
 		log.Fields{
 			"current_time": progTime.Format(util.TimeFormat),
@@ -101,6 +108,8 @@ func (fs *FSImporter) Run(datastore Datastore) {
 	fmt.Println("\t[-] Indexing log entries. This may take a while.")
 	datastore.Index()
 
+	fs.buildQualityReports(indexedFiles)
+
 	progTime = time.Now()
 	fs.res.Log.WithFields(
 		log.Fields{
@@ -108,6 +117,53 @@ func (fs *FSImporter) Run(datastore Datastore) {
 			"total_time":   progTime.Sub(start).String(),
 		},
 	).Info("Finished importing log files")
+	return indexedFiles
+}
+
+//indexNewFiles gathers the details of the given bro files and returns the
+//files which have not been imported before. If sensor is set, it
+//overrides the sensor assigned to each file.
+func (fs *FSImporter) indexNewFiles(files []string, sensor string, start time.Time) []*fpt.IndexedFile {
+	//hash the files and get their stats
+	indexedFiles := indexFiles(files, fs.indexingThreads, fs.res.Config, fs.window, fs.res.Log)
+	if sensor != "" {
+		for _, indexedFile := range indexedFiles {
+			if indexedFile != nil {
+				indexedFile.Sensor = sensor
+			}
+		}
+	}
+
+	progTime := time.Now()
+	fs.res.Log.WithFields(
+		log.Fields{
+			"current_time": progTime.Format(util.TimeFormat),
+			"total_time":   progTime.Sub(start).String(),
+		},
+	).Info("Finished collecting file details. Starting upload.")
+
+	indexedFiles = removeOldFilesFromIndex(indexedFiles, fs.window, fs.res.MetaDB, fs.res.Log)
+	if len(indexedFiles) == 0 {
+		fmt.Println("\t[-] No new files to import")
+		fs.res.Log.Info("Finished importing log files. No new files were found.")
+		return nil
+	}
+
+	fs.assignClockOffsets(indexedFiles)
+	return indexedFiles
+}
+
+//buildQualityReports rebuilds the data quality reports of the databases
+//the files were imported into
+func (fs *FSImporter) buildQualityReports(indexedFiles []*fpt.IndexedFile) {
+	built := make(map[string]bool)
+	for _, indexedFile := range indexedFiles {
+		if built[indexedFile.TargetDatabase] {
+			continue
+		}
+		built[indexedFile.TargetDatabase] = true
+		quality.BuildQualityReport(fs.res, indexedFile.TargetDatabase)
+	}
 }
 
 // readDir recursively reads the directory looking for log and .gz files
@@ -137,9 +193,10 @@ func readDir(cpath string, logger *log.Logger) []string {
 }
 
 //indexFiles takes in a list of bro files, a number of threads, and parses
-//some metadata out of the files
+//some metadata out of the files. Files which were logged entirely outside
+//of the time window are skipped.
 func indexFiles(files []string, indexingThreads int,
-	cfg *config.Config, logger *log.Logger) []*fpt.IndexedFile {
+	cfg *config.Config, window TimeWindow, logger *log.Logger) []*fpt.IndexedFile {
 	n := len(files)
 	output := make([]*fpt.IndexedFile, n)
 	indexingWG := new(sync.WaitGroup)
@@ -161,6 +218,12 @@ func indexFiles(files []string, indexingThreads int,
 					//errored on files will be nil
 					continue
 				}
+				if !fileInWindow(indexedFile, window, &sysConf.S.Bro, logger) {
+					logger.WithFields(log.Fields{
+						"file": files[j],
+					}).Info("Skipping file outside of the import time window")
+					continue
+				}
 				indexedFiles[j] = indexedFile
 			}
 			wg.Done()
@@ -171,24 +234,60 @@ func indexFiles(files []string, indexingThreads int,
 	return output
 }
 
+//fileInWindow checks whether the time range covered by an indexed file
+//overlaps the time window. The end of the range is only read from the
+//file if the window has a lower bound. The range is shifted by the clock
+//offset configured for the file. Offsets are estimated after the files
+//are indexed, so the range of a file whose offset may be estimated is
+//widened by the MaxClockOffset instead.
+func fileInWindow(indexedFile *fpt.IndexedFile, window TimeWindow,
+	broConfig *config.BroStaticCfg, logger *log.Logger) bool {
+	if !window.IsSet() {
+		return true
+	}
+
+	offset, configured := configuredClockOffset(indexedFile.Path, indexedFile.Sensor, broConfig)
+	var slack int64
+	if !configured && broConfig.ClockReference != "" &&
+		indexedFile.Sensor != broConfig.ClockReference {
+		slack = broConfig.MaxClockOffset
+	}
+
+	var closeTime time.Time
+	if !window.Since.IsZero() {
+		var err error
+		closeTime, err = scanCloseTime(indexedFile.Path)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"file":  indexedFile.Path,
+				"error": err.Error(),
+			}).Debug("Could not read the close time of file")
+		}
+	}
+	openTime := indexedFile.GetHeader().Open
+	if !openTime.IsZero() {
+		openTime = openTime.Add(time.Duration(offset-slack) * time.Second)
+	}
+	if !closeTime.IsZero() {
+		closeTime = closeTime.Add(time.Duration(offset+slack) * time.Second)
+	}
+	return window.Overlaps(openTime, closeTime)
+}
+
 //parseFiles takes in a list of indexed bro files, the number of
-//threads to use to parse the files, whether or not to sort data by date,
-//a MongoDB datastore object to store the bro data in, and a logger to report
-//errors and parses the bro files line by line into the database.
-func (fs *FSImporter) parseFiles(indexedFiles []*fpt.IndexedFile, parsingThreads int, datastore Datastore, logger *log.Logger) ([]uconnPair, map[uconnPair]int) {
+//threads to use to parse the files, the record filter which stores the bro
+//data in its datastore, and a logger to report errors and parses the bro
+//files line by line into the database.
+func (fs *FSImporter) parseFiles(indexedFiles []*fpt.IndexedFile, parsingThreads int, filter *recordFilter, logger *log.Logger) {
 
 	//set up parallel parsing
 	n := len(indexedFiles)
 	parsingWG := new(sync.WaitGroup)
 
-	// Counts the number of uconns per source-destination pair
-	connMap := make(map[uconnPair]int)
-
-	// map to hold the too many connections uconns
-	var filterHugeUconnsMap []uconnPair
-
-	// Creates a mutex for locking map keys during read-write operations
-	var mutex = &sync.Mutex{}
+	tracker, trackFiles := filter.datastore.(fileTracker)
+	if trackFiles {
+		tracker.ExpectFiles(indexedFiles)
+	}
 
 	for i := 0; i < parsingThreads; i++ {
 		parsingWG.Add(1)
@@ -225,80 +324,30 @@ func (fs *FSImporter) parseFiles(indexedFiles []*fpt.IndexedFile, parsingThreads
 						indexedFiles[j].GetHeader(),
 						indexedFiles[j].GetFieldMap(),
 						indexedFiles[j].GetBroDataFactory(),
+						indexedFiles[j].ClockOffset,
 						logger,
 					)
-					// The number of conns in a uconn
-					connCount := 0
-					// The maximum number of conns that will be stored
-					// We need to move this somewhere where the importer & analyzer can both access it
-					connLimit := fs.res.Config.S.Strobe.ConnectionLimit
-
 					if data != nil {
-						//figure out what database this line is heading for
-						targetCollection := indexedFiles[j].TargetCollection
-						targetDB := indexedFiles[j].TargetDatabase
-
-						// if target collection is the conns table we want to limit
-						// conns entries to unique connection pairs with fewer than connLimit
-						// records
-						if targetCollection == fs.res.Config.T.Structure.ConnTable {
-							parseConn := reflect.ValueOf(data).Elem()
-
-							var uconn uconnPair
-
-							// Use reflection to access the conn entry's fields. At this point inside
-							// the if statement we know parseConn is a "conn" instance, but the code
-							// assumes a generic "BroType" interface.
-							uconn.src = parseConn.FieldByName("Source").Interface().(string)
-							uconn.dst = parseConn.FieldByName("Destination").Interface().(string)
-
-							// Run conn pair through filter to filter out certain connections
-							ignore := fs.filterConnPair(uconn.src, uconn.dst)
-
-							// If connection pair is not subject to filtering, process
-							if !ignore {
-								// Override LocalOrigin and LocalResponse fields based on InternalSubnets setting
-								// Changes to parseConn are also made in the data variable
-								parseConn.FieldByName("LocalOrigin").SetBool(containsIP(fs.GetInternalSubnets(), net.ParseIP(uconn.src)))
-								parseConn.FieldByName("LocalResponse").SetBool(containsIP(fs.GetInternalSubnets(), net.ParseIP(uconn.dst)))
-
-								// Safely store the number of conns for this uconn
-								mutex.Lock()
-								connMap[uconn] = connMap[uconn] + 1
-								connCount = connMap[uconn]
-
-								// Do not store more than the connLimit
-								if connCount < connLimit {
-									datastore.Store(&ImportedData{
-										BroData:          data,
-										TargetDatabase:   targetDB,
-										TargetCollection: targetCollection,
-									})
-								} else if connCount == connLimit {
-									// Once we know a uconn has passed the connLimit not only
-									// do we want to avoid storing any more, but we want to
-									// remove all entries already added. The first time we pass
-									// the limit put an entry in filterHugeUconnsMap in order
-									// to run a bulk delete later.
-									filterHugeUconnsMap = append(filterHugeUconnsMap, uconn)
-								}
-
-								mutex.Unlock()
+						if ts, ok := getTimestamp(data); ok {
+							indexedFiles[j].ObserveTimestamp(ts)
+							//skip entries an earlier import of the file already stored
+							if indexedFiles[j].WasImported(ts) {
+								continue
 							}
-						} else {
-							// We do not limit any of the other log types
-
-							datastore.Store(&ImportedData{
-								BroData:          data,
-								TargetDatabase:   targetDB,
-								TargetCollection: targetCollection,
-							})
 						}
-
+						filter.store(
+							data,
+							indexedFiles[j].Sensor,
+							indexedFiles[j].TargetDatabase,
+							indexedFiles[j].TargetCollection,
+						)
 					}
 				}
 				indexedFiles[j].ParseTime = time.Now()
 				fileHandle.Close()
+				if trackFiles {
+					tracker.FinishFile(indexedFiles[j])
+				}
 				logger.WithFields(log.Fields{
 					"path": indexedFiles[j].Path,
 				}).Info("Finished parsing file")
@@ -307,13 +356,11 @@ func (fs *FSImporter) parseFiles(indexedFiles []*fpt.IndexedFile, parsingThreads
 		}(indexedFiles, logger, parsingWG, i, parsingThreads, n)
 	}
 	parsingWG.Wait()
-
-	return filterHugeUconnsMap, connMap
 }
 
-// bulkRemoveHugeUconns loops through every IP pair in filterHugeUconnsMap and deletes all corresponding
+// bulkRemoveHugeUconns loops through every IP pair which exceeded the connection limit and deletes all corresponding
 // entries in the "conn" collection. It also creates new entries in the FrequentConnTable collection.
-func (fs *FSImporter) bulkRemoveHugeUconns(targetDB string, filterHugeUconnsMap []uconnPair, connMap map[uconnPair]int) {
+func (fs *FSImporter) bulkRemoveHugeUconns(targetDB string, filter *recordFilter) {
 	resDB := fs.res.DB
 	resConf := fs.res.Config
 	logger := fs.res.Log
@@ -321,7 +368,7 @@ func (fs *FSImporter) bulkRemoveHugeUconns(targetDB string, filterHugeUconnsMap
 
 	// create a new datastore just for frequent connections since the old datastore
 	// will be Flushed by now and closed for writing
-	datastore := NewMongoDatastore(resDB.Session, fs.res.MetaDB,
+	datastore := NewMongoDatastore(resDB, fs.res.MetaDB,
 		resConf.S.Bro.ImportBuffer, logger)
 
 	// open a new database session for the bulk deletion
@@ -332,12 +379,13 @@ func (fs *FSImporter) bulkRemoveHugeUconns(targetDB string, filterHugeUconnsMap
 	bulk.Unordered()
 
 	fmt.Println("\t[-] Removing unused connection info. This may take a while.")
-	for _, uconn := range filterHugeUconnsMap {
+	for _, uconn := range filter.hugeUconns {
 		datastore.Store(&ImportedData{
 			BroData: &parsetypes.Freq{
 				Source:          uconn.src,
 				Destination:     uconn.dst,
-				ConnectionCount: connMap[uconn],
+				ConnectionCount: filter.connMap[uconn],
+				Sensors:         filter.connSensors[uconn],
 			},
 			TargetDatabase:   targetDB,
 			TargetCollection: resConf.T.Structure.FrequentConnTable,
@@ -368,8 +416,10 @@ func (fs *FSImporter) bulkRemoveHugeUconns(targetDB string, filterHugeUconnsMap
 //removeOldFilesFromIndex checks all indexedFiles passed in to ensure
 //that they have not previously been imported into the same database.
 //The files are compared based on their hashes (md5 of first 15000 bytes)
-//and the database they are slated to be imported into.
-func removeOldFilesFromIndex(indexedFiles []*fpt.IndexedFile,
+//and the database they are slated to be imported into. A file which was
+//clipped to a time window may be imported again with a window which does
+//not overlap the earlier one. The window is recorded on each new file.
+func removeOldFilesFromIndex(indexedFiles []*fpt.IndexedFile, window TimeWindow,
 	metaDatabase *database.MetaDB, logger *log.Logger) []*fpt.IndexedFile {
 	var toReturn []*fpt.IndexedFile
 	oldFiles, err := metaDatabase.GetFiles()
@@ -384,26 +434,58 @@ func removeOldFilesFromIndex(indexedFiles []*fpt.IndexedFile,
 			//this file was errored on earlier, i.e. we didn't find a tgtDB etc.
 			continue
 		}
-
-		have := false
-		for _, oldFile := range oldFiles {
-			if oldFile.Hash == newFile.Hash && oldFile.TargetDatabase == newFile.TargetDatabase {
-				logger.WithFields(log.Fields{
-					"path":            newFile.Path,
-					"target_database": newFile.TargetDatabase,
-				}).Warning("Refusing to import file into the same database twice")
-				have = true
-				break
-			}
+		window.Record(newFile)
+
+		covered, importedRanges := earlierImports(oldFiles, newFile)
+		if covered {
+			logger.WithFields(log.Fields{
+				"path":            newFile.Path,
+				"target_database": newFile.TargetDatabase,
+			}).Warning("Refusing to import file into the same database twice")
+			continue
 		}
 
-		if !have {
-			toReturn = append(toReturn, newFile)
+		if len(importedRanges) != 0 {
+			//only import the records the earlier imports left out
+			logger.WithFields(log.Fields{
+				"path":            newFile.Path,
+				"target_database": newFile.TargetDatabase,
+				"imported_ranges": importedRanges,
+			}).Warning("Importing only the records of the file left out of its earlier imports")
+			newFile.SetImportedRanges(importedRanges)
 		}
+		toReturn = append(toReturn, newFile)
 	}
 	return toReturn
 }
 
+//earlierImports checks the earlier imports of the new file into the same
+//database. It returns true if a single earlier import already stored every
+//record within the new file's window. Otherwise, it returns the windows
+//of the earlier imports which overlap the new window, so the records within
+//them are not stored twice.
+func earlierImports(oldFiles []fpt.IndexedFile, newFile *fpt.IndexedFile) (bool, []fpt.ImportedRange) {
+	var importedRanges []fpt.ImportedRange
+	newWindow := recordedWindow(newFile)
+	for i := range oldFiles {
+		oldFile := &oldFiles[i]
+		if oldFile.Hash != newFile.Hash || oldFile.TargetDatabase != newFile.TargetDatabase {
+			continue
+		}
+		oldWindow := recordedWindow(oldFile)
+		if oldWindow.Covers(newWindow) {
+			return true, nil
+		}
+		if oldWindow.Overlaps(newWindow.Since, newWindow.Until) {
+			importedRanges = append(importedRanges, fpt.ImportedRange{
+				Since: oldFile.WindowSince,
+				Until: oldFile.WindowUntil,
+			})
+		}
+	}
+	return false, importedRanges
+}
+
 //updateFilesIndex updates the files collection in the metaDB with the newly parsed files
 func updateFilesIndex(indexedFiles []*fpt.IndexedFile, metaDatabase *database.MetaDB,
 	logger *log.Logger) {
@@ -0,0 +1,514 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	//HTTPImporter receives bro files pushed by sensors over HTTP. Sensors
+	//authenticate with a bearer token, and each file is spooled into the
+	//import directory under its dataset and sensor. Spooled files are
+	//queued and imported in the background by Run.
+	HTTPImporter struct {
+		spoolDirectory string
+		maxUploadSize  int64
+		closeTime      time.Duration
+		tokens         map[string]string
+		logger         *log.Logger
+		queue          chan httpImportJob
+		// targetDatabase names the database a spooled file is imported into
+		targetDatabase func(path string) string
+		// importFile imports a spooled file into its open dataset and
+		// returns true if the file was imported
+		importFile func(database string, path string, sensor string) bool
+		// finishDataset finishes a dataset which stopped receiving files
+		finishDataset func(database string)
+		// failedWrites counts the records which could not be written
+		failedWrites func() int64
+		// probeWrites checks if MongoDB can be reached while writes fail
+		probeWrites func() error
+		// writesFailing is set once an imported file had records which
+		// could not be written, and is cleared by a later file or by a
+		// successful probe
+		writesFailing bool
+		// headers holds the bro header of each log stream
+		headers map[string][]byte
+		lock    *sync.Mutex
+	}
+
+	//HTTPImportResult describes the outcome of an upload to the HTTPImporter
+	HTTPImportResult struct {
+		File     string `json:"file"`
+		Sensor   string `json:"sensor"`
+		Dataset  string `json:"dataset"`
+		Database string `json:"database,omitempty"`
+		Queued   bool   `json:"queued"`
+		Error    string `json:"error,omitempty"`
+	}
+
+	//httpImportJob is a spooled file waiting to be imported
+	httpImportJob struct {
+		path     string
+		sensor   string
+		database string
+	}
+)
+
+//httpImportNamePattern restricts the names used to build spool paths
+var httpImportNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.:-]*$`)
+
+//errUploadTooLarge is returned when an upload is larger than the limit
+var errUploadTooLarge = errors.New("file is larger than the upload limit")
+
+//NewHTTPImporter creates an HTTPImporter which spools files into the
+//importer's import directory and imports them into their datasets using a
+//fresh datastore for each file. tokens maps sensor names to the tokens they
+//authenticate with. Uploads larger than maxUploadSize bytes are rejected.
+//At most queueSize files wait to be imported, and datasets are finished
+//once they have not received files for closeTime.
+func NewHTTPImporter(importer *FSImporter, newDatastore func() Datastore,
+	tokens map[string]string, maxUploadSize int64, queueSize int,
+	closeTime time.Duration) *HTTPImporter {
+	//datasets is only used by the goroutine running Run
+	datasets := make(map[string]*DatasetImport)
+	broConfig := &importer.res.Config.S.Bro
+	return &HTTPImporter{
+		spoolDirectory: broConfig.ImportDirectory,
+		maxUploadSize:  maxUploadSize,
+		closeTime:      closeTime,
+		tokens:         tokens,
+		logger:         importer.res.Log,
+		queue:          make(chan httpImportJob, queueSize),
+		targetDatabase: func(path string) string {
+			return getTargetDatabase(path, broConfig)
+		},
+		importFile: func(database string, path string, sensor string) bool {
+			dataset, ok := datasets[database]
+			if !ok {
+				dataset = importer.NewDatasetImport(database)
+				datasets[database] = dataset
+			}
+			return len(dataset.ImportFiles([]string{path}, sensor, newDatastore())) > 0
+		},
+		finishDataset: func(database string) {
+			if dataset, ok := datasets[database]; ok {
+				dataset.Finish()
+				delete(datasets, database)
+			}
+		},
+		failedWrites: importer.res.DB.FailedWrites,
+		probeWrites: func() error {
+			ssn := importer.res.DB.Session.Copy()
+			defer ssn.Close()
+			return ssn.Ping()
+		},
+		headers: make(map[string][]byte),
+		lock:    new(sync.Mutex),
+	}
+}
+
+//ServeHTTP handles an upload. The sensor, dataset, and filename are given
+//as query parameters and the file is sent as the request body. Files
+//ending in .gz must be gzip compressed. Plain bro logs may be sent with a
+//Content-Encoding of gzip, in which case they are stored as .gz files.
+//Chunks of a log stream are sent with the log query parameter in place
+//of the filename. The file is queued for import and 202 Accepted is
+//returned. Files are refused while records of the last imported file
+//could not be written to MongoDB, until Run finds MongoDB reachable again.
+func (h *HTTPImporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	result := HTTPImportResult{
+		File:    filepath.Base(query.Get("filename")),
+		Sensor:  query.Get("sensor"),
+		Dataset: query.Get("dataset"),
+	}
+	stream := query.Get("log")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.respond(w, http.StatusMethodNotAllowed, result, errors.New("files must be sent with POST"))
+		return
+	}
+
+	if !h.authenticate(result.Sensor, r.Header.Get("Authorization")) {
+		h.respond(w, http.StatusUnauthorized, result, errors.New("invalid sensor or token"))
+		return
+	}
+
+	if !httpImportNamePattern.MatchString(result.Dataset) {
+		h.respond(w, http.StatusBadRequest, result, errors.New("invalid dataset name"))
+		return
+	}
+
+	h.lock.Lock()
+	writesFailing := h.writesFailing
+	h.lock.Unlock()
+	if writesFailing {
+		h.respond(w, http.StatusServiceUnavailable, result,
+			errors.New("records could not be written to MongoDB. Try again later"))
+		return
+	}
+
+	if r.ContentLength > h.maxUploadSize {
+		h.respond(w, http.StatusRequestEntityTooLarge, result, errUploadTooLarge)
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, h.maxUploadSize)
+
+	var spoolPath string
+	var status int
+	var err error
+	if stream != "" {
+		if !httpImportNamePattern.MatchString(stream) || strings.Contains(stream, ".") {
+			h.respond(w, http.StatusBadRequest, result, errors.New("invalid log name"))
+			return
+		}
+		result.File = fmt.Sprintf("%s.%d.log", stream, time.Now().UnixNano())
+		spoolPath = filepath.Join(h.spoolDirectory, result.Dataset, result.Sensor, result.File)
+		status, err = h.spoolStream(spoolPath, result.Dataset+"/"+result.Sensor+"/"+stream,
+			body, r.Header.Get("Content-Encoding") == "gzip")
+	} else {
+		if r.Header.Get("Content-Encoding") == "gzip" && !strings.HasSuffix(result.File, ".gz") {
+			result.File += ".gz"
+		}
+		if !httpImportNamePattern.MatchString(result.File) ||
+			!(strings.HasSuffix(result.File, ".log") || strings.HasSuffix(result.File, ".gz")) {
+			h.respond(w, http.StatusBadRequest, result, errors.New("filename must end in .log or .gz"))
+			return
+		}
+		//files are stored by dataset so they are imported into DBRoot-dataset
+		spoolPath = filepath.Join(h.spoolDirectory, result.Dataset, result.Sensor, result.File)
+		status, err = h.spool(spoolPath, body)
+	}
+	if err != nil {
+		h.respond(w, status, result, err)
+		return
+	}
+
+	job := httpImportJob{
+		path:     spoolPath,
+		sensor:   result.Sensor,
+		database: h.targetDatabase(spoolPath),
+	}
+	select {
+	case h.queue <- job:
+	default:
+		os.Remove(spoolPath)
+		h.respond(w, http.StatusServiceUnavailable, result,
+			errors.New("too many files are waiting to be imported. Try again later"))
+		return
+	}
+
+	result.Queued = true
+	result.Database = job.database
+	h.logger.WithFields(log.Fields{
+		"path":     spoolPath,
+		"sensor":   result.Sensor,
+		"database": result.Database,
+	}).Info("Queued file received over HTTP")
+	h.respond(w, http.StatusAccepted, result, nil)
+}
+
+//Resume queues the files left in the spool directory by an earlier run.
+//Files which have already been imported are skipped when they are
+//imported again. Partially received files are removed.
+func (h *HTTPImporter) Resume() {
+	//no upload is in progress yet, so any temporary files were abandoned
+	filepath.Walk(h.spoolDirectory, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasPrefix(info.Name(), ".") &&
+			strings.HasSuffix(info.Name(), ".part") {
+			os.Remove(path)
+		}
+		return nil
+	})
+
+	for _, path := range readDir(h.spoolDirectory, h.logger) {
+		relative, err := filepath.Rel(h.spoolDirectory, path)
+		if err != nil {
+			continue
+		}
+		//spooled files are stored as dataset/sensor/file
+		pieces := strings.Split(relative, string(os.PathSeparator))
+		if len(pieces) != 3 || strings.HasPrefix(pieces[2], ".") {
+			continue
+		}
+		job := httpImportJob{
+			path:     path,
+			sensor:   pieces[1],
+			database: h.targetDatabase(path),
+		}
+		select {
+		case h.queue <- job:
+		default:
+			h.logger.WithFields(log.Fields{
+				"path": path,
+			}).Warning("Import queue is full. Spooled file was not queued")
+		}
+	}
+}
+
+//Run imports the queued files until the stop channel is closed. Datasets
+//which have not received files for the close time are finished, as are
+//the open datasets when Run returns. While writes are failing, MongoDB is
+//probed on the same schedule so uploads resume once it recovers. Files left in the queue stay in the
+//spool directory and are queued again by Resume.
+func (h *HTTPImporter) Run(stop <-chan struct{}) {
+	checkInterval := time.Minute
+	if h.closeTime < checkInterval {
+		checkInterval = h.closeTime
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	lastImport := make(map[string]time.Time)
+	for {
+		select {
+		case <-stop:
+			for database := range lastImport {
+				h.finishDataset(database)
+			}
+			return
+		case job := <-h.queue:
+			h.importJob(job)
+			lastImport[job.database] = time.Now()
+		case now := <-ticker.C:
+			h.checkWrites()
+			for database, last := range lastImport {
+				if now.Sub(last) >= h.closeTime {
+					h.finishDataset(database)
+					delete(lastImport, database)
+				}
+			}
+		}
+	}
+}
+
+//importJob imports a spooled file. Files which are not imported are
+//removed as nothing refers to them. Whether records of the file could not
+//be written is recorded so uploads are refused until writes succeed.
+func (h *HTTPImporter) importJob(job httpImportJob) {
+	fields := log.Fields{
+		"path":     job.path,
+		"sensor":   job.sensor,
+		"database": job.database,
+	}
+	failedBefore := h.failedWrites()
+	imported := h.importFile(job.database, job.path, job.sensor)
+	failed := h.failedWrites() - failedBefore
+
+	h.lock.Lock()
+	h.writesFailing = failed > 0
+	h.lock.Unlock()
+	if failed > 0 {
+		fields["failed_writes"] = failed
+		h.logger.WithFields(fields).Error("Records of a file received over HTTP could not be written")
+		return
+	}
+
+	if !imported {
+		os.Remove(job.path)
+		h.logger.WithFields(fields).Warning(
+			"File received over HTTP was not imported. It may have been imported already or may not be a bro log")
+		return
+	}
+	h.logger.WithFields(fields).Info("Imported file received over HTTP")
+}
+
+//checkWrites probes MongoDB while writes are failing and accepts uploads
+//again once it responds. If writes still fail, the next imported file
+//refuses uploads again.
+func (h *HTTPImporter) checkWrites() {
+	h.lock.Lock()
+	writesFailing := h.writesFailing
+	h.lock.Unlock()
+	if !writesFailing {
+		return
+	}
+
+	if err := h.probeWrites(); err != nil {
+		h.logger.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warning("MongoDB is still unavailable. Refusing files received over HTTP")
+		return
+	}
+
+	h.lock.Lock()
+	h.writesFailing = false
+	h.lock.Unlock()
+	h.logger.Info("MongoDB is reachable again. Accepting files received over HTTP")
+}
+
+//authenticate checks the bearer token in the authorization header against
+//the token for the sensor
+func (h *HTTPImporter) authenticate(sensor string, authorization string) bool {
+	expected, ok := h.tokens[sensor]
+	if !ok || expected == "" || !httpImportNamePattern.MatchString(sensor) {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+//spool writes the body of an upload to the spool path. The file is written
+//under a temporary name and renamed once complete so partially received
+//files are never imported. An HTTP status code is returned with any error.
+func (h *HTTPImporter) spool(spoolPath string, body io.Reader) (int, error) {
+	return h.spoolWith(spoolPath, func(file io.Writer) (int, error) {
+		_, err := io.Copy(file, body)
+		return readStatus(err)
+	})
+}
+
+//spoolStream writes a chunk of a log stream to the spool path. The bro
+//header lines at the start of a chunk are remembered under the stream's
+//key, and chunks sent without a header are given the last header
+//received for the stream. Compressed chunks are decompressed, and are
+//rejected if they decompress to more than the upload limit.
+func (h *HTTPImporter) spoolStream(spoolPath string, key string, body io.Reader,
+	compressed bool) (int, error) {
+	var decompressed *io.LimitedReader
+	if compressed {
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return readStatus(err)
+		}
+		defer gzipReader.Close()
+		//read one byte past the limit to tell if the chunk is too large
+		decompressed = io.LimitReader(gzipReader, h.maxUploadSize+1).(*io.LimitedReader)
+		body = decompressed
+	}
+
+	return h.spoolWith(spoolPath, func(file io.Writer) (int, error) {
+		reader := bufio.NewReader(body)
+		var header bytes.Buffer
+		for {
+			next, err := reader.Peek(1)
+			if err != nil || next[0] != '#' {
+				break
+			}
+			line, err := reader.ReadBytes('\n')
+			header.Write(line)
+			if err != nil {
+				break
+			}
+		}
+
+		h.lock.Lock()
+		if header.Len() > 0 {
+			h.headers[key] = header.Bytes()
+		}
+		streamHeader, ok := h.headers[key]
+		h.lock.Unlock()
+		if !ok {
+			return http.StatusBadRequest, errors.New("the chunk has no bro header and no header has been received for the log")
+		}
+
+		if _, err := file.Write(streamHeader); err != nil {
+			return http.StatusInternalServerError, errors.New("could not store file")
+		}
+		_, err := io.Copy(file, reader)
+		if err == nil && decompressed != nil && decompressed.N <= 0 {
+			return http.StatusRequestEntityTooLarge, errUploadTooLarge
+		}
+		return readStatus(err)
+	})
+}
+
+//spoolWith creates the spool file and fills it using write, which returns
+//an HTTP status code with any error. The file is received under a
+//temporary name which only one upload may hold at a time, and is linked
+//into place so a file received concurrently is never replaced.
+func (h *HTTPImporter) spoolWith(spoolPath string, write func(io.Writer) (int, error)) (int, error) {
+	if _, err := os.Stat(spoolPath); err == nil {
+		return http.StatusConflict, errors.New("file has already been received")
+	}
+
+	err := os.MkdirAll(filepath.Dir(spoolPath), 0755)
+	if err != nil {
+		h.logger.WithFields(log.Fields{
+			"path":  spoolPath,
+			"error": err.Error(),
+		}).Error("Could not create spool directory")
+		return http.StatusInternalServerError, errors.New("could not store file")
+	}
+
+	tempPath := filepath.Join(filepath.Dir(spoolPath), "."+filepath.Base(spoolPath)+".part")
+	tempFile, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return http.StatusConflict, errors.New("file is already being received")
+	}
+	if err != nil {
+		h.logger.WithFields(log.Fields{
+			"path":  tempPath,
+			"error": err.Error(),
+		}).Error("Could not create spool file")
+		return http.StatusInternalServerError, errors.New("could not store file")
+	}
+
+	status, err := write(tempFile)
+	tempFile.Close()
+	if err != nil {
+		os.Remove(tempPath)
+		return status, err
+	}
+
+	err = os.Link(tempPath, spoolPath)
+	os.Remove(tempPath)
+	if os.IsExist(err) {
+		return http.StatusConflict, errors.New("file has already been received")
+	}
+	if err != nil {
+		h.logger.WithFields(log.Fields{
+			"path":  spoolPath,
+			"error": err.Error(),
+		}).Error("Could not move spool file into place")
+		return http.StatusInternalServerError, errors.New("could not store file")
+	}
+	return http.StatusOK, nil
+}
+
+//readStatus maps an error reading an upload to an HTTP status code.
+//Uploads cut off by the size limit are reported as too large.
+func readStatus(err error) (int, error) {
+	if err == nil {
+		return http.StatusOK, nil
+	}
+	//http.MaxBytesReader does not export its error
+	if err.Error() == "http: request body too large" {
+		return http.StatusRequestEntityTooLarge, errUploadTooLarge
+	}
+	return http.StatusBadRequest, errors.New("could not read file: " + err.Error())
+}
+
+//respond writes the result of an upload as JSON
+func (h *HTTPImporter) respond(w http.ResponseWriter, status int,
+	result HTTPImportResult, err error) {
+	if err != nil {
+		result.Error = err.Error()
+		h.logger.WithFields(log.Fields{
+			"file":    result.File,
+			"sensor":  result.Sensor,
+			"dataset": result.Dataset,
+			"error":   result.Error,
+		}).Warning("Rejected file received over HTTP")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
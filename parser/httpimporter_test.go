@@ -0,0 +1,373 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type httpImportCall struct {
+	database string
+	path     string
+	sensor   string
+}
+
+func newTestHTTPImporter(t *testing.T, spoolDirectory string, calls *[]httpImportCall) *HTTPImporter {
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	return &HTTPImporter{
+		spoolDirectory: spoolDirectory,
+		maxUploadSize:  1024,
+		closeTime:      time.Hour,
+		tokens:         map[string]string{"sensor1": "secret"},
+		logger:         logger,
+		queue:          make(chan httpImportJob, 2),
+		targetDatabase: func(path string) string {
+			return "RITA-" + filepath.Base(filepath.Dir(filepath.Dir(path)))
+		},
+		importFile: func(database string, path string, sensor string) bool {
+			*calls = append(*calls, httpImportCall{database, path, sensor})
+			return !strings.Contains(path, "bad")
+		},
+		finishDataset: func(database string) {
+			*calls = append(*calls, httpImportCall{database: database})
+		},
+		failedWrites: func() int64 { return 0 },
+		probeWrites:  func() error { return nil },
+		headers:      make(map[string][]byte),
+		lock:         new(sync.Mutex),
+	}
+}
+
+func postLog(t *testing.T, server *httptest.Server, query string, token string, body string) (int, HTTPImportResult) {
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/logs?"+query, strings.NewReader(body))
+	require.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	var result HTTPImportResult
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+	return resp.StatusCode, result
+}
+
+func TestHTTPImporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-httpimporter")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var calls []httpImportCall
+	importer := newTestHTTPImporter(t, dir, &calls)
+	server := httptest.NewServer(importer)
+	defer server.Close()
+
+	query := "sensor=sensor1&dataset=2018-01-01&filename=conn.00:00:00-01:00:00.log"
+	status, result := postLog(t, server, query, "secret", "#separator \\x09\n")
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.True(t, result.Queued)
+	assert.Equal(t, "RITA-2018-01-01", result.Database)
+
+	spoolPath := filepath.Join(dir, "2018-01-01", "sensor1", "conn.00:00:00-01:00:00.log")
+	contents, err := ioutil.ReadFile(spoolPath)
+	require.Nil(t, err)
+	assert.Equal(t, "#separator \\x09\n", string(contents))
+
+	// the same file may not be sent twice
+	status, _ = postLog(t, server, query, "secret", "#separator \\x09\n")
+	assert.Equal(t, http.StatusConflict, status)
+
+	status, _ = postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=bad.log", "secret", "garbage")
+	assert.Equal(t, http.StatusAccepted, status)
+
+	// files are not accepted while the queue is full
+	status, result = postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=dns.log", "secret", "")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.False(t, result.Queued)
+	_, err = os.Stat(filepath.Join(dir, "2018-01-01", "sensor1", "dns.log"))
+	assert.True(t, os.IsNotExist(err))
+
+	// the queued files are imported in the background and the dataset is
+	// finished when the importer stops
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		importer.Run(stop)
+		close(stopped)
+	}()
+	for len(importer.queue) > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	<-stopped
+
+	badPath := filepath.Join(dir, "2018-01-01", "sensor1", "bad.log")
+	require.Len(t, calls, 3)
+	assert.Equal(t, httpImportCall{"RITA-2018-01-01", spoolPath, "sensor1"}, calls[0])
+	assert.Equal(t, httpImportCall{"RITA-2018-01-01", badPath, "sensor1"}, calls[1])
+	assert.Equal(t, httpImportCall{database: "RITA-2018-01-01"}, calls[2])
+
+	// files which can't be imported are not kept
+	_, err = os.Stat(badPath)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(spoolPath)
+	assert.Nil(t, err)
+}
+
+func TestHTTPImporterRefusesFilesWhileWritesFail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-httpimporter")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var calls []httpImportCall
+	importer := newTestHTTPImporter(t, dir, &calls)
+	var failed int64
+	importer.failedWrites = func() int64 { return failed }
+	importFile := importer.importFile
+	importer.importFile = func(database string, path string, sensor string) bool {
+		if strings.Contains(path, "conn") {
+			failed += 5
+		}
+		return importFile(database, path, sensor)
+	}
+	server := httptest.NewServer(importer)
+	defer server.Close()
+
+	status, _ := postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=conn.log", "secret", "")
+	assert.Equal(t, http.StatusAccepted, status)
+	importer.importJob(<-importer.queue)
+
+	status, result := postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=dns.log", "secret", "")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.False(t, result.Queued)
+
+	//uploads are refused while MongoDB cannot be reached
+	importer.probeWrites = func() error { return errors.New("no reachable servers") }
+	importer.checkWrites()
+	status, _ = postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=dns.log", "secret", "")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	//and are accepted again once it recovers
+	importer.probeWrites = func() error { return nil }
+	importer.checkWrites()
+	status, _ = postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=dns.log", "secret", "")
+	assert.Equal(t, http.StatusAccepted, status)
+	importer.importJob(<-importer.queue)
+	status, _ = postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=http.log", "secret", "")
+	assert.Equal(t, http.StatusAccepted, status)
+}
+
+func TestHTTPImporterRecoversWhenWritesWork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-httpimporter")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var calls []httpImportCall
+	importer := newTestHTTPImporter(t, dir, &calls)
+	importer.closeTime = 10 * time.Millisecond
+	var failed int64
+	importer.failedWrites = func() int64 { return failed }
+	importFile := importer.importFile
+	importer.importFile = func(database string, path string, sensor string) bool {
+		if strings.Contains(path, "conn") {
+			failed += 5
+		}
+		return importFile(database, path, sensor)
+	}
+	server := httptest.NewServer(importer)
+	defer server.Close()
+
+	status, _ := postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=conn.log", "secret", "")
+	assert.Equal(t, http.StatusAccepted, status)
+	importer.importJob(<-importer.queue)
+	status, _ = postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=dns.log", "secret", "")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		importer.Run(stop)
+		close(done)
+	}()
+
+	//Run probes MongoDB and accepts uploads again without another import
+	for i := 0; i < 200 && status != http.StatusAccepted; i++ {
+		time.Sleep(5 * time.Millisecond)
+		status, _ = postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=dns.log", "secret", "")
+	}
+	close(stop)
+	<-done
+	assert.Equal(t, http.StatusAccepted, status)
+}
+
+func TestHTTPImporterResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-httpimporter")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	spoolPath := filepath.Join(dir, "2018-01-01", "sensor1", "conn.log")
+	require.Nil(t, os.MkdirAll(filepath.Dir(spoolPath), 0755))
+	require.Nil(t, ioutil.WriteFile(spoolPath, []byte("#separator \\x09\n"), 0644))
+	// partially received files are removed instead of queued
+	partPath := filepath.Join(dir, "2018-01-01", "sensor1", ".dns.log.part")
+	require.Nil(t, ioutil.WriteFile(partPath, []byte("#separator \\x09\n"), 0644))
+
+	var calls []httpImportCall
+	importer := newTestHTTPImporter(t, dir, &calls)
+	importer.Resume()
+
+	require.Len(t, importer.queue, 1)
+	assert.Equal(t, httpImportJob{spoolPath, "sensor1", "RITA-2018-01-01"}, <-importer.queue)
+	_, err = os.Stat(partPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHTTPImporterStreams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-httpimporter")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var calls []httpImportCall
+	importer := newTestHTTPImporter(t, dir, &calls)
+	server := httptest.NewServer(importer)
+	defer server.Close()
+
+	query := "sensor=sensor1&dataset=2018-01-01&log=conn"
+
+	// the first chunk of a stream must carry the bro header
+	status, _ := postLog(t, server, query, "secret", "1\tC1\n")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	header := "#separator \\x09\n#fields\tts\tuid\n"
+	status, first := postLog(t, server, query, "secret", header+"1\tC1\n")
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.True(t, strings.HasPrefix(first.File, "conn.") && strings.HasSuffix(first.File, ".log"))
+
+	// later chunks are given the header of the stream
+	status, second := postLog(t, server, query, "secret", "2\tC2\n")
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.NotEqual(t, first.File, second.File)
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "2018-01-01", "sensor1", first.File))
+	require.Nil(t, err)
+	assert.Equal(t, header+"1\tC1\n", string(contents))
+	contents, err = ioutil.ReadFile(filepath.Join(dir, "2018-01-01", "sensor1", second.File))
+	require.Nil(t, err)
+	assert.Equal(t, header+"2\tC2\n", string(contents))
+
+	status, _ = postLog(t, server, "sensor=sensor1&dataset=2018-01-01&log=../conn", "secret", header)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// compressed chunks may not decompress past the upload limit
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(header + strings.Repeat("3\tC3\n", 1000)))
+	writer.Close()
+	require.True(t, compressed.Len() < 1024)
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/logs?"+query, &compressed)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	files, err := ioutil.ReadDir(filepath.Join(dir, "2018-01-01", "sensor1"))
+	require.Nil(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestHTTPImporterSpoolConflicts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-httpimporter")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var calls []httpImportCall
+	importer := newTestHTTPImporter(t, dir, &calls)
+	spoolPath := filepath.Join(dir, "2018-01-01", "sensor1", "conn.log")
+	write := func(contents string) func(io.Writer) (int, error) {
+		return func(file io.Writer) (int, error) {
+			file.Write([]byte(contents))
+			return http.StatusOK, nil
+		}
+	}
+
+	// a file being received under the same name is not replaced
+	status, err := importer.spoolWith(spoolPath, func(file io.Writer) (int, error) {
+		status, err := importer.spoolWith(spoolPath, write("second"))
+		assert.Equal(t, http.StatusConflict, status)
+		assert.NotNil(t, err)
+		return write("first")(file)
+	})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Nil(t, err)
+
+	// and neither is a file which has been received
+	status, _ = importer.spoolWith(spoolPath, write("third"))
+	assert.Equal(t, http.StatusConflict, status)
+	contents, err := ioutil.ReadFile(spoolPath)
+	require.Nil(t, err)
+	assert.Equal(t, "first", string(contents))
+}
+
+func TestHTTPImporterRejectsRequests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-httpimporter")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var calls []httpImportCall
+	server := httptest.NewServer(newTestHTTPImporter(t, dir, &calls))
+	defer server.Close()
+
+	status, _ := postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=conn.log", "wrong", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = postLog(t, server, "sensor=sensor2&dataset=2018-01-01&filename=conn.log", "secret", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = postLog(t, server, "sensor=sensor1&dataset=..&filename=conn.log", "secret", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=conn.txt", "secret", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = postLog(t, server, "sensor=sensor1&dataset=2018-01-01&filename=conn.log", "secret",
+		strings.Repeat("a", 2048))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	// bodies without a length are cut off at the limit
+	req, err := http.NewRequest(http.MethodPost,
+		server.URL+"/api/v1/logs?sensor=sensor1&dataset=2018-01-01&filename=conn.log",
+		ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 2048))))
+	require.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + "/api/v1/logs")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	assert.Empty(t, calls)
+	files, err := ioutil.ReadDir(filepath.Join(dir, "2018-01-01", "sensor1"))
+	require.Nil(t, err)
+	assert.Empty(t, files)
+}
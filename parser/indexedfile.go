@@ -71,7 +71,7 @@ func newIndexedFile(filePath string, config *config.Config,
 	toReturn.SetFieldMap(fieldMap)
 
 	//parse first line
-	line := parseLine(scanner.Text(), header, fieldMap, broDataFactory, logger)
+	line := parseLine(scanner.Text(), header, fieldMap, broDataFactory, 0, logger)
 	if line == nil {
 		fileHandle.Close()
 		return toReturn, errors.New("Could not parse first line of file for time")
@@ -89,6 +89,8 @@ func newIndexedFile(filePath string, config *config.Config,
 		return toReturn, errors.New("Could not find a dataset for file")
 	}
 
+	toReturn.Sensor = getSensorID(filePath, &config.S.Bro, &config.R.Bro)
+
 	fileHandle.Close()
 	return toReturn, nil
 }
@@ -132,3 +134,21 @@ func getTargetDatabase(filePath string, broConfig *config.BroStaticCfg) string {
 	}
 	return targetDatabase.String()
 }
+
+//getSensorID assigns a sensor to a log file based on the path and the bro
+//config. A sensor captured by the SensorPattern takes precedence over the
+//SensorID set for the whole import directory.
+func getSensorID(filePath string, broConfig *config.BroStaticCfg,
+	broRunning *config.BroRunningCfg) string {
+	if broRunning.SensorPattern != nil {
+		relativePath := strings.TrimPrefix(
+			strings.TrimPrefix(filePath, broConfig.ImportDirectory),
+			string(os.PathSeparator),
+		)
+		match := broRunning.SensorPattern.FindStringSubmatch(relativePath)
+		if len(match) > 1 && match[1] != "" {
+			return match[1]
+		}
+	}
+	return broConfig.SensorID
+}
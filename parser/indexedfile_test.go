@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/activecm/rita/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSensorID(t *testing.T) {
+	broConfig := &config.BroStaticCfg{
+		ImportDirectory: "/opt/bro/logs",
+		SensorID:        "default-sensor",
+	}
+
+	// without a pattern every file belongs to the configured sensor
+	noPattern := &config.BroRunningCfg{}
+	assert.Equal(t, "default-sensor",
+		getSensorID("/opt/bro/logs/conn.log", broConfig, noPattern))
+	assert.Equal(t, "default-sensor",
+		getSensorID("/opt/bro/logs/site-a/conn.log", broConfig, noPattern))
+
+	// the pattern is matched against the path relative to the import directory
+	subfolder := &config.BroRunningCfg{
+		SensorPattern: regexp.MustCompile("^([^/]+)/"),
+	}
+	assert.Equal(t, "site-a",
+		getSensorID("/opt/bro/logs/site-a/conn.log", broConfig, subfolder))
+	assert.Equal(t, "default-sensor",
+		getSensorID("/opt/bro/logs/conn.log", broConfig, subfolder),
+		"files which do not match the pattern should fall back to SensorID")
+
+	broConfig.SensorID = ""
+	assert.Equal(t, "",
+		getSensorID("/opt/bro/logs/conn.log", broConfig, noPattern))
+}
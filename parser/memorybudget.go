@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	pt "github.com/activecm/rita/parser/parsetypes"
+)
+
+//memoryBudget limits the memory held by records which have been handed to
+//a datastore but not yet written. Callers block in acquire until enough
+//memory has been released, which slows the parsers down to the speed at
+//which MongoDB accepts writes.
+type memoryBudget struct {
+	limit   int64
+	used    int64
+	waiters int
+	waited  time.Duration
+	mutex   *sync.Mutex
+	cond    *sync.Cond
+	flushes []chan struct{}
+}
+
+//newMemoryBudget creates a memoryBudget holding at most limit bytes. A
+//limit of zero or less never blocks.
+func newMemoryBudget(limit int64) *memoryBudget {
+	mutex := new(sync.Mutex)
+	return &memoryBudget{
+		limit: limit,
+		mutex: mutex,
+		cond:  sync.NewCond(mutex),
+	}
+}
+
+//register adds a channel which is signalled when the writer holding it
+//should write out its partially filled buffer to free up memory
+func (b *memoryBudget) register(flush chan struct{}) {
+	b.mutex.Lock()
+	b.flushes = append(b.flushes, flush)
+	b.mutex.Unlock()
+}
+
+//acquire reserves size bytes, waiting for memory to be released if the
+//budget is spent. A record larger than the whole budget is let through
+//once nothing else is held.
+func (b *memoryBudget) acquire(size int64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.limit > 0 && b.used > 0 && b.used+size > b.limit {
+		start := time.Now()
+		b.waiters++
+		for _, flush := range b.flushes {
+			select {
+			case flush <- struct{}{}:
+			default:
+			}
+		}
+		for b.used > 0 && b.used+size > b.limit {
+			b.cond.Wait()
+		}
+		b.waiters--
+		b.waited += time.Since(start)
+	}
+	b.used += size
+}
+
+//release returns size bytes to the budget
+func (b *memoryBudget) release(size int64) {
+	b.mutex.Lock()
+	b.used -= size
+	b.mutex.Unlock()
+	b.cond.Broadcast()
+}
+
+//pressured returns true if a caller is waiting for memory to be released
+func (b *memoryBudget) pressured() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.waiters > 0
+}
+
+//usage returns the bytes held and the total time callers spent waiting
+func (b *memoryBudget) usage() (int64, time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.used, b.waited
+}
+
+//recordSize estimates the memory held by a bro entry
+func recordSize(data pt.BroData) int64 {
+	value := reflect.ValueOf(data).Elem()
+	size := int64(value.Type().Size())
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			size += int64(field.Len())
+		case reflect.Slice:
+			size += int64(field.Len()) * int64(field.Type().Elem().Size())
+			if field.Type().Elem().Kind() == reflect.String {
+				for j := 0; j < field.Len(); j++ {
+					size += int64(field.Index(j).Len())
+				}
+			}
+		}
+	}
+	return size
+}
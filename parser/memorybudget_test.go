@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	pt "github.com/activecm/rita/parser/parsetypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBudgetBackpressure(t *testing.T) {
+	budget := newMemoryBudget(100)
+	flush := make(chan struct{}, 1)
+	budget.register(flush)
+
+	budget.acquire(60)
+	assert.False(t, budget.pressured())
+
+	acquired := make(chan struct{})
+	go func() {
+		budget.acquire(60)
+		close(acquired)
+	}()
+
+	// the writer is asked to flush while the caller waits
+	select {
+	case <-flush:
+	case <-time.After(time.Second):
+		t.Fatal("writer was not asked to flush")
+	}
+	assert.True(t, budget.pressured())
+	select {
+	case <-acquired:
+		t.Fatal("acquired memory beyond the budget")
+	default:
+	}
+
+	budget.release(60)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("released memory was not handed to the waiting caller")
+	}
+
+	used, waited := budget.usage()
+	assert.Equal(t, int64(60), used)
+	assert.True(t, waited > 0)
+	assert.False(t, budget.pressured())
+
+	// a record larger than the budget gets through once nothing is held
+	budget.release(60)
+	budget.acquire(500)
+	used, _ = budget.usage()
+	assert.Equal(t, int64(500), used)
+}
+
+func TestMemoryBudgetUnlimited(t *testing.T) {
+	budget := newMemoryBudget(0)
+	budget.acquire(1 << 40)
+	budget.acquire(1 << 40)
+	used, waited := budget.usage()
+	assert.Equal(t, int64(1<<41), used)
+	assert.Equal(t, time.Duration(0), waited)
+}
+
+func TestRecordSize(t *testing.T) {
+	empty := recordSize(&pt.DNS{})
+	dns := recordSize(&pt.DNS{
+		Query:   "example.com",
+		Answers: []string{"10.0.0.1", "10.0.0.2"},
+	})
+	assert.True(t, empty > 0)
+	assert.True(t, dns >= empty+int64(len("example.com")+len("10.0.0.1")+len("10.0.0.2")))
+}
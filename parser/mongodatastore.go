@@ -3,10 +3,13 @@ package parser
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/activecm/rita/database"
+	pt "github.com/activecm/rita/parser/parsetypes"
 	mgo "github.com/globalsign/mgo"
 )
 
@@ -24,13 +27,23 @@ type collectionMap struct {
 	rwLock      *sync.Mutex
 }
 
+//queuedRecord is a bro entry waiting to be written along with the
+//number of bytes it holds in the memory budget
+type queuedRecord struct {
+	data pt.BroData
+	size int64
+}
+
 //collectionWriter reads a channel and inserts the data into MongoDB
 type collectionWriter struct {
-	writeChannel     chan *ImportedData
+	//queued is accessed atomically and is kept first
+	//to ensure 64 bit alignment
+	queued           int64
+	writeChannel     chan queuedRecord
+	flushChannel     chan struct{}
+	budget           *memoryBudget
 	writerWG         *sync.WaitGroup
-	session          *mgo.Session
-	logger           *log.Logger
-	bufferSize       int
+	bulk             *database.BulkWriter
 	targetDatabase   string
 	targetCollection string
 	indices          []string
@@ -38,7 +51,7 @@ type collectionWriter struct {
 
 //MongoDatastore provides a backend for storing bro data in MongoDB
 type MongoDatastore struct {
-	session       *mgo.Session
+	db            *database.DB
 	metaDB        *database.MetaDB
 	bufferSize    int
 	logger        *log.Logger
@@ -46,14 +59,19 @@ type MongoDatastore struct {
 	writeMap      storeMap
 	analyzedDBs   []string
 	unanalyzedDBs []string
+	budget        *memoryBudget
+	statsInterval time.Duration
+	stopStats     chan struct{}
+	statsWG       *sync.WaitGroup
+	started       time.Time
 }
 
 //NewMongoDatastore returns a new MongoDatastore and caches the existing
 //db names
-func NewMongoDatastore(session *mgo.Session, metaDB *database.MetaDB,
+func NewMongoDatastore(db *database.DB, metaDB *database.MetaDB,
 	bufferSize int, logger *log.Logger) *MongoDatastore {
 	return &MongoDatastore{
-		session:    session,
+		db:         db,
 		metaDB:     metaDB,
 		bufferSize: bufferSize,
 		logger:     logger,
@@ -64,9 +82,27 @@ func NewMongoDatastore(session *mgo.Session, metaDB *database.MetaDB,
 		},
 		analyzedDBs:   metaDB.GetAnalyzedDatabases(),
 		unanalyzedDBs: metaDB.GetUnAnalyzedDatabases(),
+		budget:        newMemoryBudget(0),
+		statsWG:       new(sync.WaitGroup),
 	}
 }
 
+//SetMemoryBudget limits the memory held by records waiting to be written
+//to the given number of megabytes. Store blocks while the budget is spent.
+//A budget of 0 disables the limit. SetMemoryBudget must be called before
+//any data is stored.
+func (mongo *MongoDatastore) SetMemoryBudget(megabytes int64) {
+	mongo.budget = newMemoryBudget(megabytes * 1024 * 1024)
+}
+
+//SetStatsInterval sets how often the depth of the write queues and the
+//write throughput are logged while data is stored. An interval of 0
+//disables the reports. SetStatsInterval must be called before any data
+//is stored.
+func (mongo *MongoDatastore) SetStatsInterval(interval time.Duration) {
+	mongo.statsInterval = interval
+}
+
 //Store saves parsed Bro data to MongoDB.
 //Additionally, it caches some information to create indices later on
 func (mongo *MongoDatastore) Store(data *ImportedData) {
@@ -76,7 +112,11 @@ func (mongo *MongoDatastore) Store(data *ImportedData) {
 		return
 	}
 	collWriter := mongo.getCollectionWriter(data, collMap)
-	collWriter.writeChannel <- data
+
+	size := recordSize(data.BroData)
+	mongo.budget.acquire(size)
+	atomic.AddInt64(&collWriter.queued, 1)
+	collWriter.writeChannel <- queuedRecord{data: data.BroData, size: size}
 }
 
 //Flush waits for all writing to finish
@@ -91,13 +131,22 @@ func (mongo *MongoDatastore) Flush() {
 	}
 	mongo.writeMap.rwLock.Unlock()
 	mongo.writerWG.Wait()
+
+	if mongo.stopStats != nil {
+		close(mongo.stopStats)
+		mongo.statsWG.Wait()
+		mongo.stopStats = nil
+		mongo.logImportStats(log.Fields{
+			"duration": time.Since(mongo.started).String(),
+		}, "Finished writing records")
+	}
 }
 
 //Index ensures that the data is searchable
 func (mongo *MongoDatastore) Index() {
 	//NOTE: We do this one by one in order to prevent individual indexing
 	//operations from taking too long
-	ssn := mongo.session.Copy()
+	ssn := mongo.db.Session.Copy()
 	defer ssn.Close()
 
 	mongo.writeMap.rwLock.Lock()
@@ -193,12 +242,19 @@ func (mongo *MongoDatastore) getCollectionWriter(data *ImportedData, collMap *co
 	if ok {
 		return collWriter
 	}
+	if mongo.stopStats == nil {
+		mongo.startStats()
+	}
+	flushChannel := make(chan struct{}, 1)
+	mongo.budget.register(flushChannel)
+	mongo.writerWG.Add(1)
 	collMap.collections[data.TargetCollection] = &collectionWriter{
-		writeChannel:     make(chan *ImportedData),
-		writerWG:         mongo.writerWG,
-		session:          mongo.session.Copy(),
-		logger:           mongo.logger,
-		bufferSize:       mongo.bufferSize,
+		writeChannel: make(chan queuedRecord),
+		flushChannel: flushChannel,
+		budget:       mongo.budget,
+		writerWG:     mongo.writerWG,
+		bulk: mongo.db.NewBulkWriterFor(data.TargetDatabase,
+			data.TargetCollection, mongo.bufferSize),
 		targetDatabase:   data.TargetDatabase,
 		targetCollection: data.TargetCollection,
 		indices:          data.BroData.Indices(),
@@ -207,44 +263,108 @@ func (mongo *MongoDatastore) getCollectionWriter(data *ImportedData, collMap *co
 	return collMap.collections[data.TargetCollection]
 }
 
+//startStats records the start of the import and spins up a thread which
+//periodically logs the state of the collection writers
+func (mongo *MongoDatastore) startStats() {
+	mongo.started = time.Now()
+	mongo.stopStats = make(chan struct{})
+	if mongo.statsInterval <= 0 {
+		return
+	}
+
+	mongo.statsWG.Add(1)
+	go func(stop chan struct{}) {
+		defer mongo.statsWG.Done()
+		ticker := time.NewTicker(mongo.statsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				mongo.logImportStats(log.Fields{}, "Import progress")
+			}
+		}
+	}(mongo.stopStats)
+}
+
+//logImportStats logs the number of records waiting in each collection
+//writer's queue, the rate at which each collection has been written to,
+//and the memory held by records waiting to be written
+func (mongo *MongoDatastore) logImportStats(fields log.Fields, message string) {
+	elapsed := time.Since(mongo.started).Seconds()
+	if elapsed <= 0 {
+		elapsed = 1
+	}
+
+	var queued, written int64
+	mongo.writeMap.rwLock.Lock()
+	for _, collMap := range mongo.writeMap.databases {
+		collMap.rwLock.Lock()
+		for _, collWriter := range collMap.collections {
+			collQueued := atomic.LoadInt64(&collWriter.queued)
+			collWritten := collWriter.bulk.Written()
+			queued += collQueued
+			written += collWritten
+			mongo.logger.WithFields(log.Fields{
+				"target_database":   collWriter.targetDatabase,
+				"target_collection": collWriter.targetCollection,
+				"queue_depth":       collQueued,
+				"written":           collWritten,
+				"records_per_sec":   int64(float64(collWritten) / elapsed),
+			}).Debug("Collection writer progress")
+		}
+		collMap.rwLock.Unlock()
+	}
+	mongo.writeMap.rwLock.Unlock()
+
+	used, waited := mongo.budget.usage()
+	fields["queue_depth"] = queued
+	fields["written"] = written
+	fields["records_per_sec"] = int64(float64(written) / elapsed)
+	fields["memory_used_mb"] = used / (1024 * 1024)
+	fields["memory_limit_mb"] = mongo.budget.limit / (1024 * 1024)
+	fields["backpressure_wait"] = waited.String()
+	mongo.logger.WithFields(fields).Info(message)
+}
+
 //bulkInsert is a goroutine which reads a channel and inserts the data in bulk
-//into MongoDB
+//into MongoDB. The buffered data is written early if a call to Store is
+//waiting for memory to be released.
 func (writer *collectionWriter) bulkInsert() {
-	writer.writerWG.Add(1)
 	defer writer.writerWG.Done()
-	defer writer.session.Close()
-
-	buffer := make([]interface{}, 0, writer.bufferSize)
-	collection := writer.session.DB(writer.targetDatabase).C(writer.targetCollection)
-
-	for data := range writer.writeChannel {
-		if len(buffer) == writer.bufferSize {
-			bulk := collection.Bulk()
-			bulk.Unordered()
-			bulk.Insert(buffer...)
-			_, err := bulk.Run()
-			if err != nil {
-				writer.logger.WithFields(log.Fields{
-					"target_database":   writer.targetDatabase,
-					"target_collection": writer.targetCollection,
-					"error":             err.Error(),
-				}).Error("Unable to insert bulk data in MongoDB")
-			}
-			buffer = buffer[:0]
-		}
-		buffer = append(buffer, data.BroData)
+
+	var buffered int
+	var bufferBytes int64
+	//written releases the memory held by the records which have left
+	//the bulk writer's buffer
+	written := func() {
+		atomic.AddInt64(&writer.queued, -int64(buffered))
+		writer.budget.release(bufferBytes)
+		buffered = 0
+		bufferBytes = 0
 	}
 
-	//guaranteed to be at least 1 line in the buffer
-	bulk := collection.Bulk()
-	bulk.Unordered()
-	bulk.Insert(buffer...)
-	_, err := bulk.Run()
-	if err != nil {
-		writer.logger.WithFields(log.Fields{
-			"target_database":   writer.targetDatabase,
-			"target_collection": writer.targetCollection,
-			"error":             err.Error(),
-		}).Error("Unable to insert bulk data in MongoDB")
+	for {
+		select {
+		case record, ok := <-writer.writeChannel:
+			if !ok {
+				writer.bulk.Close()
+				written()
+				return
+			}
+			writer.bulk.Insert(record.data)
+			buffered++
+			bufferBytes += record.size
+			if writer.bulk.Buffered() > 0 && writer.budget.pressured() {
+				writer.bulk.Flush()
+			}
+			if writer.bulk.Buffered() == 0 {
+				written()
+			}
+		case <-writer.flushChannel:
+			writer.bulk.Flush()
+			written()
+		}
 	}
 }
@@ -0,0 +1,494 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+type (
+	//Flow holds the fields RITA uses from a NetFlow or IPFIX flow record.
+	//Bytes and Packets describe the traffic sent from the source to the
+	//destination. The reverse counts are only set by exporters which report
+	//bidirectional flows.
+	Flow struct {
+		Start          time.Time
+		End            time.Time
+		SrcAddr        net.IP
+		DstAddr        net.IP
+		SrcPort        uint16
+		DstPort        uint16
+		Protocol       uint8
+		TCPFlags       uint8
+		Bytes          uint64
+		Packets        uint64
+		ReverseBytes   uint64
+		ReversePackets uint64
+	}
+
+	//Decoder decodes NetFlow v5, NetFlow v9, and IPFIX export messages.
+	//Templates announced by v9 and IPFIX exporters are cached per exporter
+	//and observation domain so later data records can be decoded.
+	//Decoder is safe to use from multiple goroutines.
+	Decoder struct {
+		lock      *sync.Mutex
+		templates map[templateKey]*template
+	}
+
+	//templateKey identifies a template announced by an exporter
+	templateKey struct {
+		exporter string
+		version  uint16
+		domain   uint32
+		id       uint16
+	}
+
+	//template describes the layout of the data records for a template id
+	template struct {
+		fields []templateField
+	}
+
+	//templateField describes one field of a data record. A length of
+	//variableLength marks an IPFIX variable length field.
+	templateField struct {
+		id         uint16
+		length     uint16
+		enterprise uint32
+	}
+)
+
+const (
+	versionV5    = 5
+	versionV9    = 9
+	versionIPFIX = 10
+
+	variableLength = 65535
+
+	//reverseEnterprise is the private enterprise number used by RFC 5103
+	//to mark the reverse direction of a bidirectional flow
+	reverseEnterprise = 29305
+)
+
+//Information elements used by RITA. NetFlow v9 field types share their
+//numbering with the IPFIX information elements.
+const (
+	ieOctetDeltaCount          = 1
+	iePacketDeltaCount         = 2
+	ieProtocolIdentifier       = 4
+	ieTCPControlBits           = 6
+	ieSourceTransportPort      = 7
+	ieSourceIPv4Address        = 8
+	ieDestinationTransportPort = 11
+	ieDestinationIPv4Address   = 12
+	ieFlowEndSysUpTime         = 21
+	ieFlowStartSysUpTime       = 22
+	ieSourceIPv6Address        = 27
+	ieDestinationIPv6Address   = 28
+	ieOctetTotalCount          = 85
+	iePacketTotalCount         = 86
+	ieFlowStartSeconds         = 150
+	ieFlowEndSeconds           = 151
+	ieFlowStartMilliseconds    = 152
+	ieFlowEndMilliseconds      = 153
+	ieSystemInitTimeMillis     = 160
+	ieInitiatorOctets          = 231
+	ieResponderOctets          = 232
+	ieInitiatorPackets         = 298
+	ieResponderPackets         = 299
+)
+
+//ErrTruncated is returned when a message ends before its declared length
+var ErrTruncated = errors.New("truncated flow export message")
+
+//NewDecoder creates a Decoder with an empty template cache
+func NewDecoder() *Decoder {
+	return &Decoder{
+		lock:      new(sync.Mutex),
+		templates: make(map[templateKey]*template),
+	}
+}
+
+//Decode decodes a single export message sent by the exporter. The flows
+//decoded before any error are returned along with the error. Data records
+//which reference a template that has not been received yet are skipped.
+func (d *Decoder) Decode(exporter string, data []byte) ([]Flow, error) {
+	if len(data) < 2 {
+		return nil, ErrTruncated
+	}
+	version := binary.BigEndian.Uint16(data)
+	switch version {
+	case versionV5:
+		return decodeV5(data)
+	case versionV9:
+		return d.decodeV9(exporter, data)
+	case versionIPFIX:
+		return d.decodeIPFIX(exporter, data)
+	}
+	return nil, fmt.Errorf("unsupported flow export version %d", version)
+}
+
+//MessageLength returns the length of the IPFIX message at the start of
+//data. IPFIX files (RFC 5655) are a series of IPFIX messages.
+func MessageLength(data []byte) (int, error) {
+	if len(data) < 16 {
+		return 0, ErrTruncated
+	}
+	if binary.BigEndian.Uint16(data) != versionIPFIX {
+		return 0, errors.New("not an IPFIX message")
+	}
+	return int(binary.BigEndian.Uint16(data[2:])), nil
+}
+
+//decodeV5 decodes a NetFlow v5 message which has a fixed record layout
+func decodeV5(data []byte) ([]Flow, error) {
+	const headerLen = 24
+	const recordLen = 48
+	if len(data) < headerLen {
+		return nil, ErrTruncated
+	}
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	sysUptime := binary.BigEndian.Uint32(data[4:])
+	exportTime := time.Unix(
+		int64(binary.BigEndian.Uint32(data[8:])),
+		int64(binary.BigEndian.Uint32(data[12:])),
+	)
+
+	flows := make([]Flow, 0, count)
+	for i := 0; i < count; i++ {
+		offset := headerLen + i*recordLen
+		if offset+recordLen > len(data) {
+			return flows, ErrTruncated
+		}
+		record := data[offset : offset+recordLen]
+		flows = append(flows, Flow{
+			SrcAddr:  net.IP(copyBytes(record[0:4])),
+			DstAddr:  net.IP(copyBytes(record[4:8])),
+			Packets:  uint64(binary.BigEndian.Uint32(record[16:])),
+			Bytes:    uint64(binary.BigEndian.Uint32(record[20:])),
+			Start:    uptimeToTime(exportTime, sysUptime, binary.BigEndian.Uint32(record[24:])),
+			End:      uptimeToTime(exportTime, sysUptime, binary.BigEndian.Uint32(record[28:])),
+			SrcPort:  binary.BigEndian.Uint16(record[32:]),
+			DstPort:  binary.BigEndian.Uint16(record[34:]),
+			TCPFlags: record[37],
+			Protocol: record[38],
+		})
+	}
+	return flows, nil
+}
+
+//decodeV9 decodes a NetFlow v9 message
+func (d *Decoder) decodeV9(exporter string, data []byte) ([]Flow, error) {
+	const headerLen = 20
+	if len(data) < headerLen {
+		return nil, ErrTruncated
+	}
+	sysUptime := binary.BigEndian.Uint32(data[4:])
+	exportTime := time.Unix(int64(binary.BigEndian.Uint32(data[8:])), 0)
+	sourceID := binary.BigEndian.Uint32(data[16:])
+
+	var flows []Flow
+	offset := headerLen
+	for offset+4 <= len(data) {
+		setID := binary.BigEndian.Uint16(data[offset:])
+		setLen := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if setLen < 4 || offset+setLen > len(data) {
+			return flows, ErrTruncated
+		}
+		body := data[offset+4 : offset+setLen]
+		offset += setLen
+
+		switch {
+		case setID == 0:
+			if err := d.readTemplates(exporter, versionV9, sourceID, body, false); err != nil {
+				return flows, err
+			}
+		case setID == 1:
+			//options templates describe exporter metadata, not flows
+		case setID >= 256:
+			tmpl := d.getTemplate(exporter, versionV9, sourceID, setID)
+			if tmpl == nil {
+				continue
+			}
+			flows = append(flows, tmpl.decodeRecords(body, exportTime, sysUptime, true)...)
+		}
+	}
+	return flows, nil
+}
+
+//decodeIPFIX decodes an IPFIX message
+func (d *Decoder) decodeIPFIX(exporter string, data []byte) ([]Flow, error) {
+	const headerLen = 16
+	if len(data) < headerLen {
+		return nil, ErrTruncated
+	}
+	msgLen := int(binary.BigEndian.Uint16(data[2:]))
+	if msgLen < headerLen || msgLen > len(data) {
+		return nil, ErrTruncated
+	}
+	data = data[:msgLen]
+	exportTime := time.Unix(int64(binary.BigEndian.Uint32(data[4:])), 0)
+	domain := binary.BigEndian.Uint32(data[12:])
+
+	var flows []Flow
+	offset := headerLen
+	for offset+4 <= len(data) {
+		setID := binary.BigEndian.Uint16(data[offset:])
+		setLen := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if setLen < 4 || offset+setLen > len(data) {
+			return flows, ErrTruncated
+		}
+		body := data[offset+4 : offset+setLen]
+		offset += setLen
+
+		switch {
+		case setID == 2:
+			if err := d.readTemplates(exporter, versionIPFIX, domain, body, true); err != nil {
+				return flows, err
+			}
+		case setID == 3:
+			//options templates describe exporter metadata, not flows
+		case setID >= 256:
+			tmpl := d.getTemplate(exporter, versionIPFIX, domain, setID)
+			if tmpl == nil {
+				continue
+			}
+			flows = append(flows, tmpl.decodeRecords(body, exportTime, 0, false)...)
+		}
+	}
+	return flows, nil
+}
+
+//readTemplates caches the templates in a template set. IPFIX templates
+//may include enterprise specific fields.
+func (d *Decoder) readTemplates(exporter string, version uint16, domain uint32,
+	body []byte, ipfix bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	offset := 0
+	for offset+4 <= len(body) {
+		id := binary.BigEndian.Uint16(body[offset:])
+		fieldCount := int(binary.BigEndian.Uint16(body[offset+2:]))
+		offset += 4
+
+		key := templateKey{exporter, version, domain, id}
+		if id < 256 {
+			//the remainder of the set is padding
+			return nil
+		}
+		if fieldCount == 0 {
+			//template withdrawal
+			delete(d.templates, key)
+			continue
+		}
+
+		tmpl := &template{fields: make([]templateField, 0, fieldCount)}
+		for i := 0; i < fieldCount; i++ {
+			if offset+4 > len(body) {
+				return ErrTruncated
+			}
+			field := templateField{
+				id:     binary.BigEndian.Uint16(body[offset:]),
+				length: binary.BigEndian.Uint16(body[offset+2:]),
+			}
+			offset += 4
+			if ipfix && field.id&0x8000 != 0 {
+				if offset+4 > len(body) {
+					return ErrTruncated
+				}
+				field.id &= 0x7fff
+				field.enterprise = binary.BigEndian.Uint32(body[offset:])
+				offset += 4
+			}
+			tmpl.fields = append(tmpl.fields, field)
+		}
+		d.templates[key] = tmpl
+	}
+	return nil
+}
+
+//getTemplate returns a cached template or nil if it has not been received
+func (d *Decoder) getTemplate(exporter string, version uint16, domain uint32, id uint16) *template {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.templates[templateKey{exporter, version, domain, id}]
+}
+
+//decodeRecords decodes the data records in a data set. NetFlow v9 times
+//are relative to the exporter's uptime given in the message header.
+func (t *template) decodeRecords(body []byte, exportTime time.Time,
+	sysUptime uint32, v9 bool) []Flow {
+	var flows []Flow
+	offset := 0
+	for offset < len(body) {
+		var flow Flow
+		var start, end timeFields
+		complete := true
+		recordStart := offset
+
+		for _, field := range t.fields {
+			length := int(field.length)
+			if field.length == variableLength {
+				if offset >= len(body) {
+					complete = false
+					break
+				}
+				length = int(body[offset])
+				offset++
+				if length == 255 {
+					if offset+2 > len(body) {
+						complete = false
+						break
+					}
+					length = int(binary.BigEndian.Uint16(body[offset:]))
+					offset += 2
+				}
+			}
+			if offset+length > len(body) {
+				complete = false
+				break
+			}
+			value := body[offset : offset+length]
+			offset += length
+			applyField(&flow, &start, &end, field, value)
+		}
+
+		//any trailing bytes too short for a record are padding
+		if !complete || offset == recordStart {
+			break
+		}
+
+		flow.Start = start.resolve(exportTime, sysUptime, v9)
+		flow.End = end.resolve(exportTime, sysUptime, v9)
+		if flow.Start.IsZero() {
+			flow.Start = exportTime
+		}
+		if flow.End.IsZero() || flow.End.Before(flow.Start) {
+			flow.End = flow.Start
+		}
+		flows = append(flows, flow)
+	}
+	return flows
+}
+
+//timeFields collects the different ways an exporter may report a time
+type timeFields struct {
+	uptime       uint32
+	hasUptime    bool
+	seconds      uint64
+	milliseconds uint64
+	initTime     uint64
+}
+
+//resolve converts the reported time into a wall clock time. The zero time
+//is returned if the time was not reported.
+func (t timeFields) resolve(exportTime time.Time, sysUptime uint32, v9 bool) time.Time {
+	switch {
+	case t.milliseconds != 0:
+		return time.Unix(0, int64(t.milliseconds)*int64(time.Millisecond))
+	case t.seconds != 0:
+		return time.Unix(int64(t.seconds), 0)
+	case t.hasUptime && v9:
+		return uptimeToTime(exportTime, sysUptime, t.uptime)
+	case t.hasUptime && t.initTime != 0:
+		return time.Unix(0, int64(t.initTime+uint64(t.uptime))*int64(time.Millisecond))
+	}
+	return time.Time{}
+}
+
+//applyField stores the value of a data record field in the flow
+func applyField(flow *Flow, start *timeFields, end *timeFields,
+	field templateField, value []byte) {
+	if field.enterprise == reverseEnterprise {
+		switch field.id {
+		case ieOctetDeltaCount, ieOctetTotalCount:
+			flow.ReverseBytes = readUint(value)
+		case iePacketDeltaCount, iePacketTotalCount:
+			flow.ReversePackets = readUint(value)
+		}
+		return
+	}
+	if field.enterprise != 0 {
+		return
+	}
+
+	switch field.id {
+	case ieOctetDeltaCount, ieInitiatorOctets:
+		flow.Bytes = readUint(value)
+	case iePacketDeltaCount, ieInitiatorPackets:
+		flow.Packets = readUint(value)
+	case ieOctetTotalCount:
+		if flow.Bytes == 0 {
+			flow.Bytes = readUint(value)
+		}
+	case iePacketTotalCount:
+		if flow.Packets == 0 {
+			flow.Packets = readUint(value)
+		}
+	case ieResponderOctets:
+		flow.ReverseBytes = readUint(value)
+	case ieResponderPackets:
+		flow.ReversePackets = readUint(value)
+	case ieProtocolIdentifier:
+		flow.Protocol = uint8(readUint(value))
+	case ieTCPControlBits:
+		flow.TCPFlags = uint8(readUint(value))
+	case ieSourceTransportPort:
+		flow.SrcPort = uint16(readUint(value))
+	case ieDestinationTransportPort:
+		flow.DstPort = uint16(readUint(value))
+	case ieSourceIPv4Address, ieSourceIPv6Address:
+		if len(value) == net.IPv4len || len(value) == net.IPv6len {
+			flow.SrcAddr = net.IP(copyBytes(value))
+		}
+	case ieDestinationIPv4Address, ieDestinationIPv6Address:
+		if len(value) == net.IPv4len || len(value) == net.IPv6len {
+			flow.DstAddr = net.IP(copyBytes(value))
+		}
+	case ieFlowStartSysUpTime:
+		start.uptime = uint32(readUint(value))
+		start.hasUptime = true
+	case ieFlowEndSysUpTime:
+		end.uptime = uint32(readUint(value))
+		end.hasUptime = true
+	case ieFlowStartSeconds:
+		start.seconds = readUint(value)
+	case ieFlowEndSeconds:
+		end.seconds = readUint(value)
+	case ieFlowStartMilliseconds:
+		start.milliseconds = readUint(value)
+	case ieFlowEndMilliseconds:
+		end.milliseconds = readUint(value)
+	case ieSystemInitTimeMillis:
+		start.initTime = readUint(value)
+		end.initTime = start.initTime
+	}
+}
+
+//readUint reads a big endian unsigned integer of up to 8 bytes. Exporters
+//may use reduced size encoding for integer fields.
+func readUint(value []byte) uint64 {
+	var result uint64
+	for _, b := range value {
+		result = result<<8 | uint64(b)
+	}
+	return result
+}
+
+//uptimeToTime converts a time given in milliseconds of exporter uptime to
+//a wall clock time
+func uptimeToTime(exportTime time.Time, sysUptime uint32, uptime uint32) time.Time {
+	//the subtraction wraps correctly if the uptime counter rolled over
+	ago := time.Duration(sysUptime-uptime) * time.Millisecond
+	return exportTime.Add(-ago)
+}
+
+//copyBytes copies a slice so decoded flows don't reference packet buffers
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
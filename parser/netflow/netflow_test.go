@@ -0,0 +1,170 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//packetBuilder appends big endian values to a byte slice
+type packetBuilder []byte
+
+func (p *packetBuilder) u8(v uint8) *packetBuilder {
+	*p = append(*p, v)
+	return p
+}
+
+func (p *packetBuilder) u16(v uint16) *packetBuilder {
+	*p = append(*p, 0, 0)
+	binary.BigEndian.PutUint16((*p)[len(*p)-2:], v)
+	return p
+}
+
+func (p *packetBuilder) u32(v uint32) *packetBuilder {
+	*p = append(*p, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32((*p)[len(*p)-4:], v)
+	return p
+}
+
+func (p *packetBuilder) ip(addr string) *packetBuilder {
+	ip := net.ParseIP(addr)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	*p = append(*p, ip...)
+	return p
+}
+
+func (p *packetBuilder) setLength(offset int) {
+	binary.BigEndian.PutUint16((*p)[offset:], uint16(len(*p)))
+}
+
+func TestDecodeV5(t *testing.T) {
+	var packet packetBuilder
+	// header: 1 record, uptime of 100s, exported at 1000s
+	packet.u16(5).u16(1).u32(100000).u32(1000).u32(0).u32(0).u8(0).u8(0).u16(0)
+	packet.ip("10.0.0.1").ip("192.0.2.1").ip("0.0.0.0")
+	packet.u16(0).u16(0)         // input and output interfaces
+	packet.u32(10).u32(1500)     // packets and bytes
+	packet.u32(40000).u32(90000) // first and last in uptime ms
+	packet.u16(50000).u16(443)   // ports
+	packet.u8(0).u8(0x1b).u8(6)  // pad, tcp flags, protocol
+	packet.u8(0).u16(0).u16(0)   // tos and AS numbers
+	packet.u8(0).u8(0).u16(0)    // masks and pad
+
+	flows, err := NewDecoder().Decode("exporter", packet)
+	require.Nil(t, err)
+	require.Len(t, flows, 1)
+	flow := flows[0]
+	assert.Equal(t, "10.0.0.1", flow.SrcAddr.String())
+	assert.Equal(t, "192.0.2.1", flow.DstAddr.String())
+	assert.Equal(t, uint16(50000), flow.SrcPort)
+	assert.Equal(t, uint16(443), flow.DstPort)
+	assert.Equal(t, uint8(6), flow.Protocol)
+	assert.Equal(t, uint8(0x1b), flow.TCPFlags)
+	assert.Equal(t, uint64(10), flow.Packets)
+	assert.Equal(t, uint64(1500), flow.Bytes)
+	assert.Equal(t, time.Unix(940, 0), flow.Start)
+	assert.Equal(t, time.Unix(990, 0), flow.End)
+
+	_, err = NewDecoder().Decode("exporter", packet[:50])
+	assert.Equal(t, ErrTruncated, err)
+}
+
+func TestDecodeV9(t *testing.T) {
+	decoder := NewDecoder()
+
+	var templates packetBuilder
+	templates.u16(9).u16(1).u32(100000).u32(1000).u32(1).u32(7)
+	templates.u16(0).u16(0) // template flowset, length set below
+	templates.u16(256).u16(8)
+	templates.u16(ieSourceIPv4Address).u16(4)
+	templates.u16(ieDestinationIPv4Address).u16(4)
+	templates.u16(ieSourceTransportPort).u16(2)
+	templates.u16(ieDestinationTransportPort).u16(2)
+	templates.u16(ieProtocolIdentifier).u16(1)
+	templates.u16(ieOctetDeltaCount).u16(4)
+	templates.u16(ieFlowStartSysUpTime).u16(4)
+	templates.u16(ieFlowEndSysUpTime).u16(4)
+	binary.BigEndian.PutUint16(templates[22:], uint16(len(templates)-20))
+
+	var data packetBuilder
+	data.u16(9).u16(2).u32(100000).u32(1000).u32(2).u32(7)
+	data.u16(256).u16(4 + 2*25 + 2)
+	for _, port := range []uint16{53, 123} {
+		data.ip("10.0.0.1").ip("192.0.2.1").u16(40000).u16(port).u8(17)
+		data.u32(300).u32(99000).u32(99500)
+	}
+	data.u16(0) // padding
+
+	// data which arrives before its template is skipped
+	flows, err := decoder.Decode("exporter", data)
+	require.Nil(t, err)
+	assert.Empty(t, flows)
+
+	flows, err = decoder.Decode("exporter", templates)
+	require.Nil(t, err)
+	assert.Empty(t, flows)
+
+	flows, err = decoder.Decode("exporter", data)
+	require.Nil(t, err)
+	require.Len(t, flows, 2)
+	assert.Equal(t, uint16(53), flows[0].DstPort)
+	assert.Equal(t, uint16(123), flows[1].DstPort)
+	assert.Equal(t, uint8(17), flows[1].Protocol)
+	assert.Equal(t, uint64(300), flows[1].Bytes)
+	assert.Equal(t, time.Unix(999, 0), flows[1].Start)
+	assert.Equal(t, time.Unix(999, 500*int64(time.Millisecond)), flows[1].End)
+
+	// templates are not shared between exporters
+	flows, err = decoder.Decode("other exporter", data)
+	require.Nil(t, err)
+	assert.Empty(t, flows)
+}
+
+func TestDecodeIPFIX(t *testing.T) {
+	decoder := NewDecoder()
+
+	var packet packetBuilder
+	packet.u16(10).u16(0).u32(2000).u32(1).u32(3)
+	setStart := len(packet)
+	packet.u16(2).u16(0)
+	packet.u16(300).u16(8)
+	packet.u16(ieSourceIPv6Address).u16(16)
+	packet.u16(ieDestinationIPv6Address).u16(16)
+	packet.u16(ieProtocolIdentifier).u16(1)
+	packet.u16(ieFlowStartMilliseconds).u16(8)
+	packet.u16(ieFlowEndSeconds).u16(4)
+	packet.u16(ieOctetDeltaCount).u16(2) // reduced size encoding
+	packet.u16(ieOctetDeltaCount | 0x8000).u16(4).u32(reverseEnterprise)
+	packet.u16(9999 | 0x8000).u16(variableLength).u32(12345)
+	binary.BigEndian.PutUint16(packet[setStart+2:], uint16(len(packet)-setStart))
+
+	setStart = len(packet)
+	packet.u16(300).u16(0)
+	packet.ip("2001:db8::1").ip("2001:db8::2").u8(6)
+	packet.u32(0).u32(1500000) // start of 1500s in milliseconds
+	packet.u32(1600).u16(2000).u32(4000)
+	packet.u8(3).u8('a').u8('b').u8('c') // enterprise specific string
+	binary.BigEndian.PutUint16(packet[setStart+2:], uint16(len(packet)-setStart))
+	packet.setLength(2)
+
+	length, err := MessageLength(packet)
+	require.Nil(t, err)
+	assert.Equal(t, len(packet), length)
+
+	flows, err := decoder.Decode("exporter", packet)
+	require.Nil(t, err)
+	require.Len(t, flows, 1)
+	flow := flows[0]
+	assert.Equal(t, "2001:db8::1", flow.SrcAddr.String())
+	assert.Equal(t, "2001:db8::2", flow.DstAddr.String())
+	assert.Equal(t, time.Unix(1500, 0), flow.Start)
+	assert.Equal(t, time.Unix(1600, 0), flow.End)
+	assert.Equal(t, uint64(2000), flow.Bytes)
+	assert.Equal(t, uint64(4000), flow.ReverseBytes)
+}
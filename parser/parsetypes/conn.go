@@ -52,6 +52,8 @@ type (
 		RespIPBytes int64 `bson:"resp_ip_bytes" bro:"resp_ip_bytes" brotype:"count"`
 		// TunnelParents lists tunnel parents
 		TunnelParents []string `bson:"tunnel_parents" bro:"tunnel_parents" brotype:"set[string]"`
+		// Sensor names the sensor which recorded this entry
+		Sensor string `bson:"sensor,omitempty"`
 	}
 )
 
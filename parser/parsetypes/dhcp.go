@@ -0,0 +1,56 @@
+package parsetypes
+
+import (
+	"github.com/activecm/rita/config"
+	"github.com/globalsign/mgo/bson"
+)
+
+// DHCP provides a data structure for entries in the bro DHCP log. Bro 2.6
+// changed the fields of the log, so the fields of both versions are held.
+type DHCP struct {
+	// ID contains the id set by mongodb
+	ID bson.ObjectId `bson:"_id,omitempty"`
+	// TimeStamp of this exchange
+	TimeStamp int64 `bson:"ts" bro:"ts" brotype:"time"`
+	// UIDs links this exchange to the connections which carried it
+	UIDs []string `bson:"uids" bro:"uids" brotype:"set[string]"`
+	// UID links this exchange to its connection in bro versions before 2.6
+	UID string `bson:"uid" bro:"uid" brotype:"string"`
+	// ClientAddr is the address of the client
+	ClientAddr string `bson:"client_addr" bro:"client_addr" brotype:"addr"`
+	// ServerAddr is the address of the DHCP server
+	ServerAddr string `bson:"server_addr" bro:"server_addr" brotype:"addr"`
+	// MAC is the hardware address of the client
+	MAC string `bson:"mac" bro:"mac" brotype:"string"`
+	// HostName is the name the client sent
+	HostName string `bson:"host_name" bro:"host_name" brotype:"string"`
+	// ClientFQDN is the fully qualified name the client sent
+	ClientFQDN string `bson:"client_fqdn" bro:"client_fqdn" brotype:"string"`
+	// Domain is the domain given to the client
+	Domain string `bson:"domain" bro:"domain" brotype:"string"`
+	// RequestedAddr is the address the client asked for
+	RequestedAddr string `bson:"requested_addr" bro:"requested_addr" brotype:"addr"`
+	// AssignedAddr is the address given to the client
+	AssignedAddr string `bson:"assigned_addr" bro:"assigned_addr" brotype:"addr"`
+	// AssignedIP is the address given to the client in bro versions before 2.6
+	AssignedIP string `bson:"assigned_ip" bro:"assigned_ip" brotype:"addr"`
+	// LeaseTime is how long the address was given to the client for
+	LeaseTime float64 `bson:"lease_time" bro:"lease_time" brotype:"interval"`
+	// MsgTypes lists the DHCP messages seen in the exchange
+	MsgTypes []string `bson:"msg_types" bro:"msg_types" brotype:"vector[string]"`
+	// Duration is the length of the exchange
+	Duration float64 `bson:"duration" bro:"duration" brotype:"interval"`
+	// Sensor names the sensor which recorded this entry
+	Sensor string `bson:"sensor,omitempty"`
+}
+
+//TargetCollection returns the mongo collection this entry should be inserted
+//into
+func (in *DHCP) TargetCollection(config *config.StructureTableCfg) string {
+	return config.DHCPTable
+}
+
+//Indices gives MongoDB indices that should be used with the collection
+func (in *DHCP) Indices() []string {
+	return []string{"ts", "$hashed:mac"}
+}
@@ -58,6 +58,8 @@ type DNS struct {
 	TTLs []float64 `bson:"TTLs" bro:"TTLs" brotype:"vector[interval]"`
 	// Rejected indicates if this query was rejected or not
 	Rejected bool `bson:"rejected" bro:"rejected" brotype:"bool"`
+	// Sensor names the sensor which recorded this entry
+	Sensor string `bson:"sensor,omitempty"`
 }
 
 //TargetCollection returns the mongo collection this entry should be inserted
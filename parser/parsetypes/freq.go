@@ -12,6 +12,8 @@ type (
 		Source          string `bson:"src" bro:"id.orig_h" brotype:"addr"`
 		Destination     string `bson:"dst" bro:"id.resp_h" brotype:"addr"`
 		ConnectionCount int    `bson:"connection_count" bro:"connection_count" brotype:"connection_count"`
+		// Sensors lists the sensors which recorded the connections
+		Sensors []string `bson:"sensors,omitempty"`
 	}
 )
 
@@ -23,5 +25,5 @@ func (in *Freq) TargetCollection(config *config.StructureTableCfg) string {
 
 //Indices gives MongoDB indices that should be used with the collection
 func (in *Freq) Indices() []string {
-	return []string{"$hashed:src", "$hashed:dst", "-connection_count"}
+	return []string{"$hashed:src", "$hashed:dst", "-connection_count", "sensors"}
 }
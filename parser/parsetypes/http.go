@@ -68,6 +68,8 @@ type HTTP struct {
 	RespFilenames []string `bson:"resp_filenames" bro:"resp_filenames" brotype:"vector[string]"`
 	// RespMimeTypes contains an ordered vector of unique MIME entities in the HTTP response body
 	RespMimeTypes []string `bson:"resp_mime_types" bro:"resp_mime_types" brotype:"vector[string]"`
+	// Sensor names the sensor which recorded this entry
+	Sensor string `bson:"sensor,omitempty"`
 }
 
 //TargetCollection returns the mongo collection this entry should be inserted
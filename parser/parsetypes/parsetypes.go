@@ -26,6 +26,14 @@ func NewBroDataFactory(fileType string) func() BroData {
 		return func() BroData {
 			return &HTTP{}
 		}
+	case "ssl":
+		return func() BroData {
+			return &SSL{}
+		}
+	case "dhcp":
+		return func() BroData {
+			return &DHCP{}
+		}
 	case "freq":
 		return func() BroData {
 			return &Freq{}
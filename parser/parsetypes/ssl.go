@@ -0,0 +1,59 @@
+package parsetypes
+
+import (
+	"github.com/activecm/rita/config"
+	"github.com/globalsign/mgo/bson"
+)
+
+// SSL provides a data structure for entries in bro's SSL log file
+type SSL struct {
+	// ID is the object id as set by mongodb
+	ID bson.ObjectId `bson:"_id,omitempty"`
+	// TimeStamp of this connection
+	TimeStamp int64 `bson:"ts" bro:"ts" brotype:"time"`
+	// UID is the Unique Id for this connection (generated by Bro)
+	UID string `bson:"uid" bro:"uid" brotype:"string"`
+	// Source is the source address for this connection
+	Source string `bson:"id_orig_h" bro:"id.orig_h" brotype:"addr"`
+	// SourcePort is the source port of this connection
+	SourcePort int `bson:"id_orig_p" bro:"id.orig_p" brotype:"port"`
+	// Destination is the destination of the connection
+	Destination string `bson:"id_resp_h" bro:"id.resp_h" brotype:"addr"`
+	// DestinationPort is the port at the destination host
+	DestinationPort int `bson:"id_resp_p" bro:"id.resp_p" brotype:"port"`
+	// Version is the SSL or TLS version the server chose
+	Version string `bson:"version" bro:"version" brotype:"string"`
+	// Cipher is the cipher suite the server chose
+	Cipher string `bson:"cipher" bro:"cipher" brotype:"string"`
+	// Curve is the elliptic curve the server chose
+	Curve string `bson:"curve" bro:"curve" brotype:"string"`
+	// ServerName is the hostname the client sent in the SNI extension
+	ServerName string `bson:"server_name" bro:"server_name" brotype:"string"`
+	// Resumed is true if the session was resumed
+	Resumed bool `bson:"resumed" bro:"resumed" brotype:"bool"`
+	// LastAlert is the last alert seen during the connection
+	LastAlert string `bson:"last_alert" bro:"last_alert" brotype:"string"`
+	// NextProtocol is the protocol the server chose using ALPN
+	NextProtocol string `bson:"next_protocol" bro:"next_protocol" brotype:"string"`
+	// Established is true if the handshake completed
+	Established bool `bson:"established" bro:"established" brotype:"bool"`
+	// Subject is the subject of the server's certificate
+	Subject string `bson:"subject" bro:"subject" brotype:"string"`
+	// Issuer is the issuer of the server's certificate
+	Issuer string `bson:"issuer" bro:"issuer" brotype:"string"`
+	// ValidationStatus is the result of validating the certificate chain
+	ValidationStatus string `bson:"validation_status" bro:"validation_status" brotype:"string"`
+	// Sensor names the sensor which recorded this entry
+	Sensor string `bson:"sensor,omitempty"`
+}
+
+//TargetCollection returns the mongo collection this entry should be inserted
+//into
+func (line *SSL) TargetCollection(config *config.StructureTableCfg) string {
+	return config.SSLTable
+}
+
+//Indices gives MongoDB indices that should be used with the collection
+func (line *SSL) Indices() []string {
+	return []string{"$hashed:id_orig_h", "$hashed:id_resp_h", "$hashed:server_name", "uid"}
+}
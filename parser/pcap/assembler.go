@@ -0,0 +1,453 @@
+package pcap
+
+import (
+	"net"
+	"time"
+)
+
+//Inactivity timeouts after which sessions are considered finished. These
+//match bro's defaults.
+const (
+	tcpTimeout    = 5 * time.Minute
+	udpTimeout    = time.Minute
+	icmpTimeout   = time.Minute
+	tcpCloseDelay = 5 * time.Second
+)
+
+//icmpCounterparts maps ICMP request types to the types sent in reply
+var icmpCounterparts = map[uint8]uint8{
+	8: 0, 13: 14, 15: 16, 17: 18, //ICMP
+	128: 129, 130: 131, 133: 134, 135: 136, //ICMPv6
+}
+
+type (
+	//Session holds the details of a TCP, UDP, or ICMP session. The
+	//originator is the host which sent the first packet, unless the first
+	//packet seen was a TCP SYN-ACK or an ICMP reply.
+	Session struct {
+		Start    time.Time
+		End      time.Time
+		OrigAddr net.IP
+		RespAddr net.IP
+		//ICMP sessions record the ICMP type as the originator port and the
+		//code, or the type of the reply, as the responder port
+		OrigPort    uint16
+		RespPort    uint16
+		Protocol    uint8
+		OrigPackets int64
+		RespPackets int64
+		OrigIPBytes int64
+		RespIPBytes int64
+
+		orig    sessionDirection
+		resp    sessionDirection
+		history []byte
+	}
+
+	//sessionDirection tracks the traffic sent by one side of a session
+	sessionDirection struct {
+		payloadBytes int64
+		syn          bool
+		fin          bool
+		rst          bool
+		seqSet       bool
+		seqBase      uint32
+		seqMax       uint32
+	}
+
+	//sessionKey identifies a session from the point of view of one side
+	sessionKey struct {
+		srcAddr  string
+		dstAddr  string
+		srcPort  uint16
+		dstPort  uint16
+		protocol uint8
+	}
+
+	//DNSTransaction pairs a DNS query with its response. Responses which
+	//were not preceded by a query are recorded on their own.
+	DNSTransaction struct {
+		Session   *Session
+		Timestamp time.Time
+		//RTT is zero unless both the query and response were seen
+		RTT       time.Duration
+		Query     DNSMessage
+		Responded bool
+		Response  DNSMessage
+	}
+
+	//dnsKey identifies a DNS transaction within a session
+	dnsKey struct {
+		session *Session
+		id      uint16
+	}
+
+	//Assembler groups packets into sessions and extracts DNS transactions.
+	//Sessions are reported once they time out or are closed, and when the
+	//assembler is flushed.
+	Assembler struct {
+		sessions   map[sessionKey]*Session
+		pendingDNS map[dnsKey]*DNSTransaction
+		onSession  func(*Session)
+		onDNS      func(*DNSTransaction)
+		now        time.Time
+		lastSweep  time.Time
+	}
+)
+
+//NewAssembler creates an Assembler which reports finished sessions and
+//DNS transactions using the given functions
+func NewAssembler(onSession func(*Session), onDNS func(*DNSTransaction)) *Assembler {
+	return &Assembler{
+		sessions:   make(map[sessionKey]*Session),
+		pendingDNS: make(map[dnsKey]*DNSTransaction),
+		onSession:  onSession,
+		onDNS:      onDNS,
+	}
+}
+
+//AddPacket adds a decoded packet captured at the given time to its session
+func (a *Assembler) AddPacket(timestamp time.Time, packet IPPacket) {
+	if packet.Fragment {
+		return
+	}
+	switch packet.Protocol {
+	case ProtocolTCP, ProtocolUDP, ProtocolICMP, ProtocolICMPv6:
+	default:
+		return
+	}
+
+	if timestamp.After(a.now) {
+		a.now = timestamp
+	}
+	if a.now.Sub(a.lastSweep) >= time.Second {
+		a.expire(false)
+		a.lastSweep = a.now
+	}
+
+	key := packetKey(packet)
+	session, fromOrig := a.sessions[key], true
+	if session == nil {
+		session, fromOrig = a.sessions[key.reverse()], false
+	}
+	if session == nil {
+		session = newSession(timestamp, packet, key)
+		fromOrig = session.OrigPort == key.srcPort && session.OrigAddr.Equal(packet.SrcIP)
+		origKey := key
+		if !fromOrig {
+			origKey = key.reverse()
+		}
+		a.sessions[origKey] = session
+	}
+
+	session.addPacket(timestamp, packet, fromOrig)
+
+	if packet.SrcPort == 53 || packet.DstPort == 53 {
+		a.addDNS(timestamp, session, packet)
+	}
+}
+
+//Flush reports every session and DNS transaction which is still open
+func (a *Assembler) Flush() {
+	a.expire(true)
+}
+
+//expire reports the sessions which have finished, or every session if all
+//is set
+func (a *Assembler) expire(all bool) {
+	for key, session := range a.sessions {
+		if all || session.finished(a.now) {
+			delete(a.sessions, key)
+			a.finishSession(session)
+		}
+	}
+}
+
+//finishSession reports a session along with its unanswered DNS queries
+func (a *Assembler) finishSession(session *Session) {
+	for key, transaction := range a.pendingDNS {
+		if key.session == session {
+			delete(a.pendingDNS, key)
+			a.onDNS(transaction)
+		}
+	}
+	a.onSession(session)
+}
+
+//addDNS pairs DNS queries with their responses
+func (a *Assembler) addDNS(timestamp time.Time, session *Session, packet IPPacket) {
+	var message DNSMessage
+	var err error
+	if packet.Protocol == ProtocolTCP {
+		message, err = ParseDNSOverTCP(packet.Payload)
+	} else if packet.Protocol == ProtocolUDP {
+		message, err = ParseDNS(packet.Payload)
+	} else {
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	key := dnsKey{session: session, id: message.ID}
+	if !message.Response {
+		if pending, ok := a.pendingDNS[key]; ok {
+			//a retransmitted query replaces an unanswered one
+			a.onDNS(pending)
+		}
+		a.pendingDNS[key] = &DNSTransaction{
+			Session:   session,
+			Timestamp: timestamp,
+			Query:     message,
+		}
+		return
+	}
+
+	transaction, ok := a.pendingDNS[key]
+	if ok {
+		delete(a.pendingDNS, key)
+		transaction.RTT = timestamp.Sub(transaction.Timestamp)
+	} else {
+		transaction = &DNSTransaction{
+			Session:   session,
+			Timestamp: timestamp,
+			Query:     message,
+		}
+	}
+	transaction.Responded = true
+	transaction.Response = message
+	a.onDNS(transaction)
+}
+
+//packetKey returns the key for the session a packet belongs to from the
+//sender's point of view
+func packetKey(packet IPPacket) sessionKey {
+	key := sessionKey{
+		srcAddr:  string(packet.SrcIP.To16()),
+		dstAddr:  string(packet.DstIP.To16()),
+		srcPort:  packet.SrcPort,
+		dstPort:  packet.DstPort,
+		protocol: packet.Protocol,
+	}
+	if packet.Protocol == ProtocolICMP || packet.Protocol == ProtocolICMPv6 {
+		//pair requests with their replies
+		key.srcPort = uint16(packet.ICMPType)
+		if reply, ok := icmpCounterparts[packet.ICMPType]; ok {
+			key.dstPort = uint16(reply)
+		} else if request, ok := icmpRequestFor(packet.ICMPType); ok {
+			key.dstPort = uint16(request)
+		} else {
+			key.dstPort = uint16(packet.ICMPCode)
+		}
+	}
+	return key
+}
+
+//icmpRequestFor returns the ICMP request type which the reply type answers
+func icmpRequestFor(replyType uint8) (uint8, bool) {
+	for request, reply := range icmpCounterparts {
+		if reply == replyType {
+			return request, true
+		}
+	}
+	return 0, false
+}
+
+//reverse returns the key from the point of view of the other side
+func (k sessionKey) reverse() sessionKey {
+	return sessionKey{
+		srcAddr:  k.dstAddr,
+		dstAddr:  k.srcAddr,
+		srcPort:  k.dstPort,
+		dstPort:  k.srcPort,
+		protocol: k.protocol,
+	}
+}
+
+//newSession creates a session for the first packet seen. A TCP SYN-ACK
+//or ICMP reply was sent by the responder, so the session is flipped.
+func newSession(timestamp time.Time, packet IPPacket, key sessionKey) *Session {
+	session := &Session{
+		Start:    timestamp,
+		End:      timestamp,
+		OrigAddr: packet.SrcIP,
+		RespAddr: packet.DstIP,
+		OrigPort: key.srcPort,
+		RespPort: key.dstPort,
+		Protocol: packet.Protocol,
+	}
+
+	flipped := false
+	switch packet.Protocol {
+	case ProtocolTCP:
+		flipped = packet.TCPFlags&TCPFlagSYN != 0 && packet.TCPFlags&TCPFlagACK != 0
+	case ProtocolICMP, ProtocolICMPv6:
+		_, flipped = icmpRequestFor(packet.ICMPType)
+	}
+	if flipped {
+		session.OrigAddr, session.RespAddr = packet.DstIP, packet.SrcIP
+		session.OrigPort, session.RespPort = key.dstPort, key.srcPort
+		session.history = append(session.history, '^')
+	}
+	return session
+}
+
+//addPacket updates the session with a packet sent by the originator if
+//fromOrig is set or by the responder otherwise
+func (s *Session) addPacket(timestamp time.Time, packet IPPacket, fromOrig bool) {
+	if timestamp.After(s.End) {
+		s.End = timestamp
+	}
+
+	direction := &s.resp
+	if fromOrig {
+		direction = &s.orig
+		s.OrigPackets++
+		s.OrigIPBytes += int64(packet.IPLength)
+	} else {
+		s.RespPackets++
+		s.RespIPBytes += int64(packet.IPLength)
+	}
+
+	if packet.Protocol != ProtocolTCP {
+		direction.payloadBytes += int64(len(packet.Payload))
+		if len(packet.Payload) > 0 {
+			s.addHistory('D', fromOrig)
+		}
+		return
+	}
+
+	flags := packet.TCPFlags
+	seq := packet.TCPSeq
+	if flags&TCPFlagSYN != 0 {
+		//the sequence number of a SYN does not count towards the payload
+		seq++
+		direction.syn = true
+		if flags&TCPFlagACK != 0 {
+			s.addHistory('H', fromOrig)
+		} else {
+			s.addHistory('S', fromOrig)
+		}
+	}
+	if flags&TCPFlagFIN != 0 {
+		direction.fin = true
+		s.addHistory('F', fromOrig)
+	}
+	if flags&TCPFlagRST != 0 {
+		direction.rst = true
+		s.addHistory('R', fromOrig)
+	}
+	if len(packet.Payload) > 0 {
+		s.addHistory('D', fromOrig)
+	} else if flags&^TCPFlagPSH == TCPFlagACK {
+		s.addHistory('A', fromOrig)
+	}
+
+	//count payload bytes using sequence numbers so retransmissions are
+	//not counted twice
+	if !direction.seqSet {
+		direction.seqSet = true
+		direction.seqBase = seq
+		direction.seqMax = seq
+	}
+	end := seq + uint32(len(packet.Payload))
+	if int32(end-direction.seqMax) > 0 {
+		direction.seqMax = end
+	}
+	direction.payloadBytes = int64(direction.seqMax - direction.seqBase)
+}
+
+//addHistory records a history letter for the side which sent the packet.
+//Letters from the originator are upper case. Each letter is only recorded
+//once.
+func (s *Session) addHistory(letter byte, fromOrig bool) {
+	if !fromOrig {
+		letter += 'a' - 'A'
+	}
+	for _, seen := range s.history {
+		if seen == letter {
+			return
+		}
+	}
+	s.history = append(s.history, letter)
+}
+
+//finished returns true if the session has timed out or been closed
+func (s *Session) finished(now time.Time) bool {
+	idle := now.Sub(s.End)
+	switch s.Protocol {
+	case ProtocolTCP:
+		if s.orig.rst || s.resp.rst || (s.orig.fin && s.resp.fin) {
+			return idle >= tcpCloseDelay
+		}
+		return idle >= tcpTimeout
+	case ProtocolUDP:
+		return idle >= udpTimeout
+	}
+	return idle >= icmpTimeout
+}
+
+//OrigBytes returns the number of payload bytes sent by the originator
+func (s *Session) OrigBytes() int64 {
+	return s.orig.payloadBytes
+}
+
+//RespBytes returns the number of payload bytes sent by the responder
+func (s *Session) RespBytes() int64 {
+	return s.resp.payloadBytes
+}
+
+//History returns the bro style history of the session
+func (s *Session) History() string {
+	return string(s.history)
+}
+
+//ConnState approximates bro's connection state for the session
+func (s *Session) ConnState() string {
+	if s.Protocol != ProtocolTCP {
+		switch {
+		case s.RespPackets > 0 && s.OrigPackets > 0:
+			return "SF"
+		case s.RespPackets > 0:
+			return "SHR"
+		}
+		return "S0"
+	}
+
+	orig, resp := s.orig, s.resp
+	switch {
+	case orig.syn && !resp.syn:
+		switch {
+		case orig.rst:
+			return "RSTOS0"
+		case resp.rst:
+			return "REJ"
+		case orig.fin:
+			return "SH"
+		}
+		return "S0"
+	case resp.syn && !orig.syn:
+		switch {
+		case resp.rst:
+			return "RSTRH"
+		case resp.fin:
+			return "SHR"
+		}
+		return "OTH"
+	case orig.syn && resp.syn:
+		switch {
+		case orig.rst:
+			return "RSTO"
+		case resp.rst:
+			return "RSTR"
+		case orig.fin && resp.fin:
+			return "SF"
+		case orig.fin:
+			return "S2"
+		case resp.fin:
+			return "S3"
+		}
+		return "S1"
+	}
+	return "OTH"
+}
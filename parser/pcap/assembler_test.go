@@ -0,0 +1,210 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//assemblerResults collects the output of an Assembler
+type assemblerResults struct {
+	sessions []*Session
+	dns      []*DNSTransaction
+}
+
+func newTestAssembler() (*Assembler, *assemblerResults) {
+	results := &assemblerResults{}
+	assembler := NewAssembler(
+		func(session *Session) { results.sessions = append(results.sessions, session) },
+		func(transaction *DNSTransaction) { results.dns = append(results.dns, transaction) },
+	)
+	return assembler, results
+}
+
+//tcpPacket creates a TCP packet with the given flags and payload
+func tcpPacket(src string, srcPort uint16, dst string, dstPort uint16,
+	flags uint8, seq uint32, payload string) IPPacket {
+	return IPPacket{
+		SrcIP:    net.ParseIP(src).To4(),
+		DstIP:    net.ParseIP(dst).To4(),
+		Protocol: ProtocolTCP,
+		IPLength: 40 + len(payload),
+		SrcPort:  srcPort,
+		DstPort:  dstPort,
+		TCPFlags: flags,
+		TCPSeq:   seq,
+		Payload:  []byte(payload),
+	}
+}
+
+func TestAssembleTCP(t *testing.T) {
+	assembler, results := newTestAssembler()
+	start := time.Unix(1500000000, 0)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	client, server := "10.0.0.1", "192.0.2.1"
+
+	assembler.AddPacket(at(0), tcpPacket(client, 40000, server, 80, TCPFlagSYN, 100, ""))
+	assembler.AddPacket(at(10), tcpPacket(server, 80, client, 40000, TCPFlagSYN|TCPFlagACK, 900, ""))
+	assembler.AddPacket(at(20), tcpPacket(client, 40000, server, 80, TCPFlagACK, 101, ""))
+	assembler.AddPacket(at(30), tcpPacket(client, 40000, server, 80, TCPFlagACK|TCPFlagPSH, 101, "GET / HTTP/1.0\r\n\r\n"))
+	//retransmissions are not counted twice
+	assembler.AddPacket(at(40), tcpPacket(client, 40000, server, 80, TCPFlagACK|TCPFlagPSH, 101, "GET / HTTP/1.0\r\n\r\n"))
+	assembler.AddPacket(at(50), tcpPacket(server, 80, client, 40000, TCPFlagACK|TCPFlagPSH, 901, "HTTP/1.0 200 OK\r\n\r\n"))
+	assembler.AddPacket(at(60), tcpPacket(server, 80, client, 40000, TCPFlagACK|TCPFlagFIN, 920, ""))
+	assembler.AddPacket(at(70), tcpPacket(client, 40000, server, 80, TCPFlagACK|TCPFlagFIN, 119, ""))
+
+	//a connection seen midstream
+	assembler.AddPacket(at(80), tcpPacket(client, 40001, server, 443, TCPFlagACK, 5000, "x"))
+
+	//closed connections are reported once the close delay passes
+	assembler.AddPacket(at(6000), tcpPacket(client, 40002, server, 22, TCPFlagSYN, 1, ""))
+	require.Len(t, results.sessions, 1)
+
+	session := results.sessions[0]
+	assert.Equal(t, "10.0.0.1", session.OrigAddr.String())
+	assert.Equal(t, uint16(80), session.RespPort)
+	assert.Equal(t, "ShADdfF", session.History())
+	assert.Equal(t, "SF", session.ConnState())
+	assert.Equal(t, int64(18), session.OrigBytes())
+	assert.Equal(t, int64(19), session.RespBytes())
+	assert.Equal(t, int64(5), session.OrigPackets)
+	assert.Equal(t, int64(3), session.RespPackets)
+	assert.Equal(t, int64(5*40+2*18), session.OrigIPBytes)
+	assert.Equal(t, 70*time.Millisecond, session.End.Sub(session.Start))
+
+	assembler.Flush()
+	require.Len(t, results.sessions, 3)
+	states := map[uint16]string{}
+	for _, session := range results.sessions[1:] {
+		states[session.RespPort] = session.ConnState()
+	}
+	assert.Equal(t, "OTH", states[443])
+	assert.Equal(t, "S0", states[22])
+}
+
+func TestAssembleFlipped(t *testing.T) {
+	assembler, results := newTestAssembler()
+	now := time.Unix(1500000000, 0)
+
+	//the SYN was missed, so the SYN-ACK identifies the responder
+	assembler.AddPacket(now, tcpPacket("192.0.2.1", 443, "10.0.0.1", 40000, TCPFlagSYN|TCPFlagACK, 1, ""))
+	assembler.AddPacket(now, tcpPacket("10.0.0.1", 40000, "192.0.2.1", 443, TCPFlagRST, 1, ""))
+
+	//an echo reply identifies the host which sent the request
+	assembler.AddPacket(now, IPPacket{
+		SrcIP:    net.ParseIP("192.0.2.1").To4(),
+		DstIP:    net.ParseIP("10.0.0.1").To4(),
+		Protocol: ProtocolICMP,
+		IPLength: 84,
+		ICMPType: 0,
+	})
+	assembler.Flush()
+	require.Len(t, results.sessions, 2)
+
+	for _, session := range results.sessions {
+		assert.Equal(t, "10.0.0.1", session.OrigAddr.String())
+		if session.Protocol == ProtocolTCP {
+			assert.Equal(t, uint16(443), session.RespPort)
+			assert.Equal(t, "^hR", session.History())
+			assert.Equal(t, "OTH", session.ConnState())
+		} else {
+			assert.Equal(t, uint16(8), session.OrigPort)
+			assert.Equal(t, uint16(0), session.RespPort)
+			assert.Equal(t, "SHR", session.ConnState())
+			assert.Equal(t, int64(84), session.RespIPBytes)
+		}
+	}
+}
+
+//dnsMessage builds a DNS message for the query with the given A records
+func dnsMessage(id uint16, flags uint16, query string, answers ...string) []byte {
+	message := make([]byte, 12)
+	binary.BigEndian.PutUint16(message[0:], id)
+	binary.BigEndian.PutUint16(message[2:], flags)
+	binary.BigEndian.PutUint16(message[4:], 1)
+	binary.BigEndian.PutUint16(message[6:], uint16(len(answers)))
+	for _, label := range splitLabels(query) {
+		message = append(message, byte(len(label)))
+		message = append(message, label...)
+	}
+	message = append(message, 0, 0, 1, 0, 1)
+	for _, answer := range answers {
+		//point back to the query name
+		message = append(message, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0x0e, 0x10, 0, 4)
+		message = append(message, net.ParseIP(answer).To4()...)
+	}
+	return message
+}
+
+func splitLabels(name string) []string {
+	var labels []string
+	start := 0
+	for i := 0; i <= len(name); i++ {
+		if i == len(name) || name[i] == '.' {
+			labels = append(labels, name[start:i])
+			start = i + 1
+		}
+	}
+	return labels
+}
+
+func TestAssembleDNS(t *testing.T) {
+	assembler, results := newTestAssembler()
+	now := time.Unix(1500000000, 0)
+	udp := func(src string, srcPort uint16, dst string, dstPort uint16, payload []byte) IPPacket {
+		return IPPacket{
+			SrcIP:    net.ParseIP(src).To4(),
+			DstIP:    net.ParseIP(dst).To4(),
+			Protocol: ProtocolUDP,
+			IPLength: 28 + len(payload),
+			SrcPort:  srcPort,
+			DstPort:  dstPort,
+			Payload:  payload,
+		}
+	}
+
+	assembler.AddPacket(now, udp("10.0.0.1", 5353, "10.0.0.53", 53, dnsMessage(1, 0x0100, "example.com")))
+	assembler.AddPacket(now.Add(20*time.Millisecond), udp("10.0.0.53", 53, "10.0.0.1", 5353,
+		dnsMessage(1, 0x8180, "example.com", "192.0.2.1", "192.0.2.2")))
+	assembler.AddPacket(now, udp("10.0.0.1", 5353, "10.0.0.53", 53, dnsMessage(2, 0x0100, "unanswered.example.com")))
+
+	require.Len(t, results.dns, 1)
+	answered := results.dns[0]
+	assert.True(t, answered.Responded)
+	assert.Equal(t, 20*time.Millisecond, answered.RTT)
+	assert.Equal(t, "example.com", answered.Query.Query)
+	assert.Equal(t, uint16(1), answered.Query.QType)
+	assert.True(t, answered.Response.RA)
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2"}, answered.Response.Answers)
+	assert.Equal(t, []uint32{3600, 3600}, answered.Response.TTLs)
+
+	assembler.Flush()
+	require.Len(t, results.dns, 2)
+	assert.False(t, results.dns[1].Responded)
+	assert.Equal(t, "unanswered.example.com", results.dns[1].Query.Query)
+	require.Len(t, results.sessions, 1)
+	assert.Equal(t, "Dd", results.sessions[0].History())
+	assert.Equal(t, "SF", results.sessions[0].ConnState())
+}
+
+func TestParseDNSErrors(t *testing.T) {
+	_, err := ParseDNS([]byte{1, 2, 3})
+	assert.NotNil(t, err)
+
+	//compression loops are rejected
+	message := dnsMessage(1, 0x0100, "example.com")
+	message[12] = 0xc0
+	message[13] = 12
+	_, err = ParseDNS(message)
+	assert.NotNil(t, err)
+
+	tcp := append([]byte{0, 0}, dnsMessage(1, 0x0100, "example.com")...)
+	binary.BigEndian.PutUint16(tcp, uint16(len(tcp)-2))
+	parsed, err := ParseDNSOverTCP(tcp)
+	require.Nil(t, err)
+	assert.Equal(t, "example.com", parsed.Query)
+}
@@ -0,0 +1,236 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+//IP protocol numbers of the transports which are decoded
+const (
+	ProtocolICMP   = 1
+	ProtocolTCP    = 6
+	ProtocolUDP    = 17
+	ProtocolICMPv6 = 58
+)
+
+//TCP flags
+const (
+	TCPFlagFIN = 0x01
+	TCPFlagSYN = 0x02
+	TCPFlagRST = 0x04
+	TCPFlagPSH = 0x08
+	TCPFlagACK = 0x10
+)
+
+var (
+	//ErrNotIP is returned when a packet does not hold an IP datagram
+	ErrNotIP = errors.New("packet does not contain an IP datagram")
+
+	//ErrUnsupportedLinkType is returned when packets use a link type which
+	//cannot be decoded
+	ErrUnsupportedLinkType = errors.New("unsupported link type")
+
+	//errTruncated is returned when a header runs past the end of a packet
+	errTruncated = errors.New("truncated packet")
+)
+
+//IPPacket holds the decoded IP and transport headers of a packet.
+//Non-initial fragments only have their IP header decoded.
+type IPPacket struct {
+	SrcIP    net.IP
+	DstIP    net.IP
+	Protocol uint8
+	//IPLength is the length of the datagram according to the IP header
+	IPLength int
+	//Fragment is true for non-initial fragments of a datagram
+	Fragment bool
+
+	SrcPort  uint16
+	DstPort  uint16
+	TCPFlags uint8
+	TCPSeq   uint32
+	TCPAck   uint32
+	ICMPType uint8
+	ICMPCode uint8
+
+	//Payload holds the transport payload, which may have been cut short
+	//by the capture's snap length
+	Payload []byte
+}
+
+//Decode parses the IP and transport headers of a packet
+func Decode(linkType uint32, data []byte) (IPPacket, error) {
+	var packet IPPacket
+	network, err := linkPayload(linkType, data)
+	if err != nil {
+		return packet, err
+	}
+	if len(network) < 1 {
+		return packet, ErrNotIP
+	}
+
+	var transport []byte
+	switch network[0] >> 4 {
+	case 4:
+		transport, err = decodeIPv4(network, &packet)
+	case 6:
+		transport, err = decodeIPv6(network, &packet)
+	default:
+		return packet, ErrNotIP
+	}
+	if err != nil || packet.Fragment {
+		return packet, err
+	}
+	return packet, decodeTransport(transport, &packet)
+}
+
+//linkPayload strips the link layer header from a packet
+func linkPayload(linkType uint32, data []byte) ([]byte, error) {
+	switch linkType {
+	case LinkTypeRaw:
+		return data, nil
+	case LinkTypeNull:
+		//the address family is in host byte order, so rely on the IP
+		//version in the datagram instead
+		if len(data) < 4 {
+			return nil, errTruncated
+		}
+		return data[4:], nil
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, errTruncated
+		}
+		return etherPayload(binary.BigEndian.Uint16(data[14:]), data[16:])
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return nil, errTruncated
+		}
+		etherType := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		//skip any VLAN tags
+		for etherType == 0x8100 || etherType == 0x88a8 {
+			if len(data) < 4 {
+				return nil, errTruncated
+			}
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+		return etherPayload(etherType, data)
+	}
+	return nil, ErrUnsupportedLinkType
+}
+
+//etherPayload returns the data if the ether type is IPv4 or IPv6
+func etherPayload(etherType uint16, data []byte) ([]byte, error) {
+	if etherType != 0x0800 && etherType != 0x86dd {
+		return nil, ErrNotIP
+	}
+	return data, nil
+}
+
+//decodeIPv4 parses an IPv4 header and returns the transport data
+func decodeIPv4(data []byte, packet *IPPacket) ([]byte, error) {
+	if len(data) < 20 {
+		return nil, errTruncated
+	}
+	headerLength := int(data[0]&0x0f) * 4
+	if headerLength < 20 || len(data) < headerLength {
+		return nil, errTruncated
+	}
+	packet.IPLength = int(binary.BigEndian.Uint16(data[2:]))
+	packet.Fragment = binary.BigEndian.Uint16(data[6:])&0x1fff != 0
+	packet.Protocol = data[9]
+	packet.SrcIP = net.IP(append([]byte(nil), data[12:16]...))
+	packet.DstIP = net.IP(append([]byte(nil), data[16:20]...))
+
+	end := len(data)
+	if packet.IPLength >= headerLength && packet.IPLength < end {
+		//drop ethernet padding
+		end = packet.IPLength
+	}
+	return data[headerLength:end], nil
+}
+
+//decodeIPv6 parses an IPv6 header and any extension headers and returns
+//the transport data
+func decodeIPv6(data []byte, packet *IPPacket) ([]byte, error) {
+	if len(data) < 40 {
+		return nil, errTruncated
+	}
+	payloadLength := int(binary.BigEndian.Uint16(data[4:]))
+	packet.IPLength = payloadLength + 40
+	packet.SrcIP = net.IP(append([]byte(nil), data[8:24]...))
+	packet.DstIP = net.IP(append([]byte(nil), data[24:40]...))
+
+	nextHeader := data[6]
+	end := len(data)
+	if packet.IPLength < end {
+		end = packet.IPLength
+	}
+	data = data[40:end]
+
+	for {
+		switch nextHeader {
+		case 0, 43, 60: //hop-by-hop, routing, and destination options
+			if len(data) < 8 {
+				return nil, errTruncated
+			}
+			length := (int(data[1]) + 1) * 8
+			if len(data) < length {
+				return nil, errTruncated
+			}
+			nextHeader = data[0]
+			data = data[length:]
+		case 44: //fragment
+			if len(data) < 8 {
+				return nil, errTruncated
+			}
+			nextHeader = data[0]
+			packet.Fragment = binary.BigEndian.Uint16(data[2:])&0xfff8 != 0
+			data = data[8:]
+		default:
+			packet.Protocol = nextHeader
+			return data, nil
+		}
+	}
+}
+
+//decodeTransport parses TCP, UDP, and ICMP headers
+func decodeTransport(data []byte, packet *IPPacket) error {
+	switch packet.Protocol {
+	case ProtocolTCP:
+		if len(data) < 20 {
+			return errTruncated
+		}
+		packet.SrcPort = binary.BigEndian.Uint16(data[0:])
+		packet.DstPort = binary.BigEndian.Uint16(data[2:])
+		packet.TCPSeq = binary.BigEndian.Uint32(data[4:])
+		packet.TCPAck = binary.BigEndian.Uint32(data[8:])
+		packet.TCPFlags = data[13]
+		offset := int(data[12]>>4) * 4
+		if offset < 20 || len(data) < offset {
+			return errTruncated
+		}
+		packet.Payload = data[offset:]
+	case ProtocolUDP:
+		if len(data) < 8 {
+			return errTruncated
+		}
+		packet.SrcPort = binary.BigEndian.Uint16(data[0:])
+		packet.DstPort = binary.BigEndian.Uint16(data[2:])
+		end := int(binary.BigEndian.Uint16(data[4:]))
+		if end < 8 || end > len(data) {
+			end = len(data)
+		}
+		packet.Payload = data[8:end]
+	case ProtocolICMP, ProtocolICMPv6:
+		if len(data) < 4 {
+			return errTruncated
+		}
+		packet.ICMPType = data[0]
+		packet.ICMPCode = data[1]
+		packet.Payload = data[4:]
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+//errBadDNS is returned when a payload is not a valid DNS message
+var errBadDNS = errors.New("invalid DNS message")
+
+//DNSMessage holds the fields RITA uses from a DNS query or response
+type DNSMessage struct {
+	ID       uint16
+	Response bool
+	AA       bool
+	TC       bool
+	RD       bool
+	RA       bool
+	Z        uint8
+	RCode    uint8
+	Query    string
+	QType    uint16
+	QClass   uint16
+	//Answers holds the data of each answer record formatted as text
+	Answers []string
+	TTLs    []uint32
+}
+
+//ParseDNS parses a DNS message sent over UDP
+func ParseDNS(payload []byte) (DNSMessage, error) {
+	var message DNSMessage
+	if len(payload) < 12 {
+		return message, errBadDNS
+	}
+	flags := binary.BigEndian.Uint16(payload[2:])
+	message.ID = binary.BigEndian.Uint16(payload[0:])
+	message.Response = flags&0x8000 != 0
+	message.AA = flags&0x0400 != 0
+	message.TC = flags&0x0200 != 0
+	message.RD = flags&0x0100 != 0
+	message.RA = flags&0x0080 != 0
+	message.Z = uint8(flags>>4) & 0x07
+	message.RCode = uint8(flags & 0x000f)
+	if flags&0x7800 != 0 {
+		//only standard queries are tracked
+		return message, errBadDNS
+	}
+
+	questions := int(binary.BigEndian.Uint16(payload[4:]))
+	answers := int(binary.BigEndian.Uint16(payload[6:]))
+	if questions != 1 {
+		return message, errBadDNS
+	}
+
+	name, offset, err := readDNSName(payload, 12)
+	if err != nil || offset+4 > len(payload) {
+		return message, errBadDNS
+	}
+	message.Query = name
+	message.QType = binary.BigEndian.Uint16(payload[offset:])
+	message.QClass = binary.BigEndian.Uint16(payload[offset+2:])
+	offset += 4
+
+	//answers which are cut short by the snap length are dropped
+	for i := 0; i < answers; i++ {
+		_, offset, err = readDNSName(payload, offset)
+		if err != nil || offset+10 > len(payload) {
+			break
+		}
+		rrType := binary.BigEndian.Uint16(payload[offset:])
+		ttl := binary.BigEndian.Uint32(payload[offset+4:])
+		length := int(binary.BigEndian.Uint16(payload[offset+8:]))
+		offset += 10
+		if offset+length > len(payload) {
+			break
+		}
+		message.Answers = append(message.Answers, formatDNSRecord(payload, offset, length, rrType))
+		message.TTLs = append(message.TTLs, ttl)
+		offset += length
+	}
+	return message, nil
+}
+
+//ParseDNSOverTCP parses a DNS message sent in a single TCP segment. TCP
+//messages are prefixed with their length.
+func ParseDNSOverTCP(payload []byte) (DNSMessage, error) {
+	if len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != len(payload)-2 {
+		return DNSMessage{}, errBadDNS
+	}
+	return ParseDNS(payload[2:])
+}
+
+//readDNSName reads a possibly compressed domain name starting at offset
+//and returns the name along with the offset following it
+func readDNSName(message []byte, offset int) (string, int, error) {
+	var labels []string
+	end := -1
+	//bound the number of compression pointers followed to avoid loops
+	for jumps := 0; jumps < 64; {
+		if offset >= len(message) {
+			return "", 0, errBadDNS
+		}
+		length := int(message[offset])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(message) {
+				return "", 0, errBadDNS
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(message[offset:]) & 0x3fff)
+			jumps++
+		case length&0xc0 != 0:
+			return "", 0, errBadDNS
+		default:
+			if offset+1+length > len(message) {
+				return "", 0, errBadDNS
+			}
+			labels = append(labels, string(message[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+	return "", 0, errBadDNS
+}
+
+//formatDNSRecord formats the data of a resource record as text
+func formatDNSRecord(message []byte, offset int, length int, rrType uint16) string {
+	data := message[offset : offset+length]
+	switch rrType {
+	case 1, 28: //A and AAAA
+		if length == 4 || length == 16 {
+			return net.IP(data).String()
+		}
+	case 2, 5, 12: //NS, CNAME, and PTR
+		if name, _, err := readDNSName(message, offset); err == nil {
+			return name
+		}
+	case 15: //MX
+		if length > 2 {
+			if name, _, err := readDNSName(message, offset+2); err == nil {
+				return name
+			}
+		}
+	case 6: //SOA
+		if name, _, err := readDNSName(message, offset); err == nil {
+			return name
+		}
+	case 16: //TXT
+		var texts []string
+		for len(data) > 0 && int(data[0]) < len(data) {
+			texts = append(texts, string(data[1:1+int(data[0])]))
+			data = data[1+int(data[0]):]
+		}
+		return "TXT " + strconv.Itoa(length) + " " + strings.Join(texts, " ")
+	}
+	return "<unknown type=" + strconv.Itoa(int(rrType)) + ">"
+}
@@ -0,0 +1,122 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+//Link types which packets may be decoded from
+const (
+	LinkTypeNull     = 0
+	LinkTypeEthernet = 1
+	LinkTypeRaw      = 101
+	LinkTypeLinuxSLL = 113
+)
+
+//maxSnapLength bounds the size of a single captured packet so corrupt
+//files do not cause huge allocations
+const maxSnapLength = 256 * 1024
+
+//ErrUnknownFormat is returned when a file is not a capture file
+var ErrUnknownFormat = errors.New("not a pcap file")
+
+type (
+	//Packet is a single packet read from a capture file
+	Packet struct {
+		Timestamp time.Time
+		LinkType  uint32
+		Data      []byte
+	}
+
+	//Reader reads packets from a classic libpcap capture file
+	Reader struct {
+		reader     io.Reader
+		byteOrder  binary.ByteOrder
+		nanosecond bool
+		linkType   uint32
+		header     [16]byte
+	}
+)
+
+//IsPcap returns true if the leading bytes of a file hold a libpcap magic number
+func IsPcap(magic []byte) bool {
+	if len(magic) < 4 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(magic) {
+	case 0xa1b2c3d4, 0xd4c3b2a1, 0xa1b23c4d, 0x4d3cb2a1:
+		return true
+	}
+	return false
+}
+
+//NewReader reads the file header of a libpcap capture and returns a
+//Reader for the packets which follow
+func NewReader(reader io.Reader) (*Reader, error) {
+	var header [24]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrUnknownFormat
+		}
+		return nil, err
+	}
+
+	r := &Reader{reader: reader}
+	switch binary.BigEndian.Uint32(header[:4]) {
+	case 0xa1b2c3d4:
+		r.byteOrder = binary.BigEndian
+	case 0xd4c3b2a1:
+		r.byteOrder = binary.LittleEndian
+	case 0xa1b23c4d:
+		r.byteOrder = binary.BigEndian
+		r.nanosecond = true
+	case 0x4d3cb2a1:
+		r.byteOrder = binary.LittleEndian
+		r.nanosecond = true
+	default:
+		return nil, ErrUnknownFormat
+	}
+	//the upper bits of the link type may hold FCS information
+	r.linkType = r.byteOrder.Uint32(header[20:]) & 0x0fffffff
+	return r, nil
+}
+
+//LinkType returns the link type of the packets in the capture
+func (r *Reader) LinkType() uint32 {
+	return r.linkType
+}
+
+//ReadPacket returns the next packet in the capture. io.EOF is returned
+//once all of the packets have been read.
+func (r *Reader) ReadPacket() (Packet, error) {
+	if _, err := io.ReadFull(r.reader, r.header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Packet{}, fmt.Errorf("truncated packet header")
+		}
+		return Packet{}, err
+	}
+
+	seconds := int64(r.byteOrder.Uint32(r.header[0:]))
+	fraction := int64(r.byteOrder.Uint32(r.header[4:]))
+	capturedLength := r.byteOrder.Uint32(r.header[8:])
+	if capturedLength > maxSnapLength {
+		return Packet{}, fmt.Errorf("packet length %d is too large", capturedLength)
+	}
+
+	data := make([]byte, capturedLength)
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		return Packet{}, fmt.Errorf("truncated packet")
+	}
+
+	if !r.nanosecond {
+		fraction *= int64(time.Microsecond)
+	}
+	return Packet{
+		Timestamp: time.Unix(seconds, fraction),
+		LinkType:  r.linkType,
+		Data:      data,
+	}, nil
+}
@@ -0,0 +1,170 @@
+package pcap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//udpFrame builds an ethernet frame holding a UDP datagram with a VLAN tag
+func udpFrame(payload []byte) []byte {
+	frame := make([]byte, 18+20+8)
+	binary.BigEndian.PutUint16(frame[12:], 0x8100)
+	binary.BigEndian.PutUint16(frame[16:], 0x0800)
+
+	ip := frame[18:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(28+len(payload)))
+	ip[9] = ProtocolUDP
+	copy(ip[12:], net.ParseIP("10.0.0.1").To4())
+	copy(ip[16:], net.ParseIP("10.0.0.2").To4())
+
+	udp := ip[20:]
+	binary.BigEndian.PutUint16(udp[0:], 5000)
+	binary.BigEndian.PutUint16(udp[2:], 2055)
+	binary.BigEndian.PutUint16(udp[4:], uint16(8+len(payload)))
+
+	frame = append(frame, payload...)
+	//ethernet padding is not part of the payload
+	return append(frame, 0, 0, 0, 0)
+}
+
+func TestReadPcap(t *testing.T) {
+	for _, byteOrder := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		var file bytes.Buffer
+		header := make([]byte, 24)
+		byteOrder.PutUint32(header[0:], 0xa1b23c4d)
+		byteOrder.PutUint16(header[4:], 2)
+		byteOrder.PutUint16(header[6:], 4)
+		byteOrder.PutUint32(header[16:], 65535)
+		byteOrder.PutUint32(header[20:], LinkTypeEthernet)
+		file.Write(header)
+
+		frame := udpFrame([]byte("flow"))
+		record := make([]byte, 16)
+		byteOrder.PutUint32(record[0:], 1500000000)
+		byteOrder.PutUint32(record[4:], 250)
+		byteOrder.PutUint32(record[8:], uint32(len(frame)))
+		byteOrder.PutUint32(record[12:], uint32(len(frame)))
+		file.Write(record)
+		file.Write(frame)
+
+		assert.True(t, IsPcap(file.Bytes()))
+		reader, err := NewReader(&file)
+		require.Nil(t, err)
+		assert.Equal(t, uint32(LinkTypeEthernet), reader.LinkType())
+
+		packet, err := reader.ReadPacket()
+		require.Nil(t, err)
+		assert.Equal(t, time.Unix(1500000000, 250), packet.Timestamp)
+
+		decoded, err := Decode(packet.LinkType, packet.Data)
+		require.Nil(t, err)
+		assert.Equal(t, "10.0.0.1", decoded.SrcIP.String())
+		assert.Equal(t, "10.0.0.2", decoded.DstIP.String())
+		assert.Equal(t, uint8(ProtocolUDP), decoded.Protocol)
+		assert.Equal(t, uint16(5000), decoded.SrcPort)
+		assert.Equal(t, uint16(2055), decoded.DstPort)
+		assert.Equal(t, []byte("flow"), decoded.Payload)
+
+		_, err = reader.ReadPacket()
+		assert.Equal(t, io.EOF, err)
+	}
+
+	_, err := NewReader(bytes.NewReader([]byte("#separator \\x09\n#set_separator\t,\n")))
+	assert.Equal(t, ErrUnknownFormat, err)
+}
+
+func TestDecodeIPv6TCP(t *testing.T) {
+	packet := make([]byte, 40+8+20)
+	packet[0] = 0x60
+	binary.BigEndian.PutUint16(packet[4:], 28)
+	packet[6] = 60 //destination options
+	copy(packet[8:], net.ParseIP("2001:db8::1"))
+	copy(packet[24:], net.ParseIP("2001:db8::2"))
+	packet[40] = ProtocolTCP
+
+	tcp := packet[48:]
+	binary.BigEndian.PutUint16(tcp[0:], 40000)
+	binary.BigEndian.PutUint16(tcp[2:], 443)
+	binary.BigEndian.PutUint32(tcp[4:], 1000)
+	tcp[12] = 5 << 4
+	tcp[13] = TCPFlagSYN
+
+	decoded, err := Decode(LinkTypeRaw, packet)
+	require.Nil(t, err)
+	assert.Equal(t, "2001:db8::2", decoded.DstIP.String())
+	assert.Equal(t, uint8(ProtocolTCP), decoded.Protocol)
+	assert.Equal(t, uint16(443), decoded.DstPort)
+	assert.Equal(t, uint8(TCPFlagSYN), decoded.TCPFlags)
+	assert.Equal(t, uint32(1000), decoded.TCPSeq)
+	assert.Equal(t, 68, decoded.IPLength)
+	assert.Empty(t, decoded.Payload)
+
+	_, err = Decode(LinkTypeRaw, packet[:30])
+	assert.NotNil(t, err)
+}
+
+//ngBlock builds a little endian pcapng block
+func ngBlock(blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	block := make([]byte, 8, 12+len(body))
+	binary.LittleEndian.PutUint32(block[0:], blockType)
+	binary.LittleEndian.PutUint32(block[4:], uint32(12+len(body)))
+	block = append(block, body...)
+	trailer := make([]byte, 4)
+	binary.LittleEndian.PutUint32(trailer, uint32(12+len(body)))
+	return append(block, trailer...)
+}
+
+func TestReadPcapng(t *testing.T) {
+	var file bytes.Buffer
+	sectionHeader := make([]byte, 16)
+	binary.LittleEndian.PutUint32(sectionHeader[0:], ngByteOrderMagic)
+	binary.LittleEndian.PutUint16(sectionHeader[4:], 1)
+	binary.LittleEndian.PutUint64(sectionHeader[8:], 0xffffffffffffffff)
+	file.Write(ngBlock(ngSectionHeaderBlock, sectionHeader))
+
+	//an interface with nanosecond timestamps
+	iface := make([]byte, 8)
+	binary.LittleEndian.PutUint16(iface[0:], LinkTypeEthernet)
+	iface = append(iface, ngOptionTimeResolution, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0, 0)
+	file.Write(ngBlock(ngInterfaceBlock, iface))
+
+	//blocks which are not packets are skipped
+	file.Write(ngBlock(5, make([]byte, 8)))
+
+	frame := udpFrame([]byte("flow"))
+	timestamp := uint64(1500000000)*uint64(time.Second) + 250
+	packet := make([]byte, 20)
+	binary.LittleEndian.PutUint32(packet[4:], uint32(timestamp>>32))
+	binary.LittleEndian.PutUint32(packet[8:], uint32(timestamp))
+	binary.LittleEndian.PutUint32(packet[12:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(packet[16:], uint32(len(frame)))
+	file.Write(ngBlock(ngEnhancedPacketBlock, append(packet, frame...)))
+
+	source, err := OpenCapture(bufio.NewReader(&file))
+	require.Nil(t, err)
+
+	read, err := source.ReadPacket()
+	require.Nil(t, err)
+	assert.Equal(t, time.Unix(1500000000, 250), read.Timestamp)
+	assert.Equal(t, uint32(LinkTypeEthernet), read.LinkType)
+	assert.Equal(t, frame, read.Data)
+
+	_, err = source.ReadPacket()
+	assert.Equal(t, io.EOF, err)
+
+	//binary resolutions count fractions of a second in powers of two
+	assert.Equal(t, time.Unix(3, int64(time.Second/2)),
+		ngInterface{resolution: 1, binary: true}.time(7))
+}
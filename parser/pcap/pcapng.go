@@ -0,0 +1,297 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+//pcapng block types
+const (
+	ngSectionHeaderBlock    = 0x0a0d0d0a
+	ngInterfaceBlock        = 1
+	ngObsoletePacketBlock   = 2
+	ngSimplePacketBlock     = 3
+	ngEnhancedPacketBlock   = 6
+	ngOptionEnd             = 0
+	ngOptionTimeResolution  = 9
+	ngByteOrderMagic        = 0x1a2b3c4d
+	ngSwappedByteOrderMagic = 0x4d3c2b1a
+)
+
+type (
+	//PacketSource reads packets from a capture file
+	PacketSource interface {
+		ReadPacket() (Packet, error)
+	}
+
+	//NgReader reads packets from a pcapng capture file
+	NgReader struct {
+		reader     io.Reader
+		byteOrder  binary.ByteOrder
+		interfaces []ngInterface
+	}
+
+	//ngInterface describes an interface packets were captured on
+	ngInterface struct {
+		linkType   uint32
+		snapLength uint32
+		//resolution is the number of timestamp units per second, given as
+		//a power of ten or, if binary is set, as a power of two
+		resolution uint8
+		binary     bool
+	}
+)
+
+//IsPcapng returns true if the leading bytes of a file hold a pcapng
+//section header
+func IsPcapng(magic []byte) bool {
+	return len(magic) >= 4 && binary.BigEndian.Uint32(magic) == ngSectionHeaderBlock
+}
+
+//OpenCapture returns a PacketSource for a pcap or pcapng capture
+func OpenCapture(reader *bufio.Reader) (PacketSource, error) {
+	magic, _ := reader.Peek(4)
+	switch {
+	case IsPcap(magic):
+		return NewReader(reader)
+	case IsPcapng(magic):
+		return NewNgReader(reader)
+	}
+	return nil, ErrUnknownFormat
+}
+
+//NewNgReader reads the first section header of a pcapng capture and
+//returns an NgReader for the blocks which follow
+func NewNgReader(reader io.Reader) (*NgReader, error) {
+	var blockType [4]byte
+	if _, err := io.ReadFull(reader, blockType[:]); err != nil ||
+		!IsPcapng(blockType[:]) {
+		return nil, ErrUnknownFormat
+	}
+	r := &NgReader{reader: reader}
+	if err := r.readSectionHeader(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//ReadPacket returns the next packet in the capture. io.EOF is returned
+//once all of the packets have been read.
+func (r *NgReader) ReadPacket() (Packet, error) {
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r.reader, header[:4]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return Packet{}, fmt.Errorf("truncated block header")
+			}
+			return Packet{}, err
+		}
+		if IsPcapng(header[:4]) {
+			if err := r.readSectionHeader(); err != nil {
+				return Packet{}, err
+			}
+			continue
+		}
+
+		if _, err := io.ReadFull(r.reader, header[4:]); err != nil {
+			return Packet{}, fmt.Errorf("truncated block header")
+		}
+		blockType := r.byteOrder.Uint32(header[0:])
+		length := r.byteOrder.Uint32(header[4:])
+		if length < 12 || length%4 != 0 || length > maxSnapLength+64 {
+			return Packet{}, fmt.Errorf("invalid block length %d", length)
+		}
+
+		block := make([]byte, length-8)
+		if _, err := io.ReadFull(r.reader, block); err != nil {
+			return Packet{}, fmt.Errorf("truncated block")
+		}
+		//drop the trailing copy of the block length
+		body := block[:len(block)-4]
+
+		var packet Packet
+		var ok bool
+		var err error
+		switch blockType {
+		case ngInterfaceBlock:
+			err = r.addInterface(body)
+		case ngEnhancedPacketBlock:
+			packet, ok, err = r.enhancedPacket(body)
+		case ngObsoletePacketBlock:
+			packet, ok, err = r.obsoletePacket(body)
+		case ngSimplePacketBlock:
+			packet, ok, err = r.simplePacket(body)
+		}
+		if err != nil {
+			return Packet{}, err
+		}
+		if ok {
+			return packet, nil
+		}
+	}
+}
+
+//readSectionHeader reads the remainder of a section header block after its
+//block type. Each section sets the byte order for its blocks and
+//describes its own interfaces.
+func (r *NgReader) readSectionHeader() error {
+	var header [8]byte
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		return fmt.Errorf("truncated section header")
+	}
+	switch binary.BigEndian.Uint32(header[4:]) {
+	case ngByteOrderMagic:
+		r.byteOrder = binary.BigEndian
+	case ngSwappedByteOrderMagic:
+		r.byteOrder = binary.LittleEndian
+	default:
+		return ErrUnknownFormat
+	}
+
+	length := r.byteOrder.Uint32(header[0:])
+	if length < 28 || length%4 != 0 {
+		return fmt.Errorf("invalid section header length %d", length)
+	}
+	//skip the version, section length, and options
+	if _, err := io.CopyN(ioutil.Discard, r.reader, int64(length-12)); err != nil {
+		return fmt.Errorf("truncated section header")
+	}
+	r.interfaces = nil
+	return nil
+}
+
+//addInterface records the details of an interface description block
+func (r *NgReader) addInterface(body []byte) error {
+	if len(body) < 8 {
+		return fmt.Errorf("truncated interface description")
+	}
+	iface := ngInterface{
+		linkType:   uint32(r.byteOrder.Uint16(body[0:])),
+		snapLength: r.byteOrder.Uint32(body[4:]),
+		resolution: 6,
+	}
+
+	options := body[8:]
+	for len(options) >= 4 {
+		code := r.byteOrder.Uint16(options[0:])
+		length := int(r.byteOrder.Uint16(options[2:]))
+		if code == ngOptionEnd || 4+length > len(options) {
+			break
+		}
+		if code == ngOptionTimeResolution && length >= 1 {
+			iface.resolution = options[4] & 0x7f
+			iface.binary = options[4]&0x80 != 0
+		}
+		options = options[4+(length+3)/4*4:]
+	}
+
+	r.interfaces = append(r.interfaces, iface)
+	return nil
+}
+
+//enhancedPacket reads an enhanced packet block
+func (r *NgReader) enhancedPacket(body []byte) (Packet, bool, error) {
+	if len(body) < 20 {
+		return Packet{}, false, fmt.Errorf("truncated packet")
+	}
+	iface, err := r.getInterface(r.byteOrder.Uint32(body[0:]))
+	if err != nil {
+		return Packet{}, false, err
+	}
+	timestamp := uint64(r.byteOrder.Uint32(body[4:]))<<32 | uint64(r.byteOrder.Uint32(body[8:]))
+	capturedLength := int(r.byteOrder.Uint32(body[12:]))
+	if 20+capturedLength > len(body) {
+		return Packet{}, false, fmt.Errorf("truncated packet")
+	}
+	return Packet{
+		Timestamp: iface.time(timestamp),
+		LinkType:  iface.linkType,
+		Data:      body[20 : 20+capturedLength],
+	}, true, nil
+}
+
+//obsoletePacket reads a packet block written by old versions of pcapng
+func (r *NgReader) obsoletePacket(body []byte) (Packet, bool, error) {
+	if len(body) < 20 {
+		return Packet{}, false, fmt.Errorf("truncated packet")
+	}
+	iface, err := r.getInterface(uint32(r.byteOrder.Uint16(body[0:])))
+	if err != nil {
+		return Packet{}, false, err
+	}
+	timestamp := uint64(r.byteOrder.Uint32(body[4:]))<<32 | uint64(r.byteOrder.Uint32(body[8:]))
+	capturedLength := int(r.byteOrder.Uint32(body[12:]))
+	if 20+capturedLength > len(body) {
+		return Packet{}, false, fmt.Errorf("truncated packet")
+	}
+	return Packet{
+		Timestamp: iface.time(timestamp),
+		LinkType:  iface.linkType,
+		Data:      body[20 : 20+capturedLength],
+	}, true, nil
+}
+
+//simplePacket reads a simple packet block. Simple packets do not record
+//when they were captured.
+func (r *NgReader) simplePacket(body []byte) (Packet, bool, error) {
+	if len(body) < 4 {
+		return Packet{}, false, fmt.Errorf("truncated packet")
+	}
+	iface, err := r.getInterface(0)
+	if err != nil {
+		return Packet{}, false, err
+	}
+	capturedLength := int(r.byteOrder.Uint32(body[0:]))
+	if iface.snapLength != 0 && capturedLength > int(iface.snapLength) {
+		capturedLength = int(iface.snapLength)
+	}
+	if 4+capturedLength > len(body) {
+		capturedLength = len(body) - 4
+	}
+	return Packet{
+		LinkType: iface.linkType,
+		Data:     body[4 : 4+capturedLength],
+	}, true, nil
+}
+
+//getInterface returns the interface a packet was captured on
+func (r *NgReader) getInterface(id uint32) (ngInterface, error) {
+	if int(id) >= len(r.interfaces) {
+		return ngInterface{}, fmt.Errorf("packet refers to unknown interface %d", id)
+	}
+	return r.interfaces[id], nil
+}
+
+//time converts a timestamp in the interface's resolution to a time
+func (i ngInterface) time(timestamp uint64) time.Time {
+	if i.binary {
+		if i.resolution == 0 {
+			return time.Unix(int64(timestamp), 0)
+		}
+		if i.resolution >= 64 {
+			return time.Unix(0, 0)
+		}
+		seconds := timestamp >> i.resolution
+		fraction := timestamp & (1<<i.resolution - 1)
+		nanoseconds := float64(fraction) / float64(uint64(1)<<i.resolution) * float64(time.Second)
+		return time.Unix(int64(seconds), int64(nanoseconds))
+	}
+
+	unitsPerSecond := uint64(1)
+	for n := uint8(0); n < i.resolution && n < 19; n++ {
+		unitsPerSecond *= 10
+	}
+	seconds := timestamp / unitsPerSecond
+	fraction := timestamp % unitsPerSecond
+	var nanoseconds uint64
+	if unitsPerSecond <= uint64(time.Second) {
+		nanoseconds = fraction * (uint64(time.Second) / unitsPerSecond)
+	} else {
+		nanoseconds = fraction / (unitsPerSecond / uint64(time.Second))
+	}
+	return time.Unix(int64(seconds), int64(nanoseconds))
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"hash/fnv"
+	"io"
+	"strconv"
+
+	pt "github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/parser/pcap"
+	log "github.com/sirupsen/logrus"
+)
+
+//PcapImporter builds conn and dns entries directly from pcap and pcapng
+//captures. Packets are grouped into TCP, UDP, and ICMP sessions and DNS
+//queries are paired with their responses. Connection states and
+//histories are approximations of what bro would log.
+type PcapImporter struct {
+	fs             *FSImporter
+	targetDatabase string
+	sensor         string
+}
+
+//NewPcapImporter creates a PcapImporter which imports captures into the
+//target database and tags the entries with the sensor name. The
+//importer's filters and time window are applied to every entry.
+func NewPcapImporter(fs *FSImporter, targetDatabase string, sensor string) *PcapImporter {
+	return &PcapImporter{
+		fs:             fs,
+		targetDatabase: targetDatabase,
+		sensor:         sensor,
+	}
+}
+
+//ImportFiles imports the given capture files, which may be gzip
+//compressed. Files which have already been imported into the target
+//database are skipped.
+func (p *PcapImporter) ImportFiles(files []string, datastore Datastore) {
+	p.fs.importRecordFiles(files, p.targetDatabase, "", p.sensor, datastore, p.importFile)
+}
+
+//importFile stores the sessions and DNS transactions in a capture. The
+//number of entries stored is returned.
+func (p *PcapImporter) importFile(path string, filter *recordFilter) (int, error) {
+	reader, closer, err := openRecordFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer closer.Close()
+
+	capture, err := pcap.OpenCapture(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	structure := &p.fs.res.Config.T.Structure
+	count := 0
+	assembler := pcap.NewAssembler(
+		func(session *pcap.Session) {
+			filter.store(sessionToConn(session), p.sensor, p.targetDatabase, structure.ConnTable)
+			count++
+		},
+		func(transaction *pcap.DNSTransaction) {
+			filter.store(dnsTransactionToDNS(transaction), p.sensor, p.targetDatabase, structure.DNSTable)
+			count++
+		},
+	)
+
+	undecoded := 0
+	for {
+		packet, err := capture.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			assembler.Flush()
+			return count, err
+		}
+
+		decoded, err := pcap.Decode(packet.LinkType, packet.Data)
+		if err != nil {
+			undecoded++
+			continue
+		}
+		assembler.AddPacket(packet.Timestamp, decoded)
+	}
+	assembler.Flush()
+
+	p.fs.res.Log.WithFields(log.Fields{
+		"path":      path,
+		"undecoded": undecoded,
+	}).Info("Finished assembling sessions")
+	return count, nil
+}
+
+//sessionToConn converts a session into a conn entry
+func sessionToConn(session *pcap.Session) *pt.Conn {
+	return &pt.Conn{
+		TimeStamp:       session.Start.Unix(),
+		UID:             sessionUID(session),
+		Source:          session.OrigAddr.String(),
+		SourcePort:      int(session.OrigPort),
+		Destination:     session.RespAddr.String(),
+		DestinationPort: int(session.RespPort),
+		Proto:           flowProto(session.Protocol),
+		Duration:        session.End.Sub(session.Start).Seconds(),
+		OrigBytes:       session.OrigBytes(),
+		RespBytes:       session.RespBytes(),
+		ConnState:       session.ConnState(),
+		History:         session.History(),
+		OrigPkts:        session.OrigPackets,
+		OrigIPBytes:     session.OrigIPBytes,
+		RespPkts:        session.RespPackets,
+		RespIPBytes:     session.RespIPBytes,
+	}
+}
+
+//dnsTransactionToDNS converts a DNS transaction into a dns entry which
+//shares its unique id with the conn entry of its session
+func dnsTransactionToDNS(transaction *pcap.DNSTransaction) *pt.DNS {
+	session := transaction.Session
+	query := transaction.Query
+	entry := &pt.DNS{
+		TimeStamp:       transaction.Timestamp.Unix(),
+		UID:             sessionUID(session),
+		Source:          session.OrigAddr.String(),
+		SourcePort:      int(session.OrigPort),
+		Destination:     session.RespAddr.String(),
+		DestinationPort: int(session.RespPort),
+		Proto:           flowProto(session.Protocol),
+		TransID:         int64(query.ID),
+		Query:           query.Query,
+		QClass:          int64(query.QClass),
+		QClassName:      dnsClassName(int64(query.QClass)),
+		QType:           int64(query.QType),
+		QTypeName:       dnsQueryTypeName(int64(query.QType)),
+		RD:              query.RD,
+		Z:               int64(query.Z),
+	}
+	if !transaction.Responded {
+		return entry
+	}
+
+	response := transaction.Response
+	entry.RTT = transaction.RTT.Seconds()
+	entry.RCode = int64(response.RCode)
+	entry.RCodeName = dnsResponseCodeName(entry.RCode)
+	entry.AA = response.AA
+	entry.TC = response.TC
+	entry.RA = response.RA
+	entry.Rejected = entry.RCodeName == "REFUSED"
+	entry.Answers = response.Answers
+	for _, ttl := range response.TTLs {
+		entry.TTLs = append(entry.TTLs, float64(ttl))
+	}
+	return entry
+}
+
+//sessionUID creates a unique id for a session from its endpoints and
+//start time
+func sessionUID(session *pcap.Session) string {
+	hash := fnv.New64a()
+	hash.Write(session.OrigAddr)
+	hash.Write(session.RespAddr)
+	hash.Write([]byte{byte(session.OrigPort >> 8), byte(session.OrigPort),
+		byte(session.RespPort >> 8), byte(session.RespPort), session.Protocol})
+	hash.Write([]byte(strconv.FormatInt(session.Start.UnixNano(), 10)))
+	return "C" + strconv.FormatUint(hash.Sum64(), 36)
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/parser/pcap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionToConn(t *testing.T) {
+	var sessions []*pcap.Session
+	assembler := pcap.NewAssembler(
+		func(session *pcap.Session) { sessions = append(sessions, session) },
+		func(*pcap.DNSTransaction) {},
+	)
+	start := time.Unix(1500000000, 0)
+	assembler.AddPacket(start, pcap.IPPacket{
+		SrcIP:    net.ParseIP("10.0.0.1").To4(),
+		DstIP:    net.ParseIP("192.0.2.1").To4(),
+		Protocol: pcap.ProtocolTCP,
+		IPLength: 60,
+		SrcPort:  40000,
+		DstPort:  443,
+		TCPFlags: pcap.TCPFlagSYN,
+	})
+	assembler.AddPacket(start.Add(3*time.Second), pcap.IPPacket{
+		SrcIP:    net.ParseIP("10.0.0.1").To4(),
+		DstIP:    net.ParseIP("192.0.2.1").To4(),
+		Protocol: pcap.ProtocolTCP,
+		IPLength: 60,
+		SrcPort:  40000,
+		DstPort:  443,
+		TCPFlags: pcap.TCPFlagSYN,
+	})
+	assembler.Flush()
+	require.Len(t, sessions, 1)
+
+	conn := sessionToConn(sessions[0])
+	assert.Equal(t, int64(1500000000), conn.TimeStamp)
+	assert.Equal(t, "10.0.0.1", conn.Source)
+	assert.Equal(t, "192.0.2.1", conn.Destination)
+	assert.Equal(t, 443, conn.DestinationPort)
+	assert.Equal(t, "tcp", conn.Proto)
+	assert.Equal(t, "S0", conn.ConnState)
+	assert.Equal(t, "S", conn.History)
+	assert.Equal(t, 3.0, conn.Duration)
+	assert.Equal(t, int64(2), conn.OrigPkts)
+	assert.Equal(t, int64(120), conn.OrigIPBytes)
+	assert.Equal(t, int64(0), conn.RespPkts)
+	assert.Equal(t, sessionUID(sessions[0]), conn.UID)
+}
+
+func TestDNSTransactionToDNS(t *testing.T) {
+	session := &pcap.Session{
+		Start:    time.Unix(1500000000, 0),
+		OrigAddr: net.ParseIP("10.0.0.1").To4(),
+		RespAddr: net.ParseIP("10.0.0.53").To4(),
+		OrigPort: 5353,
+		RespPort: 53,
+		Protocol: pcap.ProtocolUDP,
+	}
+	transaction := &pcap.DNSTransaction{
+		Session:   session,
+		Timestamp: session.Start,
+		RTT:       250 * time.Millisecond,
+		Query:     pcap.DNSMessage{ID: 7, Query: "example.com", QType: 28, QClass: 1, RD: true},
+		Responded: true,
+		Response: pcap.DNSMessage{ID: 7, Response: true, RA: true, RCode: 3,
+			Answers: []string{"2001:db8::1"}, TTLs: []uint32{60}},
+	}
+
+	entry := dnsTransactionToDNS(transaction)
+	assert.Equal(t, sessionUID(session), entry.UID)
+	assert.Equal(t, "udp", entry.Proto)
+	assert.Equal(t, 53, entry.DestinationPort)
+	assert.Equal(t, "example.com", entry.Query)
+	assert.Equal(t, "AAAA", entry.QTypeName)
+	assert.Equal(t, "C_INTERNET", entry.QClassName)
+	assert.Equal(t, "NXDOMAIN", entry.RCodeName)
+	assert.Equal(t, 0.25, entry.RTT)
+	assert.True(t, entry.RD)
+	assert.True(t, entry.RA)
+	assert.Equal(t, []string{"2001:db8::1"}, entry.Answers)
+	assert.Equal(t, []float64{60}, entry.TTLs)
+
+	transaction.Responded = false
+	entry = dnsTransactionToDNS(transaction)
+	assert.Empty(t, entry.RCodeName)
+	assert.Empty(t, entry.Answers)
+}
@@ -0,0 +1,296 @@
+package parser
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	pt "github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+)
+
+//proxyLogFormats holds the squid logformat definitions of the access log
+//formats which may be named instead of written out
+var proxyLogFormats = map[string]string{
+	"squid":    `%ts.%03tu %6tr %>a %Ss/%03>Hs %<st %rm %ru %[un %Sh/%<a %mt`,
+	"common":   `%>a %[ui %[un [%tl] "%rm %ru HTTP/%rv" %>Hs %<st %Ss:%Sh`,
+	"combined": `%>a %[ui %[un [%tl] "%rm %ru HTTP/%rv" %>Hs %<st "%{Referer}>h" "%{User-Agent}>h" %Ss:%Sh`,
+}
+
+//proxyLogCode matches a format code in a squid logformat definition. The
+//modifiers and field width are matched but not used.
+var proxyLogCode = regexp.MustCompile(`^%[-'#\[]*[0-9]*(?:\.[0-9]+)?(\{[^}]*\})?([<>]{0,2}[A-Za-z]+)`)
+
+type (
+	//ProxyLogFormat parses the lines of a proxy access log written in a
+	//squid logformat definition
+	ProxyLogFormat struct {
+		pattern *regexp.Regexp
+		codes   []string
+	}
+
+	//ProxyImporter imports web proxy access logs as http entries. The
+	//entries record the client which made each request and the host it
+	//requested, rather than the proxy, so beaconing through the proxy may
+	//be found.
+	ProxyImporter struct {
+		fs             *FSImporter
+		targetDatabase string
+		sensor         string
+		format         *ProxyLogFormat
+	}
+)
+
+//NewProxyLogFormat compiles a squid logformat definition or the name of a
+//predefined format: squid, common, or combined. The format must record
+//the time of each request, the client address, and the requested URL.
+func NewProxyLogFormat(definition string) (*ProxyLogFormat, error) {
+	if predefined, ok := proxyLogFormats[definition]; ok {
+		definition = predefined
+	}
+
+	format := &ProxyLogFormat{}
+	pattern := "^\\s*"
+	for len(definition) > 0 {
+		if definition[0] == ' ' || definition[0] == '\t' {
+			pattern += "\\s+"
+			definition = strings.TrimLeft(definition, " \t")
+			continue
+		}
+		if strings.HasPrefix(definition, "%%") {
+			pattern += "%"
+			definition = definition[2:]
+			continue
+		}
+
+		match := proxyLogCode.FindStringSubmatch(definition)
+		if match == nil {
+			pattern += regexp.QuoteMeta(definition[:1])
+			definition = definition[1:]
+			continue
+		}
+		definition = definition[len(match[0]):]
+
+		code := match[2]
+		if match[1] != "" {
+			code = match[1] + code
+		}
+		format.codes = append(format.codes, code)
+
+		//values wrapped in quotes or brackets may hold spaces
+		switch {
+		case strings.HasPrefix(definition, `"`):
+			pattern += `([^"]*)`
+		case strings.HasPrefix(definition, "]"):
+			pattern += `([^\]]*)`
+		default:
+			pattern += `(\S*?)`
+		}
+	}
+	pattern += "\\s*$"
+
+	var err error
+	format.pattern, err = regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	has := make(map[string]bool)
+	for _, code := range format.codes {
+		has[code] = true
+	}
+	if !has["ts"] && !has["tl"] {
+		return nil, fmt.Errorf("the log format must record the time with %%ts or %%tl")
+	}
+	if !has[">a"] {
+		return nil, fmt.Errorf("the log format must record the client address with %%>a")
+	}
+	if !has["ru"] {
+		return nil, fmt.Errorf("the log format must record the requested URL with %%ru")
+	}
+	return format, nil
+}
+
+//parse converts a line of an access log into an http entry. Nil is
+//returned if the line does not match the format.
+func (f *ProxyLogFormat) parse(line string) *pt.HTTP {
+	match := f.pattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+
+	values := make(map[string]string)
+	for i, code := range f.codes {
+		value := match[i+1]
+		if value != "-" && value != "" {
+			values[code] = value
+		}
+	}
+
+	entry := &pt.HTTP{
+		UID:       proxyUID(line),
+		Source:    values[">a"],
+		Method:    values["rm"],
+		Version:   values["rv"],
+		Referrer:  values["{Referer}>h"],
+		UserAgent: values["{User-Agent}>h"],
+		UserName:  values["un"],
+	}
+	if mimeType, ok := values["mt"]; ok {
+		entry.RespMimeTypes = []string{mimeType}
+	}
+
+	if timestamp, ok := values["ts"]; ok {
+		secs, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil
+		}
+		entry.TimeStamp = secs
+	} else {
+		logged, err := time.Parse("02/Jan/2006:15:04:05 -0700", values["tl"])
+		if err != nil {
+			return nil
+		}
+		entry.TimeStamp = logged.Unix()
+	}
+
+	//squid records the server as a hostname when no address was used,
+	//such as when the response was cached
+	if server := net.ParseIP(values["<a"]); server != nil {
+		entry.Destination = server.String()
+	}
+	entry.SourcePort, _ = strconv.Atoi(values[">p"])
+	entry.DestinationPort, _ = strconv.Atoi(values["<p"])
+
+	status := values[">Hs"]
+	if status == "" {
+		status = values["Hs"]
+	}
+	entry.StatusCode, _ = strconv.ParseInt(status, 10, 64)
+	entry.ReqLen, _ = strconv.ParseInt(values[">st"], 10, 64)
+	entry.RespLen, _ = strconv.ParseInt(values["<st"], 10, 64)
+
+	host, port, uri := splitProxyURL(entry.Method, values["ru"])
+	entry.Host = host
+	entry.URI = uri
+	if header, ok := values["{Host}>h"]; ok && entry.Host == "" {
+		entry.Host = header
+	}
+	if entry.DestinationPort == 0 {
+		entry.DestinationPort = port
+	}
+
+	if entry.Source == "" || entry.Host == "" {
+		return nil
+	}
+	return entry
+}
+
+//splitProxyURL finds the host, port, and path requested in a proxy log.
+//CONNECT requests name the host and port rather than a URL.
+func splitProxyURL(method string, requested string) (string, int, string) {
+	if method == "CONNECT" || !strings.Contains(requested, "://") {
+		host, portStr, err := net.SplitHostPort(requested)
+		if err != nil {
+			return "", 0, requested
+		}
+		port, _ := strconv.Atoi(portStr)
+		return strings.ToLower(host), port, requested
+	}
+
+	parsed, err := url.Parse(requested)
+	if err != nil {
+		return "", 0, requested
+	}
+	port, _ := strconv.Atoi(parsed.Port())
+	if port == 0 {
+		switch parsed.Scheme {
+		case "http":
+			port = 80
+		case "https":
+			port = 443
+		case "ftp":
+			port = 21
+		}
+	}
+
+	uri := parsed.RequestURI()
+	if parsed.Opaque != "" {
+		uri = requested
+	}
+	return strings.ToLower(parsed.Hostname()), port, uri
+}
+
+//proxyUID creates a unique id for a request from the line it was read from
+func proxyUID(line string) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(line))
+	return "P" + strconv.FormatUint(hash.Sum64(), 36)
+}
+
+//NewProxyImporter creates a ProxyImporter which imports access logs
+//written in the given format into the target database and tags the
+//entries with the sensor name. The importer's time window is applied to
+//every entry.
+func NewProxyImporter(fs *FSImporter, targetDatabase string, sensor string,
+	format *ProxyLogFormat) *ProxyImporter {
+	return &ProxyImporter{
+		fs:             fs,
+		targetDatabase: targetDatabase,
+		sensor:         sensor,
+		format:         format,
+	}
+}
+
+//ImportFiles imports the given access logs, which may be gzip compressed.
+//Files which have already been imported into the target database are
+//skipped.
+func (p *ProxyImporter) ImportFiles(files []string, datastore Datastore) {
+	collection := p.fs.res.Config.T.Structure.HTTPTable
+	p.fs.importRecordFiles(files, p.targetDatabase, collection, p.sensor, datastore, p.importFile)
+}
+
+//importFile stores the requests in an access log. The number of entries
+//stored is returned.
+func (p *ProxyImporter) importFile(path string, filter *recordFilter) (int, error) {
+	reader, closer, err := openRecordFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer closer.Close()
+
+	collection := p.fs.res.Config.T.Structure.HTTPTable
+	count := 0
+	malformed := 0
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) > 0 && line[0] != '#' {
+			entry := p.format.parse(line)
+			if entry != nil {
+				filter.store(entry, p.sensor, p.targetDatabase, collection)
+				count++
+			} else {
+				malformed++
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+
+	p.fs.res.Log.WithFields(log.Fields{
+		"path":      path,
+		"malformed": malformed,
+	}).Info("Finished converting proxy log")
+	return count, nil
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxySquidFormat(t *testing.T) {
+	format, err := NewProxyLogFormat("squid")
+	require.Nil(t, err)
+
+	entry := format.parse("1286536309.586    921 192.168.0.68 TCP_MISS/200 507 POST " +
+		"http://rcv.example.com:8080/beacon?id=1 - HIER_DIRECT/203.0.113.1 application/xml")
+	require.NotNil(t, entry)
+	assert.Equal(t, int64(1286536309), entry.TimeStamp)
+	assert.Equal(t, "192.168.0.68", entry.Source)
+	assert.Equal(t, "203.0.113.1", entry.Destination)
+	assert.Equal(t, 8080, entry.DestinationPort)
+	assert.Equal(t, "rcv.example.com", entry.Host)
+	assert.Equal(t, "/beacon?id=1", entry.URI)
+	assert.Equal(t, "POST", entry.Method)
+	assert.Equal(t, int64(200), entry.StatusCode)
+	assert.Equal(t, int64(507), entry.RespLen)
+	assert.Empty(t, entry.UserName)
+	assert.Equal(t, []string{"application/xml"}, entry.RespMimeTypes)
+	assert.Equal(t, "P", entry.UID[:1])
+
+	//cached responses and tunnels do not record a server address
+	entry = format.parse("1286536310.000      5 192.168.0.68 TCP_TUNNEL/200 4500 CONNECT " +
+		"Secure.Example.com:443 alice HIER_NONE/- -")
+	require.NotNil(t, entry)
+	assert.Equal(t, "secure.example.com", entry.Host)
+	assert.Equal(t, 443, entry.DestinationPort)
+	assert.Empty(t, entry.Destination)
+	assert.Equal(t, "alice", entry.UserName)
+
+	assert.Nil(t, format.parse("not a squid log line"))
+}
+
+func TestProxyCombinedFormat(t *testing.T) {
+	format, err := NewProxyLogFormat("combined")
+	require.Nil(t, err)
+
+	entry := format.parse(`10.1.1.5 - bob [01/Mar/2019:12:00:00 +0000] "GET http://example.com/index.html HTTP/1.1" ` +
+		`200 1024 "http://example.com/" "Mozilla/5.0 (X11; Linux x86_64)" TCP_MISS:HIER_DIRECT`)
+	require.NotNil(t, entry)
+	assert.Equal(t, int64(1551441600), entry.TimeStamp)
+	assert.Equal(t, "10.1.1.5", entry.Source)
+	assert.Equal(t, "example.com", entry.Host)
+	assert.Equal(t, 80, entry.DestinationPort)
+	assert.Equal(t, "/index.html", entry.URI)
+	assert.Equal(t, "1.1", entry.Version)
+	assert.Equal(t, "bob", entry.UserName)
+	assert.Equal(t, "http://example.com/", entry.Referrer)
+	assert.Equal(t, "Mozilla/5.0 (X11; Linux x86_64)", entry.UserAgent)
+}
+
+func TestProxyCustomFormat(t *testing.T) {
+	format, err := NewProxyLogFormat(`%ts %>a:%>p %>st %<st %rm %ru "%{User-Agent}>h"`)
+	require.Nil(t, err)
+
+	entry := format.parse(`1551441600 10.1.1.5:51000 300 2000 GET https://example.org/a "curl/7.58.0"`)
+	require.NotNil(t, entry)
+	assert.Equal(t, 51000, entry.SourcePort)
+	assert.Equal(t, 443, entry.DestinationPort)
+	assert.Equal(t, int64(300), entry.ReqLen)
+	assert.Equal(t, int64(2000), entry.RespLen)
+	assert.Equal(t, "curl/7.58.0", entry.UserAgent)
+
+	_, err = NewProxyLogFormat(`%>a %ru`)
+	assert.NotNil(t, err)
+	_, err = NewProxyLogFormat(`%ts %ru`)
+	assert.NotNil(t, err)
+	_, err = NewProxyLogFormat(`%ts %>a`)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/activecm/rita/analysis/quality"
+	"github.com/activecm/rita/config"
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+)
+
+//recordFileReader stores the entries held in a file which is not a bro
+//log using the record filter. The number of entries read is returned.
+type recordFileReader func(path string, filter *recordFilter) (int, error)
+
+//importRecordFiles imports files which are not bro logs into the target
+//database. Files which have already been imported into the target
+//database are skipped. The collection and sensor are recorded with the
+//files in the MetaDB.
+func (fs *FSImporter) importRecordFiles(files []string, targetDB string,
+	collection string, sensor string, datastore Datastore, readFile recordFileReader) {
+	start := time.Now()
+	logger := fs.res.Log
+
+	var indexedFiles []*fpt.IndexedFile
+	for _, file := range files {
+		indexedFile, err := indexRecordFile(file, targetDB, collection, sensor, &fs.res.Config.S.Bro)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"path":  file,
+				"error": err.Error(),
+			}).Error("Could not read file")
+			continue
+		}
+		indexedFiles = append(indexedFiles, indexedFile)
+	}
+
+	indexedFiles = removeOldFilesFromIndex(indexedFiles, fs.window, fs.res.MetaDB, logger)
+	if len(indexedFiles) == 0 {
+		fmt.Println("\t[-] No new files to import")
+		logger.Info("Finished importing files. No new files were found.")
+		return
+	}
+
+	filter := fs.newRecordFilter(datastore)
+	for _, indexedFile := range indexedFiles {
+		fmt.Println("\t[-] Importing " + indexedFile.Path)
+		filter.file = indexedFile
+		count, err := readFile(indexedFile.Path, filter)
+		filter.file = nil
+		fields := log.Fields{
+			"path":    indexedFile.Path,
+			"entries": count,
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+			logger.WithFields(fields).Error("Could not finish reading file")
+		} else {
+			logger.WithFields(fields).Info("Finished reading file")
+		}
+		indexedFile.ParseTime = time.Now()
+	}
+
+	//the files are recorded first so the data quality report can check them
+	updateFilesIndex(indexedFiles, fs.res.MetaDB, logger)
+	fs.finishRecords(filter, targetDB, datastore)
+
+	progTime := time.Now()
+	logger.WithFields(
+		log.Fields{
+			"current_time": progTime.Format(util.TimeFormat),
+			"total_time":   progTime.Sub(start).String(),
+		},
+	).Info("Finished importing files")
+}
+
+//finishRecords waits for the stored entries to be written, moves the
+//unique connections which exceeded the connection limit into the strobe
+//collection, indexes the data, and rebuilds the data quality report
+func (fs *FSImporter) finishRecords(filter *recordFilter, targetDB string, datastore Datastore) {
+	datastore.Flush()
+	fs.bulkRemoveHugeUconns(targetDB, filter)
+	fmt.Println("\t[-] Indexing log entries. This may take a while.")
+	datastore.Index()
+	quality.BuildQualityReport(fs.res, targetDB)
+}
+
+//indexRecordFile gathers the details used to track which files have been
+//imported and the clock offset configured for the file
+func indexRecordFile(path string, targetDB string, collection string,
+	sensor string, broConfig *config.BroStaticCfg) (*fpt.IndexedFile, error) {
+	fileHandle, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fileHandle.Close()
+
+	fInfo, err := fileHandle.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := getFileHash(fileHandle, fInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+
+	offset, _ := configuredClockOffset(absPath, sensor, broConfig)
+	return &fpt.IndexedFile{
+		ClockOffset:      offset,
+		Path:             absPath,
+		Length:           fInfo.Size(),
+		ModTime:          fInfo.ModTime(),
+		Hash:             hash,
+		TargetCollection: collection,
+		TargetDatabase:   targetDB,
+		Sensor:           sensor,
+	}, nil
+}
+
+//openRecordFile opens a file for reading, decompressing it if it is
+//gzip compressed. The returned closer must be closed once reading is done.
+func openRecordFile(path string) (*bufio.Reader, io.Closer, error) {
+	fileHandle, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReader(fileHandle)
+	magic, _ := reader.Peek(2)
+	if len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		return reader, fileHandle, nil
+	}
+
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		fileHandle.Close()
+		return nil, nil, err
+	}
+	return bufio.NewReader(gzipReader), fileHandle, nil
+}
@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"net"
+	"reflect"
+	"sync"
+
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	pt "github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/util"
+)
+
+//recordFilter applies the import filters to parsed entries before handing
+//them to a datastore. It also counts the conn entries for each unique
+//connection so unique connections with more than the connection limit of
+//entries can be moved to the strobe collection once the import finishes.
+type recordFilter struct {
+	fs         *FSImporter
+	datastore  Datastore
+	mutex      *sync.Mutex
+	connMap    map[uconnPair]int
+	hugeUconns []uconnPair
+	// connSensors lists the sensors which recorded each unique connection
+	connSensors map[uconnPair][]string
+	// file is the record file being read, if any. Its clock offset is
+	// applied to the entries read from it, and the time range of the
+	// entries is recorded on it.
+	file *fpt.IndexedFile
+}
+
+//newRecordFilter creates a recordFilter which stores entries in the datastore
+func (fs *FSImporter) newRecordFilter(datastore Datastore) *recordFilter {
+	return &recordFilter{
+		fs:          fs,
+		datastore:   datastore,
+		mutex:       new(sync.Mutex),
+		connMap:     make(map[uconnPair]int),
+		connSensors: make(map[uconnPair][]string),
+	}
+}
+
+//store tags the entry with the sensor which recorded it and stores it in
+//the target database and collection unless it is filtered out.
+//store is safe to call from multiple goroutines.
+func (r *recordFilter) store(data pt.BroData, sensor string,
+	targetDB string, targetCollection string) {
+	fs := r.fs
+
+	//bro logs have the clock offset applied as they are parsed
+	if r.file != nil {
+		shiftTimestamp(data, r.file.ClockOffset)
+	}
+
+	ts, hasTs := getTimestamp(data)
+	if hasTs && r.file != nil {
+		r.mutex.Lock()
+		r.file.ObserveTimestamp(ts)
+		r.mutex.Unlock()
+	}
+
+	//drop entries logged outside of the import time window
+	if hasTs && !fs.window.ContainsTimestamp(ts) {
+		return
+	}
+
+	//drop entries an earlier import of the file already stored
+	if hasTs && r.file != nil && r.file.WasImported(ts) {
+		return
+	}
+
+	//tag the entry with the sensor that recorded it
+	setSensor(data, sensor)
+
+	// We do not limit any of the other log types
+	if targetCollection != fs.res.Config.T.Structure.ConnTable {
+		r.datastore.Store(&ImportedData{
+			BroData:          data,
+			TargetDatabase:   targetDB,
+			TargetCollection: targetCollection,
+		})
+		return
+	}
+
+	// The maximum number of conns that will be stored
+	// We need to move this somewhere where the importer & analyzer can both access it
+	connLimit := fs.res.Config.S.Strobe.ConnectionLimit
+
+	// if target collection is the conns table we want to limit
+	// conns entries to unique connection pairs with fewer than connLimit
+	// records
+	parseConn := reflect.ValueOf(data).Elem()
+
+	var uconn uconnPair
+
+	// Use reflection to access the conn entry's fields. At this point
+	// we know parseConn is a "conn" instance, but the code
+	// assumes a generic "BroType" interface.
+	uconn.src = parseConn.FieldByName("Source").Interface().(string)
+	uconn.dst = parseConn.FieldByName("Destination").Interface().(string)
+
+	// Run conn pair through filter to filter out certain connections
+	ignore := fs.filterConnPair(uconn.src, uconn.dst)
+
+	// If connection pair is subject to filtering, drop it
+	if ignore {
+		return
+	}
+
+	// Override LocalOrigin and LocalResponse fields based on InternalSubnets setting
+	// Changes to parseConn are also made in the data variable
+	parseConn.FieldByName("LocalOrigin").SetBool(util.ContainsIP(fs.GetInternalSubnets(), net.ParseIP(uconn.src)))
+	parseConn.FieldByName("LocalResponse").SetBool(util.ContainsIP(fs.GetInternalSubnets(), net.ParseIP(uconn.dst)))
+
+	// Safely store the number of conns for this uconn
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.connMap[uconn] = r.connMap[uconn] + 1
+	connCount := r.connMap[uconn]
+	r.addSensor(uconn, sensor)
+
+	// Do not store more than the connLimit
+	if connCount < connLimit {
+		r.datastore.Store(&ImportedData{
+			BroData:          data,
+			TargetDatabase:   targetDB,
+			TargetCollection: targetCollection,
+		})
+	} else if connCount == connLimit {
+		// Once we know a uconn has passed the connLimit not only
+		// do we want to avoid storing any more, but we want to
+		// remove all entries already added. The first time we pass
+		// the limit put an entry in hugeUconns in order
+		// to run a bulk delete later.
+		r.hugeUconns = append(r.hugeUconns, uconn)
+	}
+}
+
+//addSensor records that the sensor saw the unique connection. The caller
+//must hold the mutex.
+func (r *recordFilter) addSensor(uconn uconnPair, sensor string) {
+	if sensor == "" {
+		return
+	}
+	for _, known := range r.connSensors[uconn] {
+		if known == sensor {
+			return
+		}
+	}
+	r.connSensors[uconn] = append(r.connSensors[uconn], sensor)
+}
@@ -0,0 +1,486 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pt "github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+)
+
+//suricataTimeFormat is the format of timestamps in eve.json
+const suricataTimeFormat = "2006-01-02T15:04:05.999999-0700"
+
+//suricataDNSTimeout is how long a DNS transaction is held open waiting for
+//answers before it is stored
+const suricataDNSTimeout = 30 * time.Second
+
+type (
+	//SuricataImporter imports Suricata eve.json logs. Flow, dns, http, and
+	//tls events are stored as conn, dns, http, and ssl entries so they may
+	//be analyzed like bro logs.
+	SuricataImporter struct {
+		fs             *FSImporter
+		targetDatabase string
+		sensor         string
+	}
+
+	//eveConverter converts eve.json events into bro entries. DNS queries
+	//and answers are logged as separate events by Suricata, so they are
+	//held until the transaction is complete.
+	eveConverter struct {
+		sensor     string
+		store      func(data pt.BroData, sensor string)
+		pendingDNS map[eveDNSKey]*eveDNSTransaction
+		latest     time.Time
+		lastFlush  time.Time
+		skipped    map[string]int
+	}
+
+	//eveDNSKey identifies a DNS transaction
+	eveDNSKey struct {
+		flowID string
+		id     int64
+		query  string
+	}
+
+	//eveDNSTransaction holds a DNS entry until its answers have arrived
+	eveDNSTransaction struct {
+		entry  *pt.DNS
+		sensor string
+		seen   time.Time
+		query  time.Time
+	}
+
+	//eveEvent holds the fields RITA uses from an eve.json event
+	eveEvent struct {
+		Timestamp string      `json:"timestamp"`
+		FlowID    json.Number `json:"flow_id"`
+		EventType string      `json:"event_type"`
+		SrcIP     string      `json:"src_ip"`
+		SrcPort   int         `json:"src_port"`
+		DestIP    string      `json:"dest_ip"`
+		DestPort  int         `json:"dest_port"`
+		Proto     string      `json:"proto"`
+		AppProto  string      `json:"app_proto"`
+		ICMPType  int         `json:"icmp_type"`
+		ICMPCode  int         `json:"icmp_code"`
+		Host      string      `json:"host"`
+		TxID      *int64      `json:"tx_id"`
+		Flow      *eveFlow    `json:"flow"`
+		TCP       *eveTCP     `json:"tcp"`
+		DNS       *eveDNS     `json:"dns"`
+		HTTP      *eveHTTP    `json:"http"`
+		TLS       *eveTLS     `json:"tls"`
+	}
+
+	//eveFlow holds the counters of a flow event
+	eveFlow struct {
+		PktsToServer  int64  `json:"pkts_toserver"`
+		PktsToClient  int64  `json:"pkts_toclient"`
+		BytesToServer int64  `json:"bytes_toserver"`
+		BytesToClient int64  `json:"bytes_toclient"`
+		Start         string `json:"start"`
+		End           string `json:"end"`
+	}
+
+	//eveTCP holds the TCP details of a flow event
+	eveTCP struct {
+		TCPFlags string `json:"tcp_flags"`
+	}
+
+	//eveDNS holds a DNS query or answer. Version 1 answers are logged one
+	//record at a time while version 2 answers hold every record.
+	eveDNS struct {
+		Type    string              `json:"type"`
+		ID      int64               `json:"id"`
+		RRName  string              `json:"rrname"`
+		RRType  string              `json:"rrtype"`
+		RCode   string              `json:"rcode"`
+		Flags   string              `json:"flags"`
+		AA      bool                `json:"aa"`
+		TC      bool                `json:"tc"`
+		RD      bool                `json:"rd"`
+		RA      bool                `json:"ra"`
+		RData   string              `json:"rdata"`
+		TTL     *float64            `json:"ttl"`
+		Answers []eveDNSAnswer      `json:"answers"`
+		Grouped map[string][]string `json:"grouped"`
+	}
+
+	//eveDNSAnswer holds a resource record in a version 2 answer
+	eveDNSAnswer struct {
+		RData string  `json:"rdata"`
+		TTL   float64 `json:"ttl"`
+	}
+
+	//eveHTTP holds the details of an HTTP transaction
+	eveHTTP struct {
+		Hostname    string `json:"hostname"`
+		URL         string `json:"url"`
+		UserAgent   string `json:"http_user_agent"`
+		ContentType string `json:"http_content_type"`
+		Referrer    string `json:"http_refer"`
+		Method      string `json:"http_method"`
+		Protocol    string `json:"protocol"`
+		Status      int64  `json:"status"`
+		Length      int64  `json:"length"`
+	}
+
+	//eveTLS holds the details of a TLS handshake
+	eveTLS struct {
+		Subject  string `json:"subject"`
+		IssuerDN string `json:"issuerdn"`
+		SNI      string `json:"sni"`
+		Version  string `json:"version"`
+	}
+)
+
+//NewSuricataImporter creates a SuricataImporter which imports events into
+//the target database. Events are tagged with the sensor name, or with the
+//host recorded in the event if the sensor name is empty. The importer's
+//filters and time window are applied to every event.
+func NewSuricataImporter(fs *FSImporter, targetDatabase string, sensor string) *SuricataImporter {
+	return &SuricataImporter{
+		fs:             fs,
+		targetDatabase: targetDatabase,
+		sensor:         sensor,
+	}
+}
+
+//ImportFiles imports the events held in the given eve.json files, which
+//may be gzip compressed. Files which have already been imported into the
+//target database are skipped.
+func (s *SuricataImporter) ImportFiles(files []string, datastore Datastore) {
+	s.fs.importRecordFiles(files, s.targetDatabase, "", s.sensor, datastore, s.importFile)
+}
+
+//importFile stores the events in an eve.json file. The number of entries
+//stored is returned.
+func (s *SuricataImporter) importFile(path string, filter *recordFilter) (int, error) {
+	reader, closer, err := openRecordFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer closer.Close()
+
+	structure := &s.fs.res.Config.T.Structure
+	count := 0
+	converter := newEveConverter(s.sensor, func(data pt.BroData, sensor string) {
+		filter.store(data, sensor, s.targetDatabase, data.TargetCollection(structure))
+		count++
+	})
+
+	malformed := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && !converter.convert(line) {
+			malformed++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			converter.flushDNS(true)
+			return count, err
+		}
+	}
+	converter.flushDNS(true)
+
+	fields := log.Fields{"path": path, "malformed": malformed}
+	for eventType, skipped := range converter.skipped {
+		fields["skipped_"+eventType] = skipped
+	}
+	s.fs.res.Log.WithFields(fields).Info("Finished converting Suricata events")
+	return count, nil
+}
+
+//newEveConverter creates an eveConverter which passes the converted
+//entries to store
+func newEveConverter(sensor string, store func(data pt.BroData, sensor string)) *eveConverter {
+	return &eveConverter{
+		sensor:     sensor,
+		store:      store,
+		pendingDNS: make(map[eveDNSKey]*eveDNSTransaction),
+		skipped:    make(map[string]int),
+	}
+}
+
+//convert converts a line of eve.json. False is returned if the line could
+//not be parsed.
+func (c *eveConverter) convert(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return true
+	}
+
+	var event eveEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return false
+	}
+	ts, err := time.Parse(suricataTimeFormat, event.Timestamp)
+	if err != nil {
+		return false
+	}
+
+	if ts.After(c.latest) {
+		c.latest = ts
+	}
+
+	sensor := c.sensor
+	if sensor == "" {
+		sensor = event.Host
+	}
+
+	switch {
+	case event.EventType == "flow" && event.Flow != nil:
+		c.store(eveToConn(&event, ts), sensor)
+	case event.EventType == "http" && event.HTTP != nil:
+		c.store(eveToHTTP(&event, ts), sensor)
+	case event.EventType == "tls" && event.TLS != nil:
+		c.store(eveToSSL(&event, ts), sensor)
+	case event.EventType == "dns" && event.DNS != nil:
+		c.addDNS(&event, ts, sensor)
+	default:
+		c.skipped[event.EventType]++
+	}
+
+	if c.latest.Sub(c.lastFlush) >= time.Second {
+		c.flushDNS(false)
+		c.lastFlush = c.latest
+	}
+	return true
+}
+
+//addDNS adds a query or answer to its DNS transaction
+func (c *eveConverter) addDNS(event *eveEvent, ts time.Time, sensor string) {
+	dns := event.DNS
+	key := eveDNSKey{
+		flowID: string(event.FlowID),
+		id:     dns.ID,
+		query:  strings.ToLower(dns.RRName),
+	}
+
+	transaction, ok := c.pendingDNS[key]
+	if !ok {
+		entry := &pt.DNS{
+			TimeStamp:       ts.Unix(),
+			UID:             eveUID(event.FlowID),
+			Source:          event.SrcIP,
+			SourcePort:      event.SrcPort,
+			Destination:     event.DestIP,
+			DestinationPort: event.DestPort,
+			Proto:           eveProto(event.Proto),
+			TransID:         dns.ID,
+			Query:           dns.RRName,
+			QClass:          1,
+			QClassName:      "C_INTERNET",
+			QTypeName:       dns.RRType,
+			QType:           dnsQueryType(dns.RRType),
+		}
+		//answers are logged in the direction they were sent
+		if dns.Type != "query" && event.SrcPort == 53 && event.DestPort != 53 {
+			entry.Source, entry.Destination = event.DestIP, event.SrcIP
+			entry.SourcePort, entry.DestinationPort = event.DestPort, event.SrcPort
+		}
+		transaction = &eveDNSTransaction{entry: entry, sensor: sensor}
+		c.pendingDNS[key] = transaction
+	}
+	transaction.seen = ts
+
+	entry := transaction.entry
+	if dns.Type == "query" {
+		transaction.query = ts
+		entry.TimeStamp = ts.Unix()
+		entry.RD = entry.RD || dns.RD
+		return
+	}
+
+	if !transaction.query.IsZero() && ts.After(transaction.query) {
+		entry.RTT = ts.Sub(transaction.query).Seconds()
+	}
+	if dns.RCode != "" {
+		entry.RCodeName = dns.RCode
+		entry.RCode = dnsResponseCodes[dns.RCode]
+		entry.Rejected = dns.RCode == "REFUSED"
+	}
+	if flags, err := strconv.ParseUint(dns.Flags, 16, 16); err == nil {
+		entry.AA = flags&0x0400 != 0
+		entry.TC = flags&0x0200 != 0
+		entry.RD = flags&0x0100 != 0
+		entry.RA = flags&0x0080 != 0
+	} else {
+		entry.AA = entry.AA || dns.AA
+		entry.TC = entry.TC || dns.TC
+		entry.RD = entry.RD || dns.RD
+		entry.RA = entry.RA || dns.RA
+	}
+
+	switch {
+	case len(dns.Answers) > 0:
+		for _, answer := range dns.Answers {
+			entry.Answers = append(entry.Answers, answer.RData)
+			entry.TTLs = append(entry.TTLs, answer.TTL)
+		}
+	case len(dns.Grouped) > 0:
+		//grouped answers do not record TTLs
+		rrTypes := make([]string, 0, len(dns.Grouped))
+		for rrType := range dns.Grouped {
+			rrTypes = append(rrTypes, rrType)
+		}
+		sort.Strings(rrTypes)
+		for _, rrType := range rrTypes {
+			entry.Answers = append(entry.Answers, dns.Grouped[rrType]...)
+		}
+	case dns.RData != "":
+		entry.Answers = append(entry.Answers, dns.RData)
+		if dns.TTL != nil {
+			entry.TTLs = append(entry.TTLs, *dns.TTL)
+		}
+	}
+}
+
+//flushDNS stores the DNS transactions which have not been updated within
+//the DNS timeout, or every transaction if all is set
+func (c *eveConverter) flushDNS(all bool) {
+	cutoff := c.latest.Add(-suricataDNSTimeout)
+	for key, transaction := range c.pendingDNS {
+		if all || transaction.seen.Before(cutoff) {
+			c.store(transaction.entry, transaction.sensor)
+			delete(c.pendingDNS, key)
+		}
+	}
+}
+
+//eveToConn converts a flow event into a conn entry. Suricata counts whole
+//packets, so the payload byte counts are filled with the packet byte
+//counts as well.
+func eveToConn(event *eveEvent, ts time.Time) *pt.Conn {
+	flow := event.Flow
+	conn := &pt.Conn{
+		TimeStamp:       ts.Unix(),
+		UID:             eveUID(event.FlowID),
+		Source:          event.SrcIP,
+		SourcePort:      event.SrcPort,
+		Destination:     event.DestIP,
+		DestinationPort: event.DestPort,
+		Proto:           eveProto(event.Proto),
+		Service:         eveService(event.AppProto),
+		OrigBytes:       flow.BytesToServer,
+		RespBytes:       flow.BytesToClient,
+		OrigPkts:        flow.PktsToServer,
+		OrigIPBytes:     flow.BytesToServer,
+		RespPkts:        flow.PktsToClient,
+		RespIPBytes:     flow.BytesToClient,
+	}
+
+	start, err := time.Parse(suricataTimeFormat, flow.Start)
+	if err == nil {
+		conn.TimeStamp = start.Unix()
+		if end, err := time.Parse(suricataTimeFormat, flow.End); err == nil && end.After(start) {
+			conn.Duration = end.Sub(start).Seconds()
+		}
+	}
+
+	var tcpFlags uint64
+	if event.TCP != nil {
+		tcpFlags, _ = strconv.ParseUint(event.TCP.TCPFlags, 16, 8)
+	}
+	conn.ConnState = approximateConnState(conn.Proto, uint8(tcpFlags), flow.PktsToClient > 0)
+
+	//bro logs the ICMP type and code as the source and destination ports
+	if conn.Proto == "icmp" {
+		conn.SourcePort = event.ICMPType
+		conn.DestinationPort = event.ICMPCode
+	}
+	return conn
+}
+
+//eveToHTTP converts an http event into an http entry
+func eveToHTTP(event *eveEvent, ts time.Time) *pt.HTTP {
+	http := event.HTTP
+	entry := &pt.HTTP{
+		TimeStamp:       ts.Unix(),
+		UID:             eveUID(event.FlowID),
+		Source:          event.SrcIP,
+		SourcePort:      event.SrcPort,
+		Destination:     event.DestIP,
+		DestinationPort: event.DestPort,
+		Version:         strings.TrimPrefix(http.Protocol, "HTTP/"),
+		Method:          http.Method,
+		Host:            http.Hostname,
+		URI:             http.URL,
+		Referrer:        http.Referrer,
+		UserAgent:       http.UserAgent,
+		RespLen:         http.Length,
+		StatusCode:      http.Status,
+	}
+	if event.TxID != nil {
+		entry.TransDepth = *event.TxID + 1
+	}
+	if http.ContentType != "" {
+		entry.RespMimeTypes = []string{http.ContentType}
+	}
+	return entry
+}
+
+//eveUID creates a bro style unique id from a Suricata flow id so entries
+//from the same flow share an id
+func eveUID(flowID json.Number) string {
+	id, err := strconv.ParseUint(string(flowID), 10, 64)
+	if err != nil {
+		return ""
+	}
+	return "S" + strconv.FormatUint(id, 36)
+}
+
+//eveProto maps Suricata's protocol names to the transport names bro uses
+func eveProto(proto string) string {
+	switch strings.ToUpper(proto) {
+	case "TCP":
+		return "tcp"
+	case "UDP":
+		return "udp"
+	case "ICMP", "IPV6-ICMP":
+		return "icmp"
+	}
+	return "unknown_transport"
+}
+
+//eveService maps Suricata's application protocol names to bro's service names
+func eveService(appProto string) string {
+	switch appProto {
+	case "", "failed", "unknown":
+		return ""
+	case "tls":
+		return "ssl"
+	}
+	return appProto
+}
+
+//eveToSSL converts a tls event into an ssl entry. Suricata logs the
+//version as "TLS 1.2" while bro logs it as "TLSv12".
+func eveToSSL(event *eveEvent, ts time.Time) *pt.SSL {
+	tls := event.TLS
+	version := tls.Version
+	if fields := strings.Fields(version); len(fields) == 2 && fields[0] != "UNDETERMINED" {
+		version = fields[0] + "v" + strings.Replace(fields[1], ".", "", -1)
+	}
+	return &pt.SSL{
+		TimeStamp:       ts.Unix(),
+		UID:             eveUID(event.FlowID),
+		Source:          event.SrcIP,
+		SourcePort:      event.SrcPort,
+		Destination:     event.DestIP,
+		DestinationPort: event.DestPort,
+		Version:         version,
+		ServerName:      tls.SNI,
+		Subject:         tls.Subject,
+		Issuer:          tls.IssuerDN,
+	}
+}
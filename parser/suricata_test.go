@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"sort"
+	"testing"
+
+	pt "github.com/activecm/rita/parser/parsetypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//convertEve converts eve.json lines and returns the stored entries
+func convertEve(t *testing.T, sensor string, lines ...string) ([]pt.BroData, []string) {
+	var entries []pt.BroData
+	var sensors []string
+	converter := newEveConverter(sensor, func(data pt.BroData, sensor string) {
+		entries = append(entries, data)
+		sensors = append(sensors, sensor)
+	})
+	for _, line := range lines {
+		require.True(t, converter.convert([]byte(line)), line)
+	}
+	converter.flushDNS(true)
+	return entries, sensors
+}
+
+func TestEveFlowToConn(t *testing.T) {
+	entries, sensors := convertEve(t, "",
+		`{"timestamp":"2019-03-01T12:00:10.500000+0000","flow_id":1234567890123,"event_type":"flow",`+
+			`"src_ip":"10.0.0.1","src_port":50000,"dest_ip":"192.0.2.1","dest_port":443,"proto":"TCP",`+
+			`"app_proto":"tls","host":"sensor1","flow":{"pkts_toserver":10,"pkts_toclient":8,`+
+			`"bytes_toserver":1500,"bytes_toclient":9000,"start":"2019-03-01T12:00:00.000000+0000",`+
+			`"end":"2019-03-01T12:00:02.500000+0000","state":"closed"},"tcp":{"tcp_flags":"1b"}}`,
+		`{"timestamp":"2019-03-01T12:00:11.000000+0000","flow_id":5,"event_type":"flow",`+
+			`"src_ip":"10.0.0.1","dest_ip":"192.0.2.1","proto":"ICMP","icmp_type":8,"icmp_code":0,`+
+			`"flow":{"pkts_toserver":1,"pkts_toclient":0,"bytes_toserver":98,"bytes_toclient":0,`+
+			`"start":"2019-03-01T12:00:11.000000+0000","end":"2019-03-01T12:00:11.000000+0000"}}`,
+	)
+
+	require.Len(t, entries, 2)
+	conn := entries[0].(*pt.Conn)
+	assert.Equal(t, "sensor1", sensors[0])
+	assert.Equal(t, eveUID("1234567890123"), conn.UID)
+	assert.Equal(t, int64(1551441600), conn.TimeStamp)
+	assert.Equal(t, 2.5, conn.Duration)
+	assert.Equal(t, "tcp", conn.Proto)
+	assert.Equal(t, "ssl", conn.Service)
+	assert.Equal(t, "SF", conn.ConnState)
+	assert.Equal(t, 443, conn.DestinationPort)
+	assert.Equal(t, int64(1500), conn.OrigIPBytes)
+	assert.Equal(t, int64(9000), conn.RespIPBytes)
+	assert.Equal(t, int64(10), conn.OrigPkts)
+	assert.Equal(t, int64(8), conn.RespPkts)
+
+	icmp := entries[1].(*pt.Conn)
+	assert.Equal(t, "icmp", icmp.Proto)
+	assert.Equal(t, 8, icmp.SourcePort)
+	assert.Equal(t, 0, icmp.DestinationPort)
+	assert.Equal(t, "S0", icmp.ConnState)
+}
+
+func TestEveHTTP(t *testing.T) {
+	entries, sensors := convertEve(t, "override",
+		`{"timestamp":"2019-03-01T12:00:00.000000+0000","flow_id":7,"event_type":"http",`+
+			`"src_ip":"10.0.0.1","src_port":50000,"dest_ip":"192.0.2.1","dest_port":80,"proto":"TCP",`+
+			`"host":"sensor1","tx_id":0,"http":{"hostname":"example.com","url":"/index.html",`+
+			`"http_user_agent":"curl/7.58.0","http_content_type":"text/html","http_method":"GET",`+
+			`"protocol":"HTTP/1.1","status":200,"length":612}}`,
+	)
+
+	require.Len(t, entries, 1)
+	http := entries[0].(*pt.HTTP)
+	assert.Equal(t, "override", sensors[0])
+	assert.Equal(t, "example.com", http.Host)
+	assert.Equal(t, "/index.html", http.URI)
+	assert.Equal(t, "curl/7.58.0", http.UserAgent)
+	assert.Equal(t, "GET", http.Method)
+	assert.Equal(t, "1.1", http.Version)
+	assert.Equal(t, int64(200), http.StatusCode)
+	assert.Equal(t, int64(612), http.RespLen)
+	assert.Equal(t, int64(1), http.TransDepth)
+	assert.Equal(t, []string{"text/html"}, http.RespMimeTypes)
+}
+
+func TestEveTLS(t *testing.T) {
+	entries, _ := convertEve(t, "",
+		`{"timestamp":"2019-03-01T12:00:12.000000+0000","flow_id":6,"event_type":"tls",`+
+			`"src_ip":"10.0.0.1","src_port":50000,"dest_ip":"192.0.2.1","dest_port":443,"proto":"TCP",`+
+			`"tls":{"subject":"CN=example.com","issuerdn":"CN=Example CA","sni":"example.com",`+
+			`"version":"TLS 1.2"}}`,
+	)
+
+	require.Len(t, entries, 1)
+	ssl := entries[0].(*pt.SSL)
+	assert.Equal(t, eveUID("6"), ssl.UID)
+	assert.Equal(t, "example.com", ssl.ServerName)
+	assert.Equal(t, "TLSv12", ssl.Version)
+	assert.Equal(t, "CN=example.com", ssl.Subject)
+	assert.Equal(t, "CN=Example CA", ssl.Issuer)
+	assert.Equal(t, 443, ssl.DestinationPort)
+}
+
+func TestEveDNS(t *testing.T) {
+	entries, _ := convertEve(t, "",
+		//a version 2 query and answer
+		`{"timestamp":"2019-03-01T12:00:00.000000+0000","flow_id":8,"event_type":"dns",`+
+			`"src_ip":"10.0.0.1","src_port":40000,"dest_ip":"10.0.0.53","dest_port":53,"proto":"UDP",`+
+			`"dns":{"type":"query","id":4660,"rrname":"example.com","rrtype":"A","tx_id":0}}`,
+		`{"timestamp":"2019-03-01T12:00:00.250000+0000","flow_id":8,"event_type":"dns",`+
+			`"src_ip":"10.0.0.1","src_port":40000,"dest_ip":"10.0.0.53","dest_port":53,"proto":"UDP",`+
+			`"dns":{"version":2,"type":"answer","id":4660,"flags":"8180","rrname":"example.com",`+
+			`"rrtype":"A","rcode":"NOERROR","answers":[{"rrname":"example.com","rrtype":"A",`+
+			`"ttl":300,"rdata":"93.184.216.34"}]}}`,
+		//version 1 answers are logged in the direction they were sent
+		`{"timestamp":"2019-03-01T12:05:00.000000+0000","flow_id":9,"event_type":"dns",`+
+			`"src_ip":"10.0.0.53","src_port":53,"dest_ip":"10.0.0.2","dest_port":40001,"proto":"UDP",`+
+			`"dns":{"type":"answer","id":1,"rrname":"www.example.org","rrtype":"CNAME",`+
+			`"rcode":"NOERROR","ttl":60,"rdata":"example.org"}}`,
+		`{"timestamp":"2019-03-01T12:05:00.000000+0000","flow_id":9,"event_type":"dns",`+
+			`"src_ip":"10.0.0.53","src_port":53,"dest_ip":"10.0.0.2","dest_port":40001,"proto":"UDP",`+
+			`"dns":{"type":"answer","id":1,"rrname":"www.example.org","rrtype":"A",`+
+			`"rcode":"NOERROR","ttl":60,"rdata":"192.0.2.10"}}`,
+	)
+
+	require.Len(t, entries, 2)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].(*pt.DNS).TimeStamp < entries[j].(*pt.DNS).TimeStamp
+	})
+
+	first := entries[0].(*pt.DNS)
+	assert.Equal(t, "example.com", first.Query)
+	assert.Equal(t, int64(1), first.QType)
+	assert.Equal(t, "A", first.QTypeName)
+	assert.Equal(t, int64(4660), first.TransID)
+	assert.Equal(t, "NOERROR", first.RCodeName)
+	assert.Equal(t, 0.25, first.RTT)
+	assert.True(t, first.RD)
+	assert.True(t, first.RA)
+	assert.False(t, first.AA)
+	assert.Equal(t, []string{"93.184.216.34"}, first.Answers)
+	assert.Equal(t, []float64{300}, first.TTLs)
+	assert.Equal(t, "udp", first.Proto)
+
+	second := entries[1].(*pt.DNS)
+	assert.Equal(t, "www.example.org", second.Query)
+	assert.Equal(t, "10.0.0.2", second.Source)
+	assert.Equal(t, "10.0.0.53", second.Destination)
+	assert.Equal(t, 53, second.DestinationPort)
+	assert.Equal(t, []string{"example.org", "192.0.2.10"}, second.Answers)
+	assert.Equal(t, []float64{60, 60}, second.TTLs)
+}
+
+func TestEveMalformed(t *testing.T) {
+	converter := newEveConverter("", func(data pt.BroData, sensor string) {})
+	assert.False(t, converter.convert([]byte(`{"timestamp":`)))
+	assert.False(t, converter.convert([]byte(`{"timestamp":"yesterday","event_type":"flow"}`)))
+	assert.True(t, converter.convert([]byte("   \n")))
+}
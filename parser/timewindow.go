@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"time"
+
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+)
+
+//TimeWindow restricts an import to the records logged between Since and
+//Until. A zero Since or Until leaves that side of the window open.
+type TimeWindow struct {
+	Since time.Time
+	Until time.Time
+}
+
+//IsSet returns true if either side of the window is bounded
+func (w TimeWindow) IsSet() bool {
+	return !w.Since.IsZero() || !w.Until.IsZero()
+}
+
+//ContainsTimestamp returns true if the unix timestamp falls within the window
+func (w TimeWindow) ContainsTimestamp(ts int64) bool {
+	t := time.Unix(ts, 0)
+	if !w.Since.IsZero() && t.Before(w.Since) {
+		return false
+	}
+	if !w.Until.IsZero() && t.After(w.Until) {
+		return false
+	}
+	return true
+}
+
+//Record stores the window on a file imported within it so later imports
+//of the file can check which records were left out
+func (w TimeWindow) Record(indexedFile *fpt.IndexedFile) {
+	indexedFile.WindowSince = 0
+	indexedFile.WindowUntil = 0
+	if !w.Since.IsZero() {
+		indexedFile.WindowSince = w.Since.Unix()
+	}
+	if !w.Until.IsZero() {
+		indexedFile.WindowUntil = w.Until.Unix()
+	}
+}
+
+//recordedWindow returns the window a file was imported within
+func recordedWindow(indexedFile *fpt.IndexedFile) TimeWindow {
+	var w TimeWindow
+	if indexedFile.WindowSince != 0 {
+		w.Since = time.Unix(indexedFile.WindowSince, 0)
+	}
+	if indexedFile.WindowUntil != 0 {
+		w.Until = time.Unix(indexedFile.WindowUntil, 0)
+	}
+	return w
+}
+
+//Overlaps returns true if the range from start to end may contain records
+//within the window. A zero start or end is treated as unknown, in which
+//case the range is assumed to extend into the window on that side.
+func (w TimeWindow) Overlaps(start time.Time, end time.Time) bool {
+	if !w.Until.IsZero() && !start.IsZero() && start.After(w.Until) {
+		return false
+	}
+	if !w.Since.IsZero() && !end.IsZero() && end.Before(w.Since) {
+		return false
+	}
+	return true
+}
+
+//Covers returns true if every timestamp within the other window falls
+//within this window
+func (w TimeWindow) Covers(other TimeWindow) bool {
+	if !w.Since.IsZero() && (other.Since.IsZero() || other.Since.Before(w.Since)) {
+		return false
+	}
+	if !w.Until.IsZero() && (other.Until.IsZero() || other.Until.After(w.Until)) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fpt "github.com/activecm/rita/parser/fileparsetypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeWindowContainsTimestamp(t *testing.T) {
+	since := time.Unix(1000, 0)
+	until := time.Unix(2000, 0)
+
+	window := TimeWindow{Since: since, Until: until}
+	assert.True(t, window.IsSet())
+	assert.False(t, window.ContainsTimestamp(999))
+	assert.True(t, window.ContainsTimestamp(1000))
+	assert.True(t, window.ContainsTimestamp(2000))
+	assert.False(t, window.ContainsTimestamp(2001))
+
+	openEnded := TimeWindow{Since: since}
+	assert.True(t, openEnded.ContainsTimestamp(1<<40))
+	assert.False(t, openEnded.ContainsTimestamp(0))
+
+	var unset TimeWindow
+	assert.False(t, unset.IsSet())
+	assert.True(t, unset.ContainsTimestamp(0))
+}
+
+func TestTimeWindowOverlaps(t *testing.T) {
+	window := TimeWindow{Since: time.Unix(1000, 0), Until: time.Unix(2000, 0)}
+
+	assert.True(t, window.Overlaps(time.Unix(500, 0), time.Unix(1500, 0)))
+	assert.True(t, window.Overlaps(time.Unix(1500, 0), time.Unix(2500, 0)))
+	assert.True(t, window.Overlaps(time.Unix(500, 0), time.Unix(2500, 0)))
+	assert.False(t, window.Overlaps(time.Unix(0, 0), time.Unix(999, 0)))
+	assert.False(t, window.Overlaps(time.Unix(2001, 0), time.Unix(3000, 0)))
+
+	// unknown bounds cannot rule a file out
+	assert.True(t, window.Overlaps(time.Time{}, time.Unix(1500, 0)))
+	assert.True(t, window.Overlaps(time.Unix(500, 0), time.Time{}))
+	assert.False(t, window.Overlaps(time.Unix(2001, 0), time.Time{}))
+}
+
+func TestAlreadyImported(t *testing.T) {
+	clipped := func(since int64, until int64) *fpt.IndexedFile {
+		file := &fpt.IndexedFile{Hash: "abc", TargetDatabase: "db"}
+		var window TimeWindow
+		if since != 0 {
+			window.Since = time.Unix(since, 0)
+		}
+		if until != 0 {
+			window.Until = time.Unix(until, 0)
+		}
+		window.Record(file)
+		return file
+	}
+
+	earlier := func(oldFiles ...*fpt.IndexedFile) func(*fpt.IndexedFile) (bool, []fpt.ImportedRange) {
+		var files []fpt.IndexedFile
+		for _, oldFile := range oldFiles {
+			files = append(files, *oldFile)
+		}
+		return func(newFile *fpt.IndexedFile) (bool, []fpt.ImportedRange) {
+			return earlierImports(files, newFile)
+		}
+	}
+
+	// files imported without a window hold all of their records
+	covered, _ := earlier(clipped(0, 0))(clipped(0, 0))
+	assert.True(t, covered)
+	covered, _ = earlier(clipped(0, 0))(clipped(1000, 2000))
+	assert.True(t, covered)
+	covered, _ = earlier(clipped(1000, 2000))(clipped(1500, 2000))
+	assert.True(t, covered)
+
+	// the rest of a clipped file may be imported later
+	covered, ranges := earlier(clipped(1000, 2000))(clipped(2001, 0))
+	assert.False(t, covered)
+	assert.Empty(t, ranges)
+	covered, ranges = earlier(clipped(0, 999))(clipped(1000, 2000))
+	assert.False(t, covered)
+	assert.Empty(t, ranges)
+
+	// only the records outside of the earlier window are imported
+	covered, ranges = earlier(clipped(1000, 2000))(clipped(1500, 0))
+	assert.False(t, covered)
+	assert.Equal(t, []fpt.ImportedRange{{Since: 1000, Until: 2000}}, ranges)
+	covered, ranges = earlier(clipped(1000, 0))(clipped(0, 0))
+	assert.False(t, covered)
+	assert.Equal(t, []fpt.ImportedRange{{Since: 1000}}, ranges)
+
+	file := clipped(0, 0)
+	file.SetImportedRanges(ranges)
+	assert.False(t, file.WasImported(999))
+	assert.True(t, file.WasImported(1000))
+	assert.True(t, file.WasImported(1<<40))
+
+	other := clipped(0, 0)
+	other.TargetDatabase = "other"
+	covered, ranges = earlier(clipped(0, 0))(other)
+	assert.False(t, covered)
+	assert.Empty(t, ranges)
+}
+
+func TestScanCloseTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-timewindow")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	closed := filepath.Join(dir, "conn.log")
+	err = ioutil.WriteFile(closed, []byte(
+		"#separator \\x09\n#open\t2018-01-01-00-00-00\n1514764800.0\tC1\n#close\t2018-01-01-01-00-00\n",
+	), 0644)
+	require.Nil(t, err)
+
+	closeTime, err := scanCloseTime(closed)
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2018, 1, 1, 1, 0, 0, 0, time.Local), closeTime)
+
+	// files which are still being written have no #close line
+	open := filepath.Join(dir, "dns.log")
+	err = ioutil.WriteFile(open, []byte("#open\t2018-01-01-00-00-00\n"), 0644)
+	require.Nil(t, err)
+
+	_, err = scanCloseTime(open)
+	assert.NotNil(t, err)
+}
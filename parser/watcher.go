@@ -0,0 +1,281 @@
+package parser
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/config"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	//FSWatcher polls the import directory and imports bro files once they
+	//have finished being written. Datasets which stop receiving files are
+	//closed, at which point they are finished and may be handed off for
+	//analysis. A closed dataset which receives new files is reopened.
+	FSWatcher struct {
+		importer        *FSImporter
+		newDatastore    func() Datastore
+		broConfig       *config.BroStaticCfg
+		logger          *log.Logger
+		pollInterval    time.Duration
+		settleTime      time.Duration
+		closeTime       time.Duration
+		onDatasetClosed func(database string)
+		pending         map[string]watchedFile
+		imported        map[string]watchedFile
+		openDatasets    map[string]time.Time
+		closedDatasets  map[string]struct{}
+		datasets        map[string]*DatasetImport
+		// fileComplete checks if bro has finished writing a file
+		fileComplete func(file string) bool
+	}
+
+	//watchedFile records the state of a file when it was last polled
+	watchedFile struct {
+		size        int64
+		modTime     time.Time
+		stableSince time.Time
+		// incomplete is set once the file has settled without bro
+		// finishing it, so it is not checked again until it changes
+		incomplete bool
+	}
+)
+
+//NewFSWatcher creates a watcher which imports the files in the import
+//directory using the given importer. A fresh datastore is created for
+//each batch of files as datastores may not be reused after being flushed.
+//Files are imported once they have been closed by bro and their size and
+//modification time have not changed for settleTime. Datasets which have
+//not received new files for closeTime are closed.
+func NewFSWatcher(importer *FSImporter, newDatastore func() Datastore,
+	pollInterval time.Duration, settleTime time.Duration,
+	closeTime time.Duration) *FSWatcher {
+	return &FSWatcher{
+		importer:       importer,
+		newDatastore:   newDatastore,
+		broConfig:      &importer.res.Config.S.Bro,
+		logger:         importer.res.Log,
+		pollInterval:   pollInterval,
+		settleTime:     settleTime,
+		closeTime:      closeTime,
+		pending:        make(map[string]watchedFile),
+		imported:       make(map[string]watchedFile),
+		openDatasets:   make(map[string]time.Time),
+		closedDatasets: make(map[string]struct{}),
+		datasets:       make(map[string]*DatasetImport),
+		fileComplete:   fileComplete,
+	}
+}
+
+//SetDatasetClosedHandler registers a function to be called once a dataset
+//has been closed and finished. Files which arrive for a closed dataset
+//reopen it, and the handler is called again once it closes.
+func (w *FSWatcher) SetDatasetClosedHandler(handler func(database string)) {
+	w.onDatasetClosed = handler
+}
+
+//Run polls the import directory until the stop channel is closed. The
+//datasets which are still open are finished before returning so their
+//data is indexed.
+func (w *FSWatcher) Run(stop <-chan struct{}) {
+	fmt.Println("[+] Watching " + w.broConfig.ImportDirectory)
+	for {
+		w.poll(time.Now())
+		select {
+		case <-stop:
+			for _, dataset := range w.datasets {
+				dataset.Finish()
+			}
+			return
+		case <-time.After(w.pollInterval):
+		}
+	}
+}
+
+//poll imports any files which have finished being written and closes
+//any datasets which have gone quiet
+func (w *FSWatcher) poll(now time.Time) {
+	files := readDir(w.broConfig.ImportDirectory, w.logger)
+
+	seen := make(map[string]struct{}, len(files))
+	ready := make(map[string][]string)
+	for _, file := range files {
+		fInfo, err := os.Stat(file)
+		if err != nil {
+			//the file may have been moved since the directory was read
+			continue
+		}
+		seen[file] = struct{}{}
+		if !w.updateFile(file, fInfo.Size(), fInfo.ModTime(), now) {
+			continue
+		}
+
+		targetDatabase := getTargetDatabase(file, w.broConfig)
+		if w.openDataset(targetDatabase, now) {
+			fmt.Println("[+] Dataset " + targetDatabase + " has reopened")
+			w.logger.WithFields(log.Fields{
+				"file":     file,
+				"database": targetDatabase,
+			}).Info("Reopened closed dataset for new file")
+		}
+		ready[targetDatabase] = append(ready[targetDatabase], file)
+	}
+
+	w.forgetMissingFiles(seen)
+
+	databases := make([]string, 0, len(ready))
+	for database := range ready {
+		databases = append(databases, database)
+	}
+	sort.Strings(databases)
+	for _, database := range databases {
+		dataset, ok := w.datasets[database]
+		if !ok {
+			dataset = w.importer.NewDatasetImport(database)
+			w.datasets[database] = dataset
+		}
+		fmt.Printf("[+] Importing %d new files into %s\n", len(ready[database]), database)
+		dataset.ImportFiles(ready[database], "", w.newDatastore())
+	}
+
+	for _, database := range w.closeDatasets(now) {
+		fmt.Println("[+] Dataset " + database + " has closed")
+		w.logger.WithFields(log.Fields{
+			"database": database,
+		}).Info("Dataset closed")
+		if dataset, ok := w.datasets[database]; ok {
+			dataset.Finish()
+			delete(w.datasets, database)
+		}
+		if w.onDatasetClosed != nil {
+			w.onDatasetClosed(database)
+		}
+	}
+}
+
+//updateFile records the current state of a file and returns true if the
+//file has just become ready for import. A file is ready once its size and
+//modification time have been stable for the settle time and bro has
+//finished writing it. Each file is only reported as ready once unless it
+//is replaced.
+func (w *FSWatcher) updateFile(file string, size int64, modTime time.Time, now time.Time) bool {
+	if state, ok := w.imported[file]; ok {
+		if state.size == size && state.modTime.Equal(modTime) {
+			return false
+		}
+		delete(w.imported, file)
+	}
+
+	state, ok := w.pending[file]
+	if !ok || state.size != size || !state.modTime.Equal(modTime) {
+		w.pending[file] = watchedFile{
+			size:        size,
+			modTime:     modTime,
+			stableSince: now,
+		}
+		return false
+	}
+
+	if now.Sub(state.stableSince) < w.settleTime || state.incomplete {
+		return false
+	}
+
+	if !w.fileComplete(file) {
+		w.logger.WithFields(log.Fields{
+			"file": file,
+		}).Warning("Waiting for bro to finish writing file")
+		state.incomplete = true
+		w.pending[file] = state
+		return false
+	}
+
+	delete(w.pending, file)
+	w.imported[file] = state
+	return true
+}
+
+//forgetMissingFiles drops the state of files which were not seen in the
+//latest poll so deleted files do not hold datasets open
+func (w *FSWatcher) forgetMissingFiles(seen map[string]struct{}) {
+	for file := range w.pending {
+		if _, ok := seen[file]; !ok {
+			delete(w.pending, file)
+		}
+	}
+	for file := range w.imported {
+		if _, ok := seen[file]; !ok {
+			delete(w.imported, file)
+		}
+	}
+}
+
+//openDataset marks the dataset as having received a file and returns true
+//if the dataset had been closed
+func (w *FSWatcher) openDataset(database string, now time.Time) bool {
+	w.openDatasets[database] = now
+	if _, closed := w.closedDatasets[database]; !closed {
+		return false
+	}
+	delete(w.closedDatasets, database)
+	return true
+}
+
+//closeDatasets returns the datasets which have not received new files
+//for the close time and marks them as closed
+func (w *FSWatcher) closeDatasets(now time.Time) []string {
+	var closed []string
+	for database, lastImport := range w.openDatasets {
+		if now.Sub(lastImport) < w.closeTime {
+			continue
+		}
+		//wait on any files for this dataset which are still being written
+		if w.hasPendingFiles(database) {
+			continue
+		}
+		delete(w.openDatasets, database)
+		w.closedDatasets[database] = struct{}{}
+		closed = append(closed, database)
+	}
+	return closed
+}
+
+//hasPendingFiles returns true if a file headed for the database has been
+//seen but has not finished being written
+func (w *FSWatcher) hasPendingFiles(database string) bool {
+	for file := range w.pending {
+		if getTargetDatabase(file, w.broConfig) == database {
+			return true
+		}
+	}
+	return false
+}
+
+//fileComplete returns true if bro has finished writing the file. Logs are
+//complete once they end with a #close line. Compressed logs are complete
+//once they can be read to the end of the gzip stream.
+func fileComplete(file string) bool {
+	if !strings.HasSuffix(file, ".gz") {
+		_, err := scanCloseTime(file)
+		return err == nil
+	}
+
+	fileHandle, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer fileHandle.Close()
+
+	reader, err := gzip.NewReader(fileHandle)
+	if err != nil {
+		return false
+	}
+	_, err = io.Copy(ioutil.Discard, reader)
+	return err == nil
+}
@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWatcher(settleTime time.Duration, closeTime time.Duration) *FSWatcher {
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	return &FSWatcher{
+		logger: logger,
+		broConfig: &config.BroStaticCfg{
+			ImportDirectory: "/opt/bro/logs",
+			DBRoot:          "RITA",
+		},
+		settleTime:      settleTime,
+		closeTime:       closeTime,
+		onDatasetClosed: func(string) {},
+		pending:         make(map[string]watchedFile),
+		imported:        make(map[string]watchedFile),
+		openDatasets:    make(map[string]time.Time),
+		closedDatasets:  make(map[string]struct{}),
+		datasets:        make(map[string]*DatasetImport),
+		fileComplete:    func(string) bool { return true },
+	}
+}
+
+func TestWatcherWaitsForFilesToSettle(t *testing.T) {
+	watcher := newTestWatcher(time.Minute, time.Hour)
+	file := "/opt/bro/logs/2018-01-01/conn.00:00:00-01:00:00.log.gz"
+	start := time.Unix(1514764800, 0)
+
+	// a new file is never ready
+	assert.False(t, watcher.updateFile(file, 100, start, start))
+
+	// a file which is still growing is not ready
+	assert.False(t, watcher.updateFile(file, 200, start.Add(time.Minute), start.Add(time.Minute)))
+
+	// a file which has not settled for long enough is not ready
+	assert.False(t, watcher.updateFile(file, 200, start.Add(time.Minute), start.Add(90*time.Second)))
+
+	// a file which has settled is ready exactly once
+	assert.True(t, watcher.updateFile(file, 200, start.Add(time.Minute), start.Add(2*time.Minute)))
+	assert.False(t, watcher.updateFile(file, 200, start.Add(time.Minute), start.Add(3*time.Minute)))
+
+	// a replaced file is picked up again once it settles
+	assert.False(t, watcher.updateFile(file, 50, start.Add(4*time.Minute), start.Add(4*time.Minute)))
+	assert.True(t, watcher.updateFile(file, 50, start.Add(4*time.Minute), start.Add(5*time.Minute)))
+}
+
+func TestWatcherWaitsForFilesToBeClosed(t *testing.T) {
+	watcher := newTestWatcher(time.Minute, time.Hour)
+	file := "/opt/bro/logs/2018-01-01/conn.log"
+	start := time.Unix(1514764800, 0)
+
+	checks := 0
+	complete := false
+	watcher.fileComplete = func(string) bool {
+		checks++
+		return complete
+	}
+
+	assert.False(t, watcher.updateFile(file, 100, start, start))
+	// a settled file which bro has not closed is not ready
+	assert.False(t, watcher.updateFile(file, 100, start, start.Add(2*time.Minute)))
+	// and is not checked again until it changes
+	complete = true
+	assert.False(t, watcher.updateFile(file, 100, start, start.Add(3*time.Minute)))
+	assert.Equal(t, 1, checks)
+
+	// once bro writes the footer the file settles again
+	assert.False(t, watcher.updateFile(file, 120, start.Add(4*time.Minute), start.Add(4*time.Minute)))
+	assert.True(t, watcher.updateFile(file, 120, start.Add(4*time.Minute), start.Add(5*time.Minute)))
+	assert.Equal(t, 2, checks)
+}
+
+func TestFileComplete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rita-watcher")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	contents := "#separator \\x09\n#open\t2018-01-01-00-00-00\n1514764800.0\tC1\n"
+	closed := contents + "#close\t2018-01-01-01-00-00\n"
+
+	openLog := filepath.Join(dir, "open.log")
+	require.Nil(t, ioutil.WriteFile(openLog, []byte(contents), 0644))
+	assert.False(t, fileComplete(openLog))
+
+	closedLog := filepath.Join(dir, "closed.log")
+	require.Nil(t, ioutil.WriteFile(closedLog, []byte(closed), 0644))
+	assert.True(t, fileComplete(closedLog))
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(closed))
+	writer.Close()
+
+	gzipped := filepath.Join(dir, "closed.log.gz")
+	require.Nil(t, ioutil.WriteFile(gzipped, compressed.Bytes(), 0644))
+	assert.True(t, fileComplete(gzipped))
+
+	// a compressed file which is still being written is cut off
+	truncated := filepath.Join(dir, "truncated.log.gz")
+	require.Nil(t, ioutil.WriteFile(truncated, compressed.Bytes()[:compressed.Len()-8], 0644))
+	assert.False(t, fileComplete(truncated))
+}
+
+func TestWatcherClosesQuietDatasets(t *testing.T) {
+	watcher := newTestWatcher(time.Minute, time.Hour)
+	start := time.Unix(1514764800, 0)
+
+	watcher.openDatasets["RITA-2018-01-01"] = start
+	watcher.openDatasets["RITA-2018-01-02"] = start.Add(30 * time.Minute)
+
+	// a dataset with a file still being written stays open
+	watcher.updateFile("/opt/bro/logs/2018-01-02/dns.log", 10, start, start)
+
+	assert.Empty(t, watcher.closeDatasets(start.Add(30*time.Minute)))
+	assert.Equal(t, []string{"RITA-2018-01-01"}, watcher.closeDatasets(start.Add(time.Hour)))
+	assert.Empty(t, watcher.closeDatasets(start.Add(2*time.Hour)))
+
+	watcher.forgetMissingFiles(map[string]struct{}{})
+	assert.Equal(t, []string{"RITA-2018-01-02"}, watcher.closeDatasets(start.Add(2*time.Hour)))
+	assert.Contains(t, watcher.closedDatasets, "RITA-2018-01-01")
+}
+
+func TestWatcherClosesDatasetsWithoutAHandler(t *testing.T) {
+	watcher := newTestWatcher(time.Minute, time.Hour)
+	watcher.onDatasetClosed = nil
+	start := time.Unix(1514764800, 0)
+
+	// datasets are closed so they are finished even if they aren't analyzed
+	watcher.openDatasets["RITA-2018-01-01"] = start
+	assert.Equal(t, []string{"RITA-2018-01-01"}, watcher.closeDatasets(start.Add(time.Hour)))
+}
+
+func TestWatcherReopensClosedDatasets(t *testing.T) {
+	watcher := newTestWatcher(time.Minute, time.Hour)
+	start := time.Unix(1514764800, 0)
+
+	assert.False(t, watcher.openDataset("RITA-COLLECTOR", start))
+	assert.Equal(t, []string{"RITA-COLLECTOR"}, watcher.closeDatasets(start.Add(time.Hour)))
+
+	// a file arriving the next day reopens the dataset
+	assert.True(t, watcher.openDataset("RITA-COLLECTOR", start.Add(24*time.Hour)))
+	assert.NotContains(t, watcher.closedDatasets, "RITA-COLLECTOR")
+	assert.False(t, watcher.openDataset("RITA-COLLECTOR", start.Add(25*time.Hour)))
+
+	// and it closes again once it goes quiet
+	assert.Empty(t, watcher.closeDatasets(start.Add(25*time.Hour)))
+	assert.Equal(t, []string{"RITA-COLLECTOR"}, watcher.closeDatasets(start.Add(26*time.Hour)))
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"html/template"
 	"os"
+	"time"
 
 	"github.com/activecm/rita/analysis/beacon"
 	beaconData "github.com/activecm/rita/datatypes/beacon"
@@ -26,7 +27,8 @@ func printBeacons(db string, res *resources.Resources) error {
 	res.DB.SelectDB(db)
 	var data []beaconData.AnalysisView
 	ssn := res.DB.Session.Copy()
-	beacon.GetBeaconResultsView(res, ssn, 0).All(&data)
+	beacon.GetBeaconResultsView(res, ssn, 0, "").All(&data)
+	data = beacon.HideSuppressed(data, time.Now())
 	ssn.Close()
 
 	w, err := getBeaconWriter(data)
@@ -38,10 +40,18 @@ func printBeacons(db string, res *resources.Resources) error {
 }
 
 func getBeaconWriter(beacons []beaconData.AnalysisView) (string, error) {
-	tmpl := "<tr><td>{{printf \"%.3f\" .Score}}</td><td>{{.Src}}</td><td>{{.Dst}}</td><td>{{.Connections}}</td><td>{{printf \"%.3f\" .AvgBytes}}</td><td>"
+	tmpl := "<tr><td>{{printf \"%.3f\" .Score}}</td><td>{{.Src}}</td><td>{{.Dst}}</td>"
+	tmpl += "<td>{{range $idx, $lease := .SrcDevices}}{{if $idx}}, {{end}}{{ $lease.Device }}{{end}}</td>"
+	tmpl += "<td>{{range $idx, $lease := .DstDevices}}{{if $idx}}, {{end}}{{ $lease.Device }}{{end}}</td>"
+	tmpl += "<td>{{.Connections}}</td><td>{{printf \"%.3f\" .AvgBytes}}</td><td>"
 	tmpl += "{{.TSIRange}}</td><td>{{.DSRange}}</td><td>{{.TSIMode}}</td><td>{{.DSMode}}</td><td>{{.TSIModeCount}}</td><td>{{.DSModeCount}}<td>"
 	tmpl += "{{printf \"%.3f\" .TSISkew}}</td><td>{{printf \"%.3f\" .DSSkew}}</td><td>{{.TSIDispersion}}</td><td>{{.DSDispersion}}</td><td>"
-	tmpl += "{{printf \"%.3f\" .TSDuration}}</tr>\n"
+	tmpl += "{{printf \"%.3f\" .TSDuration}}</td><td>{{printf \"%.3f\" .TSSkewScore}}</td><td>{{printf \"%.3f\" .TSDispersionScore}}</td><td>"
+	tmpl += "{{printf \"%.3f\" .TSDurationScore}}</td><td>{{printf \"%.3f\" .TSCoverage}}</td><td>{{printf \"%.3f\" .TSConsistency}}</td><td>"
+	tmpl += "{{printf \"%.3f\" .TSPeriodStr}}</td><td>{{printf \"%.3f\" .TSClusterScore}}</td><td>"
+	tmpl += "{{printf \"%.3f\" .DSSkewScore}}</td><td>{{printf \"%.3f\" .DSDispersionScore}}</td><td>"
+	tmpl += "{{printf \"%.3f\" .DSSmallnessScore}}</td><td>{{printf \"%.3f\" .RSSkewScore}}</td><td>{{printf \"%.3f\" .RSDispersionScore}}</td><td>"
+	tmpl += "{{printf \"%.3f\" .RSSmallnessScore}}</td></tr>\n"
 
 	out, err := template.New("beacon").Parse(tmpl)
 	if err != nil {
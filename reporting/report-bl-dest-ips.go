@@ -7,7 +7,6 @@ import (
 	"github.com/globalsign/mgo/bson"
 
 	"github.com/activecm/rita/datatypes/blacklist"
-	"github.com/activecm/rita/datatypes/structure"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
@@ -25,14 +24,10 @@ func printBLDestIPs(db string, res *resources.Resources) error {
 		Find(nil).Sort("-conn").All(&blIPs)
 
 	for i, ip := range blIPs {
-		var connected []structure.UniqueConnection
 		res.DB.Session.DB(db).
 			C(res.Config.T.Structure.UniqueConnTable).Find(
 			bson.M{"dst": ip.IP},
-		).All(&connected)
-		for _, uconn := range connected {
-			blIPs[i].ConnectedHosts = append(blIPs[i].ConnectedHosts, uconn.Src)
-		}
+		).Distinct("src", &blIPs[i].ConnectedHosts)
 	}
 
 	out, err := template.New("bl-dest-ips.html").Parse(templates.BLDestIPTempl)
@@ -10,9 +10,9 @@ import (
 
 	"github.com/activecm/rita/analysis/dns"
 	"github.com/activecm/rita/datatypes/blacklist"
-	"github.com/activecm/rita/datatypes/structure"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
 )
 
 func printBLHostnames(db string, res *resources.Resources) error {
@@ -34,14 +34,16 @@ func printBLHostnames(db string, res *resources.Resources) error {
 		//and loop over the ips
 		for _, ip := range ips {
 			//then find all of the hosts which talked to the ip
-			var connected []structure.UniqueConnection
+			var connected []string
 			res.DB.Session.DB(db).
 				C(res.Config.T.Structure.UniqueConnTable).Find(
 				bson.M{"dst": ip},
-			).All(&connected)
+			).Distinct("src", &connected)
 			//and aggregate the source ip addresses
-			for _, uconn := range connected {
-				blHosts[i].ConnectedHosts = append(blHosts[i].ConnectedHosts, uconn.Src)
+			for _, src := range connected {
+				if !util.StringInSlice(src, blHosts[i].ConnectedHosts) {
+					blHosts[i].ConnectedHosts = append(blHosts[i].ConnectedHosts, src)
+				}
 			}
 		}
 	}
@@ -63,6 +65,7 @@ func getBLHostnameWriter(results []blacklist.BlacklistedHostname) (string, error
 	tmpl := "<tr><td>{{.Hostname}}</td><td>{{.Connections}}</td><td>{{.UniqueConnections}}</td>" +
 		"<td>{{.TotalBytes}}</td>" +
 		"<td>{{range $idx, $list := .Lists}}{{if $idx}}, {{end}}{{ $list }}{{end}}</td>" +
+		"<td>{{range $idx, $lease := .TargetDevices}}{{if $idx}}, {{end}}{{ $lease.Device }}{{end}}</td>" +
 		"<td>{{range $idx, $host := .ConnectedHosts}}{{if $idx}}, {{end}}{{ $host }}{{end}}</td>" +
 		"</tr>\n"
 
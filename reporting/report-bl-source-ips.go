@@ -9,7 +9,6 @@ import (
 	"github.com/globalsign/mgo/bson"
 
 	"github.com/activecm/rita/datatypes/blacklist"
-	"github.com/activecm/rita/datatypes/structure"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
@@ -27,14 +26,10 @@ func printBLSourceIPs(db string, res *resources.Resources) error {
 		Find(nil).Sort("-conn").All(&blIPs)
 
 	for i, ip := range blIPs {
-		var connected []structure.UniqueConnection
 		res.DB.Session.DB(db).
 			C(res.Config.T.Structure.UniqueConnTable).Find(
 			bson.M{"src": ip.IP},
-		).All(&connected)
-		for _, uconn := range connected {
-			blIPs[i].ConnectedHosts = append(blIPs[i].ConnectedHosts, uconn.Dst)
-		}
+		).Distinct("dst", &blIPs[i].ConnectedHosts)
 	}
 
 	out, err := template.New("bl-source-ips.html").Parse(templates.BLSourceIPTempl)
@@ -54,6 +49,7 @@ func getBLIPWriter(results []blacklist.BlacklistedIP) (string, error) {
 	tmpl := "<tr><td>{{.IP}}</td><td>{{.Connections}}</td><td>{{.UniqueConnections}}</td>" +
 		"<td>{{.TotalBytes}}</td>" +
 		"<td>{{range $idx, $list := .Lists}}{{if $idx}}, {{end}}{{ $list }}{{end}}</td>" +
+		"<td>{{range $idx, $lease := .TargetDevices}}{{if $idx}}, {{end}}{{ $lease.Device }}{{end}}</td>" +
 		"<td>{{range $idx, $host := .ConnectedHosts}}{{if $idx}}, {{end}}{{ $host }}{{end}}</td>" +
 		"</tr>\n"
 
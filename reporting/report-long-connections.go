@@ -5,7 +5,7 @@ import (
 	"html/template"
 	"os"
 
-	"github.com/activecm/rita/datatypes/data"
+	"github.com/activecm/rita/datatypes/structure"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
@@ -21,8 +21,8 @@ func printLongConns(db string, res *resources.Resources) error {
 		return err
 	}
 
-	var conns []data.Conn
-	coll := res.DB.Session.DB(db).C(res.Config.T.Structure.ConnTable)
+	var conns []structure.LongConnection
+	coll := res.DB.Session.DB(db).C(res.Config.T.Structure.LongConnTable)
 	coll.Find(nil).Sort("-duration").Limit(1000).All(&conns)
 
 	w, err := getLongConnWriter(conns)
@@ -32,8 +32,8 @@ func printLongConns(db string, res *resources.Resources) error {
 	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w)})
 }
 
-func getLongConnWriter(conns []data.Conn) (string, error) {
-	tmpl := "<tr><td>{{.Src}}</td><td>{{.Spt}}</td><td>{{.Dst}}</td><td>{{.Dpt}}</td><td>{{.Dur}}</td><td>{{.Proto}}</td></tr>\n"
+func getLongConnWriter(conns []structure.LongConnection) (string, error) {
+	tmpl := "<tr><td>{{.Src}}</td><td>{{.SrcPort}}</td><td>{{.Dst}}</td><td>{{.DstPort}}</td><td>{{.Duration}}</td><td>{{.Proto}}</td><td>{{.TotalBytes}}</td><td>{{.TotalPackets}}</td><td>{{.RecordCount}}</td></tr>\n"
 	out, err := template.New("Conn").Parse(tmpl)
 	if err != nil {
 		return "", err
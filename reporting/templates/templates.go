@@ -91,10 +91,12 @@ var DBhometempl = dbHeader + `
 var BeaconsTempl = dbHeader + `
 <div class="container">
   <table>
-  <tr><th>Score</th><th>Source</th><th>Destination</th><th>Connections</th><th>Avg. Bytes</th><th>
+  <tr><th>Score</th><th>Source</th><th>Destination</th><th>Source Devices</th><th>Destination Devices</th><th>Connections</th><th>Avg. Bytes</th><th>
 	Intvl. Range</th><th>Size Range</th><th>Intvl. Mode</th><th>Size Mode</th><th>Intvl. Mode Count</th>
 	<th>Size Mode Count</th><th>Intvl. Skew</th><th>Size Skew</th><th>Intvl. Dispersion</th><th>Size Dispersion
-	</th><th>TS Duration</tr>
+	</th><th>TS Duration</th><th>Intvl. Skew Score</th><th>Intvl. Dispersion Score</th><th>TS Duration Score</th><th>TS Coverage</th><th>TS Consistency</th><th>Period Score</th><th>Interval Clusters Score</th>
+	<th>Size Skew Score</th><th>Size Dispersion Score</th><th>Size Smallness Score</th><th>Resp. Size Skew Score</th>
+	<th>Resp. Size Dispersion Score</th><th>Resp. Size Smallness Score</th></tr>
       {{.Writer}}
   </table>
 </div>
@@ -114,7 +116,7 @@ var StrobesTempl = dbHeader + `
 var BLSourceIPTempl = dbHeader + `
 <div class="container">
   <table>
-  <tr><th>IP</th><th>Connections</th><th>Unique Connections</th><th>Total Bytes</th><th>Lists</th><th>Destinations</th><tr>
+  <tr><th>IP</th><th>Connections</th><th>Unique Connections</th><th>Total Bytes</th><th>Lists</th><th>Devices</th><th>Destinations</th><tr>
     {{.Writer}}
   </table>
 </div>
@@ -124,7 +126,7 @@ var BLSourceIPTempl = dbHeader + `
 var BLDestIPTempl = dbHeader + `
 <div class="container">
   <table>
-  <tr><th>IP</th><th>Connections</th><th>Unique Connections</th><th>Total Bytes</th><th>Lists</th><th>Sources</th><tr>
+  <tr><th>IP</th><th>Connections</th><th>Unique Connections</th><th>Total Bytes</th><th>Lists</th><th>Devices</th><th>Sources</th><tr>
     {{.Writer}}
   </table>
 </div>
@@ -134,7 +136,7 @@ var BLDestIPTempl = dbHeader + `
 var BLHostnameTempl = dbHeader + `
 <div class="container">
   <table>
-  <tr><th>Hostname</th><th>Connections</th><th>Unique Connections</th><th>Total Bytes</th><th>Lists</th><th>Sources</th><tr>
+  <tr><th>Hostname</th><th>Connections</th><th>Unique Connections</th><th>Total Bytes</th><th>Lists</th><th>Devices</th><th>Sources</th><tr>
     {{.Writer}}
   </table>
 </div>
@@ -144,7 +146,7 @@ var BLHostnameTempl = dbHeader + `
 var LongConnsTempl = dbHeader + `
 <div class="container">
   <table>
-	<tr><th>Source</th><th>Source Port</th><th>Destination</th><th>Destination Port</th><th>Duration</th><th>Protocol</th></tr>
+	<tr><th>Source</th><th>Source Port</th><th>Destination</th><th>Destination Port</th><th>Duration</th><th>Protocol</th><th>Total Bytes</th><th>Total Packets</th><th>Records</th></tr>
 	  {{.Writer}}
 	</table>
 </div>
@@ -1,9 +1,11 @@
 package util
 
 import (
+	"fmt"
 	"math"
 	"net"
 	"os"
+	"strings"
 )
 
 //TimeFormat stores a correctly formatted timestamp
@@ -85,3 +87,41 @@ func StringInSlice(value string, list []string) bool {
 	}
 	return false
 }
+
+//ParseSubnets parses a list of subnets in CIDR notation. Entries which are
+//single addresses are treated as single host subnets. The valid entries
+//are returned along with an error naming the first invalid entry.
+func ParseSubnets(subnets []string) ([]*net.IPNet, error) {
+	var parsed []*net.IPNet
+	var err error
+	for _, entry := range subnets {
+		cidr := entry
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, block, parseErr := net.ParseCIDR(cidr)
+		if parseErr != nil {
+			if err == nil {
+				err = fmt.Errorf("invalid subnet %q", entry)
+			}
+			continue
+		}
+		parsed = append(parsed, block)
+	}
+	return parsed, err
+}
+
+//ContainsIP returns true if the address is in one of the subnets. A nil
+//address, such as the result of parsing a hostname, is never contained.
+func ContainsIP(subnets []*net.IPNet, ip net.IP) bool {
+	for _, block := range subnets {
+		if block.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
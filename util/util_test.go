@@ -2,6 +2,7 @@ package util
 
 import (
 	"math"
+	"net"
 	"os"
 	"path"
 	"sort"
@@ -18,6 +19,24 @@ func TestIsIP(t *testing.T) {
 	assert.False(t, IsIP(notIP))
 }
 
+func TestParseSubnets(t *testing.T) {
+	subnets, err := ParseSubnets([]string{"10.0.0.0/8", "192.168.1.5", "fd00::/8", "fe80::1", "bogus"})
+	assert.NotNil(t, err)
+	assert.Len(t, subnets, 4)
+
+	assert.True(t, ContainsIP(subnets, net.ParseIP("10.1.2.3")))
+	assert.True(t, ContainsIP(subnets, net.ParseIP("192.168.1.5")))
+	assert.True(t, ContainsIP(subnets, net.ParseIP("fd00::1")))
+	assert.True(t, ContainsIP(subnets, net.ParseIP("fe80::1")))
+	assert.False(t, ContainsIP(subnets, net.ParseIP("fe80::2")))
+	assert.False(t, ContainsIP(subnets, net.ParseIP("192.168.1.6")))
+	assert.False(t, ContainsIP(subnets, net.ParseIP("203.0.113.1")))
+	assert.False(t, ContainsIP(subnets, net.ParseIP("example.com")))
+
+	_, err = ParseSubnets([]string{"10.0.0.0/8", "192.168.1.5"})
+	assert.Nil(t, err)
+}
+
 func TestFileExists(t *testing.T) {
 	filePath := "./.jeinwei8380243unt4u"
 	os.Remove(filePath)